@@ -0,0 +1,70 @@
+package google
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// errSource identifies Google in categorised errors
+const errSource = "google"
+
+// WrapError attaches an error category to errors returned by the Google APIs and the
+// Google token endpoint
+func WrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return errs.Wrap(googleAPICategory(apiErr), errSource, err)
+	}
+
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return errs.Wrap(tokenErrorCategory(retrieveErr.ErrorCode, retrieveErr.Response), errSource, err)
+	}
+
+	return errs.Wrap(errs.CategoryOf(err), errSource, err)
+}
+
+// googleAPICategory looks at the error reasons first since Google reports rate limits,
+// quota exhaustion and files the user may not download as 403 as well
+func googleAPICategory(err *googleapi.Error) errs.Category {
+	for _, item := range err.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "concurrentLimitExceeded":
+			return errs.RateLimited
+		case "quotaExceeded", "dailyLimitExceeded", "storageQuotaExceeded":
+			return errs.Quota
+		case "notFound":
+			return errs.NotFound
+		case "backendError", "internalError":
+			return errs.Network
+		case "authError", "insufficientPermissions", "domainPolicy":
+			// The token lacks a scope or the account's admin blocked the app
+			return errs.AuthRevoked
+		case "cannotDownloadFile", "insufficientFilePermissions", "appNotAuthorizedToFile",
+			"cannotCopyFile", "fileNotDownloadable", "exportSizeLimitExceeded":
+			return errs.ItemPermission
+		}
+	}
+	return errs.FromStatus(err.Code)
+}
+
+// tokenErrorCategory classifies a failed refresh token exchange. invalid_grant means the
+// user revoked access or the refresh token expired.
+func tokenErrorCategory(code string, resp *http.Response) errs.Category {
+	switch code {
+	case "invalid_grant", "unauthorized_client", "invalid_client":
+		return errs.AuthRevoked
+	}
+	if resp != nil {
+		return errs.FromStatus(resp.StatusCode)
+	}
+	return errs.Unknown
+}
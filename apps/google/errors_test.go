@@ -0,0 +1,35 @@
+package google
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+func TestWrapErrorForbidden(t *testing.T) {
+	for reason, want := range map[string]errs.Category{
+		"insufficientPermissions":     errs.AuthRevoked,
+		"domainPolicy":                errs.AuthRevoked,
+		"cannotDownloadFile":          errs.ItemPermission,
+		"insufficientFilePermissions": errs.ItemPermission,
+		"appNotAuthorizedToFile":      errs.ItemPermission,
+		"userRateLimitExceeded":       errs.RateLimited,
+		"storageQuotaExceeded":        errs.Quota,
+		"somethingNew":                errs.SourceBug,
+		"":                            errs.SourceBug,
+	} {
+		apiErr := &googleapi.Error{Code: http.StatusForbidden}
+		if reason != "" {
+			apiErr.Errors = []googleapi.ErrorItem{{Reason: reason}}
+		}
+		err := WrapError(fmt.Errorf("download: %w", apiErr))
+		assert.Equal(t, want, errs.CategoryOf(err), "reason %q", reason)
+	}
+
+	err := WrapError(&googleapi.Error{Code: http.StatusUnauthorized})
+	assert.Equal(t, errs.AuthRevoked, errs.CategoryOf(err))
+}
@@ -9,10 +9,12 @@ import (
 	"mime/quotedprintable"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/StorX2-0/Backup-Tools/db"
 	"github.com/StorX2-0/Backup-Tools/middleware"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/satellite"
 
 	"github.com/labstack/echo/v4"
 	"google.golang.org/api/gmail/v1"
@@ -69,6 +71,19 @@ type Attachment struct {
 	Data     []byte
 }
 
+// GmailObjectMetadata returns the provenance of a message for its backup
+func GmailObjectMetadata(msg *gmail.Message) satellite.ObjectMetadata {
+	meta := satellite.ObjectMetadata{
+		Source:   "google",
+		SourceID: msg.Id,
+		MimeType: "application/json",
+	}
+	if msg.InternalDate > 0 {
+		meta.SourceModified = time.UnixMilli(msg.InternalDate).UTC().Format(time.RFC3339)
+	}
+	return meta
+}
+
 func NewGmailClient(c echo.Context) (*GmailClient, error) {
 
 	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
@@ -82,11 +97,13 @@ func NewGmailClient(c echo.Context) (*GmailClient, error) {
 		return nil, fmt.Errorf("unable to retrieve google-auth token from database: %v", err)
 	}
 
-	return NewGmailClientUsingToken(token)
+	return NewGmailClientUsingToken(token, "")
 }
 
-func NewGmailClientUsingToken(token string) (*GmailClient, error) {
-	client, err := clientUsingToken(token)
+// NewGmailClientUsingToken creates a Gmail client for an access token of account, the
+// email address of the mailbox if known
+func NewGmailClientUsingToken(token, account string) (*GmailClient, error) {
+	client, err := clientUsingToken(token, account)
 
 	if err != nil {
 		return nil, err
@@ -111,7 +128,7 @@ func (client *GmailClient) GetUserThreads(nextPageToken string) (*ThreadsRespons
 
 	threads, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	ts := make([]*gmail.Thread, 0, len(threads.Threads))
@@ -140,7 +157,7 @@ func (client *GmailClient) InsertMessage(message *gmail.Message) error {
 		LabelIds: message.LabelIds,
 	}).Do()
 
-	return err
+	return WrapError(err)
 }
 
 func (client *GmailClient) GetUserThreadsIDs(nextPageToken string) (*gmail.ListThreadsResponse, error) {
@@ -148,7 +165,11 @@ func (client *GmailClient) GetUserThreadsIDs(nextPageToken string) (*gmail.ListT
 	if nextPageToken != "" {
 		req.PageToken(nextPageToken)
 	}
-	return req.Do()
+	res, err := req.Do()
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return res, nil
 }
 
 // Function takes nextPageToken and returns 100 results of User's messages.
@@ -162,7 +183,7 @@ func (client *GmailClient) GetUserMessages(nextPageToken string) (*MessagesRespo
 
 	res, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	messages := make([]*gmail.Message, 0, len(res.Messages))
@@ -187,7 +208,7 @@ func (client *GmailClient) GetUserMessagesIDs(nextPageToken string) (*gmail.List
 
 	resp, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	return resp, nil
@@ -201,7 +222,7 @@ func (client *GmailClient) GetMessageDirect(msgID string) (*gmail.Message, error
 
 	msg, err := client.Users.Messages.Get("me", msgID).Format("full").Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	if msg.Payload != nil {
@@ -354,7 +375,7 @@ func (client *GmailClient) GetThread(threadID string) (*gmail.Thread, error) {
 
 	thread, err := client.Users.Threads.Get("me", threadID).Format("full").Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	return thread, nil
@@ -364,7 +385,7 @@ func (client *GmailClient) GetAttachment(msgID, attachmentID string) (*gmail.Mes
 
 	msg, err := client.Users.Messages.Attachments.Get("me", msgID, attachmentID).Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	return msg, nil
@@ -433,7 +454,7 @@ func (client *GmailClient) GetUserMessagesControlled(nextPageToken, label string
 
 	res, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	messages := make([]*gmail.Message, 0, len(res.Messages))
@@ -459,7 +480,7 @@ func (client *GmailClient) GetUserMessagesUsingWorkers(nextPageToken string, wor
 
 	res, err := req.Do()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(err)
 	}
 
 	var (
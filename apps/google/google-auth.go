@@ -14,6 +14,7 @@ import (
 
 	"github.com/StorX2-0/Backup-Tools/db"
 	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
@@ -307,14 +308,25 @@ func AuthTokenUsingRefreshToken(refreshToken string) (string, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error making HTTP request: %v", err)
+		return "", errs.Wrap(errs.Network, errSource, fmt.Errorf("error making HTTP request: %w", err))
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v", err)
+		return "", errs.Wrap(errs.Network, errSource, fmt.Errorf("error reading response body: %w", err))
+	}
+
+	// A revoked or expired refresh token comes back as 400 invalid_grant
+	if resp.StatusCode != http.StatusOK {
+		var tokenErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &tokenErr)
+		return "", errs.Wrap(tokenErrorCategory(tokenErr.Error, resp), errSource,
+			fmt.Errorf("error refreshing google token: %d %s %s", resp.StatusCode, tokenErr.Error, tokenErr.ErrorDescription))
 	}
 
 	// Parse the response JSON
@@ -3,10 +3,13 @@ package google
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,6 +22,7 @@ import (
 	"github.com/StorX2-0/Backup-Tools/middleware"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/ratelimit"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
 	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
@@ -121,24 +125,24 @@ func GetFileNames(c echo.Context) ([]*FilesJSON, error) {
 	return fileResp, nil
 }
 
-// GetFileByID returns file by ID as attachment
+// GetFileByID returns file by ID as attachment, streaming it from Drive
 func GetFileByID(c echo.Context) error {
 	ctx := c.Request().Context()
 	var err error
 	defer monitor.Mon.Task()(&ctx)(&err)
 
-	name, data, err := GetFile(c, c.Param("ID"))
+	name, body, err := OpenFile(c, c.Param("ID"))
 	if err != nil {
 		return c.String(http.StatusForbidden, "error")
 	}
+	defer body.Close()
 
-	userCachePath := filepath.Join("./cache", utils.CreateUserTempCacheFolder(), name)
-	if err := os.WriteFile(userCachePath, data, 0644); err != nil {
-		return c.String(http.StatusForbidden, err.Error())
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
 	}
-	defer os.Remove(userCachePath)
-
-	return c.Attachment(userCachePath, name)
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	return c.Stream(http.StatusOK, contentType, body)
 }
 
 // client authenticates the client and returns an HTTP client
@@ -168,11 +172,7 @@ func client(c echo.Context) (*http.Client, error) {
 	}
 
 	logger.Info(ctx, "processing access token"+tok)
-	client := config.Client(context.Background(), &oauth2.Token{
-		AccessToken: tok,
-	})
-
-	return client, nil
+	return NewRateLimitedClient(context.Background(), config, tok, ""), nil
 }
 
 // Helper function to check if a MIME type is a Google Apps file
@@ -280,7 +280,7 @@ func GetDriveService(c echo.Context) (*drive.Service, error) {
 	return getDriveService(c)
 }
 
-func clientUsingToken(token string) (*http.Client, error) {
+func clientUsingToken(token, account string) (*http.Client, error) {
 	b, err := os.ReadFile("credentials.json")
 	if err != nil {
 		return nil, fmt.Errorf("unable to read client secret file: %v", err)
@@ -290,14 +290,21 @@ func clientUsingToken(token string) (*http.Client, error) {
 		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
 	}
 
-	return config.Client(context.Background(), &oauth2.Token{
-		AccessToken: token,
-	}), nil
+	return NewRateLimitedClient(context.Background(), config, token, account), nil
 }
 
-// GetFile downloads file from Google Drive by ID
-func GetFile(c echo.Context, id string) (string, []byte, error) {
+// NewRateLimitedClient creates an OAuth client for an access token. Its requests wait for
+// the rate limits of the Google API they are made to and back off when Google throttles.
+// account is the email address of the Google account, if known; the limits are kept per
+// account across token refreshes.
+func NewRateLimitedClient(ctx context.Context, config *oauth2.Config, accessToken, account string) *http.Client {
+	client := config.Client(ctx, &oauth2.Token{AccessToken: accessToken})
+	return ratelimit.WrapClient(client, ratelimit.GoogleAPI, ratelimit.AccountKey(account, accessToken))
+}
 
+// OpenFile opens file from Google Drive by ID and returns its name and its content as a
+// stream, which must be closed. Google Docs files are exported.
+func OpenFile(c echo.Context, id string) (string, io.ReadCloser, error) {
 	srv, err := getDriveService(c)
 	if err != nil {
 		return "", nil, err
@@ -305,44 +312,25 @@ func GetFile(c echo.Context, id string) (string, []byte, error) {
 
 	file, err := srv.Files.Get(id).Do()
 	if err != nil {
-		return "", nil, fmt.Errorf("unable to retrieve file metadata: %v", err)
-	}
-
-	res, err := srv.Files.Get(id).Download()
-	if err != nil {
-		if strings.Contains(err.Error(), "Use Export with Docs Editors files., fileNotDownloadable") {
-			mt, ext := getExportMimeTypeAndExtension(file.MimeType)
-			file.Name += ext
-			// handle folders
-			if mt != "application/vnd.google-apps.folder" {
-				if res, err = srv.Files.Export(id, mt).Download(); err != nil {
-					return "", nil, fmt.Errorf("unable to download file: %v", err)
-				}
-			} else {
-				return file.Name, nil, errors.New("folder error")
-			}
-		} else {
-			return "", nil, fmt.Errorf("unable to download file: %v", err)
-		}
+		return "", nil, fmt.Errorf("unable to retrieve file metadata: %w", err)
 	}
-	defer res.Body.Close()
 
-	data, err := io.ReadAll(res.Body)
+	name := file.Name
+	res, err := downloadFile(srv, id, file.MimeType, &name)
 	if err != nil {
-		return "", nil, fmt.Errorf("unable to read file content: %v", err)
+		return "", nil, err
 	}
-
-	return file.Name, data, nil
+	return name, res.Body, nil
 }
 
-// Uploads file to Google Drive.
-func UploadFile(c echo.Context, name string, data []byte) error {
+// Uploads file to Google Drive, streaming its content from data.
+func UploadFile(c echo.Context, name string, data io.Reader) error {
 
 	srv, err := getDriveService(c)
 	if err != nil {
 		return err
 	}
-	_, err = srv.Files.Create(&drive.File{Name: name}).Media(bytes.NewReader(data)).Do()
+	_, err = srv.Files.Create(&drive.File{Name: name}).Media(data).Do()
 	if err != nil {
 		return err
 	}
@@ -1268,14 +1256,16 @@ func GetFolderPathByID(ctx context.Context, srv *drive.Service, folderID string)
 	return strings.Join(segments, "/"), nil
 }
 
-// GetFileAndPath downloads file from Google Drive by ID and returns path with content
-func GetFileAndPath(c echo.Context, id string) (string, []byte, error) {
-	path, _, data, err := GetFileAndPathWithMimeType(c, id)
-	return path, data, err
+// OpenFileAndPath opens file from Google Drive by ID and returns path and its content as a
+// stream, which must be closed
+func OpenFileAndPath(c echo.Context, id string) (string, io.ReadCloser, error) {
+	path, _, body, err := OpenFileAndPathWithMimeType(c, id)
+	return path, body, err
 }
 
-// GetFileAndPathWithMimeType downloads file from Google Drive by ID and returns path, MimeType, and content
-func GetFileAndPathWithMimeType(c echo.Context, id string) (string, string, []byte, error) {
+// OpenFileAndPathWithMimeType opens file from Google Drive by ID and returns path, MimeType,
+// and its content as a stream, which must be closed
+func OpenFileAndPathWithMimeType(c echo.Context, id string) (string, string, io.ReadCloser, error) {
 	srv, err := getDriveService(c)
 	if err != nil {
 		return "", "", nil, err
@@ -1295,14 +1285,8 @@ func GetFileAndPathWithMimeType(c echo.Context, id string) (string, string, []by
 	if err != nil {
 		return "", "", nil, err
 	}
-	defer res.Body.Close()
 
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		return "", "", nil, fmt.Errorf("unable to read file content: %w", err)
-	}
-
-	return p, file.MimeType, data, nil
+	return p, file.MimeType, res.Body, nil
 }
 
 // downloadFile handles both regular files and Google Docs export
@@ -1343,6 +1327,9 @@ type DriveFileMetadata struct {
 	Permissions  []DrivePermission `json:"permissions"`
 	ModifiedTime string            `json:"modified_time"`
 	Starred      bool              `json:"starred"`
+	// FileID is the Drive ID of the file. It is not part of the backup item but read from
+	// the metadata stored with the object.
+	FileID string `json:"-"`
 }
 
 type DrivePermission struct {
@@ -1631,8 +1618,11 @@ func (rc *RestoreContext) RestoreFile(ctx context.Context, metadata *DriveFileMe
 		return err
 	}
 
-	fileName := filepath.Base(metadata.Key)
-	id, name := parseIDName(fileName)
+	// Objects uploaded before their metadata was recorded only have the ID in the key
+	id, name := metadata.FileID, metadata.Name
+	if id == "" || name == "" {
+		id, name = parseIDName(filepath.Base(metadata.Key))
+	}
 
 	if id != "" {
 		getCall := rc.Service.Files.Get(id).Fields("id, name, trashed, owners(emailAddress)")
@@ -1798,11 +1788,20 @@ func (rc *RestoreContext) RestoreFolder(ctx context.Context, metadata *DriveFile
 	return nil
 }
 
-func ParseBackupMetadata(data []byte) (*DriveFileMetadata, error) {
+// ParseBackupMetadata parses the metadata of a backup item. object is the metadata stored
+// with the backup object; it fills in what older backup items lack.
+func ParseBackupMetadata(data []byte, object satellite.ObjectMetadata) (*DriveFileMetadata, error) {
 	var metadata DriveFileMetadata
 	if err := json.Unmarshal(data, &metadata); err != nil {
 		return nil, err
 	}
+	metadata.FileID = object.SourceID
+	if metadata.MimeType == "" {
+		metadata.MimeType = object.MimeType
+	}
+	if metadata.ModifiedTime == "" {
+		metadata.ModifiedTime = object.SourceModified
+	}
 	return &metadata, nil
 }
 
@@ -1811,8 +1810,136 @@ type DriveBackupItem struct {
 	Content  []byte            `json:"content,omitempty"`
 }
 
-func RestoreFromBackup(ctx context.Context, srv *drive.Service, userEmail string, metadataJSON, fileBytes []byte) error {
-	metadata, err := ParseBackupMetadata(metadataJSON)
+// driveBackupItemFraming returns what WriteDriveBackupItem writes before and after the
+// encoded content
+func driveBackupItemFraming(metadata DriveFileMetadata) (string, string, error) {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", "", err
+	}
+	return `{"metadata":` + string(metadataJSON) + `,"content":"`, `"}`, nil
+}
+
+// WriteDriveBackupItem writes the DriveBackupItem of a file to w, encoding content while
+// it is read so large files are never held in memory. It returns the number of content
+// bytes read.
+func WriteDriveBackupItem(w io.Writer, metadata DriveFileMetadata, content io.Reader) (int64, error) {
+	prefix, suffix, err := driveBackupItemFraming(metadata)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(w, prefix); err != nil {
+		return 0, err
+	}
+
+	encoder := base64.NewEncoder(base64.StdEncoding, w)
+	read, err := io.Copy(encoder, content)
+	if err != nil {
+		return read, err
+	}
+	if err := encoder.Close(); err != nil {
+		return read, err
+	}
+
+	_, err = io.WriteString(w, suffix)
+	return read, err
+}
+
+// DriveBackupItemSize returns the length of what WriteDriveBackupItem writes for content
+// of size bytes
+func DriveBackupItemSize(metadata DriveFileMetadata, size int64) (int64, error) {
+	prefix, suffix, err := driveBackupItemFraming(metadata)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(prefix)) + encodedLen(size) + int64(len(suffix)), nil
+}
+
+// DriveBackupItemVersion identifies what WriteDriveBackupItem writes for content of size
+// bytes, so an interrupted upload is only resumed with the same metadata. Changes such as
+// new permissions do not bump the file's modification time.
+func DriveBackupItemVersion(metadata DriveFileMetadata, size int64) (string, error) {
+	prefix, _, err := driveBackupItemFraming(metadata)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(prefix))
+	return fmt.Sprintf("%s/%d/%x", metadata.ModifiedTime, size, sum[:8]), nil
+}
+
+func encodedLen(size int64) int64 {
+	return (size + 2) / 3 * 4
+}
+
+// OpenDriveBackupItem returns what WriteDriveBackupItem writes for content of size bytes,
+// starting at offset, so an interrupted upload can be resumed. open returns the content
+// starting at a content offset.
+func OpenDriveBackupItem(metadata DriveFileMetadata, size, offset int64, open func(offset int64) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	prefix, suffix, err := driveBackupItemFraming(metadata)
+	if err != nil {
+		return nil, err
+	}
+	head, encoded := int64(len(prefix)), encodedLen(size)
+
+	if offset >= head+encoded {
+		rest := suffix[min(offset-head-encoded, int64(len(suffix))):]
+		return io.NopCloser(strings.NewReader(rest)), nil
+	}
+
+	// Base64 encodes every 3 bytes of content to 4 characters, so the content is read
+	// from the start of the group the offset falls into
+	var skip int64
+	group := int64(0)
+	if offset > head {
+		group = (offset - head) / 4
+		skip = (offset - head) % 4
+	}
+	content, err := open(group * 3)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		encoder := base64.NewEncoder(base64.StdEncoding, pw)
+		_, err := io.Copy(encoder, content)
+		if err == nil {
+			err = encoder.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	if _, err := io.CopyN(io.Discard, pr, skip); err != nil {
+		pr.CloseWithError(err)
+		content.Close()
+		return nil, err
+	}
+
+	var start string
+	if offset < head {
+		start = prefix[offset:]
+	}
+	return &multiReadCloser{
+		Reader:  io.MultiReader(strings.NewReader(start), pr, strings.NewReader(suffix)),
+		closers: []io.Closer{pr, content},
+	}, nil
+}
+
+// multiReadCloser closes all sources of a combined reader
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	var errs []error
+	for _, c := range m.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func RestoreFromBackup(ctx context.Context, srv *drive.Service, userEmail string, metadataJSON, fileBytes []byte, object satellite.ObjectMetadata) error {
+	metadata, err := ParseBackupMetadata(metadataJSON, object)
 	if err != nil {
 		return err
 	}
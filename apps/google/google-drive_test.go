@@ -0,0 +1,86 @@
+package google
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDriveBackupItemMatchesDriveBackupItem(t *testing.T) {
+	metadata := DriveFileMetadata{
+		Key:      "user@example.com/docs/report.pdf",
+		Type:     "file",
+		Name:     "report.pdf",
+		MimeType: "application/pdf",
+		Parents:  []string{"folder"},
+	}
+	content := bytes.Repeat([]byte("report \x00\xff"), 1000)
+
+	var buf bytes.Buffer
+	read, err := WriteDriveBackupItem(&buf, metadata, bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), read)
+
+	var item DriveBackupItem
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &item))
+	assert.Equal(t, metadata, item.Metadata)
+	assert.Equal(t, content, item.Content)
+
+	expected, err := json.Marshal(DriveBackupItem{Metadata: metadata, Content: content})
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), buf.String())
+}
+
+func TestOpenDriveBackupItemResumesAtAnyOffset(t *testing.T) {
+	metadata := DriveFileMetadata{Key: "user@example.com/video.mp4", Type: "file", Name: "video.mp4"}
+	for _, size := range []int{0, 1, 2, 3, 100, 1001} {
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = byte(i * 7)
+		}
+		var full bytes.Buffer
+		_, err := WriteDriveBackupItem(&full, metadata, bytes.NewReader(content))
+		require.NoError(t, err)
+
+		total, err := DriveBackupItemSize(metadata, int64(size))
+		require.NoError(t, err)
+		require.Equal(t, int64(full.Len()), total, "size %d", size)
+
+		open := func(offset int64) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content[offset:])), nil
+		}
+		for offset := int64(0); offset <= total; offset++ {
+			r, err := OpenDriveBackupItem(metadata, int64(size), offset, open)
+			require.NoError(t, err)
+			rest, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, full.Bytes()[offset:], rest, "size %d offset %d", size, offset)
+		}
+	}
+}
+
+func TestParseBackupMetadataFillsInFromObjectMetadata(t *testing.T) {
+	data := []byte(`{"key":"user@example.com/1AbC_report.pdf","type":"file","name":"report.pdf"}`)
+
+	metadata, err := ParseBackupMetadata(data, satellite.ObjectMetadata{
+		SourceID:       "1AbCdEfGhIjK",
+		SourceModified: "2024-05-01T10:00:00Z",
+		MimeType:       "application/pdf",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1AbCdEfGhIjK", metadata.FileID)
+	assert.Equal(t, "application/pdf", metadata.MimeType)
+	assert.Equal(t, "2024-05-01T10:00:00Z", metadata.ModifiedTime)
+
+	// Older objects carry no metadata
+	metadata, err = ParseBackupMetadata(data, satellite.ObjectMetadata{})
+	require.NoError(t, err)
+	assert.Empty(t, metadata.FileID)
+	assert.Empty(t, metadata.MimeType)
+}
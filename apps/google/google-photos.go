@@ -18,6 +18,7 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/satellite"
 )
 
 type GPotosClient struct {
@@ -297,6 +298,16 @@ func passesMediaTypeFilter(file media_items.MediaItem, mediaType string) bool {
 	}
 }
 
+// PhotoObjectMetadata returns the provenance of a media item for its backup
+func PhotoObjectMetadata(item *media_items.MediaItem) satellite.ObjectMetadata {
+	return satellite.ObjectMetadata{
+		Source:         "google",
+		SourceID:       item.ID,
+		SourceModified: item.MediaMetadata.CreationTime,
+		MimeType:       item.MimeType,
+	}
+}
+
 func (gpclient *GPotosClient) GetPhoto(ctx context.Context, photoID string) (*media_items.MediaItem, error) {
 	photo, err := gpclient.MediaItems.Get(ctx, photoID)
 	if err != nil {
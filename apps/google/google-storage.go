@@ -1,10 +1,10 @@
 package google
 
 import (
-	"bytes"
 	"context"
 	"io"
 
+	"github.com/StorX2-0/Backup-Tools/satellite"
 	"github.com/labstack/echo/v4"
 	"google.golang.org/api/option"
 	"google.golang.org/api/storage/v1"
@@ -74,10 +74,24 @@ func (client *StorageClient) ListObjectsInBucketJSON(c echo.Context, bucketName
 
 type StorageObject struct {
 	Name string
-	Data []byte
+	Data io.ReadCloser
+	// ID, ContentType and Updated are filled in by GetObject
+	ID          string
+	ContentType string
+	Updated     string
 }
 
-// Takes Bucket name and Object name, returns object struct (objectName and buffered data in []byte format)
+// ObjectMetadata returns the provenance of the object for its backup
+func (obj *StorageObject) ObjectMetadata() satellite.ObjectMetadata {
+	return satellite.ObjectMetadata{
+		Source:         "google-cloud",
+		SourceID:       obj.ID,
+		SourceModified: obj.Updated,
+		MimeType:       obj.ContentType,
+	}
+}
+
+// Takes Bucket name and Object name, returns object struct (objectName and the object's content as a stream, which must be closed)
 func (client *StorageClient) GetObject(c echo.Context, bucketName, objectName string) (*StorageObject, error) {
 
 	obj, err := client.Objects.Get(bucketName, objectName).Do()
@@ -89,26 +103,22 @@ func (client *StorageClient) GetObject(c echo.Context, bucketName, objectName st
 	if err != nil {
 		return nil, err
 	}
-	defer data.Body.Close()
-	body, err := io.ReadAll(data.Body)
-	if err != nil {
-		return nil, err
-	}
 
 	return &StorageObject{
-		Name: obj.Name,
-		Data: body,
+		Name:        obj.Name,
+		Data:        data.Body,
+		ID:          obj.Id,
+		ContentType: obj.ContentType,
+		Updated:     obj.Updated,
 	}, nil
 }
 
-// Takes bucket Name Object struct (objectName and buffered data in []byte format) and uploads it into Google Cloud Storage specified bucket.
+// Takes bucket Name Object struct (objectName and content stream) and uploads it into Google Cloud Storage specified bucket.
 func (client *StorageClient) UploadObject(c echo.Context, bucketName string, obj *StorageObject) error {
 
-	reader := bytes.NewReader(obj.Data)
-
 	_, err := client.Objects.Insert(bucketName, &storage.Object{
 		Name: obj.Name,
-	}).Media(reader).Do()
+	}).Media(obj.Data).Do()
 
 	if err != nil {
 		return err
@@ -0,0 +1,71 @@
+package outlook
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+)
+
+// errSource identifies Microsoft Outlook in categorised errors
+const errSource = "outlook"
+
+// statusCoder is implemented by the Microsoft Graph SDK errors (ODataError embeds the
+// kiota ApiError), which carry the HTTP status of the failed request
+type statusCoder interface {
+	GetStatusCode() int
+}
+
+// wrapGraphError attaches an error category to errors returned by Microsoft Graph
+func wrapGraphError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var odataErr *odataerrors.ODataError
+	if errors.As(err, &odataErr) {
+		return errs.Wrap(graphErrorCategory(odataErr), errSource, err)
+	}
+
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		return errs.Wrap(errs.FromStatus(sc.GetStatusCode()), errSource, err)
+	}
+
+	return errs.Wrap(errs.CategoryOf(err), errSource, err)
+}
+
+// graphErrorCategory looks at the error code first since Graph reports a mailbox the
+// app may no longer read, such as after the user or an admin revoked consent, as 403
+func graphErrorCategory(err *odataerrors.ODataError) errs.Category {
+	var code string
+	if main := err.GetErrorEscaped(); main != nil && main.GetCode() != nil {
+		code = *main.GetCode()
+	}
+	switch code {
+	case "ErrorAccessDenied", "Authorization_RequestDenied", "InvalidAuthenticationToken":
+		return errs.AuthRevoked
+	case "ApplicationThrottled", "ErrorServerBusy", "TooManyRequests":
+		return errs.RateLimited
+	case "ErrorQuotaExceeded":
+		return errs.Quota
+	case "ErrorItemNotFound":
+		return errs.NotFound
+	}
+	return errs.FromStatus(err.GetStatusCode())
+}
+
+// tokenErrorCategory classifies a failed token request. invalid_grant means the user
+// revoked access or the refresh token expired.
+func tokenErrorCategory(code string, statusCode int) errs.Category {
+	switch code {
+	case "invalid_grant", "interaction_required", "unauthorized_client", "invalid_client":
+		return errs.AuthRevoked
+	}
+	if statusCode == http.StatusBadRequest {
+		// Other 400s from the token endpoint are configuration problems on our side
+		return errs.SourceBug
+	}
+	return errs.FromStatus(statusCode)
+}
@@ -0,0 +1,48 @@
+package outlook
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func graphError(status int, code string) *odataerrors.ODataError {
+	main := odataerrors.NewMainError()
+	if code != "" {
+		main.SetCode(&code)
+	}
+	message := "graph request failed"
+	main.SetMessage(&message)
+
+	err := odataerrors.NewODataError()
+	err.SetErrorEscaped(main)
+	err.SetStatusCode(status)
+	return err
+}
+
+func TestWrapGraphError(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		code   string
+		want   errs.Category
+	}{
+		{http.StatusForbidden, "ErrorAccessDenied", errs.AuthRevoked},
+		{http.StatusForbidden, "Authorization_RequestDenied", errs.AuthRevoked},
+		{http.StatusUnauthorized, "InvalidAuthenticationToken", errs.AuthRevoked},
+		{http.StatusTooManyRequests, "ApplicationThrottled", errs.RateLimited},
+		{http.StatusForbidden, "ErrorQuotaExceeded", errs.Quota},
+		{http.StatusNotFound, "ErrorItemNotFound", errs.NotFound},
+		{http.StatusForbidden, "SomethingNew", errs.SourceBug},
+		{http.StatusForbidden, "", errs.SourceBug},
+		{http.StatusServiceUnavailable, "", errs.Network},
+	} {
+		err := wrapGraphError(fmt.Errorf("list messages: %w", graphError(tc.status, tc.code)))
+		assert.Equal(t, tc.want, errs.CategoryOf(err), "%d %q", tc.status, tc.code)
+	}
+
+	assert.NoError(t, wrapGraphError(nil))
+}
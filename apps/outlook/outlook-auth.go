@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
 )
@@ -82,7 +83,7 @@ func BuildAuthURL(ctx context.Context) (string, error) {
 func AuthTokenUsingRefreshToken(refreshToken string) (string, error) {
 
 	if refreshToken == "" {
-		return "", fmt.Errorf("refresh token is empty")
+		return "", errs.New(errs.AuthRevoked, errSource, "refresh token is empty")
 	}
 
 	// Prepare the form data
@@ -114,18 +115,24 @@ func AuthTokenUsingRefreshToken(refreshToken string) (string, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %v", err)
+		return "", errs.Wrap(errs.Network, errSource, fmt.Errorf("error sending request: %w", err))
 	}
 	defer resp.Body.Close()
 
 	// Read the response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response: %v", err)
+		return "", errs.Wrap(errs.Network, errSource, fmt.Errorf("error reading response: %w", err))
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("error response from server: %s", string(body))
+		// A revoked or expired refresh token comes back as invalid_grant
+		var tokenErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(body, &tokenErr)
+		return "", errs.Wrap(tokenErrorCategory(tokenErr.Error, resp.StatusCode), errSource,
+			fmt.Errorf("error response from server: %s", string(body)))
 	}
 
 	// Parse the response
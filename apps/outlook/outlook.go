@@ -4,14 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"strconv"
 
+	"github.com/StorX2-0/Backup-Tools/pkg/ratelimit"
 	abs "github.com/microsoft/kiota-abstractions-go"
+	khttp "github.com/microsoft/kiota-http-go"
 	msgraph "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	"github.com/microsoftgraph/msgraph-sdk-go/users"
 )
@@ -45,9 +49,11 @@ func (b *BearerTokenAuthenticationProvider) AuthenticateRequest(ctx context.Cont
 	return nil
 }
 
-func NewOutlookClientUsingToken(accessToken string) (*OutlookClient, error) {
+// NewOutlookClientUsingToken creates a Graph client for an access token of account, the
+// email address of the mailbox if known
+func NewOutlookClientUsingToken(accessToken, account string) (*OutlookClient, error) {
 	authProvider := &BearerTokenAuthenticationProvider{accessToken: accessToken}
-	adapter, err := msgraph.NewGraphRequestAdapter(authProvider)
+	adapter, err := msgraph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(authProvider, nil, nil, newGraphHTTPClient(accessToken, account))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Graph request adapter: %w", err)
 	}
@@ -56,6 +62,19 @@ func NewOutlookClientUsingToken(accessToken string) (*OutlookClient, error) {
 	return &OutlookClient{client}, nil
 }
 
+// newGraphHTTPClient builds the default Graph HTTP client with the rate limiter below the
+// SDK middleware, so every attempt of the SDK's retry handler waits for the mailbox's
+// limits. The retry handler already honours Retry-After, so the limiter does not retry.
+func newGraphHTTPClient(accessToken, account string) *http.Client {
+	options := msgraph.GetDefaultClientOptions()
+	limiter := ratelimit.NewTransport(khttp.GetDefaultTransport(), ratelimit.Fixed("graph"), ratelimit.AccountKey(account, accessToken))
+	limiter.NoRetry = true
+
+	client := msgraphcore.GetDefaultClient(&options)
+	client.Transport = khttp.NewCustomTransportWithParentTransport(limiter, msgraphcore.GetDefaultMiddlewaresWithOptions(&options)...)
+	return client
+}
+
 func (client *OutlookClient) GetCurrentUser() (*OutlookUser, error) {
 
 	user, err := client.Me().Get(context.Background(), &users.UserItemRequestBuilderGetRequestConfiguration{
@@ -64,7 +83,7 @@ func (client *OutlookClient) GetCurrentUser() (*OutlookUser, error) {
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user from Microsoft Graph API: %w", err)
+		return nil, wrapGraphError(fmt.Errorf("failed to get user from Microsoft Graph API: %w", err))
 	}
 
 	u := NewOutlookUser(user)
@@ -275,7 +294,7 @@ func (client *OutlookClient) GetUserMessagesControlled(skip, limit int32, filter
 
 	result, err := client.Me().Messages().Get(context.Background(), &configuration)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user messages: %w", err)
+		return nil, wrapGraphError(fmt.Errorf("failed to get user messages: %w", err))
 	}
 
 	outlookMessages := make([]*OutlookMinimalMessage, 0, len(result.GetValue()))
@@ -357,7 +376,7 @@ func (client *OutlookClient) GetMessageWithDetails(skip, limit int32) ([]*Outloo
 
 	result, err := client.Me().Messages().Get(context.Background(), &configuration)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get detailed messages: %w", err)
+		return nil, wrapGraphError(fmt.Errorf("failed to get detailed messages: %w", err))
 	}
 
 	outlookMessages := make([]*OutlookMessage, 0, len(result.GetValue()))
@@ -386,7 +405,7 @@ func (client *OutlookClient) GetMessage(msgID string) (*OutlookMessage, error) {
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get message %s: %w", msgID, err)
+		return nil, wrapGraphError(fmt.Errorf("failed to get message %s: %w", msgID, err))
 	}
 
 	return NewOutlookMessage(msg), nil
@@ -401,7 +420,7 @@ func (client *OutlookClient) GetAttachment(msgID, attID string) (*OutlookAttachm
 
 	att, err := client.Me().Messages().ByMessageId(msgID).Attachments().ByAttachmentId(attID).Get(context.Background(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get attachment %s for message %s: %w", attID, msgID, err)
+		return nil, wrapGraphError(fmt.Errorf("failed to get attachment %s for message %s: %w", attID, msgID, err))
 	}
 
 	return NewOutlookAttachment(att), nil
@@ -542,7 +561,7 @@ func (client *OutlookClient) InsertMessage(message *OutlookMessage) (models.Mess
 	// Create the message in drafts
 	createdMessage, err := client.Me().Messages().Post(context.Background(), messageRequest, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create message: %w", err)
+		return nil, wrapGraphError(fmt.Errorf("failed to create message: %w", err))
 	}
 
 	// Move the message to inbox to make it appear as received
@@ -554,7 +573,7 @@ func (client *OutlookClient) InsertMessage(message *OutlookMessage) (models.Mess
 	if err != nil {
 		// Log the error but don't fail the entire operation
 		// The message was created successfully, just not moved
-		return createdMessage, fmt.Errorf("message created but failed to move to inbox: %w", err)
+		return createdMessage, wrapGraphError(fmt.Errorf("message created but failed to move to inbox: %w", err))
 	}
 
 	return createdMessage, nil
@@ -577,7 +596,7 @@ func (client *OutlookClient) SendMessage(message *OutlookMessage) error {
 	err = client.Me().Messages().ByMessageId(*createdMessage.GetId()).
 		Send().Post(context.Background(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return wrapGraphError(fmt.Errorf("failed to send message: %w", err))
 	}
 
 	return nil
@@ -2,8 +2,10 @@ package outlook
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/StorX2-0/Backup-Tools/satellite"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
 )
 
@@ -48,6 +50,19 @@ func NewOutlookMinimalMessage(message models.Messageable) *OutlookMinimalMessage
 	return result
 }
 
+// ObjectMetadata returns the provenance of the message for its backup
+func (m *OutlookMinimalMessage) ObjectMetadata() satellite.ObjectMetadata {
+	meta := satellite.ObjectMetadata{
+		Source:   "outlook",
+		SourceID: m.ID,
+		MimeType: "application/json",
+	}
+	if ms, err := strconv.ParseInt(m.ReceivedDateTime, 10, 64); err == nil {
+		meta.SourceModified = time.UnixMilli(ms).UTC().Format(time.RFC3339)
+	}
+	return meta
+}
+
 type OutlookUser struct {
 	ID                string `json:"id"`
 	DisplayName       string `json:"display_name"`
@@ -2,19 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/StorX2-0/Backup-Tools/crons"
 	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/pkg/cluster"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger/newrelic"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
 	"github.com/StorX2-0/Backup-Tools/router"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	"github.com/joho/godotenv"
+	"github.com/labstack/echo/v4"
 )
 
 func main() {
@@ -33,11 +41,63 @@ func main() {
 		os.Exit(1)
 	}
 
+	// SIGINT and SIGTERM start a graceful shutdown
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Live progress streams see the tasks of every replica
+	relayProgress(ctx, store)
+
 	// Start background jobs
-	crons.NewAutosyncManager(store).Start()
+	manager := crons.NewAutosyncManager(store)
+	manager.Start()
 
 	// Start server
-	router.StartServer(store, getAddress())
+	server := router.NewServer(store)
+	go func() {
+		if err := server.Start(getAddress()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(ctx, "Error starting server", logger.ErrorField(err))
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	shutdown(store, server, manager)
+}
+
+// shutdown stops the HTTP server and the background jobs in parallel. Both get the same
+// deadline; tasks that have not stopped by then are requeued.
+func shutdown(store *db.PostgresDb, server *echo.Echo, manager *crons.AutosyncManager) {
+	timeout := getShutdownTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logger.Info(ctx, "Shutting down", logger.String("timeout", timeout.String()))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error(ctx, "Failed to shut down server", logger.ErrorField(err))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := manager.Shutdown(ctx); err != nil {
+			logger.Error(ctx, "Failed to shut down background jobs", logger.ErrorField(err))
+		}
+	}()
+	wg.Wait()
+
+	// Only once no task uses them any more
+	if err := satellite.DefaultPool().Close(); err != nil {
+		logger.Warn(ctx, "Failed to close satellite projects", logger.ErrorField(err))
+	}
+	if err := store.Close(); err != nil {
+		logger.Warn(ctx, "Failed to close database", logger.ErrorField(err))
+	}
+	logger.Info(ctx, "Shutdown complete")
 }
 
 func initApp(ctx context.Context) error {
@@ -99,9 +159,42 @@ func setupStorage(ctx context.Context) (*db.PostgresDb, error) {
 	return store, nil
 }
 
+// progressChannel is the Postgres notification channel live progress is relayed on
+const progressChannel = "task_progress"
+
+// relayProgress connects the progress hub to the hubs of the other replicas through
+// Postgres notifications until ctx is done
+func relayProgress(ctx context.Context, store *db.PostgresDb) {
+	sqlDB, err := store.GetGormDB().DB()
+	if err != nil {
+		logger.Error(ctx, "Failed to get database handle, live progress only covers this instance", logger.ErrorField(err))
+		return
+	}
+
+	notifier := cluster.NewNotifier(sqlDB, progressChannel)
+	go notifier.Listen(ctx, progress.Default.Receive)
+	progress.Default.Relay(cluster.InstanceID(), func(message string) error {
+		notifyCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := notifier.Notify(notifyCtx, message)
+		if err != nil {
+			logger.Warn(notifyCtx, "Failed to relay progress", logger.ErrorField(err))
+		}
+		return err
+	})
+}
+
 func getAddress() string {
 	if address := utils.GetEnvWithKey("ADDRESS"); address != "" {
 		return address
 	}
 	return ":8005"
 }
+
+// getShutdownTimeout returns how long to wait for requests and running tasks on shutdown
+func getShutdownTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(utils.GetEnvWithKey("SHUTDOWN_TIMEOUT")); err == nil && timeout > 0 {
+		return timeout
+	}
+	return 30 * time.Second
+}
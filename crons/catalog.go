@@ -0,0 +1,190 @@
+package crons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/handler"
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// defaultCatalogCheckInterval is how often the catalog of every bucket is checked when
+// CATALOG_CHECK_INTERVAL is unset
+const defaultCatalogCheckInterval = 24 * time.Hour
+
+// QueueCatalogChecks queues a check for every bucket of every user whose catalog was not
+// checked within CATALOG_CHECK_INTERVAL. Buckets are taken from the catalog and from the
+// active jobs, so a job whose catalog writes all failed is checked as well.
+func (a *AutosyncManager) QueueCatalogChecks(ctx context.Context) error {
+	buckets, err := a.store.SyncedObjectRepo.GetSyncedBuckets("")
+	if err != nil {
+		return err
+	}
+
+	jobs, err := a.store.CronJobRepo.GetAllCronJobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if bucket, ok := provider.Bucket(job.Method); ok && job.Active {
+			buckets = append(buckets, repo.SyncedBucket{UserID: job.UserID, BucketName: bucket})
+		}
+	}
+
+	interval := durationFromEnv("CATALOG_CHECK_INTERVAL", defaultCatalogCheckInterval)
+	queued := 0
+	seen := map[repo.SyncedBucket]bool{}
+	for _, bucket := range buckets {
+		if seen[bucket] {
+			continue
+		}
+		seen[bucket] = true
+
+		due, err := a.store.CatalogCheckRepo.CatalogCheckDue(bucket.UserID, bucket.BucketName, interval)
+		if err != nil {
+			return err
+		}
+		if !due {
+			continue
+		}
+		if _, err := a.store.CatalogCheckRepo.QueueCatalogCheck(bucket.UserID, bucket.BucketName, repo.CatalogCheckTriggerScheduled, false); err != nil {
+			return err
+		}
+		queued++
+	}
+
+	logger.Info(ctx, "Queued catalog checks", logger.Int("count", queued))
+	return nil
+}
+
+// ProcessCatalogChecks runs queued catalog checks one after another until none is left
+func (a *AutosyncManager) ProcessCatalogChecks(ctx context.Context) error {
+	for ctx.Err() == nil {
+		check, err := a.store.CatalogCheckRepo.ClaimNextCatalogCheck()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		a.runCatalogCheck(ctx, check)
+	}
+	return nil
+}
+
+// runCatalogCheck verifies the catalog of one bucket and stores the report
+func (a *AutosyncManager) runCatalogCheck(ctx context.Context, check *repo.CatalogCheck) {
+	err := a.verifyCatalog(ctx, check)
+	check.Status = repo.CatalogCheckStatusSuccess
+	check.Error = ""
+	if err != nil {
+		check.Status = repo.CatalogCheckStatusFailed
+		check.Error = err.Error()
+		logger.Error(ctx, "Catalog check failed",
+			logger.Int("check_id", int(check.ID)),
+			logger.String("user_id", check.UserID),
+			logger.String("bucket", check.BucketName),
+			logger.ErrorField(err),
+		)
+	} else {
+		logger.Info(ctx, "Catalog check completed",
+			logger.Int("check_id", int(check.ID)),
+			logger.String("user_id", check.UserID),
+			logger.String("bucket", check.BucketName),
+			logger.Int("missing_from_catalog", check.MissingFromCatalog),
+			logger.Int("missing_from_bucket", check.MissingFromBucket),
+		)
+	}
+
+	if err := a.store.CatalogCheckRepo.FinishCatalogCheck(check); err != nil {
+		logger.Error(ctx, "Failed to store catalog check",
+			logger.Int("check_id", int(check.ID)),
+			logger.ErrorField(err),
+		)
+	}
+}
+
+func (a *AutosyncManager) verifyCatalog(ctx context.Context, check *repo.CatalogCheck) error {
+	grants, err := a.catalogScopes(check.UserID, check.BucketName)
+	if err != nil {
+		return err
+	}
+
+	var scopes []handler.CatalogScope
+	for accessGrant, prefixes := range grants {
+		store, err := satellite.OpenStore(ctx, accessGrant)
+		if err != nil {
+			// Its prefixes stay unverified, so none of their rows is removed
+			logger.Warn(ctx, "Failed to open store for catalog check",
+				logger.String("user_id", check.UserID),
+				logger.String("bucket", check.BucketName),
+				logger.ErrorField(err),
+			)
+			for _, prefix := range prefixes {
+				scopes = append(scopes, handler.CatalogScope{Prefix: prefix})
+			}
+			continue
+		}
+		defer store.Close()
+		for _, prefix := range prefixes {
+			scopes = append(scopes, handler.CatalogScope{Prefix: prefix, Store: store})
+		}
+	}
+
+	return handler.VerifyCatalog(ctx, a.store, check, scopes)
+}
+
+// catalogScopes returns the prefixes of the bucket the jobs and scheduled tasks of a user
+// back up to, by the access grant each of them uploads with. Grants are often limited to
+// the part of the bucket they back up, so every prefix is read with its own grant.
+func (a *AutosyncManager) catalogScopes(userID, bucketName string) (map[string][]string, error) {
+	methods := provider.BucketMethods(bucketName)
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("catalog checks are not supported for bucket %s", bucketName)
+	}
+
+	jobs, err := a.store.CronJobRepo.GetJobsWithAccessGrantForUser(userID, methods)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := a.store.ScheduledTasksRepo.GetTasksWithAccessGrantForUser(userID, methods)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := map[string][]string{}
+	seen := map[[2]string]bool{}
+	add := func(accessGrant, prefix string) {
+		if prefix == "/" || seen[[2]string{accessGrant, prefix}] {
+			return
+		}
+		seen[[2]string{accessGrant, prefix}] = true
+		grants[accessGrant] = append(grants[accessGrant], prefix)
+	}
+	for _, job := range jobs {
+		add(job.StorxToken, catalogPrefix(job))
+	}
+	for _, task := range tasks {
+		add(task.StorxToken, task.LoginId+"/")
+	}
+
+	if len(grants) == 0 {
+		return nil, fmt.Errorf("no job or task of bucket %s has an access grant", bucketName)
+	}
+	return grants, nil
+}
+
+// catalogPrefix returns the part of the bucket a job backs up to
+func catalogPrefix(job repo.CronJobListingDB) string {
+	if job.Method == "psql_database" {
+		// Dumps of all databases are stored next to each other
+		return "postgresql/"
+	}
+	return job.Name + "/"
+}
@@ -0,0 +1,119 @@
+package crons
+
+import (
+	"fmt"
+
+	"github.com/StorX2-0/Backup-Tools/apps/google"
+	"github.com/StorX2-0/Backup-Tools/apps/outlook"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// Dry runs of auto-sync jobs. They page through the account with the same listing calls
+// as the processors and diff the messages against synced_objects; nothing is uploaded.
+
+// Estimate lists the personal emails of the account like Run does
+func (g *gmailProcessor) Estimate(input provider.EstimateInput) (*provider.Estimate, error) {
+	refreshToken, _ := input.InputData["refresh_token"].(string)
+	if refreshToken == "" {
+		return nil, errs.New(errs.AuthRevoked, "google", "refresh token not found")
+	}
+
+	token, err := google.AuthTokenUsingRefreshToken(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("error while generating auth token: %w", err)
+	}
+
+	client, err := google.NewGmailClientUsingToken(token, input.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	synced, err := provider.SyncedItemIDs(input.Database, input.UserID, satellite.ReserveBucket_Gmail, input.Account, "google", "gmail", provider.MessageIDFromKey("gmail"))
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := provider.NewEstimate("gmail", input.MaxItems, true)
+	pageToken := ""
+	for !estimate.Full() {
+		if err := input.Ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err := client.GetUserMessagesControlled(pageToken, "CATEGORY_PERSONAL", 100, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range res.Messages {
+			if !utils.Contains(message.LabelIds, "CATEGORY_PERSONAL") {
+				continue
+			}
+			if estimate.Full() {
+				break
+			}
+			estimate.Add(message.SizeEstimate, synced[message.Id])
+		}
+
+		pageToken = res.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	return estimate.Finish(), nil
+}
+
+// Estimate lists the messages of the account. Outlook does not report message sizes, so
+// only counts are returned.
+func (o *outlookProcessor) Estimate(input provider.EstimateInput) (*provider.Estimate, error) {
+	refreshToken, _ := input.InputData["refresh_token"].(string)
+	if refreshToken == "" {
+		return nil, errs.New(errs.AuthRevoked, "outlook", "refresh token not found")
+	}
+
+	token, err := outlook.AuthTokenUsingRefreshToken(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting token from refresh token: %w", err)
+	}
+
+	client, err := outlook.NewOutlookClientUsingToken(token, input.Account)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating outlook client: %w", err)
+	}
+
+	synced, err := provider.SyncedItemIDs(input.Database, input.UserID, satellite.ReserveBucket_Outlook, input.Account, "outlook", "outlook", provider.MessageIDFromKey("outlook"))
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := provider.NewEstimate("outlook", input.MaxItems, false)
+	skip := int32(0)
+	for !estimate.Full() {
+		if err := input.Ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err := client.GetUserMessagesControlled(skip, int32(OutlookLimit), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range res.Messages {
+			if estimate.Full() {
+				break
+			}
+			estimate.Add(0, synced[message.ID])
+		}
+
+		if !res.HasMore || len(res.Messages) == 0 {
+			break
+		}
+		skip += int32(len(res.Messages))
+	}
+
+	return estimate.Finish(), nil
+}
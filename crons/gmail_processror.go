@@ -7,6 +7,7 @@ import (
 
 	"github.com/StorX2-0/Backup-Tools/apps/google"
 	"github.com/StorX2-0/Backup-Tools/handler"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
@@ -42,20 +43,20 @@ func (g *gmailProcessor) Run(input ProcessorInput) error {
 
 	refreshToken, ok := (*input.Job.InputData.Json())["refresh_token"].(string)
 	if !ok {
-		return fmt.Errorf("refresh token not found")
+		return errs.New(errs.AuthRevoked, "google", "refresh token not found")
 	}
 
 	newToken, err := google.AuthTokenUsingRefreshToken(refreshToken)
 	if err != nil {
-		return fmt.Errorf("error while generating auth token: %s", err)
+		return fmt.Errorf("error while generating auth token: %w", err)
 	}
 
-	gmailClient, err := google.NewGmailClientUsingToken(newToken)
+	gmailClient, err := google.NewGmailClientUsingToken(newToken, input.Job.Name)
 	if err != nil {
 		return err
 	}
 
-	err = handler.UploadObjectAndSync(context.Background(), input.Database, input.Job.StorxToken, satellite.ReserveBucket_Gmail, input.Job.Name+"/.file_placeholder", nil, input.Job.UserID)
+	err = handler.UploadObjectAndSync(context.Background(), input.Database, input.Storage, satellite.ReserveBucket_Gmail, input.Job.Name+"/.file_placeholder", nil, input.Job.UserID, input.ObjectMetadata(satellite.ObjectMetadata{}))
 	if err != nil {
 		return err
 	}
@@ -106,14 +107,16 @@ func (g *gmailProcessor) Run(input ProcessorInput) error {
 
 			b, err := json.Marshal(message)
 			if err != nil {
-				return err
+				return errs.Wrap(errs.SourceBug, "google", err)
 			}
 
 			syncedData = true
-			err = handler.UploadObjectAndSync(context.TODO(), input.Database, input.Job.StorxToken, "gmail", messagePath, b, input.Job.UserID)
+			err = handler.UploadObjectAndSync(context.TODO(), input.Database, input.Storage, "gmail", messagePath, b, input.Job.UserID, input.ObjectMetadata(google.GmailObjectMetadata(message)))
 			if err != nil {
+				input.Events.Failed(message.Id, err)
 				return err
 			}
+			input.Events.Synced(message.Id, messagePath, int64(len(b)))
 
 			input.Job.TaskMemory.GmailSyncCount++
 			emptyLoopCount = 0
@@ -0,0 +1,218 @@
+package crons
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/safehttp"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// hookClient posts webhook hooks. Webhooks are best effort and must not hold up a worker
+// for long. The URLs are chosen by users, so internal addresses are refused.
+var hookClient = safehttp.NewClient(10 * time.Second)
+
+// errVerificationFailed marks a verify hook that found missing or truncated objects
+var errVerificationFailed = errors.New("backup verification failed")
+
+// runHooks runs the hooks of the job for a finished task. The outcome of every hook is
+// recorded in the task's timeline; a failing hook never changes the task status.
+func (a *AutosyncManager) runHooks(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB, processErr error) {
+	hooks := job.Hooks.OnSuccess
+	if processErr != nil {
+		hooks = job.Hooks.OnFailure
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	events := provider.NewEventLog(a.store.TaskEventRepo, repo.TaskEventTypeAutoSync, task.ID)
+	defer events.Flush(ctx)
+
+	for i := 0; i < len(hooks); i++ {
+		hook := hooks[i]
+		err := a.runHook(ctx, hook, task, job, processErr)
+		if err == nil {
+			events.Info(fmt.Sprintf("%s hook completed", hook.Type))
+			continue
+		}
+
+		logger.Warn(ctx, "Job hook failed",
+			logger.Int("job_id", int(job.ID)),
+			logger.Int("task_id", int(task.ID)),
+			logger.String("hook", hook.Type),
+			logger.ErrorField(err),
+		)
+		events.Failed("", fmt.Errorf("%s hook: %w", hook.Type, err))
+
+		// A backup that does not verify is treated as failed by the rest of the chain
+		if errors.Is(err, errVerificationFailed) && processErr == nil {
+			processErr = err
+			a.recordVerificationFailure(ctx, task, job, err)
+			hooks, i = job.Hooks.OnFailure, -1
+		}
+	}
+}
+
+func (a *AutosyncManager) runHook(ctx context.Context, hook repo.JobHook, task *repo.TaskListingDB, job *repo.CronJobListingDB, processErr error) error {
+	switch hook.Type {
+	case repo.HookTypeWebhook:
+		return postWebhook(ctx, hook.URL, task, job, processErr)
+	case repo.HookTypeVerify:
+		return a.verifyTask(ctx, task, job)
+	case repo.HookTypeRunJob:
+		return a.triggerJob(ctx, job, hook.JobID)
+	default:
+		return fmt.Errorf("unknown hook type %q", hook.Type)
+	}
+}
+
+// postWebhook sends the result of the task to url
+func postWebhook(ctx context.Context, url string, task *repo.TaskListingDB, job *repo.CronJobListingDB, processErr error) error {
+	payload := map[string]interface{}{
+		"event":     "cron_successfully_completed",
+		"task_id":   task.ID,
+		"job_id":    job.ID,
+		"method":    job.Method,
+		"name":      job.Name,
+		"status":    repo.TaskStatusSuccess,
+		"execution": task.Execution,
+		"attempt":   task.AttemptNumber(),
+	}
+	if processErr != nil {
+		payload["event"] = "cron_failed"
+		payload["status"] = repo.TaskStatusFailed
+		payload["error"] = processErr.Error()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// verifyTask checks that every object the task uploaded exists with the size that was
+// uploaded
+func (a *AutosyncManager) verifyTask(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB) error {
+	bucket, ok := provider.Bucket(job.Method)
+	if !ok {
+		return fmt.Errorf("verification is not supported for %s backups", job.Method)
+	}
+
+	synced, err := a.store.TaskEventRepo.ListSyncedEvents(repo.TaskEventTypeAutoSync, task.ID)
+	if err != nil {
+		return err
+	}
+	if len(synced) == 0 {
+		return nil
+	}
+
+	store, err := satellite.OpenStore(ctx, job.StorxToken)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	for _, event := range synced {
+		object, err := store.Stat(ctx, bucket, event.ObjectKey)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", errVerificationFailed, event.ObjectKey, err)
+		}
+		// Compressed objects are compared by the size of what was compressed
+		size := object.Size
+		if object.Metadata.OriginalSize > 0 {
+			size = object.Metadata.OriginalSize
+		}
+		if event.Bytes > 0 && size != event.Bytes {
+			return fmt.Errorf("%w: %s has %d bytes, %d were uploaded",
+				errVerificationFailed, event.ObjectKey, size, event.Bytes)
+		}
+	}
+	return nil
+}
+
+// recordVerificationFailure shows a failed verification on the job and notifies the user
+func (a *AutosyncManager) recordVerificationFailure(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB, err error) {
+	if updateErr := a.store.CronJobRepo.UpdateCronJobFieldsForCron(job.ID, map[string]interface{}{
+		"message":        "Backup completed but verification failed",
+		"message_status": repo.JobMessageStatusError,
+	}); updateErr != nil {
+		logger.Error(ctx, "Failed to record verification failure",
+			logger.Int("job_id", int(job.ID)),
+			logger.ErrorField(updateErr),
+		)
+	}
+
+	priority := "high"
+	data := map[string]interface{}{
+		"event":   "cron_verification_failed",
+		"level":   4,
+		"task_id": task.ID,
+		"job_id":  job.ID,
+		"method":  job.Method,
+		"name":    job.Name,
+		"error":   err.Error(),
+	}
+	satellite.SendNotificationAsync(context.Background(), job.UserID, "Backup Verification Failed", fmt.Sprintf("Automatic backup for %s completed but could not be verified", job.Name), &priority, data, nil)
+}
+
+// triggerJob queues a task for another job of the same user, unless one is queued or
+// running already. Like a scheduled run, the job only runs once its dependencies succeeded.
+func (a *AutosyncManager) triggerJob(ctx context.Context, job *repo.CronJobListingDB, targetID uint) error {
+	target, err := a.store.CronJobRepo.GetJobByIDForUser(job.UserID, targetID)
+	if err != nil {
+		return err
+	}
+	if !target.Active {
+		return fmt.Errorf("job %d is not active", targetID)
+	}
+	met, err := a.store.CronJobRepo.DependenciesMet(targetID)
+	if err != nil {
+		return err
+	}
+	if !met {
+		return fmt.Errorf("job %d is waiting for its dependencies", targetID)
+	}
+
+	active, err := a.store.TaskRepo.HasActiveTaskForCronJob(targetID)
+	if err != nil {
+		return err
+	}
+	if active {
+		logger.Info(ctx, "Triggered job already has a task queued",
+			logger.Int("job_id", int(job.ID)),
+			logger.Int("triggered_job_id", int(targetID)),
+		)
+		return nil
+	}
+
+	if _, err := a.store.TaskRepo.CreateTaskForCronJob(targetID); err != nil {
+		return err
+	}
+	a.wakeDispatcher()
+	return nil
+}
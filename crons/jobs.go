@@ -4,12 +4,19 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/StorX2-0/Backup-Tools/db"
-	"github.com/StorX2-0/Backup-Tools/pkg/database"
+	"github.com/StorX2-0/Backup-Tools/pkg/backoff"
+	"github.com/StorX2-0/Backup-Tools/pkg/cluster"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/provider"
 	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	tasks "github.com/StorX2-0/Backup-Tools/tasks"
@@ -17,46 +24,88 @@ import (
 	"github.com/robfig/cron/v3"
 )
 
-type ProcessorInput struct {
-	InputData     *database.DbJson[map[string]interface{}]
-	Task          *repo.TaskListingDB
-	Job           *repo.CronJobListingDB
-	HeartBeatFunc func() error
-	Database      *db.PostgresDb
-}
+type ProcessorInput = provider.FullSyncInput
 
 type Processor interface {
 	Run(ProcessorInput) error
 }
 
-var processorMap = map[string]Processor{
-	"gmail":         NewGmailProcessor(),
-	"outlook":       NewOutlookProcessor(),
-	"psql_database": NewPsqlDatabaseProcessor(),
-}
+// sweepLockKey is the Postgres advisory lock key used to elect the replica that runs
+// the periodic sweeps
+const sweepLockKey int64 = 0x5354_4f52_5853_5750
 
 type AutosyncManager struct {
-	store *db.PostgresDb
+	store  *db.PostgresDb
+	pool   *WorkerPool
+	leader *cluster.LeaderElector
+	retry  *backoff.Policy
+
+	// dispatchMu serialises task claims so slot accounting stays consistent
+	dispatchMu sync.Mutex
+	wake       chan struct{}
+
+	scheduler *cron.Cron
+	// runCtx is the parent of every cron and task context; it is cancelled on shutdown
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+	stopping  atomic.Bool
 }
 
 func NewAutosyncManager(store *db.PostgresDb) *AutosyncManager {
-	return &AutosyncManager{store: store}
+	// Leader election is opt-in; a single replica runs the sweeps itself
+	electionEnabled := utils.GetEnvWithKey("LEADER_ELECTION") == "true"
+	sqlDB, err := store.GetGormDB().DB()
+	if err != nil && electionEnabled {
+		logger.Error(context.Background(), "Failed to get database handle for leader election, sweeps run on every instance", logger.ErrorField(err))
+		electionEnabled = false
+	}
+
+	runCtx, cancelRun := context.WithCancel(context.Background())
+
+	return &AutosyncManager{
+		store:     store,
+		pool:      NewWorkerPool(WorkerPoolConfigFromEnv()),
+		leader:    cluster.NewLeaderElector(sqlDB, sweepLockKey, electionEnabled),
+		retry:     RetryPolicyFromEnv(),
+		wake:      make(chan struct{}, 1),
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
+	}
 }
 
-// createCronContext creates a context with trace ID for cron jobs
-func createCronContext(operation string) context.Context {
+// isLeader reports whether this instance should run the periodic sweeps
+func (a *AutosyncManager) isLeader(ctx context.Context) bool {
+	leader, err := a.leader.IsLeader(ctx)
+	if err != nil {
+		logger.Error(ctx, "Leader election failed", logger.ErrorField(err))
+		return false
+	}
+	if !leader {
+		logger.Info(ctx, "Not the leader, skipping sweep", logger.String("instance_id", cluster.InstanceID()))
+	}
+	return leader
+}
+
+// createCronContext creates a context with trace ID for cron jobs. It is cancelled when
+// the manager shuts down.
+func (a *AutosyncManager) createCronContext(operation string) context.Context {
 	traceID := uuid.New().String()
-	ctx := logger.WithTraceID(context.Background(), traceID)
+	ctx := logger.WithTraceID(a.runCtx, traceID)
 	logger.Info(ctx, "Cron job started", logger.String("operation", operation))
 	return ctx
 }
 
 func (a *AutosyncManager) Start() {
+	go a.runDispatcher()
+
 	c := cron.New()
 
 	// Create tasks for pending jobs
 	c.AddFunc("@every 1m", func() {
-		ctx := createCronContext("create_tasks")
+		ctx := a.createCronContext("create_tasks")
+		if !a.isLeader(ctx) {
+			return
+		}
 		logger.Info(ctx, "Creating tasks for all pending jobs")
 		err := a.CreateTaskForAllPendingJobs(ctx)
 		if err != nil {
@@ -68,7 +117,7 @@ func (a *AutosyncManager) Start() {
 
 	// Process tasks
 	c.AddFunc("@every 1m", func() {
-		ctx := createCronContext("process_tasks")
+		ctx := a.createCronContext("process_tasks")
 		logger.Info(ctx, "Processing tasks")
 		err := a.ProcessTask(ctx)
 		if err != nil {
@@ -80,21 +129,28 @@ func (a *AutosyncManager) Start() {
 
 	// Check for missed heartbeats
 	c.AddFunc("@every 1m", func() {
-		ctx := createCronContext("missed_heartbeat_check")
+		ctx := a.createCronContext("missed_heartbeat_check")
+		if !a.isLeader(ctx) {
+			return
+		}
 		logger.Info(ctx, "Checking for missed heartbeats")
 
-		err := a.store.TaskRepo.MissedHeartbeatForTask()
+		stale, err := a.store.TaskRepo.MissedHeartbeatForTask()
 		if err != nil {
 			logger.Error(ctx, "Failed to check for missed heartbeats", logger.ErrorField(err))
 		} else {
-			logger.Info(ctx, "Successfully checked for missed heartbeats")
+			a.retryMissedHeartbeats(ctx, stale)
+			logger.Info(ctx, "Successfully checked for missed heartbeats", logger.Int("stale_tasks", len(stale)))
 		}
 
 	})
 
 	// Check for missed heartbeats for scheduled tasks
 	c.AddFunc("@every 1m", func() {
-		ctx := createCronContext("missed_scheduled_task_heartbeat_check")
+		ctx := a.createCronContext("missed_scheduled_task_heartbeat_check")
+		if !a.isLeader(ctx) {
+			return
+		}
 		logger.Info(ctx, "Checking for missed scheduled task heartbeats")
 
 		err := a.store.ScheduledTasksRepo.MissedHeartbeatForScheduledTask()
@@ -107,7 +163,7 @@ func (a *AutosyncManager) Start() {
 
 	// Process scheduled tasks
 	c.AddFunc("@every 30s", func() {
-		ctx := createCronContext("process_scheduled_tasks")
+		ctx := a.createCronContext("process_scheduled_tasks")
 		logger.Info(ctx, "Processing scheduled tasks")
 		scheduledTaskManager := tasks.NewScheduledTaskManager(a.store)
 		err := scheduledTaskManager.ProcessScheduledTasks(ctx)
@@ -118,6 +174,36 @@ func (a *AutosyncManager) Start() {
 		}
 	})
 
+	// Queue the periodic checks of the synced objects catalog
+	c.AddFunc("@every 1h", func() {
+		ctx := a.createCronContext("queue_catalog_checks")
+		if !a.isLeader(ctx) {
+			return
+		}
+		if err := a.QueueCatalogChecks(ctx); err != nil {
+			logger.Error(ctx, "Failed to queue catalog checks", logger.ErrorField(err))
+		}
+	})
+
+	// Run queued catalog checks, periodic and requested ones
+	c.AddFunc("@every 1m", func() {
+		ctx := a.createCronContext("process_catalog_checks")
+		if err := a.ProcessCatalogChecks(ctx); err != nil {
+			logger.Error(ctx, "Failed to process catalog checks", logger.ErrorField(err))
+		}
+	})
+
+	// Remove the backups retention policies no longer keep
+	c.AddFunc("@every 1h", func() {
+		ctx := a.createCronContext("enforce_retention")
+		if !a.isLeader(ctx) {
+			return
+		}
+		if err := a.EnforceRetention(ctx); err != nil {
+			logger.Error(ctx, "Failed to enforce retention", logger.ErrorField(err))
+		}
+	})
+
 	// c.AddFunc("@every 1m", func() {
 	// 	fmt.Println("Refreshing google auth token")
 	// 	err := a.RefreshGoogleAuthToken()
@@ -130,13 +216,72 @@ func (a *AutosyncManager) Start() {
 	// })
 
 	c.Start()
+	a.scheduler = c
 	logger.Info(context.Background(), "Cron scheduler started successfully")
 }
 
+// Shutdown stops claiming new tasks and asks running processors to stop at their next
+// checkpoint. Interrupted tasks are put back in the queue rather than marked as failed.
+// Tasks that are still running when ctx expires are released so another instance can
+// pick them up; their progress since the last saved checkpoint is redone.
+func (a *AutosyncManager) Shutdown(ctx context.Context) error {
+	// Taking the dispatch lock makes sure no claim is in flight once stopping is set
+	a.dispatchMu.Lock()
+	a.stopping.Store(true)
+	a.dispatchMu.Unlock()
+	a.cancelRun()
+
+	logger.Info(ctx, "Shutting down auto-sync manager",
+		logger.Int("busy_workers", a.pool.Stats().Busy),
+	)
+
+	drained := make(chan struct{})
+	go func() {
+		if a.scheduler != nil {
+			// Waits for the running cron jobs, including scheduled task processing
+			<-a.scheduler.Stop().Done()
+		}
+		a.pool.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+		logger.Info(ctx, "All running tasks stopped")
+	case <-ctx.Done():
+		err = fmt.Errorf("shutdown deadline exceeded before running tasks stopped: %w", ctx.Err())
+
+		released, releaseErr := a.store.TaskRepo.ReleaseClaimedTasks(cluster.InstanceID())
+		if releaseErr != nil {
+			logger.Error(ctx, "Failed to requeue running tasks", logger.ErrorField(releaseErr))
+		}
+		releasedScheduled, releaseErr := a.store.ScheduledTasksRepo.ReleaseClaimedScheduledTasks(cluster.InstanceID())
+		if releaseErr != nil {
+			logger.Error(ctx, "Failed to requeue running scheduled tasks", logger.ErrorField(releaseErr))
+		}
+		logger.Warn(ctx, "Requeued tasks that did not stop before the shutdown deadline",
+			logger.Int("tasks", int(released)),
+			logger.Int("scheduled_tasks", int(releasedScheduled)),
+		)
+	}
+
+	if resignErr := a.leader.Resign(context.Background()); resignErr != nil {
+		logger.Warn(ctx, "Failed to resign leadership", logger.ErrorField(resignErr))
+	}
+	return err
+}
+
 func (a *AutosyncManager) CreateTaskForAllPendingJobs(ctx context.Context) error {
 	var err error
 	defer monitor.Mon.Task()(&ctx)(&err)
 
+	if backfilled, err := a.store.CronJobRepo.BackfillNextRunAt(); err != nil {
+		logger.Warn(ctx, "Failed to backfill next run for jobs", logger.ErrorField(err))
+	} else if backfilled > 0 {
+		logger.Info(ctx, "Backfilled next run for jobs", logger.Int("count", backfilled))
+	}
+
 	jobIDs, err := a.store.CronJobRepo.GetJobsToProcess()
 	if err != nil {
 		return fmt.Errorf("failed to get jobs to process: %w", err)
@@ -150,7 +295,14 @@ func (a *AutosyncManager) CreateTaskForAllPendingJobs(ctx context.Context) error
 	successCount := 0
 	errorCount := 0
 
+	now := time.Now()
 	for _, jobID := range jobIDs {
+		// A job that became due before a blackout began waits for it to end
+		if release, blocked := jobID.BlackoutRelease(now, a.userSettings(ctx, jobID.UserID)); blocked {
+			a.postponeJob(ctx, &jobID, release)
+			continue
+		}
+
 		logger.Info(ctx, "Creating task for job",
 			logger.Int("job_id", int(jobID.ID)),
 			logger.String("job_name", jobID.Name),
@@ -183,6 +335,40 @@ func (a *AutosyncManager) CreateTaskForAllPendingJobs(ctx context.Context) error
 	return nil
 }
 
+// postponeJob moves a due job to the end of the blackout it fell into
+func (a *AutosyncManager) postponeJob(ctx context.Context, job *repo.CronJobListingDB, release time.Time) {
+	if err := a.store.CronJobRepo.UpdateCronJobFieldsForCron(job.ID, map[string]interface{}{
+		"next_run_at":    release,
+		"message":        fmt.Sprintf("Backup postponed to %s because of a blackout window", release.In(job.Location()).Format(time.RFC3339)),
+		"message_status": repo.JobMessageStatusInfo,
+	}); err != nil {
+		logger.Error(ctx, "Failed to postpone job",
+			logger.Int("job_id", int(job.ID)),
+			logger.ErrorField(err),
+		)
+		return
+	}
+
+	logger.Info(ctx, "Postponed job during blackout window",
+		logger.Int("job_id", int(job.ID)),
+		logger.String("release", release.Format(time.RFC3339)),
+	)
+}
+
+// userSettings returns the settings of a user, or nil if they cannot be read, in which
+// case only the global schedule settings apply
+func (a *AutosyncManager) userSettings(ctx context.Context, userID string) *repo.UserSettings {
+	settings, err := a.store.UserSettingsRepo.GetUserSettings(userID)
+	if err != nil {
+		logger.Warn(ctx, "Failed to get user settings",
+			logger.String("user_id", userID),
+			logger.ErrorField(err),
+		)
+		return nil
+	}
+	return settings
+}
+
 // func (a *AutosyncManager) RefreshGoogleAuthToken() error {
 // 	jobs, err := a.store.GetAllCronJobs()
 // 	if err != nil {
@@ -221,15 +407,35 @@ func (a *AutosyncManager) CreateTaskForAllPendingJobs(ctx context.Context) error
 // 	return errGroup.Err()
 // }
 
+// ProcessTask claims pushed tasks and hands them to the worker pool until the queue is
+// empty or no worker is free. It does not wait for the tasks to finish; a worker that
+// finishes wakes the dispatcher so the next task is picked up without waiting for the
+// next tick.
 func (a *AutosyncManager) ProcessTask(ctx context.Context) error {
 	var err error
 	defer monitor.Mon.Task()(&ctx)(&err)
 
-	processedCount := 0
+	a.dispatchMu.Lock()
+	defer a.dispatchMu.Unlock()
+
+	dispatchedCount := 0
 	errorCount := 0
 
-	for {
-		task, err := a.store.TaskRepo.GetPushedTask()
+	defer func() {
+		if depth, countErr := a.store.TaskRepo.CountPushedTasks(); countErr == nil {
+			a.pool.SetQueueDepth(depth)
+		} else {
+			logger.Warn(ctx, "Failed to count pushed tasks", logger.ErrorField(countErr))
+		}
+	}()
+
+	for a.pool.HasFreeWorker() {
+		if a.stopping.Load() {
+			logger.Info(ctx, "Shutting down, not claiming more tasks")
+			break
+		}
+
+		task, err := a.store.TaskRepo.ClaimPushedTask(cluster.InstanceID(), a.pool.SaturatedMethods())
 		if err != nil {
 			if strings.Contains(err.Error(), "record not found") {
 				logger.Info(ctx, "No tasks to process")
@@ -261,54 +467,197 @@ func (a *AutosyncManager) ProcessTask(ctx context.Context) error {
 			continue
 		}
 
-		// Send notification for cron task started running
-		priority := "normal"
-		data := map[string]interface{}{
-			"event":   "cron_started_running",
-			"level":   2,
-			"task_id": task.ID,
-			"job_id":  job.ID,
-			"method":  job.Method,
-			"name":    job.Name,
+		// Dispatch is serialised, so the slot checked above is still free unless the
+		// method limit was hit by this very task. Put it back in the queue in that case.
+		if !a.pool.TryAcquire(job.Method) {
+			if updateErr := a.store.TaskRepo.UpdateClaimedTask(task.ID, task.ClaimedBy, map[string]interface{}{
+				"status":           repo.TaskStatusPushed,
+				"claimed_by":       "",
+				"lease_expires_at": nil,
+			}); updateErr != nil {
+				logger.Error(ctx, "Failed to requeue task",
+					logger.Int("task_id", int(task.ID)),
+					logger.ErrorField(updateErr),
+				)
+			}
+			break
 		}
-		satellite.SendNotificationAsync(ctx, job.UserID, "Automatic Backup Started", fmt.Sprintf("Automatic backup for %s has started running", job.Name), &priority, data, nil)
 
-		// Process the task
-		processErr := a.processTask(ctx, task, job)
+		a.pool.Go(job.Method, func() {
+			a.runTask(ctx, task, job)
+		}, a.wakeDispatcher)
+		dispatchedCount++
+	}
 
-		// Update task status
-		if updateErr := a.UpdateTaskStatus(task, job, processErr); updateErr != nil {
-			logger.Error(ctx, "Failed to update task status",
-				logger.Int("task_id", int(task.ID)),
-				logger.ErrorField(updateErr),
-			)
-			// Continue with next task even if status update fails
-			continue
-		}
+	logger.Info(ctx, "Task dispatch completed",
+		logger.Int("dispatched", dispatchedCount),
+		logger.Int("errors", errorCount),
+		logger.Int("busy_workers", a.pool.Stats().Busy),
+	)
 
-		processedCount++
-		if processErr != nil {
-			errorCount++
+	return nil
+}
+
+// runTask processes a claimed task on a worker and records the result
+func (a *AutosyncManager) runTask(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB) {
+	// Send notification for cron task started running
+	priority := "normal"
+	data := map[string]interface{}{
+		"event":   "cron_started_running",
+		"level":   2,
+		"task_id": task.ID,
+		"job_id":  job.ID,
+		"method":  job.Method,
+		"name":    job.Name,
+	}
+	satellite.SendNotificationAsync(ctx, job.UserID, "Automatic Backup Started", fmt.Sprintf("Automatic backup for %s has started running", job.Name), &priority, data, nil)
+
+	tracker := progress.NewTracker(progress.Default, progress.Update{
+		UserID:   job.UserID,
+		TaskType: repo.TaskEventTypeAutoSync,
+		TaskID:   task.ID,
+		JobID:    job.ID,
+		Method:   job.Method,
+	})
+
+	// Process the task
+	processErr := a.processTask(ctx, task, job, tracker)
+
+	// A task paused or cancelled through the API stops at its next heartbeat; keep the
+	// status that was set and only record how far it got
+	if processErr != nil {
+		if current, err := a.store.TaskRepo.GetTaskByID(task.ID); err == nil {
+			if current.Status == repo.TaskStatusPaused || current.Status == repo.TaskStatusCancelled {
+				tracker.Finish(progress.StatusStopped)
+				a.recordStoppedTask(ctx, task, job)
+				return
+			}
+
+			// The lease lapsed and the task was failed and possibly retried elsewhere;
+			// its outcome is no longer this instance's to record
+			if current.ClaimedBy != task.ClaimedBy {
+				tracker.Finish(progress.StatusFailed)
+				logger.Warn(ctx, "Task claim lost, not recording result",
+					logger.Int("task_id", int(task.ID)),
+					logger.ErrorField(processErr),
+				)
+				return
+			}
 		}
 	}
 
-	// Record overall execution metrics
+	// A task interrupted by shutdown goes back in the queue and continues from the
+	// job's checkpoint on the next run
+	if processErr != nil && a.runCtx.Err() != nil {
+		tracker.Finish(progress.StatusStopped)
+		a.requeueInterruptedTask(ctx, task, job)
+		return
+	}
 
-	logger.Info(ctx, "Task processing completed",
-		logger.Int("processed", processedCount),
-		logger.Int("errors", errorCount),
+	if processErr != nil {
+		tracker.Finish(progress.StatusFailed)
+	} else {
+		tracker.Finish(progress.StatusCompleted)
+	}
+
+	// Update task status
+	if updateErr := a.UpdateTaskStatus(task, job, processErr); updateErr != nil {
+		logger.Error(ctx, "Failed to update task status",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(updateErr),
+		)
+	}
+}
+
+// recordStoppedTask saves the execution time of a task that was paused or cancelled and
+// the job's checkpoint, so a resumed task continues where this one stopped
+func (a *AutosyncManager) recordStoppedTask(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB) {
+	updates := map[string]interface{}{
+		"lease_expires_at": nil,
+	}
+	if task.StartTime != nil {
+		updates["execution"] = uint64(time.Since(*task.StartTime).Seconds())
+	}
+	if err := a.store.TaskRepo.UpdateClaimedTask(task.ID, task.ClaimedBy, updates); err != nil {
+		logger.Error(ctx, "Failed to save stopped task",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	if err := a.store.CronJobRepo.UpdateCronJobFieldsForCron(job.ID, map[string]interface{}{
+		"task_memory": job.TaskMemory,
+	}); err != nil {
+		logger.Error(ctx, "Failed to save job checkpoint",
+			logger.Int("job_id", int(job.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	logger.Info(ctx, "Task stopped",
+		logger.Int("task_id", int(task.ID)),
+		logger.String("status", task.Status),
+	)
+}
+
+// requeueInterruptedTask puts a task that stopped because of shutdown back in the queue
+// and saves the job's checkpoint. It does not count as a failed attempt.
+func (a *AutosyncManager) requeueInterruptedTask(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB) {
+	err := a.store.TaskRepo.TransitionTaskStatus(task.ID, []string{repo.TaskStatusRunning}, repo.TaskStatusPushed,
+		repo.JobStatusInQueue, "Automatic backup interrupted by server shutdown and requeued")
+	if err != nil {
+		logger.Error(ctx, "Failed to requeue interrupted task",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	if err := a.store.CronJobRepo.UpdateCronJobFieldsForCron(job.ID, map[string]interface{}{
+		"task_memory": job.TaskMemory,
+	}); err != nil {
+		logger.Error(ctx, "Failed to save job checkpoint",
+			logger.Int("job_id", int(job.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	logger.Info(ctx, "Task requeued after shutdown",
+		logger.Int("task_id", int(task.ID)),
+		logger.Int("job_id", int(job.ID)),
 	)
+}
 
-	return nil
+// wakeDispatcher asks the dispatcher loop to claim more tasks. It never blocks; if a
+// wake-up is already pending the call is a no-op.
+func (a *AutosyncManager) wakeDispatcher() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runDispatcher claims tasks whenever a worker frees up
+func (a *AutosyncManager) runDispatcher() {
+	for range a.wake {
+		ctx := a.createCronContext("dispatch_tasks")
+		if err := a.ProcessTask(ctx); err != nil {
+			logger.Error(ctx, "Failed to dispatch tasks", logger.ErrorField(err))
+		}
+	}
+}
+
+// WorkerStats returns a snapshot of the auto-sync worker pool
+func (a *AutosyncManager) WorkerStats() WorkerPoolStats {
+	return a.pool.Stats()
 }
 
-func (a *AutosyncManager) processTask(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB) error {
+func (a *AutosyncManager) processTask(ctx context.Context, task *repo.TaskListingDB, job *repo.CronJobListingDB, tracker *progress.Tracker) error {
 	var err error
 	defer monitor.Mon.Task()(&ctx)(&err)
 
-	processor, ok := processorMap[job.Method]
-	if !ok {
-		return fmt.Errorf("processor for method '%s' not found", job.Method)
+	p, ok := provider.Lookup(job.Method)
+	if !ok || !p.Capabilities().Has(provider.CapFullSync) {
+		return errs.New(errs.SourceBug, job.Method, "processor for method '%s' not found", job.Method)
 	}
 
 	logger.Info(ctx, "Executing processor for task",
@@ -316,14 +665,37 @@ func (a *AutosyncManager) processTask(ctx context.Context, task *repo.TaskListin
 		logger.String("method", job.Method),
 	)
 
-	// Record job execution start
+	events := provider.NewEventLog(a.store.TaskEventRepo, repo.TaskEventTypeAutoSync, task.ID)
+	defer events.Flush(ctx)
+	events.Track(tracker)
+	events.Info(fmt.Sprintf("started %s backup (attempt %d)", job.Method, task.AttemptNumber()))
 
-	err = processor.Run(ProcessorInput{
+	// One store serves every upload of the run, so the satellite handshake happens once
+	storage, err := satellite.OpenStore(ctx, job.StorxToken)
+	if err != nil {
+		events.Failed("", err)
+		return err
+	}
+	defer storage.Close()
+
+	input := ProcessorInput{
+		Ctx:       ctx,
 		InputData: job.InputData,
 		Job:       job,
 		Task:      task,
 		Database:  a.store,
+		Events:    events,
+		Storage:   storage,
+		SaveCheckpoint: func() error {
+			return a.store.CronJobRepo.UpdateCronJobFieldsForCron(job.ID, map[string]interface{}{
+				"task_memory": job.TaskMemory,
+			})
+		},
 		HeartBeatFunc: func() error {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("server shutting down, stopping execution: %w", err)
+			}
+
 			// Check if task is still running
 			currentTask, err := a.store.TaskRepo.GetTaskByID(task.ID)
 			if err != nil {
@@ -335,22 +707,44 @@ func (a *AutosyncManager) processTask(ctx context.Context, task *repo.TaskListin
 			}
 
 			// Update heartbeat
-			if err := a.store.TaskRepo.UpdateHeartBeatForTask(task.ID); err != nil {
+			if err := a.store.TaskRepo.UpdateHeartBeatForTask(task.ID, task.ClaimedBy); err != nil {
 				return fmt.Errorf("failed to update heartbeat: %w", err)
 			}
 
+			tracker.Heartbeat()
 			return nil
 		},
-	})
+	}
+	err = p.FullSync(input)
 
-	// Record completion status
 	if err != nil {
-		// Error handling
-	} else {
-		// Success handling
+		events.Failed("", err)
+		return err
 	}
+	events.Info("completed")
+
+	if listing, ok := input.SnapshotListing(); ok {
+		a.recordSnapshot(ctx, storage, listing, &repo.Snapshot{
+			UserID:   job.UserID,
+			Method:   job.Method,
+			TaskType: repo.TaskEventTypeAutoSync,
+			TaskID:   task.ID,
+			JobID:    job.ID,
+		})
+	}
+	return nil
+}
 
-	return err
+// recordSnapshot records the state of the backup after a successful run. The backup
+// itself succeeded, so failures are only logged.
+func (a *AutosyncManager) recordSnapshot(ctx context.Context, storage satellite.ObjectStore, listing provider.SnapshotListing, snapshot *repo.Snapshot) {
+	if err := provider.RecordSnapshot(ctx, a.store, storage, listing, snapshot); err != nil {
+		logger.Warn(ctx, "Failed to record snapshot",
+			logger.Int("task_id", int(snapshot.TaskID)),
+			logger.String("bucket", listing.Bucket),
+			logger.ErrorField(err),
+		)
+	}
 }
 
 func (a *AutosyncManager) UpdateTaskStatus(task *repo.TaskListingDB, job *repo.CronJobListingDB, processErr error) error {
@@ -374,10 +768,14 @@ func (a *AutosyncManager) UpdateTaskStatus(task *repo.TaskListingDB, job *repo.C
 	}
 
 	// Handle error case
+	var retryAt time.Time
+	retry := false
 	if processErr != nil {
 		task.Status = repo.TaskStatusFailed
 		task.Message = processErr.Error()
-		task.RetryCount++
+
+		category := classifyFailure(processErr, job)
+		retryAt, retry = a.retryAt(task, category)
 
 		// Record task failure
 		if job != nil {
@@ -386,23 +784,31 @@ func (a *AutosyncManager) UpdateTaskStatus(task *repo.TaskListingDB, job *repo.C
 			now := time.Now()
 			job.LastRun = &now
 
-			emailMessage := a.determineErrorMessage(processErr, job, task)
-			a.handleErrorScenarios(processErr, job, task)
+			emailMessage := a.determineErrorMessage(category, job, retry)
+			a.handleErrorScenarios(category, job, task, retryAt, retry)
 
-			// Send email notification
-			go satellite.SendEmailForBackupFailure(context.Background(), job.Name, emailMessage, job.Method)
+			// Only email once retries are exhausted; intermediate failures are still notified
+			if !retry {
+				go satellite.SendEmailForBackupFailure(context.Background(), job.Name, emailMessage, job.Method)
+				discardFailedDatabaseDump(ctx, job)
+			}
 
 			// Send generic notification with level 4
 			priority := "high"
 			data := map[string]interface{}{
-				"event":     "cron_failed",
-				"level":     4,
-				"task_id":   task.ID,
-				"job_id":    job.ID,
-				"method":    job.Method,
-				"name":      job.Name,
-				"error":     processErr.Error(),
-				"execution": task.Execution,
+				"event":          "cron_failed",
+				"level":          4,
+				"task_id":        task.ID,
+				"job_id":         job.ID,
+				"method":         job.Method,
+				"name":           job.Name,
+				"error":          processErr.Error(),
+				"error_category": category,
+				"execution":      task.Execution,
+				"attempt":        task.AttemptNumber(),
+			}
+			if retry {
+				data["retry_at"] = retryAt
 			}
 			satellite.SendNotificationAsync(context.Background(), job.UserID, "Automatic Backup Failed", fmt.Sprintf("Automatic backup for %s failed: %s", job.Name, emailMessage), &priority, data, nil)
 		}
@@ -424,10 +830,11 @@ func (a *AutosyncManager) UpdateTaskStatus(task *repo.TaskListingDB, job *repo.C
 	}
 
 	// Save task to database
-	if err := a.store.TaskRepo.UpdateTaskByID(task.ID, map[string]interface{}{
-		"status":    task.Status,
-		"message":   task.Message,
-		"execution": task.Execution,
+	if err := a.store.TaskRepo.UpdateClaimedTask(task.ID, task.ClaimedBy, map[string]interface{}{
+		"status":           task.Status,
+		"message":          task.Message,
+		"execution":        task.Execution,
+		"lease_expires_at": nil,
 	}); err != nil {
 		logger.Error(ctx, "Failed to save task status",
 			logger.Int("task_id", int(task.ID)),
@@ -444,6 +851,8 @@ func (a *AutosyncManager) UpdateTaskStatus(task *repo.TaskListingDB, job *repo.C
 			"last_run":       job.LastRun,
 			"storx_token":    job.StorxToken,
 			"active":         job.Active,
+			"next_run_at":    job.ComputeNextRunAt(time.Now(), a.userSettings(ctx, job.UserID)),
+			"task_memory":    job.TaskMemory,
 		}
 
 		// Update cron job status based on task status
@@ -464,99 +873,90 @@ func (a *AutosyncManager) UpdateTaskStatus(task *repo.TaskListingDB, job *repo.C
 		}
 	}
 
+	// The retry is queued after the job update so the job shows as queued again. Hooks
+	// only run for the final attempt.
+	if retry {
+		a.scheduleRetry(ctx, task, retryAt)
+	} else if job != nil {
+		a.runHooks(ctx, task, job, processErr)
+	}
+
 	logger.Info(ctx, "Task status updated",
 		logger.Int("task_id", int(task.ID)),
 		logger.String("status", string(task.Status)),
-		logger.Int("retry_count", int(task.RetryCount)),
+		logger.Int("attempt", task.AttemptNumber()),
+		logger.Bool("retry_scheduled", retry),
 	)
 
 	return nil
 }
 
-func (a *AutosyncManager) determineErrorMessage(processErr error, job *repo.CronJobListingDB, task *repo.TaskListingDB) string {
-	errMsg := processErr.Error()
-
-	switch {
-	case job.StorxToken == "":
-		return "Your automatic backup has been temporarily disabled due to insufficient permissions. Please update your StorX permissions and reactivate the backup from your dashboard."
+// accountName returns the name of the source account a job backs up, for user messages
+func accountName(method string) string {
+	switch method {
+	case "gmail", "google_drive", "google_photos":
+		return "Google"
+	case "outlook":
+		return "Microsoft Outlook"
+	default:
+		return method
+	}
+}
 
-	case strings.Contains(errMsg, "googleapi: Error 401"):
-		if task.RetryCount == repo.MaxRetryCount-1 {
-			return "Your automatic backup has been temporarily disabled due to invalid Google credentials. Please update your Google account permissions and reactivate the backup from your dashboard."
-		}
-		return "Your automatic backup encountered an authentication issue with Google. We're retrying the backup automatically."
+// determineErrorMessage returns the message sent to the user for a failed task
+func (a *AutosyncManager) determineErrorMessage(category errs.Category, job *repo.CronJobListingDB, retry bool) string {
+	switch category {
+	case errs.StoragePermission:
+		return "Your automatic backup has been temporarily disabled due to insufficient StorX permissions. Please update your StorX permissions and reactivate the backup from your dashboard."
 
-	case strings.Contains(errMsg, "Access is denied") ||
-		strings.Contains(errMsg, "invalid_grant") ||
-		(strings.Contains(errMsg, "microsoftgraph") && (strings.Contains(errMsg, "401") || strings.Contains(errMsg, "403"))) ||
-		(strings.Contains(errMsg, "Microsoft Graph API") && (strings.Contains(errMsg, "401") || strings.Contains(errMsg, "403"))):
-		if task.RetryCount == repo.MaxRetryCount-1 {
-			return "Your automatic backup has been temporarily disabled due to invalid Microsoft Outlook credentials. Please update your Outlook account permissions and reactivate the backup from your dashboard."
-		}
-		return "Your automatic backup encountered an authentication issue with Microsoft Outlook. We're retrying the backup automatically."
+	case errs.AuthRevoked:
+		name := accountName(job.Method)
+		return fmt.Sprintf("Your automatic backup has been temporarily disabled due to invalid %s credentials. Please update your %s account permissions and reactivate the backup from your dashboard.", name, name)
 
-	case strings.Contains(errMsg, "uplink: permission") || strings.Contains(errMsg, "uplink: invalid access"):
-		return "Your automatic backup has been temporarily disabled due to insufficient StorX permissions. Please update your StorX permissions and reactivate the backup from your dashboard."
+	case errs.Quota:
+		return "Your automatic backup failed because a storage or API quota has been exceeded. Please check your StorX storage limits. The backup will run again at its next scheduled time."
 
-	case strings.Contains(errMsg, "could not create bucket") ||
-		strings.Contains(errMsg, "tcp connector failed") ||
-		strings.Contains(errMsg, "connection attempt failed"):
-		return "Your automatic backup has been temporarily disabled due to network connectivity issues. Please check your internet connection and reactivate the backup from your dashboard."
+	case errs.Network, errs.RateLimited:
+		if retry {
+			return fmt.Sprintf("Your automatic backup was interrupted because %s. We're retrying the backup automatically.", category.Description())
+		}
+		return fmt.Sprintf("Your automatic backup failed repeatedly because %s. It will run again at its next scheduled time.", category.Description())
 
 	default:
-		return "Your automatic backup encountered a technical issue. We're retrying the backup automatically."
+		return "Your automatic backup encountered a technical issue. It will run again at its next scheduled time."
 	}
 }
 
-func (a *AutosyncManager) handleErrorScenarios(processErr error, job *repo.CronJobListingDB, task *repo.TaskListingDB) {
-	errMsg := processErr.Error()
-
-	switch {
-	case job.StorxToken == "":
+// handleErrorScenarios updates the job and task for a failed task. Failures that need the
+// user to reconnect an account deactivate the job straight away since retrying cannot fix them.
+func (a *AutosyncManager) handleErrorScenarios(category errs.Category, job *repo.CronJobListingDB, task *repo.TaskListingDB, retryAt time.Time, retry bool) {
+	switch category {
+	case errs.StoragePermission:
+		job.StorxToken = ""
 		job.Active = false
 		job.Message = "Insufficient permissions to upload to storx. Please update the permissions and reactivate the automatic backup"
 		task.Message = "Insufficient permissions to upload to storx. Please update the permissions. Automatic backup will be deactivated"
 
-	case strings.Contains(errMsg, "googleapi: Error 401"):
-		if task.RetryCount == repo.MaxRetryCount-1 {
+	case errs.AuthRevoked:
+		name := accountName(job.Method)
+		if job.InputData != nil && job.InputData.Json() != nil {
 			(*job.InputData.Json())["refresh_token"] = ""
-			job.Active = false
-			job.Message = "Invalid google credentials. Please update the credentials and reactivate the automatic backup"
-			task.Message = "Google Credentials are invalid. Please update the credentials. Automatic backup will be deactivated"
-		} else {
-			job.Message = "Invalid google credentials. Retrying..."
-			task.Message = "Google Credentials are invalid. Retrying..."
 		}
+		job.Active = false
+		job.Message = fmt.Sprintf("Invalid %s credentials. Please update the credentials and reactivate the automatic backup", name)
+		task.Message = fmt.Sprintf("%s Credentials are invalid. Please update the credentials. Automatic backup will be deactivated", name)
 
-	case strings.Contains(errMsg, "Access is denied") ||
-		strings.Contains(errMsg, "invalid_grant") ||
-		(strings.Contains(errMsg, "microsoftgraph") && (strings.Contains(errMsg, "401") || strings.Contains(errMsg, "403"))) ||
-		(strings.Contains(errMsg, "Microsoft Graph API") && (strings.Contains(errMsg, "401") || strings.Contains(errMsg, "403"))):
-		if task.RetryCount == repo.MaxRetryCount-1 {
-			(*job.InputData.Json())["refresh_token"] = ""
-			job.Active = false
-			job.Message = "Invalid Microsoft Outlook credentials. Please update the credentials and reactivate the automatic backup"
-			task.Message = "Microsoft Outlook Credentials are invalid. Please update the credentials. Automatic backup will be deactivated"
+	case errs.Network, errs.RateLimited:
+		if retry {
+			job.Message = fmt.Sprintf("Automatic backup failed because %s. Retrying at %s", category.Description(), retryAt.Format(time.RFC3339))
+			task.Message = fmt.Sprintf("Task failed: %s. Retry scheduled", task.Message)
 		} else {
-			job.Message = "Invalid Microsoft Outlook credentials. Retrying..."
-			task.Message = "Microsoft Outlook Credentials are invalid. Retrying..."
+			job.Message = fmt.Sprintf("Automatic backup failed because %s. It will run again at the next scheduled time", category.Description())
+			task.Message = fmt.Sprintf("Task failed: %s. No retries left", task.Message)
 		}
 
-	case strings.Contains(errMsg, "uplink: permission") || strings.Contains(errMsg, "uplink: invalid access"):
-		job.StorxToken = ""
-		job.Active = false
-		job.Message = "Insufficient permissions to upload to storx. Please update the permissions and reactivate the automatic backup"
-		task.Message = "Insufficient permissions to upload to storx. Please update the permissions. Automatic backup will be deactivated"
-
-	case strings.Contains(errMsg, "could not create bucket") ||
-		strings.Contains(errMsg, "tcp connector failed") ||
-		strings.Contains(errMsg, "connection attempt failed"):
-		job.Message = "Automatic backup failed due to network issues. Please check your connection and reactivate."
-		task.Message = "Task failed due to network connectivity issues. Job has been deactivated."
-
 	default:
-		job.Message = "Automatic backup encountered an error. Retrying..."
-		task.Message = "Task encountered an error. Retrying..."
-
+		job.Message = fmt.Sprintf("Automatic backup failed because %s. It will run again at the next scheduled time", category.Description())
+		task.Message = fmt.Sprintf("Task encountered an error: %s", task.Message)
 	}
 }
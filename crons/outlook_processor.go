@@ -7,6 +7,7 @@ import (
 
 	"github.com/StorX2-0/Backup-Tools/apps/outlook"
 	"github.com/StorX2-0/Backup-Tools/handler"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
@@ -45,27 +46,27 @@ func (o *outlookProcessor) Run(input ProcessorInput) error {
 
 	refreshToken, ok := (*input.Job.InputData.Json())["refresh_token"].(string)
 	if !ok {
-		return fmt.Errorf("refresh token not found")
+		return errs.New(errs.AuthRevoked, "outlook", "refresh token not found")
 	}
 
 	token, err := outlook.AuthTokenUsingRefreshToken(refreshToken)
 	if err != nil {
-		return fmt.Errorf("error while getting token from refresh token: %s", err)
+		return fmt.Errorf("error while getting token from refresh token: %w", err)
 	}
 
-	outlookClient, err := outlook.NewOutlookClientUsingToken(token)
+	outlookClient, err := outlook.NewOutlookClientUsingToken(token, input.Job.Name)
 	if err != nil {
-		return fmt.Errorf("error while creating outlook client: %s", err)
+		return fmt.Errorf("error while creating outlook client: %w", err)
 	}
 
 	// Get user details for creating folder structure
 	userDetails, err := outlookClient.GetCurrentUser()
 	if err != nil {
-		return fmt.Errorf("error getting user details: %s", err)
+		return fmt.Errorf("error getting user details: %w", err)
 	}
 
 	// Create placeholder file to initialize bucket
-	err = handler.UploadObjectAndSync(context.Background(), input.Database, input.Job.StorxToken, satellite.ReserveBucket_Outlook, userDetails.Mail+"/.file_placeholder", nil, input.Job.UserID)
+	err = handler.UploadObjectAndSync(context.Background(), input.Database, input.Storage, satellite.ReserveBucket_Outlook, userDetails.Mail+"/.file_placeholder", nil, input.Job.UserID, input.ObjectMetadata(satellite.ObjectMetadata{}))
 	if err != nil {
 		return err
 	}
@@ -117,19 +118,23 @@ func (o *outlookProcessor) Run(input ProcessorInput) error {
 			// Get full message with attachments
 			fullMsg, err := outlookClient.GetMessage(message.ID)
 			if err != nil {
+				input.Events.Failed(message.ID, err)
 				continue
 			}
 
 			b, err := json.Marshal(fullMsg)
 			if err != nil {
+				input.Events.Failed(message.ID, errs.Wrap(errs.SourceBug, "outlook", err))
 				continue
 			}
 
 			syncedData = true
-			err = handler.UploadObjectAndSync(context.Background(), input.Database, input.Job.StorxToken, satellite.ReserveBucket_Outlook, messagePath, b, input.Job.UserID)
+			err = handler.UploadObjectAndSync(context.Background(), input.Database, input.Storage, satellite.ReserveBucket_Outlook, messagePath, b, input.Job.UserID, input.ObjectMetadata(fullMsg.ObjectMetadata()))
 			if err != nil {
+				input.Events.Failed(message.ID, err)
 				continue
 			}
+			input.Events.Synced(message.ID, messagePath, int64(len(b)))
 
 			emptyLoopCount = 0
 			input.Job.TaskMemory.OutlookSyncCount++
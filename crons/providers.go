@@ -0,0 +1,60 @@
+package crons
+
+import (
+	"github.com/StorX2-0/Backup-Tools/handler"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	tasks "github.com/StorX2-0/Backup-Tools/tasks"
+)
+
+// init registers every backup source with the shared provider registry. A provider
+// that supports both auto-sync jobs and scheduled tasks is registered once with both
+// implementations so the handlers and managers see a single entry per method.
+func init() {
+	gmail, scheduledGmail := NewGmailProcessor(), tasks.NewScheduledGmailProcessor()
+	provider.MustRegister(&provider.Plugin{
+		Method:                "gmail",
+		BucketName:            satellite.ReserveBucket_Gmail,
+		Caps:                  provider.CapFullSync | provider.CapSelectiveSync | provider.CapRestore,
+		FullSyncFunc:          gmail.Run,
+		SelectiveSyncFunc:     scheduledGmail.Run,
+		FullEstimateFunc:      gmail.Estimate,
+		SelectiveEstimateFunc: scheduledGmail.Estimate,
+		AccountFunc:           handler.ProcessGoogleAccessToken,
+	})
+	outlook, scheduledOutlook := NewOutlookProcessor(), tasks.NewScheduledOutlookProcessor()
+	provider.MustRegister(&provider.Plugin{
+		Method:                "outlook",
+		BucketName:            satellite.ReserveBucket_Outlook,
+		Caps:                  provider.CapFullSync | provider.CapSelectiveSync | provider.CapRestore,
+		FullSyncFunc:          outlook.Run,
+		SelectiveSyncFunc:     scheduledOutlook.Run,
+		FullEstimateFunc:      outlook.Estimate,
+		SelectiveEstimateFunc: scheduledOutlook.Estimate,
+		AccountFunc:           handler.ProcessOutlookAccessToken,
+	})
+	provider.MustRegister(&provider.Plugin{
+		Method:       "psql_database",
+		BucketName:   "database",
+		Caps:         provider.CapFullSync | provider.CapRetention,
+		FullSyncFunc: NewPsqlDatabaseProcessor().Run,
+	})
+	drive := tasks.NewScheduledGoogleDriveProcessor()
+	provider.MustRegister(&provider.Plugin{
+		Method:                "google_drive",
+		BucketName:            satellite.ReserveBucket_Drive,
+		Caps:                  provider.CapSelectiveSync | provider.CapRestore,
+		SelectiveSyncFunc:     drive.Run,
+		SelectiveEstimateFunc: drive.Estimate,
+		AccountFunc:           handler.ProcessGoogleAccessToken,
+	})
+	photos := tasks.NewScheduledGooglePhotosProcessor()
+	provider.MustRegister(&provider.Plugin{
+		Method:                "google_photos",
+		BucketName:            satellite.ReserveBucket_Photos,
+		Caps:                  provider.CapSelectiveSync | provider.CapRestore,
+		SelectiveSyncFunc:     photos.Run,
+		SelectiveEstimateFunc: photos.Estimate,
+		AccountFunc:           handler.ProcessGoogleAccessToken,
+	})
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"time"
 
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 )
 
@@ -32,42 +37,216 @@ func (d *psqlDatabaseProcessor) Run(input ProcessorInput) error {
 		return fmt.Errorf("database_name is required")
 	}
 
-	upload, err := satellite.GetUploader(context.TODO(), input.Job.StorxToken, "database", fmt.Sprintf("postgresql/%v_%v.sql.tar.gz", databaseName, time.Now().Unix()))
+	// The dump is compressed while it is spooled, so the spool has the size the upload
+	// needs. A dump whose upload was interrupted is resumed by the same retry chain
+	// instead of dumped again. The object is stored with its compression in the metadata
+	// and read back decompressed, so its key names plain SQL.
+	spoolPath := databaseSpoolPath(input.Job)
+	memory := &input.Job.TaskMemory
+	checkpoint := resumableUpload(memory, input.Task, spoolPath)
+	if checkpoint == nil && memory.DatabaseUpload != nil {
+		// Left behind by a run that failed for good; its parts and dump are stale
+		discardDatabaseUpload(ctx, input.Storage, memory, spoolPath)
+		if err = input.SaveCheckpoint(); err != nil {
+			return err
+		}
+	}
+
+	objectKey := ""
+	var originalSize int64
+	if checkpoint != nil {
+		objectKey = checkpoint.Key
+		originalSize, err = dumpSize(spoolPath, input.Job.Compression)
+		if err != nil {
+			return err
+		}
+	} else {
+		objectKey = fmt.Sprintf("postgresql/%v_%v.sql", databaseName, time.Now().Unix())
+		originalSize, err = d.dump(input, spoolPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	spool, err := os.Stat(spoolPath)
 	if err != nil {
 		return err
 	}
 
-	cmd, err := d.GetCommand(input)
+	metadata := input.ObjectMetadata(satellite.ObjectMetadata{
+		Source:         "postgresql",
+		SourceID:       databaseName,
+		SourceModified: spool.ModTime().UTC().Format(time.RFC3339),
+		MimeType:       "application/sql",
+	})
+	metadata.OriginalSize = originalSize
+
+	err = input.HeartBeatFunc()
 	if err != nil {
 		return err
 	}
 
-	pipe, err := cmd.StdoutPipe()
+	written, err := satellite.UploadResumable(ctx, input.Storage, satellite.ResumableUpload{
+		Bucket:   "database",
+		Key:      objectKey,
+		Version:  fmt.Sprintf("%d/%d", spool.ModTime().Unix(), spool.Size()),
+		Size:     spool.Size(),
+		Metadata: metadata,
+		Open: func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+			f, err := os.Open(spoolPath)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				f.Close()
+				return nil, err
+			}
+			return f, nil
+		},
+		Checkpoint: checkpoint,
+		Save: func(checkpoint *satellite.UploadCheckpoint) error {
+			if err := input.HeartBeatFunc(); err != nil {
+				return err
+			}
+			memory.DatabaseUpload = checkpoint
+			memory.DatabaseUploadTaskID = 0
+			if checkpoint != nil {
+				memory.DatabaseUploadTaskID = input.Task.OriginalTaskID()
+			}
+			return input.SaveCheckpoint()
+		},
+	})
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(upload, pipe)
+	_ = os.Remove(spoolPath)
+	input.Events.Synced(databaseName, objectKey, written)
+	return nil
+}
+
+// databaseSpoolPath is where the dump of a job is spooled until it is uploaded
+func databaseSpoolPath(job *repo.CronJobListingDB) string {
+	return filepath.Join("./cache", "database",
+		fmt.Sprintf("job_%d.sql%s", job.ID, compress.Extension(job.Compression)))
+}
+
+// resumableUpload returns the upload checkpoint a run of task can resume: one saved by
+// the task's retry chain whose dump is still spooled at spoolPath
+func resumableUpload(memory *repo.TaskMemory, task *repo.TaskListingDB, spoolPath string) *satellite.UploadCheckpoint {
+	if memory.DatabaseUpload == nil || memory.DatabaseUploadTaskID != task.OriginalTaskID() {
+		return nil
+	}
+	if _, err := os.Stat(spoolPath); err != nil {
+		return nil
+	}
+	return memory.DatabaseUpload
+}
+
+// discardDatabaseUpload aborts the upload in memory, clears it and deletes the spooled
+// dump. store may be nil if it could not be opened; the satellite drops the parts of
+// abandoned uploads eventually.
+func discardDatabaseUpload(ctx context.Context, store satellite.ObjectStore, memory *repo.TaskMemory, spoolPath string) {
+	if memory.DatabaseUpload != nil && store != nil {
+		satellite.AbortUpload(ctx, store, memory.DatabaseUpload)
+	}
+	memory.DatabaseUpload = nil
+	memory.DatabaseUploadTaskID = 0
+	_ = os.Remove(spoolPath)
+}
+
+// discardFailedDatabaseDump cleans up after a database backup that failed for good, so
+// the next run dumps the database again
+func discardFailedDatabaseDump(ctx context.Context, job *repo.CronJobListingDB) {
+	if job.Method != "psql_database" {
+		return
+	}
+
+	var store satellite.ObjectStore
+	if job.TaskMemory.DatabaseUpload != nil && job.StorxToken != "" {
+		opened, err := satellite.OpenStore(ctx, job.StorxToken)
+		if err != nil {
+			logger.Warn(ctx, "Failed to open store to abort database upload",
+				logger.Int("job_id", int(job.ID)),
+				logger.ErrorField(err),
+			)
+		} else {
+			defer opened.Close()
+			store = opened
+		}
+	}
+	discardDatabaseUpload(ctx, store, &job.TaskMemory, databaseSpoolPath(job))
+}
+
+// dump writes the dump of the database to path, compressed as the job is configured to,
+// and returns the size of the dump before compression. The file only appears once the
+// dump finished successfully.
+func (d *psqlDatabaseProcessor) dump(input ProcessorInput, path string) (int64, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = cmd.Start()
+	partPath := path + ".part"
+	f, err := os.Create(partPath)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer os.Remove(partPath)
 
-	err = cmd.Wait()
+	cmd, err := d.GetCommand(input)
 	if err != nil {
-		return err
+		f.Close()
+		return 0, err
 	}
+	w, err := compress.NewWriter(f, input.Job.Compression)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	counter := &countingWriter{w: w}
+	cmd.Stdout = counter
 
-	err = upload.Commit()
+	err = cmd.Run()
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return counter.n, os.Rename(partPath, path)
+}
+
+// dumpSize returns the size before compression of a dump spooled by an earlier attempt
+func dumpSize(path, algorithm string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r, err := compress.NewReader(f, algorithm)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(io.Discard, r)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 func (p *psqlDatabaseProcessor) GetCommand(input ProcessorInput) (*exec.Cmd, error) {
@@ -0,0 +1,58 @@
+package crons
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumableUpload(t *testing.T) {
+	spoolPath := filepath.Join(t.TempDir(), "job_1.sql.gz")
+	require.NoError(t, os.WriteFile(spoolPath, []byte("dump"), 0o644))
+
+	checkpoint := &satellite.UploadCheckpoint{Bucket: "database", Key: "postgresql/db_1.sql", UploadID: "upload", Parts: 2}
+	originalID := uint(5)
+	memory := &repo.TaskMemory{DatabaseUpload: checkpoint, DatabaseUploadTaskID: originalID}
+
+	requeued := &repo.TaskListingDB{}
+	requeued.ID = originalID
+	retry := &repo.TaskListingDB{RetryOfTaskID: &originalID}
+	retry.ID = 6
+	nextRun := &repo.TaskListingDB{}
+	nextRun.ID = 9
+
+	assert.Equal(t, checkpoint, resumableUpload(memory, requeued, spoolPath))
+	assert.Equal(t, checkpoint, resumableUpload(memory, retry, spoolPath))
+	assert.Nil(t, resumableUpload(memory, nextRun, spoolPath), "a run of another task dumps fresh")
+	assert.Nil(t, resumableUpload(memory, retry, spoolPath+".missing"), "nothing to resume without the spooled dump")
+	assert.Nil(t, resumableUpload(&repo.TaskMemory{DatabaseUpload: checkpoint}, retry, spoolPath), "checkpoints without a task are never resumed")
+}
+
+func TestNewRunAfterFailedRunDumpsFresh(t *testing.T) {
+	spoolPath := filepath.Join(t.TempDir(), "job_1.sql.gz")
+	require.NoError(t, os.WriteFile(spoolPath, []byte("dump"), 0o644))
+
+	failed := &repo.TaskListingDB{}
+	failed.ID = 5
+	memory := &repo.TaskMemory{
+		DatabaseUpload:       &satellite.UploadCheckpoint{Bucket: "database", Key: "postgresql/db_1.sql", UploadID: "upload", Parts: 2},
+		DatabaseUploadTaskID: failed.OriginalTaskID(),
+	}
+
+	// The final attempt failed: the checkpoint and the spooled dump go away
+	discardDatabaseUpload(context.Background(), satellite.NewMemoryStore(), memory, spoolPath)
+	assert.Nil(t, memory.DatabaseUpload)
+	assert.Zero(t, memory.DatabaseUploadTaskID)
+	_, err := os.Stat(spoolPath)
+	assert.True(t, os.IsNotExist(err))
+
+	next := &repo.TaskListingDB{}
+	next.ID = 9
+	assert.Nil(t, resumableUpload(memory, next, spoolPath))
+}
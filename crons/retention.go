@@ -0,0 +1,91 @@
+package crons
+
+import (
+	"context"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// EnforceRetention removes the backups the retention policies of the jobs no longer
+// keep, from the bucket and from the synced objects catalog
+func (a *AutosyncManager) EnforceRetention(ctx context.Context) error {
+	jobs, err := a.store.CronJobRepo.GetAllCronJobs()
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		if !job.Retention.Enabled() || job.StorxToken == "" || !provider.Supports(job.Method, provider.CapRetention) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := a.enforceJobRetention(ctx, job); err != nil {
+			logger.Error(ctx, "Failed to enforce retention",
+				logger.Int("job_id", int(job.ID)),
+				logger.String("method", job.Method),
+				logger.ErrorField(err),
+			)
+		}
+	}
+	return nil
+}
+
+func (a *AutosyncManager) enforceJobRetention(ctx context.Context, job *repo.CronJobListingDB) error {
+	store, err := satellite.OpenStore(ctx, job.StorxToken)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	decisions, err := provider.RetentionPlan(ctx, store, job, job.Retention, time.Now())
+	if err != nil {
+		return err
+	}
+
+	bucket, _ := provider.Bucket(job.Method)
+	var removed []string
+	var freed int64
+	for _, decision := range decisions {
+		if decision.Keep {
+			continue
+		}
+		if err := store.Delete(ctx, bucket, decision.Key); err != nil {
+			logger.Warn(ctx, "Failed to delete expired backup",
+				logger.Int("job_id", int(job.ID)),
+				logger.String("object_key", decision.Key),
+				logger.ErrorField(err),
+			)
+			continue
+		}
+		removed = append(removed, decision.Key)
+		freed += decision.Size
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	// The objects are gone, so a catalog that still lists them would be repaired by the
+	// next catalog check anyway
+	if err := a.store.SyncedObjectRepo.DeleteSyncedObjectsByKeys(job.UserID, bucket, removed); err != nil {
+		logger.Warn(ctx, "Failed to remove expired backups from the catalog",
+			logger.Int("job_id", int(job.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	logger.Info(ctx, "Removed expired backups",
+		logger.Int("job_id", int(job.ID)),
+		logger.String("bucket", bucket),
+		logger.Int("removed", len(removed)),
+		logger.Int("kept", len(decisions)-len(removed)),
+		logger.Int("freed_bytes", int(freed)),
+	)
+	return nil
+}
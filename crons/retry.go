@@ -0,0 +1,107 @@
+package crons
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/backoff"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/repo"
+)
+
+// Retry defaults, used when the corresponding AUTOSYNC_RETRY_* variable is unset or invalid
+const (
+	defaultRetryBaseDelay = 2 * time.Minute
+	defaultRetryMaxDelay  = time.Hour
+)
+
+// RetryPolicyFromEnv reads the auto-sync retry policy from the environment:
+//
+//	AUTOSYNC_RETRY_MAX=3           retries after the first attempt, 0 disables retries
+//	AUTOSYNC_RETRY_BASE_DELAY=2m   delay before the first retry, doubled for every retry
+//	AUTOSYNC_RETRY_MAX_DELAY=1h    cap on the delay
+func RetryPolicyFromEnv() *backoff.Policy {
+	maxRetries := repo.MaxRetryCount
+	if v := utils.GetEnvWithKey("AUTOSYNC_RETRY_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxRetries = n
+		} else {
+			logger.Warn(context.Background(), "Invalid AUTOSYNC_RETRY_MAX value, using default",
+				logger.String("value", v), logger.Int("default", repo.MaxRetryCount))
+		}
+	}
+
+	return backoff.NewPolicy(
+		durationFromEnv("AUTOSYNC_RETRY_BASE_DELAY", defaultRetryBaseDelay),
+		durationFromEnv("AUTOSYNC_RETRY_MAX_DELAY", defaultRetryMaxDelay),
+		maxRetries,
+	)
+}
+
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	v := utils.GetEnvWithKey(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		logger.Warn(context.Background(), "Invalid duration, using default",
+			logger.String("key", key), logger.String("value", v), logger.String("default", def.String()))
+		return def
+	}
+	return d
+}
+
+// classifyFailure returns the error category that decides how a failed task is handled.
+// A job without a StorX token cannot upload anything, whatever the error was.
+func classifyFailure(err error, job *repo.CronJobListingDB) errs.Category {
+	if job != nil && job.StorxToken == "" {
+		return errs.StoragePermission
+	}
+	return errs.CategoryOf(err)
+}
+
+// retryAt returns when the next attempt of a failed task should run, or false if the task
+// is not retried
+func (a *AutosyncManager) retryAt(task *repo.TaskListingDB, category errs.Category) (time.Time, bool) {
+	if !category.Retryable() || !a.retry.ShouldRetry(task.AttemptNumber()) {
+		return time.Time{}, false
+	}
+	return time.Now().Add(a.retry.Delay(task.AttemptNumber())), true
+}
+
+// scheduleRetry queues the next attempt of a failed task
+func (a *AutosyncManager) scheduleRetry(ctx context.Context, task *repo.TaskListingDB, at time.Time) {
+	retry, err := a.store.TaskRepo.CreateRetryTask(task, at)
+	if err != nil {
+		logger.Error(ctx, "Failed to schedule retry",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(err),
+		)
+		return
+	}
+
+	logger.Info(ctx, "Retry scheduled",
+		logger.Int("task_id", int(task.ID)),
+		logger.Int("retry_task_id", int(retry.ID)),
+		logger.Int("attempt", int(retry.Attempt)),
+		logger.String("not_before", at.Format(time.RFC3339)),
+	)
+}
+
+// retryMissedHeartbeats schedules retries for tasks the heartbeat sweep marked as failed.
+// A stuck task is treated like a network failure; its worker most likely lost its
+// connection or was restarted.
+func (a *AutosyncManager) retryMissedHeartbeats(ctx context.Context, stale []repo.TaskListingDB) {
+	for i := range stale {
+		task := &stale[i]
+		at, ok := a.retryAt(task, errs.Network)
+		if !ok {
+			continue
+		}
+		a.scheduleRetry(ctx, task, at)
+	}
+}
@@ -0,0 +1,69 @@
+package crons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/backoff"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	job := &repo.CronJobListingDB{StorxToken: "grant"}
+	for _, tc := range []struct {
+		name string
+		err  error
+		job  *repo.CronJobListingDB
+		want errs.Category
+	}{
+		{"network", errs.New(errs.Network, "google", "connection reset"), job, errs.Network},
+		{"rate limited", fmt.Errorf("list: %w", errs.New(errs.RateLimited, "outlook", "throttled")), job, errs.RateLimited},
+		{"deadline", context.DeadlineExceeded, job, errs.Network},
+		{"auth revoked", errs.New(errs.AuthRevoked, "google", "invalid_grant"), job, errs.AuthRevoked},
+		{"source bug", errs.New(errs.SourceBug, "outlook", "bad request"), job, errs.SourceBug},
+		{"quota", errs.New(errs.Quota, "storx", "out of space"), job, errs.Quota},
+		{"uncategorised", errors.New("boom"), job, errs.Unknown},
+		{"no storx token", errs.New(errs.Network, "google", "connection reset"), &repo.CronJobListingDB{}, errs.StoragePermission},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, classifyFailure(tc.err, tc.job))
+		})
+	}
+}
+
+func TestRetryAt(t *testing.T) {
+	a := &AutosyncManager{retry: backoff.NewPolicy(time.Minute, time.Hour, 2)}
+	attempt := func(n uint) *repo.TaskListingDB { return &repo.TaskListingDB{Attempt: n} }
+
+	for _, tc := range []struct {
+		name     string
+		task     *repo.TaskListingDB
+		category errs.Category
+		retry    bool
+	}{
+		{"transient first attempt", attempt(0), errs.Network, true},
+		{"rate limited", attempt(1), errs.RateLimited, true},
+		{"last retry", attempt(2), errs.Network, true},
+		{"max attempts reached", attempt(3), errs.Network, false},
+		{"permanent", attempt(1), errs.SourceBug, false},
+		{"unknown", attempt(1), errs.Unknown, false},
+		{"auth revoked", attempt(1), errs.AuthRevoked, false},
+		{"storage permission", attempt(1), errs.StoragePermission, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := time.Now()
+			at, retry := a.retryAt(tc.task, tc.category)
+			assert.Equal(t, tc.retry, retry)
+			if retry {
+				assert.True(t, at.After(before), "retries are scheduled after a delay")
+			} else {
+				assert.True(t, at.IsZero())
+			}
+		})
+	}
+}
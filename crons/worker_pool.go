@@ -0,0 +1,250 @@
+package crons
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultWorkerCount is used when AUTOSYNC_WORKERS is unset or invalid
+const defaultWorkerCount = 4
+
+// WorkerPoolConfig configures the auto-sync worker pool
+type WorkerPoolConfig struct {
+	// Workers is the maximum number of tasks processed at the same time
+	Workers int
+	// MethodLimits caps the number of concurrent tasks per method (e.g. gmail=2).
+	// Methods without an entry are only bound by Workers.
+	MethodLimits map[string]int
+}
+
+// WorkerPoolConfigFromEnv reads the pool configuration from the environment:
+//
+//	AUTOSYNC_WORKERS=8
+//	AUTOSYNC_METHOD_LIMITS=gmail=4,outlook=2,psql_database=1
+func WorkerPoolConfigFromEnv() WorkerPoolConfig {
+	cfg := WorkerPoolConfig{
+		Workers:      defaultWorkerCount,
+		MethodLimits: make(map[string]int),
+	}
+
+	if v := utils.GetEnvWithKey("AUTOSYNC_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Workers = n
+		} else {
+			logger.Warn(context.Background(), "Invalid AUTOSYNC_WORKERS value, using default",
+				logger.String("value", v), logger.Int("default", defaultWorkerCount))
+		}
+	}
+
+	for _, pair := range strings.Split(utils.GetEnvWithKey("AUTOSYNC_METHOD_LIMITS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		method, limit, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if !ok || err != nil || n <= 0 {
+			logger.Warn(context.Background(), "Ignoring invalid AUTOSYNC_METHOD_LIMITS entry", logger.String("entry", pair))
+			continue
+		}
+		cfg.MethodLimits[strings.TrimSpace(method)] = n
+	}
+
+	return cfg
+}
+
+// WorkerPoolStats is a point-in-time snapshot of the worker pool
+type WorkerPoolStats struct {
+	Workers    int            `json:"workers"`
+	Busy       int            `json:"busy"`
+	BusyMethod map[string]int `json:"busy_by_method"`
+	QueueDepth int64          `json:"queue_depth"`
+}
+
+// WorkerPool runs auto-sync tasks concurrently, bounded by a global worker count and
+// optional per-method limits. Slots are reserved before a task is claimed from the
+// queue, so a claimed task always has a worker to run on.
+type WorkerPool struct {
+	cfg WorkerPoolConfig
+
+	mu         sync.Mutex
+	busy       int
+	busyMethod map[string]int
+	queueDepth int64
+
+	wg sync.WaitGroup
+
+	busyGauge        prometheus.Gauge
+	utilisationGauge prometheus.Gauge
+	queueGauge       prometheus.Gauge
+	methodGauge      *prometheus.GaugeVec
+}
+
+// NewWorkerPool creates a worker pool and registers its metrics
+func NewWorkerPool(cfg WorkerPoolConfig) *WorkerPool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkerCount
+	}
+	if cfg.MethodLimits == nil {
+		cfg.MethodLimits = make(map[string]int)
+	}
+
+	p := &WorkerPool{
+		cfg:        cfg,
+		busyMethod: make(map[string]int),
+		busyGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "autosync_workers_busy",
+			Help: "Number of auto-sync workers currently processing a task",
+		}),
+		utilisationGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "autosync_workers_utilisation_ratio",
+			Help: "Busy auto-sync workers divided by the pool size",
+		}),
+		queueGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "autosync_queue_depth",
+			Help: "Number of auto-sync tasks waiting for a worker",
+		}),
+		methodGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "autosync_workers_busy_by_method",
+			Help: "Number of auto-sync workers currently processing a task, by method",
+		}, []string{"method"}),
+	}
+
+	workersGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "autosync_workers_total",
+		Help: "Size of the auto-sync worker pool",
+	})
+	workersGauge.Set(float64(cfg.Workers))
+
+	for name, collector := range map[string]prometheus.Collector{
+		"autosync_workers_total":             workersGauge,
+		"autosync_workers_busy":              p.busyGauge,
+		"autosync_workers_utilisation_ratio": p.utilisationGauge,
+		"autosync_queue_depth":               p.queueGauge,
+		"autosync_workers_busy_by_method":    p.methodGauge,
+	} {
+		if err := monitor.RegisterGlobalCustomMetric(name, collector); err != nil {
+			logger.Warn(context.Background(), "Failed to register worker pool metric",
+				logger.String("metric", name), logger.ErrorField(err))
+		}
+	}
+
+	return p
+}
+
+// HasFreeWorker reports whether at least one worker is idle
+func (p *WorkerPool) HasFreeWorker() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.busy < p.cfg.Workers
+}
+
+// SaturatedMethods returns the methods that have reached their concurrency limit.
+// Tasks for these methods should stay in the queue until a worker frees up.
+func (p *WorkerPool) SaturatedMethods() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var methods []string
+	for method, limit := range p.cfg.MethodLimits {
+		if p.busyMethod[method] >= limit {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// TryAcquire reserves a worker for method. It returns false if the pool or the
+// method is at capacity.
+func (p *WorkerPool) TryAcquire(method string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.busy >= p.cfg.Workers {
+		return false
+	}
+	if limit, ok := p.cfg.MethodLimits[method]; ok && p.busyMethod[method] >= limit {
+		return false
+	}
+
+	p.busy++
+	p.busyMethod[method]++
+	p.updateGauges(method)
+	return true
+}
+
+// Go runs fn on a worker previously reserved with TryAcquire and releases the
+// worker when fn returns. onDone, if set, is called after the worker is released.
+func (p *WorkerPool) Go(method string, fn func(), onDone func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() {
+			p.release(method)
+			if onDone != nil {
+				onDone()
+			}
+		}()
+		fn()
+	}()
+}
+
+func (p *WorkerPool) release(method string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.busy--
+	p.busyMethod[method]--
+	if p.busyMethod[method] <= 0 {
+		delete(p.busyMethod, method)
+	}
+	p.updateGauges(method)
+}
+
+// updateGauges must be called with p.mu held
+func (p *WorkerPool) updateGauges(method string) {
+	p.busyGauge.Set(float64(p.busy))
+	p.utilisationGauge.Set(float64(p.busy) / float64(p.cfg.Workers))
+	p.methodGauge.WithLabelValues(method).Set(float64(p.busyMethod[method]))
+}
+
+// SetQueueDepth records the number of tasks waiting for a worker
+func (p *WorkerPool) SetQueueDepth(depth int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queueDepth = depth
+	p.queueGauge.Set(float64(depth))
+}
+
+// Stats returns a snapshot of the pool
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	busyMethod := make(map[string]int, len(p.busyMethod))
+	for method, n := range p.busyMethod {
+		busyMethod[method] = n
+	}
+
+	return WorkerPoolStats{
+		Workers:    p.cfg.Workers,
+		Busy:       p.busy,
+		BusyMethod: busyMethod,
+		QueueDepth: p.queueDepth,
+	}
+}
+
+// Wait blocks until all running tasks have finished
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
@@ -13,6 +13,10 @@ type PostgresDb struct {
 	AuthRepo           *repo.AuthRepository
 	SyncedObjectRepo   *repo.SyncedObjectRepository
 	WebhookEventRepo   *repo.WebhookEventRepository
+	UserSettingsRepo   *repo.UserSettingsRepository
+	TaskEventRepo      *repo.TaskEventRepository
+	CatalogCheckRepo   *repo.CatalogCheckRepository
+	SnapshotRepo       *repo.SnapshotRepository
 }
 
 func NewPostgresStore(dsn string, queryLogging bool) (*PostgresDb, error) {
@@ -30,10 +34,17 @@ func NewPostgresStore(dsn string, queryLogging bool) (*PostgresDb, error) {
 		AuthRepo:           repo.NewAuthRepository(db),
 		SyncedObjectRepo:   repo.NewSyncedObjectRepository(db),
 		WebhookEventRepo:   repo.NewWebhookEventRepository(db),
+		UserSettingsRepo:   repo.NewUserSettingsRepository(db),
+		TaskEventRepo:      repo.NewTaskEventRepository(db),
+		CatalogCheckRepo:   repo.NewCatalogCheckRepository(db),
+		SnapshotRepo:       repo.NewSnapshotRepository(db),
 	}, nil
 }
 
 func (s *PostgresDb) Migrate() error {
+	if err := s.SyncedObjectRepo.RemoveDuplicateSyncedObjects(); err != nil {
+		return err
+	}
 	if err := s.DB.Migrate(
 		&repo.GoogleAuthStorage{},
 		&repo.ShopifyAuthStorage{},
@@ -43,6 +54,10 @@ func (s *PostgresDb) Migrate() error {
 		&repo.ScheduledTasks{},
 		&repo.SyncedObject{},
 		&repo.WebhookEvent{},
+		&repo.UserSettings{},
+		&repo.TaskEvent{},
+		&repo.CatalogCheck{},
+		&repo.Snapshot{},
 	); err != nil {
 		return err
 	}
@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/archive"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/errgroup"
+)
+
+// ArchiveTaskType is the task type of archive downloads in live progress updates
+const ArchiveTaskType = "archive_download"
+
+// maxArchiveKeys bounds the keys an archive can be requested for
+const maxArchiveKeys = 10000
+
+// archiveDownloads numbers the downloads of this instance for progress updates
+var archiveDownloads atomic.Uint64
+
+// archiveRequest selects the objects of an archive. Exactly one of Prefix, Keys and
+// SnapshotID is used; an empty prefix selects the whole bucket.
+type archiveRequest struct {
+	Bucket     string   `query:"bucket" json:"bucket"`
+	Prefix     string   `query:"prefix" json:"prefix"`
+	Keys       []string `query:"key" json:"keys"`
+	SnapshotID uint     `query:"snapshot_id" json:"snapshot_id"`
+	Format     string   `query:"format" json:"format"`
+}
+
+// HandleArchiveDownload streams a ZIP, TAR or TAR.GZ archive of backed up objects to the
+// client without staging it anywhere. The objects are selected by bucket and prefix, a
+// list of keys, or a snapshot, in which case only items still as they were are included.
+// ZIP and TAR downloads have a known length. TAR downloads, and ZIP downloads of objects
+// whose checksums were recorded at upload, can be resumed with Range requests.
+// Progress is published to the live stream under the ID in the X-Download-ID header.
+func HandleArchiveDownload(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return sendJSONError(c, http.StatusUnauthorized, "Invalid Request", err)
+	}
+
+	accessGrant := c.Request().Header.Get("ACCESS_TOKEN")
+	if accessGrant == "" {
+		return sendJSONError(c, http.StatusForbidden, "access token not found", nil)
+	}
+
+	var req archiveRequest
+	if err := c.Bind(&req); err != nil {
+		return sendJSONError(c, http.StatusBadRequest, "Invalid request", err)
+	}
+	format, err := archive.Parse(req.Format)
+	if err != nil {
+		return sendJSONError(c, http.StatusBadRequest, "Invalid format", err)
+	}
+	if len(req.Keys) > maxArchiveKeys {
+		return sendJSONError(c, http.StatusBadRequest, fmt.Sprintf("maximum %d keys allowed", maxArchiveKeys), nil)
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	store, err := satellite.OpenStore(ctx, accessGrant)
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to open storage", err)
+	}
+	defer store.Close()
+
+	var objects []satellite.ObjectInfo
+	skipped := 0
+	switch {
+	case req.SnapshotID != 0:
+		snapshot, err := database.SnapshotRepo.GetSnapshotForUser(userID, req.SnapshotID)
+		if err != nil {
+			return sendJSONError(c, http.StatusNotFound, "Snapshot not found", err)
+		}
+		req.Bucket = snapshot.BucketName
+		objects, skipped, err = snapshotObjects(ctx, store, snapshot.BucketName, snapshot.ManifestKey, req.Prefix)
+		if err != nil {
+			return sendJSONError(c, http.StatusInternalServerError, "Failed to read snapshot", err)
+		}
+	case req.Bucket == "":
+		return sendJSONError(c, http.StatusBadRequest, "bucket or snapshot_id is required", nil)
+	case len(req.Keys) > 0:
+		objects, skipped, err = statObjects(ctx, store, req.Bucket, req.Keys)
+		if err != nil {
+			return sendJSONError(c, http.StatusInternalServerError, "Failed to look up objects", err)
+		}
+	default:
+		objects, err = store.List(ctx, req.Bucket, req.Prefix, true)
+		if err != nil {
+			return sendJSONError(c, http.StatusInternalServerError, "Failed to list objects", err)
+		}
+	}
+
+	download := &archive.Archive{
+		Format: format,
+		Open: func(ctx context.Context, entry archive.Entry) (io.ReadCloser, error) {
+			return store.Get(ctx, req.Bucket, entry.ID)
+		},
+	}
+	entries, err := archiveEntries(objects)
+	if errors.Is(err, satellite.ErrContentSizeUnknown) {
+		return sendJSONError(c, http.StatusUnprocessableEntity, "Some objects can not be archived, download them individually", err)
+	}
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to size objects", err)
+	}
+	download.Entries = entries
+	if len(download.Entries) == 0 {
+		return sendJSONError(c, http.StatusNotFound, "No objects match the request", nil)
+	}
+
+	size := download.Size()
+	etag := download.ETag()
+	header := c.Response().Header()
+
+	status, offset, length := http.StatusOK, int64(0), size
+	// Ranges of compressed archives cannot be produced, ranges of ZIP archives without
+	// checksums would read everything before them, and a range of an archive that changed
+	// since the client started is worthless; the whole archive is sent then
+	resumable := download.Resumable()
+	if rangeHeader := c.Request().Header.Get("Range"); resumable && rangeHeader != "" && ifRangeMatches(c.Request().Header.Get("If-Range"), etag) {
+		start, end, ok := parseByteRange(rangeHeader, size)
+		if !ok {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			return sendJSONError(c, http.StatusRequestedRangeNotSatisfiable, "Range not satisfiable", nil)
+		}
+		status, offset, length = http.StatusPartialContent, start, end-start+1
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+
+	downloadID := uint(archiveDownloads.Add(1))
+	header.Set(echo.HeaderContentType, archive.ContentType(format))
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-%s.%s"`, req.Bucket, time.Now().UTC().Format("20060102"), format))
+	header.Set("ETag", etag)
+	header.Set("X-Download-ID", strconv.FormatUint(uint64(downloadID), 10))
+	header.Set("X-Archive-Entries", strconv.Itoa(len(download.Entries)))
+	header.Set("X-Archive-Skipped", strconv.Itoa(skipped))
+	if resumable {
+		header.Set("Accept-Ranges", "bytes")
+	} else {
+		header.Set("Accept-Ranges", "none")
+	}
+	if size >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(length, 10))
+	}
+
+	c.Response().WriteHeader(status)
+	if c.Request().Method == http.MethodHead {
+		return nil
+	}
+
+	tracker := progress.NewTracker(progress.Default, progress.Update{
+		UserID:   userID,
+		TaskType: ArchiveTaskType,
+		TaskID:   downloadID,
+		Method:   req.Bucket,
+		Total:    len(download.Entries),
+	})
+	download.Progress = func(entry archive.Entry) {
+		tracker.ItemDone(entry.Name, entry.Size)
+	}
+
+	if err := download.WriteRange(ctx, c.Response(), offset, length); err != nil {
+		// The status was sent already; the client sees a truncated download
+		if ctx.Err() != nil {
+			tracker.Finish(progress.StatusStopped)
+			return nil
+		}
+		tracker.Finish(progress.StatusFailed)
+		logger.Error(ctx, "Failed to stream archive",
+			logger.String("user_id", userID),
+			logger.String("bucket", req.Bucket),
+			logger.String("format", format),
+			logger.ErrorField(err),
+		)
+		return nil
+	}
+	tracker.Finish(progress.StatusCompleted)
+	return nil
+}
+
+// archiveEntries returns the entries of objects that are backed up items. Entries are as
+// long as the content the store reads, which has to be known before anything is sent, so
+// compressed objects without a recorded original size can not be archived.
+func archiveEntries(objects []satellite.ObjectInfo) ([]archive.Entry, error) {
+	var entries []archive.Entry
+	unknown := 0
+	for _, object := range objects {
+		// Folder placeholders and snapshot manifests are no backed up items
+		if object.IsPrefix || path.Base(object.Key) == ".file_placeholder" || strings.HasPrefix(object.Key, satellite.SnapshotPrefix) {
+			continue
+		}
+		size, err := satellite.ContentSize(object)
+		if errors.Is(err, satellite.ErrContentSizeUnknown) {
+			unknown++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := archive.Entry{
+			Name:     archive.Name(object.Key),
+			ID:       object.Key,
+			Size:     size,
+			Modified: object.Created,
+		}
+		if sum, ok := object.Metadata.ContentCRC32(); ok {
+			entry.CRC32 = &sum
+		}
+		entries = append(entries, entry)
+	}
+	if unknown > 0 {
+		return nil, fmt.Errorf("%d objects were backed up before their size was recorded: %w", unknown, satellite.ErrContentSizeUnknown)
+	}
+	return entries, nil
+}
+
+// snapshotObjects returns the objects of the snapshot's items under prefix that still
+// have the recorded content, and how many items no longer do
+func snapshotObjects(ctx context.Context, store satellite.ObjectStore, bucketName, manifestKey, prefix string) ([]satellite.ObjectInfo, int, error) {
+	manifest, err := satellite.ReadManifest(ctx, store, bucketName, manifestKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Only the narrower of the requested prefix and the prefix the manifest lists is listed
+	switch {
+	case strings.HasPrefix(prefix, manifest.Prefix):
+	case strings.HasPrefix(manifest.Prefix, prefix):
+		prefix = manifest.Prefix
+	default:
+		return nil, 0, nil
+	}
+	listed, err := store.List(ctx, bucketName, prefix, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	current := make(map[string]satellite.ObjectInfo, len(listed))
+	for _, object := range listed {
+		current[object.Key] = object
+	}
+
+	var objects []satellite.ObjectInfo
+	skipped := 0
+	for _, item := range manifest.Items {
+		if !strings.HasPrefix(item.Key, prefix) {
+			continue
+		}
+		object, ok := current[item.Key]
+		if !ok || !item.Matches(object) {
+			skipped++
+			continue
+		}
+		objects = append(objects, object)
+	}
+	return objects, skipped, nil
+}
+
+// statObjects looks up keys of a bucket and returns the objects that exist and how many
+// do not
+func statObjects(ctx context.Context, store satellite.ObjectStore, bucketName string, keys []string) ([]satellite.ObjectInfo, int, error) {
+	found := make([]*satellite.ObjectInfo, len(keys))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(10)
+	for i, key := range keys {
+		i, key := i, key
+		g.Go(func() error {
+			object, err := store.Stat(gctx, bucketName, key)
+			if errors.Is(err, satellite.ErrObjectNotFound) {
+				return nil
+			}
+			found[i] = object
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, 0, err
+	}
+
+	var objects []satellite.ObjectInfo
+	missing := 0
+	for _, object := range found {
+		if object == nil {
+			missing++
+			continue
+		}
+		objects = append(objects, *object)
+	}
+	return objects, missing, nil
+}
+
+// parseByteRange parses a Range header of one byte range of an archive of size bytes
+// into the first and last byte. Several ranges are not supported.
+func parseByteRange(header string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if first == "" {
+		// The last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// ifRangeMatches reports whether a range may be served for an If-Range header. Dates
+// are not used to validate archives, so only a matching ETag or no header qualifies.
+func ifRangeMatches(ifRange, etag string) bool {
+	return ifRange == "" || ifRange == etag
+}
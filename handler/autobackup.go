@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,9 +14,13 @@ import (
 	"github.com/StorX2-0/Backup-Tools/apps/outlook"
 	"github.com/StorX2-0/Backup-Tools/db"
 	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/retention"
+	"github.com/StorX2-0/Backup-Tools/pkg/schedule"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/provider"
 	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	"github.com/labstack/echo/v4"
@@ -24,12 +29,17 @@ import (
 
 var Err error
 
+// intervalValues lists the suggested "on" values per interval. Daily, weekly and monthly
+// values may also carry an explicit time ("14:30", "Monday 06:00", "15 9pm") and the
+// cron interval takes any standard cron expression; see pkg/schedule.
 var intervalValues = map[string][]string{
 	"monthly": {"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13",
 		"14", "15", "16", "17", "18", "19", "20", "21", "22", "23",
 		"24", "25", "26", "27", "28"},
 	"weekly": {"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"},
 	"daily":  {"12am"},
+	"hourly": {"1", "2", "3", "4", "6", "8", "12"},
+	"cron":   {"0 2 * * *", "0 */6 * * *", "30 1 * * 1-5"},
 	// "one_time": {},
 }
 
@@ -90,12 +100,20 @@ func HandleAutomaticSyncListForUser(c echo.Context) error {
 		})
 	}
 
+	// Blackout windows of the user move the next backup; without settings only the
+	// global windows apply
+	settings, err := database.UserSettingsRepo.GetUserSettings(userID)
+	if err != nil {
+		logger.Warn(ctx, "Failed to get user settings for next backup", logger.ErrorField(err))
+		settings = nil
+	}
+
 	maskedJobs := repo.MaskTokenForCronJobListingDB(automaticSyncList)
 	response := make([]CronJobResponse, len(maskedJobs))
 	for i, job := range maskedJobs {
 		response[i] = CronJobResponse{
 			CronJobListingDB: job,
-			NextBackup:       calculateNextBackup(job),
+			NextBackup:       calculateNextBackup(job, settings),
 		}
 	}
 
@@ -104,100 +122,33 @@ func HandleAutomaticSyncListForUser(c echo.Context) error {
 		"data":    response,
 	})
 }
-func calculateNextBackup(job repo.CronJobListingDB) *time.Time {
+
+// calculateNextBackup returns the slot the job's next backup starts in: the scheduled
+// time moved by the job's jitter and out of blackout windows, in the job's time zone
+func calculateNextBackup(job repo.CronJobListingDB, settings *repo.UserSettings) *time.Time {
 	if !job.Active || job.Interval == "one_time" {
 		return nil
 	}
 
-	now := time.Now()
-	// Use today at 00:00:00 as the reference for date comparisons
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-	// If the job has already run today, we must look for the next occurrence starting from tomorrow.
-	// Otherwise, we can include today in our search.
-	startSearchingFrom := today
-	if job.LastRun != nil {
-		lastRunDate := time.Date(job.LastRun.Year(), job.LastRun.Month(), job.LastRun.Day(), 0, 0, 0, 0, job.LastRun.Location())
-		if !lastRunDate.Before(today) {
-			startSearchingFrom = today.AddDate(0, 0, 1)
-		}
-	}
-
-	var next time.Time
-
-	switch job.Interval {
-	case "daily":
-		next = startSearchingFrom
-
-	case "weekly":
-		if job.On == "" {
-			return nil
-		}
-		target := parseWeekday(job.On)
-		if target < 0 {
+	// next_run_at is kept up to date by the scheduler; compute it for jobs that
+	// have not been picked up by the backfill yet
+	next := job.NextRunAt
+	if next == nil {
+		if next = job.ComputeNextRunAt(time.Now(), settings); next == nil {
 			return nil
 		}
-		next = startSearchingFrom
-		for next.Weekday() != time.Weekday(target) {
-			next = next.AddDate(0, 0, 1)
-		}
-
-	case "monthly":
-		if job.On == "" {
-			return nil
-		}
-		day, err := strconv.Atoi(job.On)
-		if err != nil || day < 1 || day > 31 {
-			return nil
-		}
-
-		// Start with the 'day' in the month of 'startSearchingFrom'
-		next = time.Date(startSearchingFrom.Year(), startSearchingFrom.Month(), day, 0, 0, 0, 0, startSearchingFrom.Location())
+	}
 
-		// If this date is before our startSearchingFrom, move to next month
-		if next.Before(startSearchingFrom) {
-			next = addOneMonthSameDay(next, day)
+	// A backup that is overdue while a blackout is on starts once the blackout ends
+	if next.Before(time.Now()) {
+		if release, blocked := job.BlackoutRelease(time.Now(), settings); blocked {
+			next = &release
 		}
-
-	default:
-		return nil
 	}
 
-	// Set a default time (e.g., 00:00:00) for the next backup
-	return &next
-}
-
-func addOneMonthSameDay(t time.Time, day int) time.Time {
-	year, month := t.Year(), t.Month()+1
-	if month > 12 {
-		month = 1
-		year++
-	}
-	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, t.Location()).Day()
-	if day > lastDay {
-		day = lastDay
-	}
-	return time.Date(
-		year, month, day,
-		t.Hour(), t.Minute(), t.Second(), 0,
-		t.Location(),
-	)
-}
-
-func parseWeekday(weekday string) time.Weekday {
-	weekdayMap := map[string]time.Weekday{
-		"Sunday":    time.Sunday,
-		"Monday":    time.Monday,
-		"Tuesday":   time.Tuesday,
-		"Wednesday": time.Wednesday,
-		"Thursday":  time.Thursday,
-		"Friday":    time.Friday,
-		"Saturday":  time.Saturday,
-	}
-	if wd, ok := weekdayMap[weekday]; ok {
-		return wd
-	}
-	return -1
+	// Show the time in the job's time zone
+	local := next.In(job.Location())
+	return &local
 }
 
 func HandleAutomaticSyncActiveJobsForUser(c echo.Context) error {
@@ -342,26 +293,47 @@ func HandleAutomaticSyncCreate(c echo.Context) error {
 		return jsonErrorMsg(http.StatusBadRequest, "Invalid Request", "invalid sync type")
 	}
 
-	// Validate method
-	if method != "gmail" && method != "outlook" && method != "psql_database" && method != "mysql_database" {
+	// Validate method. MySQL jobs are accepted as they always were, although no processor
+	// backs them up yet.
+	if !provider.Supports(method, provider.CapFullSync) && method != "mysql_database" {
 		return jsonErrorMsg(http.StatusBadRequest, "Invalid Request", "invalid method")
 	}
 
 	// Parse request body and extract common fields
 	var reqBody struct {
-		Code         string `json:"code"`
-		Name         string `json:"name"`
-		DatabaseName string `json:"database_name"`
-		Host         string `json:"host"`
-		Port         string `json:"port"`
-		Username     string `json:"username"`
-		Password     string `json:"password"`
+		Code         string            `json:"code"`
+		Name         string            `json:"name"`
+		DatabaseName string            `json:"database_name"`
+		Host         string            `json:"host"`
+		Port         string            `json:"port"`
+		Username     string            `json:"username"`
+		Password     string            `json:"password"`
+		Timezone     string            `json:"timezone"`
+		Compression  string            `json:"compression"`
+		Retention    *retention.Policy `json:"retention"`
 	}
 
 	if err := c.Bind(&reqBody); err != nil {
 		return jsonError(http.StatusBadRequest, "Invalid Request", err)
 	}
 
+	if _, err := schedule.LoadLocation(reqBody.Timezone); err != nil {
+		return jsonError(http.StatusBadRequest, "Invalid Request", err)
+	}
+
+	compression, err := compress.Parse(reqBody.Compression)
+	if err != nil {
+		return jsonError(http.StatusBadRequest, "Invalid Request", err)
+	}
+
+	var policy retention.Policy
+	if reqBody.Retention != nil {
+		policy = *reqBody.Retention
+		if err := validateRetention(method, syncType, policy); err != nil {
+			return jsonError(http.StatusBadRequest, "Invalid retention", err)
+		}
+	}
+
 	// Process based on method
 	var name string
 	var config map[string]interface{}
@@ -386,8 +358,17 @@ func HandleAutomaticSyncCreate(c echo.Context) error {
 		return err
 	}
 
+	// A dry run only reports what the first backup would move
+	if isDryRun(c) {
+		return sendEstimate(c, method, provider.EstimateInput{
+			UserID:    userID,
+			Account:   name,
+			InputData: config,
+		})
+	}
+
 	// Create the sync job
-	data, err := createSyncJob(userID, name, method, syncType, config, c)
+	data, err := createSyncJob(userID, name, method, syncType, config, reqBody.Timezone, compression, policy, c)
 	if err != nil {
 		return err
 	}
@@ -444,7 +425,7 @@ func ProcessOutlookMethod(code string) (string, map[string]interface{}, error) {
 		return "", nil, jsonError(http.StatusBadRequest, "Invalid Code. Not able to generate auth token from code", err)
 	}
 
-	client, err := outlook.NewOutlookClientUsingToken(tok.AccessToken)
+	client, err := outlook.NewOutlookClientUsingToken(tok.AccessToken, "")
 	if err != nil {
 		return "", nil, jsonError(http.StatusBadRequest, "Invalid Code. May be it is expired or invalid", err)
 	}
@@ -467,7 +448,7 @@ func ProcessOutlookAccessToken(accessToken string) (string, map[string]interface
 		return "", nil, jsonErrorMsg(http.StatusBadRequest, "Access Token Required")
 	}
 
-	client, err := outlook.NewOutlookClientUsingToken(accessToken)
+	client, err := outlook.NewOutlookClientUsingToken(accessToken, "")
 	if err != nil {
 		return "", nil, jsonError(http.StatusBadRequest, "Invalid Access Token. May be it is expired or invalid", err)
 	}
@@ -504,7 +485,7 @@ func ProcessDatabaseMethod(reqBody DatabaseConnection) (string, map[string]inter
 }
 
 // Helper functions
-func createSyncJob(userID, name, method, syncType string, config map[string]interface{}, c echo.Context) (interface{}, error) {
+func createSyncJob(userID, name, method, syncType string, config map[string]interface{}, timezone, compression string, policy retention.Policy, c echo.Context) (interface{}, error) {
 	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
 
 	// Check for existing jobs using original name (before adding timestamp)
@@ -512,7 +493,16 @@ func createSyncJob(userID, name, method, syncType string, config map[string]inte
 		return nil, err
 	}
 
-	data, err := database.CronJobRepo.CreateCronJobForUser(userID, name, method, syncType, config)
+	// Jobs default to the user's time zone
+	if timezone == "" {
+		settings, err := database.UserSettingsRepo.GetUserSettings(userID)
+		if err != nil {
+			return nil, jsonError(http.StatusInternalServerError, "Failed to load user settings", err)
+		}
+		timezone = settings.Timezone
+	}
+
+	data, err := database.CronJobRepo.CreateCronJobForUser(userID, name, method, syncType, config, timezone, compression, policy)
 	if err != nil {
 		return nil, handleDBError(err)
 	}
@@ -573,14 +563,14 @@ func checkExistingJobs(userID, name, syncType, method string, db *db.PostgresDb)
 }
 
 func hasRunningTasksForJob(taskRepo *repo.TaskRepository, jobID uint) (bool, error) {
-	// Get all tasks for the job and check if any are running or pushed
+	// Get all tasks for the job and check if any are running, pushed or paused
 	tasks, err := taskRepo.ListAllTasksByJobID(jobID, 100, 0)
 	if err != nil {
 		return false, err
 	}
 
 	for _, task := range tasks {
-		if task.Status == "running" || task.Status == "pushed" {
+		if task.Status == repo.TaskStatusRunning || task.Status == repo.TaskStatusPushed || task.Status == repo.TaskStatusPaused {
 			return true, nil
 		}
 	}
@@ -780,6 +770,11 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 		DatabaseConnection *DatabaseConnection `json:"database_connection"`
 		StorxToken         *string             `json:"storx_token"`
 		Active             *bool               `json:"active"`
+		Timezone           *string             `json:"timezone"`
+		DependsOn          *[]uint             `json:"depends_on"`
+		Hooks              *repo.JobHooks      `json:"hooks"`
+		Compression        *string             `json:"compression"`
+		Retention          *retention.Policy   `json:"retention"`
 	}
 
 	if err := c.Bind(&reqBody); err != nil {
@@ -792,16 +787,17 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 		})
 	}
 
-	// For one-time syncs, only allow storx_token and refresh_token (outlook) updates
+	// For one-time syncs, only allow storx_token, refresh_token (outlook) and hooks updates
 	if job.SyncType == "one_time" {
-		// Block updates to interval, on, code, database_connection, active
-		if reqBody.Interval != nil || reqBody.On != nil ||
-			reqBody.DatabaseConnection != nil || reqBody.Active != nil {
+		// Block updates to interval, on, code, database_connection, active, compression, retention
+		if reqBody.Interval != nil || reqBody.On != nil || reqBody.Timezone != nil ||
+			reqBody.DatabaseConnection != nil || reqBody.Active != nil || reqBody.DependsOn != nil ||
+			reqBody.Compression != nil || reqBody.Retention != nil {
 			logger.Warn(ctx, "Attempt to update restricted fields for one-time sync",
 				logger.Int("job_id", jobID))
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{
 				"message": "Invalid Request",
-				"error":   "For one-time sync jobs, only storx_token and code for outlook/gmail updates are allowed",
+				"error":   "For one-time sync jobs, only storx_token, code for outlook/gmail and hooks updates are allowed",
 			})
 		}
 
@@ -915,7 +911,7 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 			}
 
 			// Create Outlook client and get user details
-			client, err := outlook.NewOutlookClientUsingToken(authToken)
+			client, err := outlook.NewOutlookClientUsingToken(authToken, "")
 			if err != nil {
 				logger.Error(ctx, "Failed to create Outlook client",
 					logger.Int("job_id", jobID),
@@ -963,12 +959,16 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 				logger.String("email", userDetails.Mail))
 		}
 
+		if err := applyJobChainUpdate(ctx, job, nil, reqBody.Hooks, updateRequest); err != nil {
+			return err
+		}
+
 		// If no valid updates were provided
 		if len(updateRequest) == 0 {
 			logger.Warn(ctx, "No valid update fields provided for one-time sync",
 				logger.Int("job_id", jobID))
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{
-				"message": "No valid update fields provided. Only storx_token, code (gmail), and refresh_token (outlook) are allowed",
+				"message": "No valid update fields provided. Only storx_token, code (gmail), refresh_token (outlook) and hooks are allowed",
 			})
 		}
 
@@ -977,6 +977,9 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 			logger.Int("update_fields_count", len(updateRequest)))
 
 		err = database.CronJobRepo.UpdateCronJobByID(uint(jobID), updateRequest)
+		if errors.Is(err, repo.ErrInvalidJobChain) {
+			return jsonError(http.StatusBadRequest, "Invalid job dependencies or hooks", err)
+		}
 		if err != nil {
 			logger.Error(ctx, "Failed to update one-time sync job in database",
 				logger.Int("job_id", jobID),
@@ -1040,7 +1043,9 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 		}
 
 		if *reqBody.Interval == "monthly" {
-			day, err := strconv.Atoi(onValue)
+			// On is a day of the month, optionally followed by a time ("15 06:00")
+			dayValue, clock, _ := strings.Cut(onValue, " ")
+			day, err := strconv.Atoi(dayValue)
 			if err != nil {
 				return c.JSON(http.StatusBadRequest, map[string]interface{}{
 					"message": "Invalid Request",
@@ -1048,6 +1053,9 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 				})
 			}
 			onValue = strconv.Itoa(day)
+			if clock = strings.TrimSpace(clock); clock != "" {
+				onValue += " " + clock
+			}
 
 			if day == 29 || day == 30 || day == 31 {
 				logger.Warn(ctx, "Invalid monthly date selected",
@@ -1079,6 +1087,47 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 			logger.String("on", onValue))
 	}
 
+	if reqBody.Compression != nil {
+		compression, err := compress.Parse(strings.TrimSpace(*reqBody.Compression))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "Invalid Request",
+				"error":   err.Error(),
+			})
+		}
+		updateRequest["compression"] = compression
+		logger.Info(ctx, "Compression updated",
+			logger.Int("job_id", jobID),
+			logger.String("compression", compression))
+	}
+
+	if reqBody.Retention != nil {
+		if err := validateRetention(job.Method, job.SyncType, *reqBody.Retention); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "Invalid retention",
+				"error":   err.Error(),
+			})
+		}
+		updateRequest["retention"] = *reqBody.Retention
+		logger.Info(ctx, "Retention updated",
+			logger.Int("job_id", jobID),
+			logger.Bool("enabled", reqBody.Retention.Enabled()))
+	}
+
+	if reqBody.Timezone != nil {
+		timezone := strings.TrimSpace(*reqBody.Timezone)
+		if _, err := schedule.LoadLocation(timezone); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "Invalid Request",
+				"error":   err.Error(),
+			})
+		}
+		updateRequest["timezone"] = timezone
+		logger.Info(ctx, "Timezone updated",
+			logger.Int("job_id", jobID),
+			logger.String("timezone", timezone))
+	}
+
 	if reqBody.Code != nil {
 		if job.Method != "gmail" {
 			logger.Warn(ctx, "Code update attempted for non-gmail method",
@@ -1184,7 +1233,7 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 		}
 
 		// Create Outlook client and get user details
-		client, err := outlook.NewOutlookClientUsingToken(authToken)
+		client, err := outlook.NewOutlookClientUsingToken(authToken, "")
 		if err != nil {
 			logger.Error(ctx, "Failed to create Outlook client",
 				logger.Int("job_id", jobID),
@@ -1238,6 +1287,10 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 			logger.Bool("has_storx_token", true))
 	}
 
+	if err := applyJobChainUpdate(ctx, job, reqBody.DependsOn, reqBody.Hooks, updateRequest); err != nil {
+		return err
+	}
+
 	if reqBody.Active != nil {
 		updateRequest["active"] = *reqBody.Active
 		if *reqBody.Active {
@@ -1256,6 +1309,12 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 		logger.Int("update_fields_count", len(updateRequest)))
 
 	err = database.CronJobRepo.UpdateCronJobByID(uint(jobID), updateRequest)
+	if errors.Is(err, repo.ErrInvalidJobChain) {
+		logger.Warn(ctx, "Invalid job chain",
+			logger.Int("job_id", jobID),
+			logger.ErrorField(err))
+		return jsonError(http.StatusBadRequest, "Invalid job dependencies or hooks", err)
+	}
 	if err != nil {
 		logger.Error(ctx, "Failed to update job in database",
 			logger.Int("job_id", jobID),
@@ -1288,6 +1347,58 @@ func HandleAutomaticBackupUpdate(c echo.Context) error {
 	})
 }
 
+// validateRetention checks a retention policy for a job of method. Policies only apply to
+// recurring jobs of methods that upload new objects on every run; pruning mailbox
+// backups would only make the next run upload the same messages again.
+func validateRetention(method, syncType string, policy retention.Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if !policy.Enabled() {
+		return nil
+	}
+	if syncType == "one_time" {
+		return fmt.Errorf("one-time jobs have no retention")
+	}
+	if !provider.Supports(method, provider.CapRetention) {
+		return fmt.Errorf("retention is only supported for %s backups; %s backups keep one object per item, which the next run would upload again",
+			strings.Join(provider.Methods(provider.CapRetention), ", "), method)
+	}
+	return nil
+}
+
+// applyJobChainUpdate validates new hooks of a job and adds them and new dependencies to
+// updateRequest. Fields that are nil keep their current value. Whether the chain forms
+// a cycle with the user's other jobs is checked by the update itself.
+func applyJobChainUpdate(ctx context.Context, job *repo.CronJobListingDB, dependsOn *[]uint, hooks *repo.JobHooks, updateRequest map[string]interface{}) error {
+	if dependsOn == nil && hooks == nil {
+		return nil
+	}
+
+	newDependsOn, newHooks := []uint(job.DependsOn), job.Hooks
+	if dependsOn != nil {
+		newDependsOn = *dependsOn
+	}
+	if hooks != nil {
+		newHooks = *hooks
+	}
+
+	if err := newHooks.Validate(); err != nil {
+		logger.Warn(ctx, "Invalid job hooks",
+			logger.Int("job_id", int(job.ID)),
+			logger.ErrorField(err))
+		return jsonError(http.StatusBadRequest, "Invalid hooks", err)
+	}
+	updateRequest["depends_on"] = repo.JobIDs(newDependsOn)
+	updateRequest["hooks"] = newHooks
+	logger.Info(ctx, "Job chain updated",
+		logger.Int("job_id", int(job.ID)),
+		logger.Int("dependencies", len(newDependsOn)),
+		logger.Int("success_hooks", len(newHooks.OnSuccess)),
+		logger.Int("failure_hooks", len(newHooks.OnFailure)))
+	return nil
+}
+
 func HandleAutomaticSyncDelete(c echo.Context) error {
 	ctx := c.Request().Context()
 	var err error
@@ -1390,20 +1501,7 @@ func validateInterval(interval, on string) bool {
 		return true
 	}
 
-	// Get allowed values for this interval
-	allowedValues, exists := intervalValues[interval]
-	if !exists {
-		return false
-	}
-
-	// Check if the value matches any allowed value
-	for _, v := range allowedValues {
-		if v == on {
-			return true
-		}
-	}
-
-	return false
+	return schedule.Valid(interval, on)
 }
 
 // extractAndStoreProjectID extracts project_id from storx_token and adds it to updateRequest
@@ -1511,9 +1609,20 @@ func HandleAutomaticBackupSummary(c echo.Context) error {
 
 	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
 
+	// "Today" is the current day in the user's time zone
+	loc, err := userLocation(database, userID, c.QueryParam("timezone"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": "invalid timezone",
+			"error":   err.Error(),
+		})
+	}
+	now := time.Now().In(loc)
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	startOfTomorrow := startOfToday.AddDate(0, 0, 1)
+
 	// Execute all counts in parallel - each goroutine creates its own query
 	var totalAccounts, activeBackups, providers int64
-	today := time.Now().Format("2006-01-02")
 	var todaysBackups int64
 
 	errs := make([]error, 4)
@@ -1547,8 +1656,8 @@ func HandleAutomaticBackupSummary(c echo.Context) error {
 		defer wg.Done()
 		errs[3] = database.DB.Model(&repo.TaskListingDB{}).
 			Joins("JOIN cron_job_listing_dbs ON task_listing_dbs.cron_job_id = cron_job_listing_dbs.id").
-			Where("cron_job_listing_dbs.user_id = ? AND task_listing_dbs.status = ? AND DATE(task_listing_dbs.start_time) = ?",
-				userID, repo.TaskStatusSuccess, today).
+			Where("cron_job_listing_dbs.user_id = ? AND task_listing_dbs.status = ? AND task_listing_dbs.start_time >= ? AND task_listing_dbs.start_time < ?",
+				userID, repo.TaskStatusSuccess, startOfToday, startOfTomorrow).
 			Count(&todaysBackups).Error
 	}()
 
@@ -1572,6 +1681,7 @@ func HandleAutomaticBackupSummary(c echo.Context) error {
 			"active_backups": int(activeBackups),
 			"todays_backups": int(todaysBackups),
 			"providers":      int(providers),
+			"timezone":       loc.String(),
 		},
 	})
 }
@@ -3,7 +3,6 @@ package handler
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -69,14 +68,14 @@ func HandleS3toSatellite(c echo.Context) error {
 		})
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
+	defer file.Close()
 
-	err = satellite.UploadObject(context.Background(), accesGrant, "aws-s3", itemName, data)
+	// The S3 downloader writes at offsets, so the object is staged on disk and streamed
+	// from there
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "aws-s3", itemName, file, satellite.ObjectMetadata{
+		Source:   "aws-s3",
+		SourceID: bucketName + "/" + itemName,
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -101,37 +100,14 @@ func HandleSatelliteToS3(c echo.Context) error {
 		})
 	}
 
-	data, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_S3, itemName)
+	download, err := satellite.OpenObject(context.Background(), accesGrant, satellite.ReserveBucket_S3, itemName)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": "error downloading object from Satellite" + err.Error(), "error": err.Error()})
 	}
-	dirPath := filepath.Join("./cache", utils.CreateUserTempCacheFolder())
-	path := filepath.Join(dirPath, itemName)
-
-	file, err := utils.CreateFile(path)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
-	_, err = file.Write(data)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
-	file.Close()
-	defer os.Remove(path)
-
-	cachedFile, err := os.Open(path)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
+	defer download.Close()
 
 	s3sess := aws.ConnectAws()
-	err = s3sess.UploadFile(bucketName, itemName, cachedFile)
+	err = s3sess.UploadFile(bucketName, itemName, download)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
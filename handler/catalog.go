@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/database"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+)
+
+// CatalogScope is a prefix of a bucket and the store it is listed with. A scope without a
+// store could not be opened and is not verified.
+type CatalogScope struct {
+	Prefix string
+	Store  satellite.ObjectStore
+}
+
+// VerifyCatalog compares the synced_objects catalog of check's user and bucket with the
+// objects under scopes and records the discrepancies on check. Unless check is a dry
+// run, objects missing from the catalog are added to it and rows of objects that no
+// longer exist are removed, so both are backed up correctly again. Rows are only removed
+// when every scope that covers them was listed; rows outside the scopes are left alone.
+func VerifyCatalog(ctx context.Context, database *db.PostgresDb, check *repo.CatalogCheck, scopes []CatalogScope) error {
+	// The catalog is read before the bucket is listed. A row is only written after its
+	// object was uploaded, so uploads running meanwhile never look like stale rows. They
+	// can look like objects missing from the catalog, though, when the upload records its
+	// row between the read and the repair; the repair then skips the row it finds.
+	catalog, err := database.SyncedObjectRepo.GetSyncedObjectsByUserAndBucket(check.UserID, check.BucketName, "", "")
+	if err != nil {
+		return err
+	}
+
+	var listed, unlisted []string
+	var objects []satellite.ObjectInfo
+	seen := map[string]bool{}
+	var listErr error
+	for _, scope := range scopes {
+		scoped, err := listCatalogScope(ctx, scope, check.BucketName)
+		if err != nil {
+			logger.Warn(ctx, "Failed to list part of bucket for catalog check",
+				logger.String("user_id", check.UserID),
+				logger.String("bucket", check.BucketName),
+				logger.String("prefix", scope.Prefix),
+				logger.ErrorField(err),
+			)
+			unlisted = append(unlisted, scope.Prefix)
+			listErr = err
+			continue
+		}
+		listed = append(listed, scope.Prefix)
+		for _, object := range scoped {
+			// Snapshot manifests are not backed up items and never in the catalog
+			if !seen[object.Key] && !strings.HasPrefix(object.Key, satellite.SnapshotPrefix) {
+				seen[object.Key] = true
+				objects = append(objects, object)
+			}
+		}
+	}
+	if len(listed) == 0 {
+		if listErr == nil {
+			listErr = fmt.Errorf("no part of bucket %s is backed up", check.BucketName)
+		}
+		return listErr
+	}
+
+	// Only rows under a listed prefix can be compared with the bucket
+	var covered []repo.SyncedObject
+	for _, row := range catalog {
+		if hasAnyPrefix(row.ObjectKey, listed) {
+			covered = append(covered, row)
+		}
+	}
+
+	missingFromCatalog, stale := diffCatalog(covered, objects)
+	var missingFromBucket []repo.SyncedObject
+	for _, row := range stale {
+		// The object may exist where it could not be listed
+		if !hasAnyPrefix(row.ObjectKey, unlisted) {
+			missingFromBucket = append(missingFromBucket, row)
+		}
+	}
+	check.CatalogCount = len(catalog)
+	check.ObjectCount = len(objects)
+	check.MissingFromCatalog = len(missingFromCatalog)
+	check.MissingFromBucket = len(missingFromBucket)
+
+	var added []repo.SyncedObject
+	var addedKeys []string
+	for _, object := range missingFromCatalog {
+		syncedAt := object.Created
+		if syncedAt.IsZero() {
+			syncedAt = time.Now()
+		}
+		addedKeys = append(addedKeys, object.Key)
+		added = append(added, repo.SyncedObject{
+			UserID:      check.UserID,
+			BucketName:  check.BucketName,
+			ObjectKey:   object.Key,
+			SyncedAt:    syncedAt,
+			Source:      deriveSource(check.BucketName),
+			Type:        deriveType(check.BucketName),
+			Compression: object.Metadata.Compression,
+		})
+	}
+	var removed []uint
+	var removedKeys []string
+	for _, row := range missingFromBucket {
+		removedKeys = append(removedKeys, row.ObjectKey)
+		removed = append(removed, row.ID)
+	}
+	check.MissingFromCatalogKeys = sampleKeys(addedKeys)
+	check.MissingFromBucketKeys = sampleKeys(removedKeys)
+
+	if check.DryRun {
+		return nil
+	}
+
+	if err := database.SyncedObjectRepo.CreateSyncedObjects(added); err != nil {
+		return err
+	}
+	check.Added = len(added)
+	if err := database.SyncedObjectRepo.DeleteSyncedObjectsByID(removed); err != nil {
+		return err
+	}
+	check.Removed = len(removed)
+
+	if check.Added > 0 || check.Removed > 0 {
+		logger.Info(ctx, "Repaired synced objects catalog",
+			logger.String("user_id", check.UserID),
+			logger.String("bucket", check.BucketName),
+			logger.Int("added", check.Added),
+			logger.Int("removed", check.Removed),
+		)
+	}
+	return nil
+}
+
+// listCatalogScope lists the objects under the prefix of scope
+func listCatalogScope(ctx context.Context, scope CatalogScope, bucketName string) ([]satellite.ObjectInfo, error) {
+	if scope.Store == nil {
+		return nil, fmt.Errorf("no store for prefix %q", scope.Prefix)
+	}
+	if err := scope.Store.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, err
+	}
+	return scope.Store.List(ctx, bucketName, scope.Prefix, true)
+}
+
+// hasAnyPrefix reports whether key starts with one of prefixes
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffCatalog returns the objects that have no catalog row and the catalog rows that
+// have no object. Rows that repeat a key are stale as well.
+func diffCatalog(catalog []repo.SyncedObject, objects []satellite.ObjectInfo) ([]satellite.ObjectInfo, []repo.SyncedObject) {
+	exists := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		if !object.IsPrefix {
+			exists[object.Key] = true
+		}
+	}
+
+	var missingFromBucket []repo.SyncedObject
+	cataloged := make(map[string]bool, len(catalog))
+	for _, row := range catalog {
+		if !exists[row.ObjectKey] || cataloged[row.ObjectKey] {
+			missingFromBucket = append(missingFromBucket, row)
+			continue
+		}
+		cataloged[row.ObjectKey] = true
+	}
+
+	var missingFromCatalog []satellite.ObjectInfo
+	for _, object := range objects {
+		if !object.IsPrefix && !cataloged[object.Key] {
+			missingFromCatalog = append(missingFromCatalog, object)
+		}
+	}
+	return missingFromCatalog, missingFromBucket
+}
+
+// sampleKeys returns the keys a check keeps for its report
+func sampleKeys(keys []string) database.DbJson[[]string] {
+	if len(keys) > repo.CatalogCheckSampleSize {
+		keys = keys[:repo.CatalogCheckSampleSize]
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	return *database.NewDbJsonFromValue(keys)
+}
+
+// HandleCatalogVerify queues a check of the synced objects catalog against the bucket.
+// Without a bucket every bucket of the user that has a catalog is checked.
+func HandleCatalogVerify(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return sendJSONError(c, http.StatusUnauthorized, "Invalid Request", err)
+	}
+
+	dryRun := false
+	if value := c.QueryParam("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return sendJSONError(c, http.StatusBadRequest, "Invalid dry_run", err)
+		}
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	buckets := []string{c.QueryParam("bucket")}
+	if buckets[0] == "" {
+		synced, err := database.SyncedObjectRepo.GetSyncedBuckets(userID)
+		if err != nil {
+			return sendJSONError(c, http.StatusInternalServerError, "Failed to get buckets", err)
+		}
+		buckets = buckets[:0]
+		for _, bucket := range synced {
+			buckets = append(buckets, bucket.BucketName)
+		}
+	}
+	if len(buckets) == 0 {
+		return sendJSONError(c, http.StatusNotFound, "Nothing has been backed up yet", nil)
+	}
+
+	checks := make([]*repo.CatalogCheck, 0, len(buckets))
+	for _, bucket := range buckets {
+		check, err := database.CatalogCheckRepo.QueueCatalogCheck(userID, bucket, repo.CatalogCheckTriggerManual, dryRun)
+		if err != nil {
+			return sendJSONError(c, http.StatusInternalServerError, "Failed to queue catalog check", err)
+		}
+		checks = append(checks, check)
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": fmt.Sprintf("Catalog check queued for %d bucket(s)", len(checks)),
+		"data":    checks,
+	})
+}
+
+// HandleCatalogChecks returns the latest catalog checks of the user, optionally of one
+// bucket
+func HandleCatalogChecks(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return sendJSONError(c, http.StatusUnauthorized, "Invalid Request", err)
+	}
+
+	limit := 20
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > 100 {
+			return sendJSONError(c, http.StatusBadRequest, "limit must be between 1 and 100", err)
+		}
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	checks, err := database.CatalogCheckRepo.ListCatalogChecksForUser(userID, c.QueryParam("bucket"), limit)
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to list catalog checks", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": checks,
+	})
+}
@@ -1,10 +1,8 @@
 package handler
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 
 	"github.com/StorX2-0/Backup-Tools/apps/dropbox"
@@ -42,13 +40,12 @@ func HandleDropboxToSatellite(c echo.Context) error {
 		})
 	}
 
-	data, err := io.ReadAll(file.Data)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
-	err = satellite.UploadObject(context.Background(), accesGrant, "dropbox", file.Name, data)
+	defer file.Data.Close()
+
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "dropbox", file.Name, file.Data, satellite.ObjectMetadata{
+		Source:   "dropbox",
+		SourceID: "/" + filePath,
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -72,12 +69,13 @@ func HandleSatelliteToDropbox(c echo.Context) error {
 		})
 	}
 
-	objData, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Dropbox, filePath)
+	download, err := satellite.OpenObject(context.Background(), accesGrant, satellite.ReserveBucket_Dropbox, filePath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
+	defer download.Close()
 
 	client, err := dropbox.NewDropboxClient()
 	if err != nil {
@@ -85,8 +83,7 @@ func HandleSatelliteToDropbox(c echo.Context) error {
 			"error": err.Error(),
 		})
 	}
-	data := bytes.NewReader(objData)
-	err = client.UploadFile(data, "/"+filePath)
+	err = client.UploadFile(download, "/"+filePath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/labstack/echo/v4"
+)
+
+// isDryRun reports whether the request only asks for an estimate. The flag is read from
+// the query string or, for form posts, the form.
+func isDryRun(c echo.Context) bool {
+	dryRun, _ := strconv.ParseBool(c.FormValue("dry_run"))
+	return dryRun
+}
+
+// sendEstimate runs a dry run of the backup described by input and returns how many items
+// and bytes it would move, without creating a job or task. max_items bounds the walk, up
+// to provider.MaxEstimateMaxItems.
+func sendEstimate(c echo.Context, method string, input provider.EstimateInput) error {
+	ctx := c.Request().Context()
+
+	p, ok := provider.Lookup(method)
+	if !ok {
+		return sendJSONError(c, http.StatusBadRequest, "Invalid Request", errors.New("invalid method"))
+	}
+
+	if value := c.FormValue("max_items"); value != "" {
+		maxItems, err := strconv.Atoi(value)
+		if err != nil || maxItems <= 0 || maxItems > provider.MaxEstimateMaxItems {
+			return sendJSONError(c, http.StatusBadRequest, "Invalid Request",
+				fmt.Errorf("max_items must be between 1 and %d", provider.MaxEstimateMaxItems))
+		}
+		input.MaxItems = maxItems
+	}
+
+	input.Ctx = ctx
+	input.Database = c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	estimate, err := p.Estimate(input)
+	if errors.Is(err, provider.ErrNotSupported) {
+		return sendJSONError(c, http.StatusBadRequest, "Dry run is not supported for "+method, nil)
+	}
+	if err != nil {
+		logger.Warn(ctx, "Dry run failed",
+			logger.String("method", method),
+			logger.ErrorField(err),
+		)
+		status := http.StatusBadGateway
+		if errs.CategoryOf(err).NeedsUserAction() {
+			status = http.StatusUnauthorized
+		}
+		return sendJSONError(c, status, "Failed to estimate backup", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Backup estimate",
+		"dry_run": true,
+		"data":    estimate,
+	})
+}
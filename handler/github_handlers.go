@@ -167,20 +167,17 @@ func HandleGithubRepositoryToSatellite(c echo.Context) error {
 			"error": err.Error(),
 		})
 	}
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
+	defer file.Close()
 
-	err = satellite.UploadObject(context.Background(), accesGrant, "github", repoName, data)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "github", repoName, file, satellite.ObjectMetadata{
+		Source:   "github",
+		SourceID: owner + "/" + repo,
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
-	file.Close()
 
 	return c.JSON(http.StatusOK, map[string]interface{}{"message": fmt.Sprintf("repo %s was successfully uploaded from Github to Satellite", repoName)})
 }
@@ -210,10 +207,6 @@ func HandleRepositoryFromSatelliteToGithub(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]interface{}{"message": "repo name is now specified"})
 	}
 
-	repoData, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Github, repo)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": "error downloading object from Satellite" + err.Error(), "error": err.Error()})
-	}
 	dirPath := filepath.Join("./cache", utils.CreateUserTempCacheFolder())
 	basePath := filepath.Join(dirPath, repo+".zip")
 
@@ -223,15 +216,13 @@ func HandleRepositoryFromSatelliteToGithub(c echo.Context) error {
 			"error": err.Error(),
 		})
 	}
-	_, err = file.Write(repoData)
+	defer os.RemoveAll(dirPath)
+
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.ReserveBucket_Github, repo, file)
+	file.Close()
 	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
+		return c.JSON(http.StatusForbidden, map[string]interface{}{"message": "error downloading object from Satellite" + err.Error(), "error": err.Error()})
 	}
-	file.Close()
-
-	defer os.RemoveAll(dirPath)
 
 	unzipPath := filepath.Join(dirPath, "unarchived")
 	err = os.MkdirAll(unzipPath, 0755)
@@ -92,7 +92,7 @@ func (s *GmailService) UploadMessagesToSatellite(ctx context.Context, database *
 
 				// Use helper function to upload and sync
 				// Source and Type are automatically derived from bucket name ("gmail" -> source: "google", type: "gmail")
-				err = UploadObjectAndSync(ctx, database, s.accessGrant, "gmail", messagePath, b, s.userEmail)
+				err = UploadObjectAndSyncWithAccessGrant(ctx, database, s.accessGrant, "gmail", messagePath, b, s.userEmail, google.GmailObjectMetadata(msg))
 				if err != nil {
 					logger.Info(ctx, "error uploading to satellite", logger.ErrorField(err))
 					failedIDs.Add(id)
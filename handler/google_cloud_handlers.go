@@ -181,8 +181,9 @@ func HandleGoogleCloudItemToSatellite(c echo.Context) error {
 			"error": err.Error(),
 		})
 	}
+	defer obj.Data.Close()
 
-	err = satellite.UploadObject(context.Background(), accesGrant, "google-cloud", obj.Name, obj.Data)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "google-cloud", obj.Name, obj.Data, obj.ObjectMetadata())
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -220,16 +221,17 @@ func HandleSatelliteToGoogleCloud(c echo.Context) error {
 		}
 	}
 
-	data, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Drive, itemName)
+	download, err := satellite.OpenObject(context.Background(), accesGrant, satellite.ReserveBucket_Drive, itemName)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
+	defer download.Close()
 
 	err = client.UploadObject(c, bucketName, &google.StorageObject{
 		Name: itemName,
-		Data: data,
+		Data: download,
 	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
@@ -289,7 +291,8 @@ func HandleAllFilesFromGoogleCloudBucketToSatellite(c echo.Context) error {
 			})
 		}
 
-		err = satellite.UploadObject(context.Background(), accesGrant, bucket.Id, obj.Name, obj.Data)
+		_, err = satellite.UploadStream(context.Background(), accesGrant, bucket.Id, obj.Name, obj.Data, obj.ObjectMetadata())
+		obj.Data.Close()
 		logger.Info(ctx, "uploaded : "+obj.Name, logger.String("bucketID", bucket.Id))
 		if err != nil {
 			return c.JSON(http.StatusForbidden, map[string]interface{}{
@@ -355,7 +358,8 @@ func SyncCloudBucket(c echo.Context, bucketName string) error {
 			return err
 		}
 
-		err = satellite.UploadObject(context.Background(), accesGrant, bucket.Id, obj.Name, obj.Data)
+		_, err = satellite.UploadStream(context.Background(), accesGrant, bucket.Id, obj.Name, obj.Data, obj.ObjectMetadata())
+		obj.Data.Close()
 		logger.Info(ctx, "uploaded : "+obj.Name, logger.String("bucketID", bucket.Id))
 		if err != nil {
 			return err
@@ -524,6 +528,9 @@ func SyncItem(c echo.Context, client *google.StorageClient, accessGrant, itemNam
 	if err != nil {
 		return err
 	}
-	return satellite.UploadObject(context.Background(), accessGrant, "google-cloud", obj.Name, obj.Data)
+	defer obj.Data.Close()
+
+	_, err = satellite.UploadStream(context.Background(), accessGrant, "google-cloud", obj.Name, obj.Data, obj.ObjectMetadata())
+	return err
 
 }
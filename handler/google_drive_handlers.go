@@ -420,10 +420,11 @@ func HandleSendFileFromGoogleDriveToSatellite(c echo.Context) error {
 
 	id := c.Param("ID")
 
-	name, data, err := google.GetFileAndPath(c, id)
+	name, data, err := google.OpenFileAndPath(c, id)
 	if err != nil {
 		return HandleGoogleDriveError(c, err, "retrieve file from Google Drive")
 	}
+	defer data.Close()
 	accesGrant := c.Request().Header.Get("ACCESS_TOKEN")
 	if accesGrant == "" {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
@@ -448,7 +449,10 @@ func HandleSendFileFromGoogleDriveToSatellite(c echo.Context) error {
 	// Create path with user email directory: userEmail/filename
 	drivePath := userDetails.Email + "/" + name
 
-	err = satellite.UploadObject(context.Background(), accesGrant, "google-drive", drivePath, data)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "google-drive", drivePath, data, satellite.ObjectMetadata{
+		Source:   "google",
+		SourceID: id,
+	})
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": fmt.Sprintf("failed to upload file to Satellite: %v", err),
@@ -592,14 +596,15 @@ func HandleSendFileFromSatelliteToGoogleDrive(c echo.Context) error {
 		})
 	}
 
-	data, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Drive, name)
+	download, err := satellite.OpenObject(context.Background(), accesGrant, satellite.ReserveBucket_Drive, name)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"error": fmt.Sprintf("failed to download object from Satellite: %v", err),
 		})
 	}
+	defer download.Close()
 
-	err = google.UploadFile(c, name, data)
+	err = google.UploadFile(c, name, download)
 	if err != nil {
 		return HandleGoogleDriveError(c, err, "upload file to Google Drive")
 	}
@@ -771,20 +776,23 @@ func HandleGoogleDriveDownloadAndRestore(c echo.Context) error {
 		}
 		key := key
 		g.Go(func() error {
-			data, err := satellite.DownloadObject(ctx, accessGrant, satellite.ReserveBucket_Drive, key)
+			download, err := satellite.OpenObject(ctx, accessGrant, satellite.ReserveBucket_Drive, key)
 			if err != nil {
 				logger.Warn(ctx, "Failed to download object", logger.String("key", key), logger.ErrorField(err))
 				failedKeys.Add(key)
 				return nil
 			}
 
+			object := download.Metadata()
 			var backupItem google.DriveBackupItem
-			if err := json.Unmarshal(data, &backupItem); err != nil {
+			err = json.NewDecoder(download).Decode(&backupItem)
+			download.Close()
+			if err != nil {
 				logger.Warn(ctx, "Failed to parse backup metadata", logger.String("key", key), logger.ErrorField(err))
 			}
 
 			metadataJSON, _ := json.Marshal(backupItem.Metadata)
-			if err := google.RestoreFromBackup(ctx, srv, userDetails.Email, metadataJSON, backupItem.Content); err != nil {
+			if err := google.RestoreFromBackup(ctx, srv, userDetails.Email, metadataJSON, backupItem.Content, object); err != nil {
 				logger.Warn(ctx, "Failed to restore item", logger.String("key", key), logger.ErrorField(err))
 				failedKeys.Add(key)
 			} else {
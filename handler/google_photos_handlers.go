@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -497,13 +496,6 @@ func HandleSendFileFromSatelliteToGooglePhotos(c echo.Context) error {
 		})
 	}
 
-	data, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Photos, name)
-	if err != nil {
-		return c.JSON(http.StatusForbidden, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
-
 	path := filepath.Join("./cache", utils.CreateUserTempCacheFolder(), name)
 	file, err := utils.CreateFile(path)
 	if err != nil {
@@ -511,14 +503,15 @@ func HandleSendFileFromSatelliteToGooglePhotos(c echo.Context) error {
 			"error": err.Error(),
 		})
 	}
-	_, err = file.Write(data)
+	defer os.Remove(path)
+
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.ReserveBucket_Photos, name, file)
+	file.Close()
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
-	file.Close()
-	defer os.Remove(path)
 
 	client, err := google.NewGPhotosClient(c)
 	if err != nil {
@@ -640,10 +633,6 @@ func uploadSingleFileFromPhotosToSatellite(ctx context.Context, client *google.G
 		return err
 	}
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
 
 	// Create path with user email directory: userEmail/filename
 	photoPath := userEmail + "/" + item.Filename
@@ -651,7 +640,8 @@ func uploadSingleFileFromPhotosToSatellite(ctx context.Context, client *google.G
 	// Use helper function to upload and sync to database
 	// Source and Type are automatically derived from bucket name (hardcoded)
 	// Source: "google", Type: "photos" (from bucket name "google-photos")
-	return UploadObjectAndSync(ctx, database, accesGrant, "google-photos", photoPath, body, userEmail)
+	_, err = UploadStreamAndSyncWithAccessGrant(ctx, database, accesGrant, "google-photos", photoPath, resp.Body, userEmail, google.PhotoObjectMetadata(item))
+	return err
 }
 
 func HandleSendAllFilesFromGooglePhotosToSatellite(c echo.Context) error {
@@ -860,21 +850,13 @@ func (s *PhotosService) RestorePhotosFromSatellite(ctx context.Context, keys []s
 			default:
 			}
 
-			// a. Download from Satellite
-			data, err := satellite.DownloadObject(ctx, s.accessGrant, satellite.ReserveBucket_Photos, key)
-			if err != nil {
-				logger.Error(ctx, "failed to download photo from satellite", logger.ErrorField(err), logger.String("key", key))
-				failedIDs.Add(key)
-				return nil
-			}
-
-			// b. Parse key
+			// a. Parse key
 			albumID, albumTitle, filename := parseGooglePhotosKey(key)
 			fmt.Println("Album ID: ", albumID)
 			fmt.Println("Album Title: ", albumTitle)
 			fmt.Println("Filename: ", filename)
 
-			// c. Save to unique temp file to avoid concurrent collision
+			// b. Create unique temp dir to avoid concurrent collision
 			tempDir := filepath.Join("./cache", utils.CreateUserTempCacheFolder(), uuid.NewString())
 			if err := os.MkdirAll(tempDir, 0755); err != nil {
 				failedIDs.Add(key)
@@ -882,8 +864,17 @@ func (s *PhotosService) RestorePhotosFromSatellite(ctx context.Context, keys []s
 			}
 			defer os.RemoveAll(tempDir)
 
+			// c. Download from Satellite straight into the temp file
 			tempPath := filepath.Join(tempDir, filename)
-			if err := os.WriteFile(tempPath, data, 0644); err != nil {
+			tempFile, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				failedIDs.Add(key)
+				return nil
+			}
+			_, err = satellite.DownloadStream(ctx, s.accessGrant, satellite.ReserveBucket_Photos, key, tempFile)
+			tempFile.Close()
+			if err != nil {
+				logger.Error(ctx, "failed to download photo from satellite", logger.ErrorField(err), logger.String("key", key))
 				failedIDs.Add(key)
 				return nil
 			}
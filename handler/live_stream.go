@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+)
+
+// streamKeepAlive is how often a comment is sent on an idle stream so proxies do not
+// close it
+const streamKeepAlive = 15 * time.Second
+
+// HandleAutomaticSyncLiveStream streams the progress of the user's running auto-sync
+// tasks as Server-Sent Events
+func HandleAutomaticSyncLiveStream(c echo.Context) error {
+	return streamTaskProgress(c, repo.TaskEventTypeAutoSync)
+}
+
+// HandleScheduledTasksLiveStream streams the progress of the user's running scheduled
+// tasks as Server-Sent Events
+func HandleScheduledTasksLiveStream(c echo.Context) error {
+	return streamTaskProgress(c, repo.TaskEventTypeScheduled)
+}
+
+// streamTaskProgress sends a "progress" event for every update of a task of the given
+// type. Running tasks are sent first, so a client that reconnects does not wait for the
+// next item to show them.
+func streamTaskProgress(c echo.Context, taskType string) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	updates, unsubscribe := progress.Default.Subscribe(userID, 64)
+	defer unsubscribe()
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case update, ok := <-updates:
+			if !ok {
+				// The hub was closed because the server is shutting down
+				return nil
+			}
+			if update.TaskType != taskType {
+				continue
+			}
+
+			data, err := json.Marshal(update)
+			if err != nil {
+				logger.Warn(ctx, "Failed to encode progress update", logger.ErrorField(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: progress\nid: %d-%d\ndata: %s\n\n", update.TaskID, update.At.UnixMilli(), data); err != nil {
+				return nil
+			}
+			w.Flush()
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		}
+	}
+}
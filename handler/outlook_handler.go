@@ -128,7 +128,7 @@ func parseMessageIDs(c echo.Context) ([]string, error) {
 
 // createOutlookClient creates a new Outlook client using the access token
 func createOutlookClient(accessToken string) (*outlook.OutlookClient, error) {
-	client, err := outlook.NewOutlookClientUsingToken(accessToken)
+	client, err := outlook.NewOutlookClientUsingToken(accessToken, "")
 	if err != nil {
 		return nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
@@ -327,7 +327,7 @@ func HandleListOutlookMessagesToSatellite(c echo.Context) error {
 		}
 
 		messagePath := userDetails.Mail + "/" + utils.GenerateTitleFromOutlookMessage(message)
-		err = UploadObjectAndSync(reqCtx, database, accessGrant, satellite.ReserveBucket_Outlook, messagePath, b, userID)
+		err = UploadObjectAndSyncWithAccessGrant(reqCtx, database, accessGrant, satellite.ReserveBucket_Outlook, messagePath, b, userID, msg.ObjectMetadata())
 		if err != nil {
 			logger.Error(reqCtx, "Failed to upload message to satellite",
 				logger.ErrorField(err), logger.String("id", id), logger.String("path", messagePath))
@@ -37,23 +37,18 @@ func HandleQuickbooksCustomersToSatellite(c echo.Context) error {
 
 	userCacheDBPath := "./cache/" + utils.CreateUserTempCacheFolder() + "/quickbooks.db"
 
-	byteDB, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.RestoreBucket_Quickbooks, "quickbooks.db")
 	// Copy file from satellite to local cache if everything's fine.
 	// Skip error check, if there's error - we will check that and create new file
-	if err == nil {
-		dbFile, err := utils.CreateFile(userCacheDBPath)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		_, err = dbFile.Write(byteDB)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		dbFile.Close()
+	dbFile, err := utils.CreateFile(userCacheDBPath)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.RestoreBucket_Quickbooks, "quickbooks.db", dbFile)
+	dbFile.Close()
+	if err != nil {
+		os.Remove(userCacheDBPath)
 	}
 
 	db, err := db.ConnectToQuickbooksDB()
@@ -74,13 +69,15 @@ func HandleQuickbooksCustomersToSatellite(c echo.Context) error {
 	// DELETE OLD DB COPY FROM SATELLITE UPLOAD UP TO DATE DB FILE BACK TO SATELLITE AND DELETE IT FROM LOCAL CACHE
 
 	// get db file data
-	dbByte, err := os.ReadFile(userCacheDBPath)
+	cachedDB, err := os.Open(userCacheDBPath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	defer cachedDB.Close()
+
 	// delete old db copy from satellite
 	err = satellite.DeleteObject(context.Background(), accesGrant, "quickbooks", "quickbooks.db")
 	if err != nil {
@@ -90,7 +87,10 @@ func HandleQuickbooksCustomersToSatellite(c echo.Context) error {
 	}
 
 	// upload file to satellite
-	err = satellite.UploadObject(context.Background(), accesGrant, "quickbooks", "quickbooks.db", dbByte)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "quickbooks", "quickbooks.db", cachedDB, satellite.ObjectMetadata{
+		Source:   "quickbooks",
+		MimeType: "application/vnd.sqlite3",
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -131,23 +131,18 @@ func HandleQuickbooksItemsToSatellite(c echo.Context) error {
 
 	userCacheDBPath := "./cache/" + utils.CreateUserTempCacheFolder() + "/quickbooks.db"
 
-	byteDB, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.RestoreBucket_Quickbooks, "quickbooks.db")
 	// Copy file from satellite to local cache if everything's fine.
 	// Skip error check, if there's error - we will check that and create new file
-	if err == nil {
-		dbFile, err := utils.CreateFile(userCacheDBPath)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		_, err = dbFile.Write(byteDB)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		dbFile.Close()
+	dbFile, err := utils.CreateFile(userCacheDBPath)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.RestoreBucket_Quickbooks, "quickbooks.db", dbFile)
+	dbFile.Close()
+	if err != nil {
+		os.Remove(userCacheDBPath)
 	}
 
 	db, err := db.ConnectToQuickbooksDB()
@@ -168,13 +163,15 @@ func HandleQuickbooksItemsToSatellite(c echo.Context) error {
 	// DELETE OLD DB COPY FROM SATELLITE UPLOAD UP TO DATE DB FILE BACK TO SATELLITE AND DELETE IT FROM LOCAL CACHE
 
 	// get db file data
-	dbByte, err := os.ReadFile(userCacheDBPath)
+	cachedDB, err := os.Open(userCacheDBPath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	defer cachedDB.Close()
+
 	// delete old db copy from satellite
 	err = satellite.DeleteObject(context.Background(), accesGrant, "quickbooks", "quickbooks.db")
 	if err != nil {
@@ -184,7 +181,10 @@ func HandleQuickbooksItemsToSatellite(c echo.Context) error {
 	}
 
 	// upload file to satellite
-	err = satellite.UploadObject(context.Background(), accesGrant, "quickbooks", "quickbooks.db", dbByte)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "quickbooks", "quickbooks.db", cachedDB, satellite.ObjectMetadata{
+		Source:   "quickbooks",
+		MimeType: "application/vnd.sqlite3",
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -225,23 +225,18 @@ func HandleQuickbooksInvoicesToSatellite(c echo.Context) error {
 
 	userCacheDBPath := "./cache/" + utils.CreateUserTempCacheFolder() + "/quickbooks.db"
 
-	byteDB, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.RestoreBucket_Quickbooks, "quickbooks.db")
 	// Copy file from satellite to local cache if everything's fine.
 	// Skip error check, if there's error - we will check that and create new file
-	if err == nil {
-		dbFile, err := utils.CreateFile(userCacheDBPath)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		_, err = dbFile.Write(byteDB)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		dbFile.Close()
+	dbFile, err := utils.CreateFile(userCacheDBPath)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.RestoreBucket_Quickbooks, "quickbooks.db", dbFile)
+	dbFile.Close()
+	if err != nil {
+		os.Remove(userCacheDBPath)
 	}
 
 	db, err := db.ConnectToQuickbooksDB()
@@ -262,13 +257,15 @@ func HandleQuickbooksInvoicesToSatellite(c echo.Context) error {
 	// DELETE OLD DB COPY FROM SATELLITE UPLOAD UP TO DATE DB FILE BACK TO SATELLITE AND DELETE IT FROM LOCAL CACHE
 
 	// get db file data
-	dbByte, err := os.ReadFile(userCacheDBPath)
+	cachedDB, err := os.Open(userCacheDBPath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	defer cachedDB.Close()
+
 	// delete old db copy from satellite
 	err = satellite.DeleteObject(context.Background(), accesGrant, "quickbooks", "quickbooks.db")
 	if err != nil {
@@ -278,7 +275,10 @@ func HandleQuickbooksInvoicesToSatellite(c echo.Context) error {
 	}
 
 	// upload file to satellite
-	err = satellite.UploadObject(context.Background(), accesGrant, "quickbooks", "quickbooks.db", dbByte)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "quickbooks", "quickbooks.db", cachedDB, satellite.ObjectMetadata{
+		Source:   "quickbooks",
+		MimeType: "application/vnd.sqlite3",
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/retention"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+)
+
+// HandleRetentionPreview shows which backups of a job its retention policy keeps and which
+// the sweeper would remove. Query parameters override rules of the stored policy, so a
+// policy can be tried before it is saved. Nothing is deleted.
+func HandleRetentionPreview(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	jobID, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		return sendJSONError(c, http.StatusBadRequest, "Invalid Job ID", err)
+	}
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return sendJSONError(c, http.StatusUnauthorized, "Invalid Request", err)
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	job, err := database.CronJobRepo.GetJobByIDForUser(userID, uint(jobID))
+	if err != nil {
+		return sendJSONError(c, http.StatusNotFound, "Job not found", err)
+	}
+
+	policy := job.Retention
+	for name, rule := range map[string]*int{
+		"keep_last":    &policy.KeepLast,
+		"max_age_days": &policy.MaxAgeDays,
+		"keep_daily":   &policy.KeepDaily,
+		"keep_weekly":  &policy.KeepWeekly,
+		"keep_monthly": &policy.KeepMonthly,
+	} {
+		if value := c.QueryParam(name); value != "" {
+			if *rule, err = strconv.Atoi(value); err != nil {
+				return sendJSONError(c, http.StatusBadRequest, "Invalid "+name, err)
+			}
+		}
+	}
+	if err := validateRetention(job.Method, job.SyncType, policy); err != nil {
+		return sendJSONError(c, http.StatusBadRequest, "Invalid retention policy", err)
+	}
+	if !provider.Supports(job.Method, provider.CapRetention) {
+		return sendJSONError(c, http.StatusBadRequest, "Retention is not supported for this job", nil)
+	}
+	if job.StorxToken == "" {
+		return sendJSONError(c, http.StatusBadRequest, "Job has no storage access grant", nil)
+	}
+
+	store, err := satellite.OpenStore(ctx, job.StorxToken)
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to open storage", err)
+	}
+	defer store.Close()
+
+	decisions, err := provider.RetentionPlan(ctx, store, job, policy, time.Now())
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to plan retention", err)
+	}
+
+	var kept, removed int
+	var freedBytes int64
+	for _, decision := range decisions {
+		if decision.Keep {
+			kept++
+			continue
+		}
+		removed++
+		freedBytes += decision.Size
+	}
+	if decisions == nil {
+		decisions = []retention.Decision{}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Retention preview",
+		"data": map[string]interface{}{
+			"policy":      policy,
+			"kept":        kept,
+			"removed":     removed,
+			"freed_bytes": freedBytes,
+			"backups":     decisions,
+		},
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/provider"
 	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	"github.com/labstack/echo/v4"
@@ -70,20 +71,26 @@ func HandleCreateScheduledTask(c echo.Context) error {
 		return jsonErrorMsg(http.StatusBadRequest, "method is required")
 	}
 
-	var email string
-	var config map[string]interface{}
-	switch method {
-	case "gmail", "google_photos", "google_drive":
-		email, config, err = processGmailAccessToken(accessToken)
-	case "outlook":
-		email, config, err = ProcessOutlookAccessToken(accessToken)
-	default:
-		return jsonErrorMsg(http.StatusBadRequest, "Unsupported method. Supported methods: gmail, outlook, google_photos, google_drive")
+	p, ok := provider.Lookup(method)
+	if !ok || !p.Capabilities().Has(provider.CapSelectiveSync) {
+		return jsonErrorMsg(http.StatusBadRequest, "Unsupported method. Supported methods: "+strings.Join(provider.Methods(provider.CapSelectiveSync), ", "))
 	}
+
+	email, config, err := p.Account(accessToken)
 	if err != nil {
 		return err
 	}
 
+	// A dry run only reports what the task would move
+	if isDryRun(c) {
+		return sendEstimate(c, method, provider.EstimateInput{
+			UserID:    userID,
+			Account:   email,
+			InputData: config,
+			ItemIDs:   itemIds,
+		})
+	}
+
 	statusItemsMap := make(map[string][]string)
 	statusItemsMap["pending"] = itemIds
 
@@ -345,8 +352,9 @@ func getOperationByMethod(method string) string {
 	return "Backup"
 }
 
-// Method processing functions
-func processGmailAccessToken(accessToken string) (string, map[string]interface{}, error) {
+// ProcessGoogleAccessToken resolves the Google account of an access token for the
+// scheduled tasks of Gmail, Google Drive and Google Photos
+func ProcessGoogleAccessToken(accessToken string) (string, map[string]interface{}, error) {
 	if accessToken == "" {
 		return "", nil, jsonErrorMsg(http.StatusBadRequest, "Access Token is required")
 	}
@@ -70,23 +70,18 @@ func HandleShopifyProductsToSatellite(c echo.Context) error {
 
 	userCacheDBPath := "./cache/" + utils.CreateUserTempCacheFolder() + "/shopify.db"
 
-	byteDB, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Shopify, "shopify.db")
 	// Copy file from satellite to local cache if everything's fine.
 	// Skip error check, if there's error - we will check that and create new file
-	if err == nil {
-		dbFile, err := utils.CreateFile(userCacheDBPath)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		_, err = dbFile.Write(byteDB)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		dbFile.Close()
+	dbFile, err := utils.CreateFile(userCacheDBPath)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.ReserveBucket_Shopify, "shopify.db", dbFile)
+	dbFile.Close()
+	if err != nil {
+		os.Remove(userCacheDBPath)
 	}
 
 	db, err := db.ConnectToShopifyDB()
@@ -107,13 +102,15 @@ func HandleShopifyProductsToSatellite(c echo.Context) error {
 	// DELETE OLD DB COPY FROM SATELLITE UPLOAD UP TO DATE DB FILE BACK TO SATELLITE AND DELETE IT FROM LOCAL CACHE
 
 	// get db file data
-	dbByte, err := os.ReadFile(userCacheDBPath)
+	cachedDB, err := os.Open(userCacheDBPath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	defer cachedDB.Close()
+
 	// delete old db copy from satellite
 	err = satellite.DeleteObject(context.Background(), accesGrant, "shopify", "shopify.db")
 	if err != nil {
@@ -123,7 +120,10 @@ func HandleShopifyProductsToSatellite(c echo.Context) error {
 	}
 
 	// upload file to satellite
-	err = satellite.UploadObject(context.Background(), accesGrant, "shopify", "shopify.db", dbByte)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "shopify", "shopify.db", cachedDB, satellite.ObjectMetadata{
+		Source:   "shopify",
+		MimeType: "application/vnd.sqlite3",
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -167,23 +167,18 @@ func HandleShopifyCustomersToSatellite(c echo.Context) error {
 
 	userCacheDBPath := "./cache/" + utils.CreateUserTempCacheFolder() + "/shopify.db"
 
-	byteDB, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Shopify, "shopify.db")
 	// Copy file from satellite to local cache if everything's fine.
 	// Skip error check, if there's error - we will check that and create new file
-	if err == nil {
-		dbFile, err := utils.CreateFile(userCacheDBPath)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		_, err = dbFile.Write(byteDB)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		dbFile.Close()
+	dbFile, err := utils.CreateFile(userCacheDBPath)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.ReserveBucket_Shopify, "shopify.db", dbFile)
+	dbFile.Close()
+	if err != nil {
+		os.Remove(userCacheDBPath)
 	}
 
 	db, err := db.ConnectToShopifyDB()
@@ -204,13 +199,15 @@ func HandleShopifyCustomersToSatellite(c echo.Context) error {
 	// DELETE OLD DB COPY FROM SATELLITE UPLOAD UP TO DATE DB FILE BACK TO SATELLITE AND DELETE IT FROM LOCAL CACHE
 
 	// get db file data
-	dbByte, err := os.ReadFile(userCacheDBPath)
+	cachedDB, err := os.Open(userCacheDBPath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	defer cachedDB.Close()
+
 	// delete old db copy from satellite
 	err = satellite.DeleteObject(context.Background(), accesGrant, "shopify", "shopify.db")
 	if err != nil {
@@ -220,7 +217,10 @@ func HandleShopifyCustomersToSatellite(c echo.Context) error {
 	}
 
 	// upload file to satellite
-	err = satellite.UploadObject(context.Background(), accesGrant, "shopify", "shopify.db", dbByte)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "shopify", "shopify.db", cachedDB, satellite.ObjectMetadata{
+		Source:   "shopify",
+		MimeType: "application/vnd.sqlite3",
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
@@ -265,23 +265,18 @@ func HandleShopifyOrdersToSatellite(c echo.Context) error {
 
 	userCacheDBPath := "./cache/" + utils.CreateUserTempCacheFolder() + "/shopify.db"
 
-	byteDB, err := satellite.DownloadObject(context.Background(), accesGrant, satellite.ReserveBucket_Shopify, "shopify.db")
 	// Copy file from satellite to local cache if everything's fine.
 	// Skip error check, if there's error - we will check that and create new file
-	if err == nil {
-		dbFile, err := utils.CreateFile(userCacheDBPath)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		_, err = dbFile.Write(byteDB)
-		if err != nil {
-			return c.JSON(http.StatusForbidden, map[string]interface{}{
-				"error": err.Error(),
-			})
-		}
-		dbFile.Close()
+	dbFile, err := utils.CreateFile(userCacheDBPath)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	_, err = satellite.DownloadStream(context.Background(), accesGrant, satellite.ReserveBucket_Shopify, "shopify.db", dbFile)
+	dbFile.Close()
+	if err != nil {
+		os.Remove(userCacheDBPath)
 	}
 
 	db, err := db.ConnectToShopifyDB()
@@ -302,13 +297,15 @@ func HandleShopifyOrdersToSatellite(c echo.Context) error {
 	// DELETE OLD DB COPY FROM SATELLITE UPLOAD UP TO DATE DB FILE BACK TO SATELLITE AND DELETE IT FROM LOCAL CACHE
 
 	// get db file data
-	dbByte, err := os.ReadFile(userCacheDBPath)
+	cachedDB, err := os.Open(userCacheDBPath)
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	defer cachedDB.Close()
+
 	// delete old db copy from satellite
 	err = satellite.DeleteObject(context.Background(), accesGrant, "shopify", "shopify.db")
 	if err != nil {
@@ -318,7 +315,10 @@ func HandleShopifyOrdersToSatellite(c echo.Context) error {
 	}
 
 	// upload file to satellite
-	err = satellite.UploadObject(context.Background(), accesGrant, "shopify", "shopify.db", dbByte)
+	_, err = satellite.UploadStream(context.Background(), accesGrant, "shopify", "shopify.db", cachedDB, satellite.ObjectMetadata{
+		Source:   "shopify",
+		MimeType: "application/vnd.sqlite3",
+	})
 	if err != nil {
 		return c.JSON(http.StatusForbidden, map[string]interface{}{
 			"error": err.Error(),
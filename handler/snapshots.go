@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxRestoreItems bounds the items one restore checks against the bucket
+const maxRestoreItems = 5000
+
+// HandleSnapshotList returns the latest snapshots of the user. before returns the
+// snapshots taken before a time (RFC 3339) or date, e.g. the state as of last Tuesday.
+func HandleSnapshotList(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return sendJSONError(c, http.StatusUnauthorized, "Invalid Request", err)
+	}
+
+	filter := repo.SnapshotFilter{BucketName: c.QueryParam("bucket")}
+	if account := c.QueryParam("account"); account != "" {
+		filter.Prefix = account + "/"
+	}
+	if value := c.QueryParam("job_id"); value != "" {
+		jobID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return sendJSONError(c, http.StatusBadRequest, "Invalid job_id", err)
+		}
+		filter.JobID = uint(jobID)
+	}
+	if value := c.QueryParam("before"); value != "" {
+		before, err := parseSnapshotTime(value)
+		if err != nil {
+			return sendJSONError(c, http.StatusBadRequest, "Invalid before", err)
+		}
+		filter.Before = &before
+	}
+
+	limit := 20
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > 100 {
+			return sendJSONError(c, http.StatusBadRequest, "limit must be between 1 and 100", err)
+		}
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	snapshots, err := database.SnapshotRepo.ListSnapshotsForUser(userID, filter, limit)
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to list snapshots", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": snapshots,
+	})
+}
+
+// parseSnapshotTime parses an RFC 3339 time or a date. A date means the end of that day
+// in UTC, so "before" a date includes the snapshots of the day.
+func parseSnapshotTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("expected an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// HandleSnapshotItems browses the items of a snapshot as they were when it was taken.
+// Unless recursive, items below the next "/" after prefix are returned as folders.
+func HandleSnapshotItems(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	snapshot, store, err := openSnapshot(c)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	limit, offset := 100, 0
+	if value := c.QueryParam("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > 1000 {
+			return sendJSONError(c, http.StatusBadRequest, "limit must be between 1 and 1000", err)
+		}
+	}
+	if value := c.QueryParam("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return sendJSONError(c, http.StatusBadRequest, "Invalid offset", err)
+		}
+	}
+	recursive, _ := strconv.ParseBool(c.QueryParam("recursive"))
+	prefix := c.QueryParam("prefix")
+
+	manifest, err := satellite.ReadManifest(ctx, store, snapshot.BucketName, snapshot.ManifestKey)
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to read snapshot manifest", err)
+	}
+
+	folders := []string{}
+	items := []satellite.ManifestItem{}
+	seen := map[string]bool{}
+	for _, item := range manifest.Items {
+		if !strings.HasPrefix(item.Key, prefix) {
+			continue
+		}
+		if !recursive {
+			if i := strings.Index(item.Key[len(prefix):], "/"); i >= 0 {
+				folder := item.Key[:len(prefix)+i+1]
+				if !seen[folder] {
+					seen[folder] = true
+					folders = append(folders, folder)
+				}
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Strings(folders)
+
+	total := len(items)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"snapshot": snapshot,
+			"prefix":   prefix,
+			"folders":  folders,
+			"items":    items[offset:end],
+			"total":    total,
+		},
+	})
+}
+
+// HandleSnapshotRestore restores items of a snapshot to the content they had when it was
+// taken. Items whose object still has that content are left alone; the others are copied
+// back from the content preserved with the snapshot. Items without preserved content, e.g.
+// of snapshots taken before content was preserved, can not be restored. Items are chosen
+// by keys, by prefix, or both. With dry_run nothing is copied.
+func HandleSnapshotRestore(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	var reqBody struct {
+		Keys   []string `json:"keys"`
+		Prefix string   `json:"prefix"`
+		DryRun bool     `json:"dry_run"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return sendJSONError(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+
+	snapshot, store, err := openSnapshot(c)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	manifest, err := satellite.ReadManifest(ctx, store, snapshot.BucketName, snapshot.ManifestKey)
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to read snapshot manifest", err)
+	}
+
+	wanted := make(map[string]bool, len(reqBody.Keys))
+	for _, key := range reqBody.Keys {
+		wanted[key] = false
+	}
+	var selected []satellite.ManifestItem
+	for _, item := range manifest.Items {
+		if _, ok := wanted[item.Key]; len(reqBody.Keys) > 0 && !ok {
+			continue
+		}
+		if strings.HasPrefix(item.Key, reqBody.Prefix) {
+			wanted[item.Key] = true
+			selected = append(selected, item)
+		}
+	}
+	if len(selected) > maxRestoreItems {
+		return sendJSONError(c, http.StatusBadRequest, "Too many items, narrow the selection down with keys or prefix", nil)
+	}
+	// Keys that were not selected were not part of the backup when the snapshot was taken
+	notInSnapshot := []string{}
+	for _, key := range reqBody.Keys {
+		if !wanted[key] {
+			notInSnapshot = append(notInSnapshot, key)
+		}
+	}
+	sort.Strings(notInSnapshot)
+
+	const (
+		unchanged = iota
+		restored
+		unavailable
+		failed
+	)
+	states := make([]int, len(selected))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(10)
+	for i, item := range selected {
+		i, item := i, item
+		g.Go(func() error {
+			object, err := store.Stat(gctx, snapshot.BucketName, item.Key)
+			switch {
+			case err == nil && item.Matches(*object):
+				states[i] = unchanged
+				return nil
+			case err != nil && !errors.Is(err, satellite.ErrObjectNotFound):
+				return err
+			}
+
+			preservedKey, ok := manifest.PreservedKey(item)
+			if !ok {
+				states[i] = unavailable
+				return nil
+			}
+			if _, err := store.Stat(gctx, snapshot.BucketName, preservedKey); err != nil {
+				if errors.Is(err, satellite.ErrObjectNotFound) {
+					states[i] = unavailable
+					return nil
+				}
+				return err
+			}
+			states[i] = restored
+			if reqBody.DryRun {
+				return nil
+			}
+			// One item that can not be copied back does not stop the others
+			if err := store.Copy(gctx, snapshot.BucketName, preservedKey, item.Key); err != nil {
+				logger.Warn(gctx, "Failed to restore snapshot item",
+					logger.Int("snapshot_id", int(snapshot.ID)),
+					logger.String("object_key", item.Key),
+					logger.ErrorField(err),
+				)
+				states[i] = failed
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "Failed to check snapshot items", err)
+	}
+
+	result := map[int][]satellite.ManifestItem{unchanged: {}, restored: {}, unavailable: {}, failed: {}}
+	for i, item := range selected {
+		result[states[i]] = append(result[states[i]], item)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"snapshot":        snapshot,
+			"dry_run":         reqBody.DryRun,
+			"restored":        result[restored],
+			"unchanged":       result[unchanged],
+			"unavailable":     result[unavailable],
+			"failed":          result[failed],
+			"not_in_snapshot": notInSnapshot,
+		},
+	})
+}
+
+// openSnapshot looks up the snapshot of the request's user and opens the store of the
+// request's access grant. Errors are HTTP errors for the client.
+func openSnapshot(c echo.Context) (*repo.Snapshot, satellite.ObjectStore, error) {
+	snapshotID, err := strconv.Atoi(c.Param("snapshot_id"))
+	if err != nil {
+		return nil, nil, jsonError(http.StatusBadRequest, "Invalid Snapshot ID", err)
+	}
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return nil, nil, jsonError(http.StatusUnauthorized, "Invalid Request", err)
+	}
+
+	accessGrant := c.Request().Header.Get("ACCESS_TOKEN")
+	if accessGrant == "" {
+		return nil, nil, jsonErrorMsg(http.StatusForbidden, "access token not found")
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	snapshot, err := database.SnapshotRepo.GetSnapshotForUser(userID, uint(snapshotID))
+	if err != nil {
+		return nil, nil, jsonError(http.StatusNotFound, "Snapshot not found", err)
+	}
+
+	store, err := satellite.OpenStore(c.Request().Context(), accessGrant)
+	if err != nil {
+		return nil, nil, jsonError(http.StatusInternalServerError, "Failed to open storage", err)
+	}
+	return snapshot, store, nil
+}
@@ -1,14 +1,16 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/satellite"
-	"storj.io/uplink"
 )
 
 // deriveSource derives source (provider) from bucket name
@@ -41,31 +43,126 @@ func deriveType(bucketName string) string {
 	}
 }
 
-// UploadObjectAndSync uploads data to Satellite storage and creates/updates the synced_objects table entry.
+// UploadObjectAndSyncWithAccessGrant is UploadObjectAndSync for callers that only hold an
+// access grant. It opens a store for the one upload.
+func UploadObjectAndSyncWithAccessGrant(
+	ctx context.Context,
+	database *db.PostgresDb,
+	accessGrant, bucketName, objectKey string,
+	data []byte,
+	userID string,
+	meta satellite.ObjectMetadata,
+) error {
+	if len(data) == 0 {
+		// Placeholders stay empty
+		meta.Compression = compress.None
+	}
+	_, err := UploadStreamAndSyncWithAccessGrant(ctx, database, accessGrant, bucketName, objectKey, bytes.NewReader(data), userID, meta)
+	return err
+}
+
+// UploadObjectAndSync uploads data to store and creates/updates the synced_objects table entry.
+// meta is stored with the object; its Source defaults to the provider of the bucket.
 // Returns error only if upload fails. Database tracking failures are logged but don't fail the operation.
 func UploadObjectAndSync(
 	ctx context.Context,
 	database *db.PostgresDb,
-	accessGrant, bucketName, objectKey string,
+	store satellite.ObjectStore,
+	bucketName, objectKey string,
 	data []byte,
 	userID string,
+	meta satellite.ObjectMetadata,
 ) error {
-	// Step 1: Upload to Satellite
-	if err := satellite.UploadObject(ctx, accessGrant, bucketName, objectKey, data); err != nil {
+	if len(data) == 0 {
+		// Placeholders stay empty
+		meta.Compression = compress.None
+	}
+	_, err := UploadStreamAndSync(ctx, database, store, bucketName, objectKey, bytes.NewReader(data), userID, meta)
+	return err
+}
+
+// UploadStreamAndSyncWithAccessGrant is UploadStreamAndSync for callers that only hold an
+// access grant. It opens a store for the one upload.
+func UploadStreamAndSyncWithAccessGrant(
+	ctx context.Context,
+	database *db.PostgresDb,
+	accessGrant, bucketName, objectKey string,
+	r io.Reader,
+	userID string,
+	meta satellite.ObjectMetadata,
+) (int64, error) {
+	store, err := satellite.OpenStore(ctx, accessGrant)
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload object to Satellite: %w", err)
+	}
+	defer store.Close()
+
+	return UploadStreamAndSync(ctx, database, store, bucketName, objectKey, r, userID, meta)
+}
+
+// UploadStreamAndSync is UploadObjectAndSync for data read from r, so files of any size are
+// moved without being held in memory. Returns the number of bytes uploaded.
+func UploadStreamAndSync(
+	ctx context.Context,
+	database *db.PostgresDb,
+	store satellite.ObjectStore,
+	bucketName, objectKey string,
+	r io.Reader,
+	userID string,
+	meta satellite.ObjectMetadata,
+) (int64, error) {
+	// Upload to Satellite
+	if meta.Source == "" {
+		meta.Source = deriveSource(bucketName)
+	}
+	written, err := store.Put(ctx, bucketName, objectKey, r, meta)
+	if err != nil {
 		logger.Error(ctx, "Failed to upload object to Satellite",
 			logger.String("bucket", bucketName),
 			logger.String("object_key", objectKey),
 			logger.ErrorField(err),
 		)
-		return fmt.Errorf("failed to upload object to Satellite: %w", err)
+		return written, fmt.Errorf("failed to upload object to Satellite: %w", err)
+	}
+
+	recordSyncedObject(ctx, database, bucketName, objectKey, userID, meta.Compression)
+	return written, nil
+}
+
+// UploadResumableAndSync is UploadStreamAndSync for content that is uploaded in parts and
+// resumed after a restart
+func UploadResumableAndSync(
+	ctx context.Context,
+	database *db.PostgresDb,
+	store satellite.ObjectStore,
+	upload satellite.ResumableUpload,
+	userID string,
+) (int64, error) {
+	if upload.Metadata.Source == "" {
+		upload.Metadata.Source = deriveSource(upload.Bucket)
+	}
+	written, err := satellite.UploadResumable(ctx, store, upload)
+	if err != nil {
+		logger.Error(ctx, "Failed to upload object to Satellite",
+			logger.String("bucket", upload.Bucket),
+			logger.String("object_key", upload.Key),
+			logger.ErrorField(err),
+		)
+		return written, fmt.Errorf("failed to upload object to Satellite: %w", err)
 	}
 
-	// Step 2: Derive source and type from bucket name
+	recordSyncedObject(ctx, database, upload.Bucket, upload.Key, userID, upload.Metadata.Compression)
+	return written, nil
+}
+
+// recordSyncedObject adds an uploaded object to the synced_objects table
+func recordSyncedObject(ctx context.Context, database *db.PostgresDb, bucketName, objectKey, userID, compression string) {
+	// Derive source and type from bucket name
 	source := deriveSource(bucketName)
 	objectType := deriveType(bucketName)
 
-	// Step 3: Update synced_objects table (non-blocking - log but don't fail)
-	if err := database.SyncedObjectRepo.CreateSyncedObject(userID, bucketName, objectKey, source, objectType); err != nil {
+	// Update synced_objects table (non-blocking - log but don't fail)
+	if err := database.SyncedObjectRepo.CreateSyncedObject(userID, bucketName, objectKey, source, objectType, compression); err != nil {
 		logger.Error(ctx, "Failed to create synced object entry after successful upload",
 			logger.String("bucket", bucketName),
 			logger.String("object_key", objectKey),
@@ -73,10 +170,7 @@ func UploadObjectAndSync(
 		)
 		// Note: Object is already uploaded to Satellite, but database tracking failed
 		// This is logged but we don't fail the entire operation
-		return nil
 	}
-
-	return nil
 }
 
 // GetSyncedObjectsWithPrefix ensures bucket exists, then gets synced objects from database instead of Satellite
@@ -88,25 +182,16 @@ func GetSyncedObjectsWithPrefix(
 	accessGrant, bucketName, prefix, userID, source, objectType string,
 ) (map[string]bool, error) {
 	// Step 1: Ensure bucket exists (create if needed)
-	access, err := uplink.ParseAccess(accessGrant)
-	if err != nil {
-		return nil, fmt.Errorf("parse access grant: %w", err)
-	}
-
-	project, err := uplink.OpenProject(ctx, access)
+	store, err := satellite.OpenStore(ctx, accessGrant)
 	if err != nil {
-		return nil, fmt.Errorf("open project: %w", err)
+		return nil, err
 	}
-	defer project.Close()
+	defer store.Close()
 
-	_, err = project.EnsureBucket(ctx, bucketName)
-	if err != nil {
-		_, err = project.CreateBucket(ctx, bucketName)
-		if err != nil {
-			logger.Warn(ctx, "Failed to create bucket, will be created on first upload if needed",
-				logger.String("bucket", bucketName),
-				logger.ErrorField(err))
-		}
+	if err := store.EnsureBucket(ctx, bucketName); err != nil {
+		logger.Warn(ctx, "Failed to create bucket, will be created on first upload if needed",
+			logger.String("bucket", bucketName),
+			logger.ErrorField(err))
 	}
 
 	// Step 2: Get synced objects from database
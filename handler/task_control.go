@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+)
+
+// taskAction describes a status change a user can request for a task
+type taskAction struct {
+	from      []string
+	to        string
+	jobStatus string
+	message   string
+}
+
+// autoSyncTaskActions are the status changes allowed for auto-sync tasks. A paused task
+// blocks new runs of its job until it is resumed or cancelled; the job keeps its
+// checkpoint, so a resumed task continues where the paused one stopped.
+var autoSyncTaskActions = map[string]taskAction{
+	"cancel": {
+		from:      []string{repo.TaskStatusPushed, repo.TaskStatusRunning, repo.TaskStatusPaused},
+		to:        repo.TaskStatusCancelled,
+		jobStatus: repo.JobStatusCancelled,
+		message:   "Automatic backup cancelled",
+	},
+	"pause": {
+		from:      []string{repo.TaskStatusPushed, repo.TaskStatusRunning},
+		to:        repo.TaskStatusPaused,
+		jobStatus: repo.JobStatusPaused,
+		message:   "Automatic backup paused",
+	},
+	"resume": {
+		from:      []string{repo.TaskStatusPaused},
+		to:        repo.TaskStatusPushed,
+		jobStatus: repo.JobStatusInQueue,
+		message:   "Automatic backup resumed",
+	},
+}
+
+// scheduledTaskActions are the status changes allowed for scheduled tasks. A paused task
+// keeps its pending and synced lists, so a resumed task only processes what is left.
+var scheduledTaskActions = map[string]taskAction{
+	"cancel": {
+		from: []string{repo.ScheduledTaskStatusCreated, repo.ScheduledTaskStatusRunning, repo.ScheduledTaskStatusPaused},
+		to:   repo.ScheduledTaskStatusCancelled,
+	},
+	"pause": {
+		from: []string{repo.ScheduledTaskStatusCreated, repo.ScheduledTaskStatusRunning},
+		to:   repo.ScheduledTaskStatusPaused,
+	},
+	"resume": {
+		from: []string{repo.ScheduledTaskStatusPaused},
+		to:   repo.ScheduledTaskStatusCreated,
+	},
+}
+
+// HandleAutomaticSyncCancelTask cancels a queued, running or paused auto-sync task
+func HandleAutomaticSyncCancelTask(c echo.Context) error {
+	return handleAutomaticSyncTaskAction(c, "cancel")
+}
+
+// HandleAutomaticSyncPauseTask pauses a queued or running auto-sync task
+func HandleAutomaticSyncPauseTask(c echo.Context) error {
+	return handleAutomaticSyncTaskAction(c, "pause")
+}
+
+// HandleAutomaticSyncResumeTask puts a paused auto-sync task back in the queue
+func HandleAutomaticSyncResumeTask(c echo.Context) error {
+	return handleAutomaticSyncTaskAction(c, "resume")
+}
+
+func handleAutomaticSyncTaskAction(c echo.Context, name string) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	action := autoSyncTaskActions[name]
+
+	taskID, err := strconv.Atoi(c.Param("task_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	task, err := database.TaskRepo.GetTaskByID(uint(taskID))
+	if err != nil {
+		return sendJSONError(c, http.StatusNotFound, "Task not found", err)
+	}
+
+	job, err := database.CronJobRepo.GetJobByIDForUser(userID, task.CronJobID)
+	if err != nil {
+		// Tasks of other users are reported as missing
+		return sendJSONError(c, http.StatusNotFound, "Task not found", err)
+	}
+
+	if name == "resume" && !job.Active {
+		return sendJSONError(c, http.StatusConflict, "Automatic backup is not active. Reactivate it before resuming the task", nil)
+	}
+
+	err = database.TaskRepo.TransitionTaskStatus(task.ID, action.from, action.to, action.jobStatus, action.message)
+	if errors.Is(err, repo.ErrTaskStatusConflict) {
+		return sendJSONError(c, http.StatusConflict, "Task cannot be "+pastTense(name)+" while it is "+task.Status, err)
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to update task status",
+			logger.Int("task_id", int(task.ID)),
+			logger.String("action", name),
+			logger.ErrorField(err),
+		)
+		return sendJSONError(c, http.StatusInternalServerError, "internal server error", err)
+	}
+
+	logger.Info(ctx, "Auto-sync task status changed by user",
+		logger.Int("task_id", int(task.ID)),
+		logger.Int("job_id", int(job.ID)),
+		logger.String("action", name),
+	)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": action.message,
+		"data": map[string]interface{}{
+			"task_id": task.ID,
+			"job_id":  job.ID,
+			"status":  action.to,
+		},
+	})
+}
+
+// HandleCancelScheduledTask cancels a queued, running or paused scheduled task
+func HandleCancelScheduledTask(c echo.Context) error {
+	return handleScheduledTaskAction(c, "cancel")
+}
+
+// HandlePauseScheduledTask pauses a queued or running scheduled task
+func HandlePauseScheduledTask(c echo.Context) error {
+	return handleScheduledTaskAction(c, "pause")
+}
+
+// HandleResumeScheduledTask puts a paused scheduled task back in the queue
+func HandleResumeScheduledTask(c echo.Context) error {
+	return handleScheduledTaskAction(c, "resume")
+}
+
+func handleScheduledTaskAction(c echo.Context, name string) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	action := scheduledTaskActions[name]
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	task, err := database.ScheduledTasksRepo.GetScheduledTaskByID(uint(taskID))
+	if err != nil || task.UserID != userID {
+		// Tasks of other users are reported as missing
+		return sendJSONError(c, http.StatusNotFound, "Task not found", err)
+	}
+
+	err = database.ScheduledTasksRepo.TransitionScheduledTaskStatus(task.ID, action.from, action.to)
+	if errors.Is(err, repo.ErrTaskStatusConflict) {
+		return sendJSONError(c, http.StatusConflict, "Task cannot be "+pastTense(name)+" while it is "+task.Status, err)
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to update scheduled task status",
+			logger.Int("task_id", int(task.ID)),
+			logger.String("action", name),
+			logger.ErrorField(err),
+		)
+		return sendJSONError(c, http.StatusInternalServerError, "internal server error", err)
+	}
+
+	logger.Info(ctx, "Scheduled task status changed by user",
+		logger.Int("task_id", int(task.ID)),
+		logger.String("action", name),
+	)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Scheduled task " + pastTense(name),
+		"data": map[string]interface{}{
+			"task_id": task.ID,
+			"status":  action.to,
+		},
+	})
+}
+
+func pastTense(action string) string {
+	switch action {
+	case "cancel":
+		return "cancelled"
+	case "pause":
+		return "paused"
+	default:
+		return "resumed"
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+)
+
+// HandleAutomaticSyncTaskEvents returns the timeline of an auto-sync task
+func HandleAutomaticSyncTaskEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	taskID, err := strconv.Atoi(c.Param("task_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	task, err := database.TaskRepo.GetTaskByID(uint(taskID))
+	if err != nil {
+		return sendJSONError(c, http.StatusNotFound, "Task not found", err)
+	}
+	if _, err := database.CronJobRepo.GetJobByIDForUser(userID, task.CronJobID); err != nil {
+		// Tasks of other users are reported as missing
+		return sendJSONError(c, http.StatusNotFound, "Task not found", err)
+	}
+
+	return sendTaskEvents(c, database, repo.TaskEventTypeAutoSync, task.ID)
+}
+
+// HandleScheduledTaskEvents returns the timeline of a scheduled task
+func HandleScheduledTaskEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "Invalid Request",
+			"error":   err.Error(),
+		})
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+
+	task, err := database.ScheduledTasksRepo.GetScheduledTaskByID(uint(taskID))
+	if err != nil || task.UserID != userID {
+		// Tasks of other users are reported as missing
+		return sendJSONError(c, http.StatusNotFound, "Task not found", err)
+	}
+
+	return sendTaskEvents(c, database, repo.TaskEventTypeScheduled, task.ID)
+}
+
+// sendTaskEvents writes a page of task events. The level query parameter limits the
+// result to one level, e.g. level=error lists only the items that failed.
+func sendTaskEvents(c echo.Context, database *db.PostgresDb, taskType string, taskID uint) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	level := c.QueryParam("level")
+	switch level {
+	case "", repo.TaskEventLevelInfo, repo.TaskEventLevelWarn, repo.TaskEventLevelError:
+	default:
+		return sendJSONError(c, http.StatusBadRequest, "Invalid level. Expected one of: info, warn, error", nil)
+	}
+
+	events, total, err := database.TaskEventRepo.ListTaskEvents(taskType, taskID, repo.TaskEventFilter{
+		Level:  level,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return sendJSONError(c, http.StatusInternalServerError, "internal server error", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Task events",
+		"data":    events,
+		"pagination": map[string]interface{}{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/middleware"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/schedule"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	"github.com/labstack/echo/v4"
+)
+
+// userLocation returns the time zone to use for a user. An explicit override (e.g. a
+// query parameter) wins over the saved user setting.
+func userLocation(database *db.PostgresDb, userID, override string) (*time.Location, error) {
+	if override != "" {
+		return schedule.LoadLocation(override)
+	}
+
+	settings, err := database.UserSettingsRepo.GetUserSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.LoadLocation(settings.Timezone)
+}
+
+// HandleGetUserSettings returns the settings for the authenticated user
+func HandleGetUserSettings(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "not able to authenticate user",
+			"error":   err.Error(),
+		})
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+	settings, err := database.UserSettingsRepo.GetUserSettings(userID)
+	if err != nil {
+		logger.Error(ctx, "Failed to get user settings", logger.ErrorField(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "User Settings",
+		"data":    settings,
+	})
+}
+
+// HandleUpdateUserSettings saves the settings for the authenticated user. The time zone
+// becomes the default for new jobs; existing jobs keep their own time zone. New blackout
+// windows apply to all of the user's jobs right away.
+func HandleUpdateUserSettings(c echo.Context) error {
+	ctx := c.Request().Context()
+	var err error
+	defer monitor.Mon.Task()(&ctx)(&err)
+
+	userID, err := satellite.GetUserdetails(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+			"message": "not able to authenticate user",
+			"error":   err.Error(),
+		})
+	}
+
+	var reqBody struct {
+		Timezone        *string `json:"timezone"`
+		BlackoutWindows *string `json:"blackout_windows"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"message": "Invalid request body",
+			"error":   err.Error(),
+		})
+	}
+
+	// Only the settings the request sets are changed; the others keep their saved values
+	settings := &repo.UserSettings{UserID: userID}
+	var columns []string
+	if reqBody.Timezone != nil {
+		settings.Timezone = strings.TrimSpace(*reqBody.Timezone)
+		if _, err := schedule.LoadLocation(settings.Timezone); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "Invalid Request",
+				"error":   err.Error(),
+			})
+		}
+		columns = append(columns, "timezone")
+	}
+	if reqBody.BlackoutWindows != nil {
+		settings.BlackoutWindows = strings.TrimSpace(*reqBody.BlackoutWindows)
+		if _, err := schedule.ParseBlackouts(settings.BlackoutWindows, nil); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"message": "Invalid Request",
+				"error":   err.Error(),
+			})
+		}
+		columns = append(columns, "blackout_windows")
+	}
+
+	database := c.Get(middleware.DbContextKey).(*db.PostgresDb)
+	if err := database.UserSettingsRepo.UpsertUserSettings(settings, columns...); err != nil {
+		logger.Error(ctx, "Failed to save user settings", logger.ErrorField(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+	}
+
+	// Move scheduled backups out of the new blackout windows
+	if reqBody.BlackoutWindows != nil {
+		if _, err := database.CronJobRepo.RecomputeNextRunAtForUser(userID); err != nil {
+			logger.Warn(ctx, "Failed to reschedule jobs for new blackout windows", logger.ErrorField(err))
+		}
+	}
+
+	settings, err = database.UserSettingsRepo.GetUserSettings(userID)
+	if err != nil {
+		logger.Error(ctx, "Failed to get user settings", logger.ErrorField(err))
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+	}
+
+	logger.Info(ctx, "User settings updated",
+		logger.String("timezone", settings.Timezone),
+		logger.String("blackout_windows", settings.BlackoutWindows))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "User settings updated successfully",
+		"data":    settings,
+	})
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/StorX2-0/Backup-Tools/db"
@@ -31,7 +32,13 @@ func InitializeAllMiddleware(e *echo.Echo, db *db.PostgresDb) {
 	e.Use(MonkitMiddleware())
 	e.Use(DBMiddleware(db))
 	e.Use(echomiddleware.CORS())
-	e.Use(echomiddleware.Gzip())
+	e.Use(echomiddleware.GzipWithConfig(echomiddleware.GzipConfig{
+		// Event streams are flushed per event and must not be buffered by the compressor.
+		// Archives are compressed already and their byte ranges must stay exact.
+		Skipper: func(c echo.Context) bool {
+			return strings.HasSuffix(c.Path(), "/live/stream") || c.Path() == "/archive"
+		},
+	}))
 }
 
 func DBMiddleware(db *db.PostgresDb) echo.MiddlewareFunc {
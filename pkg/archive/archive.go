@@ -0,0 +1,335 @@
+// Package archive streams ZIP and TAR archives of backed up objects. ZIP and TAR entries
+// are stored uncompressed, so the size of an archive is known before it is written and
+// any byte range of it can be produced again; TAR.GZ archives can only be streamed whole.
+// A range of a ZIP archive is only cheap if the checksums of its entries are known, see
+// Archive.Resumable.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Formats an archive can be written in
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// Parse validates the name of a format; "tgz" is accepted for FormatTarGz
+func Parse(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", FormatZip:
+		return FormatZip, nil
+	case FormatTar:
+		return FormatTar, nil
+	case FormatTarGz, "tgz":
+		return FormatTarGz, nil
+	default:
+		return "", fmt.Errorf("unsupported archive format %q, use zip, tar or tar.gz", format)
+	}
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatTar:
+		return "application/x-tar"
+	case FormatTarGz:
+		return "application/gzip"
+	default:
+		return "application/zip"
+	}
+}
+
+// Entry is one file of an archive
+type Entry struct {
+	// Name is the path of the file in the archive, see Name
+	Name string
+	// ID identifies the content for Archive.Open, e.g. an object key
+	ID       string
+	Size     int64
+	Modified time.Time
+	// CRC32 is the checksum of the content if it is known, e.g. recorded at upload. ZIP
+	// archives need it to skip content before a range.
+	CRC32 *uint32
+}
+
+// Name turns an object key into a relative path that cannot escape the directory the
+// archive is extracted to
+func Name(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+// ErrSizeMismatch is returned when the content of an entry is not as long as its Size,
+// e.g. because the object changed after it was listed
+var ErrSizeMismatch = errors.New("archive: content size does not match entry")
+
+// errRangeWritten stops writing once the requested range was written
+var errRangeWritten = errors.New("archive: range written")
+
+// zeros is written in place of content that is not part of the requested range
+var zeros = make([]byte, 1<<20)
+
+// Archive is an archive of entries
+type Archive struct {
+	Format  string
+	Entries []Entry
+	// Open opens the content of an entry
+	Open func(ctx context.Context, entry Entry) (io.ReadCloser, error)
+	// Progress is called after the content of an entry within the written range was
+	// written
+	Progress func(entry Entry)
+}
+
+// Size returns the exact size of the archive, or -1 for compressed formats
+func (a *Archive) Size() int64 {
+	if a.Format == FormatTarGz {
+		return -1
+	}
+	counter := &window{w: io.Discard, end: -1}
+	if err := a.write(context.Background(), counter, true); err != nil {
+		return -1
+	}
+	return counter.pos
+}
+
+// Resumable reports whether a range of the archive can be written without reading the
+// content before it: always for TAR, for ZIP only if the checksum of every entry is known.
+func (a *Archive) Resumable() bool {
+	switch a.Format {
+	case FormatTar:
+		return true
+	case FormatZip:
+		for _, entry := range a.Entries {
+			if entry.CRC32 == nil {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// ETag identifies the archive. Archives with the same ETag are byte for byte the same as
+// long as the content of their entries did not change.
+func (a *Archive) ETag() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", a.Format)
+	for _, entry := range a.Entries {
+		fmt.Fprintf(h, "%q %d %d\n", entry.Name, entry.Size, entry.Modified.Unix())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// WriteRange writes length bytes of the archive starting at offset to w. A negative
+// length writes the rest of the archive. Ranges not starting at 0 need a format whose
+// Size is known.
+func (a *Archive) WriteRange(ctx context.Context, w io.Writer, offset, length int64) error {
+	if offset > 0 && a.Format == FormatTarGz {
+		return fmt.Errorf("archive: %s archives cannot be written from an offset", a.Format)
+	}
+	end := int64(-1)
+	if length >= 0 {
+		end = offset + length
+	}
+	err := a.write(ctx, &window{w: w, start: offset, end: end}, false)
+	if errors.Is(err, errRangeWritten) {
+		return nil
+	}
+	return err
+}
+
+// Write writes the whole archive to w
+func (a *Archive) Write(ctx context.Context, w io.Writer) error {
+	return a.WriteRange(ctx, w, 0, -1)
+}
+
+// write produces the archive into out. When sizing, no content is read and zeros are
+// written instead; only the length of the output is of interest then.
+func (a *Archive) write(ctx context.Context, out *window, sizing bool) error {
+	switch a.Format {
+	case FormatZip:
+		return a.writeZip(ctx, out, sizing)
+	case FormatTar:
+		return a.writeTar(ctx, out, out, sizing)
+	case FormatTarGz:
+		gz := gzip.NewWriter(out)
+		if err := a.writeTar(ctx, gz, out, sizing); err != nil {
+			return err
+		}
+		return gz.Close()
+	default:
+		return fmt.Errorf("archive: unsupported format %q", a.Format)
+	}
+}
+
+func (a *Archive) writeZip(ctx context.Context, out *window, sizing bool) error {
+	zw := zip.NewWriter(out)
+	for _, entry := range a.Entries {
+		// Entries are written raw with a data descriptor, so the headers do not depend on
+		// the content and the size of the archive is known up front
+		fh := &zip.FileHeader{
+			Name:               entry.Name,
+			Method:             zip.Store,
+			Flags:              0x8,
+			CompressedSize64:   uint64(entry.Size),
+			UncompressedSize64: uint64(entry.Size),
+		}
+		if !isASCII(entry.Name) && utf8.ValidString(entry.Name) {
+			fh.Flags |= 0x800
+		}
+		if entry.Size >= 1<<32-1 {
+			fh.ReaderVersion = 45
+		}
+		fh.SetModTime(entry.Modified)
+
+		fw, err := zw.CreateRaw(fh)
+		if err != nil {
+			return err
+		}
+		// The writer buffers; the position must include the header before it is compared
+		// with the range
+		if err := zw.Flush(); err != nil {
+			return err
+		}
+		// The central directory at the end needs the checksum of every entry, so content
+		// before the range is only skipped if its checksum is known
+		skip := sizing || (entry.CRC32 != nil && out.pos+entry.Size <= out.start)
+		crc := crc32.NewIEEE()
+		if err := a.copyContent(ctx, entry, fw, crc, out, skip); err != nil {
+			return err
+		}
+		fh.CRC32 = crc.Sum32()
+		if skip && entry.CRC32 != nil {
+			fh.CRC32 = *entry.CRC32
+		}
+	}
+	return zw.Close()
+}
+
+// writeTar writes the tar stream to w; out is the window at the end of the chain
+func (a *Archive) writeTar(ctx context.Context, w io.Writer, out *window, sizing bool) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range a.Entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Name,
+			Size:     entry.Size,
+			Mode:     0o644,
+			ModTime:  entry.Modified.Truncate(time.Second),
+		}); err != nil {
+			return err
+		}
+		// Content before the range is not needed for anything, it is skipped unread
+		skip := sizing || (a.Format == FormatTar && out.pos+entry.Size <= out.start)
+		if err := a.copyContent(ctx, entry, tw, nil, out, skip); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// copyContent writes the content of entry to w, also to crc if not nil. When skip is
+// set zeros are written instead.
+func (a *Archive) copyContent(ctx context.Context, entry Entry, w io.Writer, crc io.Writer, out *window, skip bool) error {
+	if skip {
+		for remaining := entry.Size; remaining > 0; {
+			n := int64(len(zeros))
+			if remaining < n {
+				n = remaining
+			}
+			if _, err := w.Write(zeros[:n]); err != nil {
+				return err
+			}
+			remaining -= n
+		}
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r, err := a.Open(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", entry.ID, err)
+	}
+	defer r.Close()
+
+	var src io.Reader = r
+	if crc != nil {
+		src = io.TeeReader(r, crc)
+	}
+	n, err := io.CopyN(w, src, entry.Size)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w: got %d of %d bytes", entry.ID, ErrSizeMismatch, n, entry.Size)
+		}
+		return err
+	}
+	if extra, _ := r.Read(make([]byte, 1)); extra > 0 {
+		return fmt.Errorf("%s: %w: longer than %d bytes", entry.ID, ErrSizeMismatch, entry.Size)
+	}
+
+	if a.Progress != nil && out.pos > out.start {
+		a.Progress(entry)
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// window passes the bytes of the archive between start and end on to w and counts all
+// of them. end is negative for the end of the archive.
+type window struct {
+	w          io.Writer
+	start, end int64
+	pos        int64
+}
+
+func (wd *window) Write(p []byte) (int, error) {
+	from := wd.pos
+	to := from + int64(len(p))
+	if wd.end >= 0 && from >= wd.end {
+		return 0, errRangeWritten
+	}
+	wd.pos = to
+
+	lo, hi := from, to
+	if lo < wd.start {
+		lo = wd.start
+	}
+	if wd.end >= 0 && hi > wd.end {
+		hi = wd.end
+	}
+	if lo < hi {
+		if _, err := wd.w.Write(p[lo-from : hi-from]); err != nil {
+			return 0, err
+		}
+	}
+	if wd.end >= 0 && to >= wd.end {
+		return len(p), errRangeWritten
+	}
+	return len(p), nil
+}
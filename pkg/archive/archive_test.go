@@ -0,0 +1,186 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testArchive returns an archive of a few files and counts the entries opened
+func testArchive(format string, opened *int) (*Archive, map[string]string) {
+	contents := map[string]string{
+		"user@example.com/inbox/1.json":                                     strings.Repeat("message one ", 500),
+		"user@example.com/inbox/2.json":                                     "message two",
+		"user@example.com/empty.txt":                                        "",
+		"user@example.com/Überblick.pdf":                                    strings.Repeat("ü", 300),
+		"user@example.com/" + strings.Repeat("long-name/", 12) + "file.txt": "a long path",
+	}
+
+	modified := time.Date(2026, 3, 15, 12, 30, 0, 0, time.UTC)
+	archive := &Archive{
+		Format: format,
+		Open: func(ctx context.Context, entry Entry) (io.ReadCloser, error) {
+			*opened++
+			return io.NopCloser(strings.NewReader(contents[entry.ID])), nil
+		},
+	}
+	for _, key := range []string{
+		"user@example.com/inbox/1.json",
+		"user@example.com/inbox/2.json",
+		"user@example.com/empty.txt",
+		"user@example.com/Überblick.pdf",
+		"user@example.com/" + strings.Repeat("long-name/", 12) + "file.txt",
+	} {
+		archive.Entries = append(archive.Entries, Entry{Name: Name(key), ID: key, Size: int64(len(contents[key])), Modified: modified})
+	}
+	return archive, contents
+}
+
+func TestZip(t *testing.T) {
+	var opened int
+	archive, contents := testArchive(FormatZip, &opened)
+
+	var buf bytes.Buffer
+	require.NoError(t, archive.Write(context.Background(), &buf))
+	assert.Equal(t, int64(buf.Len()), archive.Size())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, len(contents))
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err, "checksum of %s", f.Name)
+		assert.Equal(t, contents[f.Name], string(data))
+	}
+}
+
+func TestTar(t *testing.T) {
+	for _, format := range []string{FormatTar, FormatTarGz} {
+		t.Run(format, func(t *testing.T) {
+			var opened int
+			archive, contents := testArchive(format, &opened)
+
+			var buf bytes.Buffer
+			require.NoError(t, archive.Write(context.Background(), &buf))
+
+			var r io.Reader = &buf
+			if format == FormatTarGz {
+				assert.Equal(t, int64(-1), archive.Size())
+				gz, err := gzip.NewReader(&buf)
+				require.NoError(t, err)
+				r = gz
+			} else {
+				assert.Equal(t, int64(buf.Len()), archive.Size())
+			}
+
+			tr := tar.NewReader(r)
+			files := 0
+			for {
+				hdr, err := tr.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				data, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				assert.Equal(t, contents[hdr.Name], string(data))
+				files++
+			}
+			assert.Equal(t, len(contents), files)
+		})
+	}
+}
+
+func TestWriteRange(t *testing.T) {
+	for _, format := range []string{FormatZip, FormatTar} {
+		t.Run(format, func(t *testing.T) {
+			var opened int
+			archive, _ := testArchive(format, &opened)
+
+			var full bytes.Buffer
+			require.NoError(t, archive.Write(context.Background(), &full))
+
+			for _, r := range [][2]int64{{0, 10}, {100, 1000}, {6000, -1}, {int64(full.Len()) - 1, 1}} {
+				var part bytes.Buffer
+				require.NoError(t, archive.WriteRange(context.Background(), &part, r[0], r[1]))
+				end := int64(full.Len())
+				if r[1] >= 0 {
+					end = r[0] + r[1]
+				}
+				assert.Equal(t, full.Bytes()[r[0]:end], part.Bytes(), "range %v", r)
+			}
+
+			// Content before the range is only read for the checksums of ZIP archives
+			opened = 0
+			require.NoError(t, archive.WriteRange(context.Background(), io.Discard, int64(full.Len())-1, -1))
+			if format == FormatTar {
+				assert.True(t, archive.Resumable())
+				assert.Equal(t, 0, opened)
+			} else {
+				assert.False(t, archive.Resumable())
+				assert.Equal(t, len(archive.Entries), opened)
+			}
+		})
+	}
+
+	// With recorded checksums ZIP ranges skip the content before them as well
+	t.Run("zip with checksums", func(t *testing.T) {
+		var opened int
+		archive, contents := testArchive(FormatZip, &opened)
+		for i := range archive.Entries {
+			sum := crc32.ChecksumIEEE([]byte(contents[archive.Entries[i].ID]))
+			archive.Entries[i].CRC32 = &sum
+		}
+		assert.True(t, archive.Resumable())
+
+		var full bytes.Buffer
+		require.NoError(t, archive.Write(context.Background(), &full))
+		_, err := zip.NewReader(bytes.NewReader(full.Bytes()), int64(full.Len()))
+		require.NoError(t, err)
+
+		opened = 0
+		var part bytes.Buffer
+		require.NoError(t, archive.WriteRange(context.Background(), &part, 6200, -1))
+		assert.Equal(t, full.Bytes()[6200:], part.Bytes())
+		assert.Less(t, opened, len(archive.Entries))
+
+		opened = 0
+		require.NoError(t, archive.WriteRange(context.Background(), io.Discard, int64(full.Len())-1, -1))
+		assert.Equal(t, 0, opened)
+	})
+
+	var opened int
+	archive, _ := testArchive(FormatTarGz, &opened)
+	assert.Error(t, archive.WriteRange(context.Background(), io.Discard, 10, -1))
+}
+
+func TestSizeMismatch(t *testing.T) {
+	var opened int
+	archive, _ := testArchive(FormatZip, &opened)
+	archive.Entries[1].Size++
+	err := archive.Write(context.Background(), io.Discard)
+	assert.True(t, errors.Is(err, ErrSizeMismatch))
+
+	archive.Entries[1].Size -= 2
+	err = archive.Write(context.Background(), io.Discard)
+	assert.True(t, errors.Is(err, ErrSizeMismatch))
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "user@example.com/a.txt", Name("user@example.com/a.txt"))
+	assert.Equal(t, "etc/passwd", Name("../../etc/passwd"))
+	assert.Equal(t, "a/b", Name("/a/./x/../b"))
+}
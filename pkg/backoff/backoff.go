@@ -0,0 +1,78 @@
+// Package backoff computes retry delays: exponential growth from a base delay, capped,
+// with jitter so that tasks which failed together do not retry together.
+package backoff
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Policy describes how failed work is retried
+type Policy struct {
+	// BaseDelay is the delay before the first retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts
+	MaxDelay time.Duration
+	// MaxRetries is the number of retries after the first attempt; zero disables retries
+	MaxRetries int
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewPolicy creates a retry policy
+func NewPolicy(baseDelay, maxDelay time.Duration, maxRetries int) *Policy {
+	return &Policy{
+		BaseDelay:  baseDelay,
+		MaxDelay:   maxDelay,
+		MaxRetries: maxRetries,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// ShouldRetry reports whether another attempt is allowed after the given attempt failed.
+// Attempts are numbered from 1.
+func (p *Policy) ShouldRetry(attempt int) bool {
+	return attempt >= 1 && attempt <= p.MaxRetries
+}
+
+// Delay returns how long to wait before the attempt following the given failed attempt.
+// The nominal delay doubles with every attempt up to MaxDelay; the result is picked
+// uniformly from the upper half of it ("equal jitter"), so it never drops below half the
+// nominal delay.
+func (p *Policy) Delay(attempt int) time.Duration {
+	nominal := p.nominal(attempt)
+	if nominal <= 1 {
+		return nominal
+	}
+
+	half := nominal / 2
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return half + time.Duration(p.rand.Int63n(int64(nominal-half)+1))
+}
+
+func (p *Policy) nominal(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+		if delay <= 0 {
+			// Overflow; treat as the cap
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,39 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayGrowsAndIsCapped(t *testing.T) {
+	p := NewPolicy(time.Minute, 10*time.Minute, 5)
+
+	for _, tc := range []struct {
+		attempt int
+		nominal time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{60, 10 * time.Minute},
+	} {
+		for i := 0; i < 50; i++ {
+			d := p.Delay(tc.attempt)
+			assert.GreaterOrEqual(t, d, tc.nominal/2, "attempt %d", tc.attempt)
+			assert.LessOrEqual(t, d, tc.nominal, "attempt %d", tc.attempt)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	p := NewPolicy(time.Minute, time.Hour, 3)
+	assert.True(t, p.ShouldRetry(1))
+	assert.True(t, p.ShouldRetry(3))
+	assert.False(t, p.ShouldRetry(4))
+
+	assert.False(t, NewPolicy(time.Minute, time.Hour, 0).ShouldRetry(1))
+}
@@ -0,0 +1,125 @@
+// Package cluster holds the pieces needed to run several replicas of the service
+// against the same database: a stable instance identity used to mark claimed rows
+// and an optional Postgres advisory-lock leader election for periodic sweeps.
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	instanceID     string
+	instanceIDOnce sync.Once
+)
+
+// InstanceID returns the identifier of this process. It is taken from the INSTANCE_ID
+// environment variable when set, otherwise it is derived from the hostname, pid and a
+// random suffix so two replicas never share an ID.
+func InstanceID() string {
+	instanceIDOnce.Do(func() {
+		if id := os.Getenv("INSTANCE_ID"); id != "" {
+			instanceID = id
+			return
+		}
+
+		host, err := os.Hostname()
+		if err != nil || host == "" {
+			host = "unknown"
+		}
+
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			instanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+			return
+		}
+		instanceID = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+	})
+	return instanceID
+}
+
+// LeaderElector decides which replica runs the periodic sweeps (task creation,
+// heartbeat checks). When disabled every instance considers itself the leader,
+// which is the single-replica behaviour.
+type LeaderElector struct {
+	db      *sql.DB
+	lockKey int64
+	enabled bool
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewLeaderElector creates an elector that competes for the Postgres advisory lock
+// identified by lockKey. If enabled is false or db is nil the elector always reports
+// leadership.
+func NewLeaderElector(db *sql.DB, lockKey int64, enabled bool) *LeaderElector {
+	return &LeaderElector{
+		db:      db,
+		lockKey: lockKey,
+		enabled: enabled && db != nil,
+	}
+}
+
+// IsLeader reports whether this instance currently holds leadership, trying to
+// acquire it if not. Advisory locks are bound to a session, so the lock is taken on a
+// dedicated connection that is kept open for as long as the instance leads. If that
+// connection dies Postgres releases the lock and another replica can take over.
+func (l *LeaderElector) IsLeader(ctx context.Context) (bool, error) {
+	if !l.enabled {
+		return true, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// The session is gone and took the lock with it
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection for leader election: %w", err)
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.lockKey).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, fmt.Errorf("failed to acquire advisory lock: %w", err)
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// Resign gives up leadership, if held, so another replica can take over
+func (l *LeaderElector) Resign(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.lockKey)
+	l.conn.Close()
+	l.conn = nil
+	if err != nil {
+		return fmt.Errorf("failed to release advisory lock: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// listenRetryDelay is how long Listen waits before listening again after its
+// connection failed
+const listenRetryDelay = 5 * time.Second
+
+// Notifier passes messages between the replicas over a Postgres notification channel.
+// Notifications are not stored: replicas only receive what is sent while they listen.
+type Notifier struct {
+	db      *sql.DB
+	channel string
+}
+
+// NewNotifier creates a notifier on channel. db must use the pgx driver.
+func NewNotifier(db *sql.DB, channel string) *Notifier {
+	return &Notifier{db: db, channel: channel}
+}
+
+// Notify sends payload to every replica listening on the channel, this one included.
+// Postgres limits payloads to 8000 bytes.
+func (n *Notifier) Notify(ctx context.Context, payload string) error {
+	if _, err := n.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", n.channel, payload); err != nil {
+		return fmt.Errorf("failed to notify %s: %w", n.channel, err)
+	}
+	return nil
+}
+
+// Listen calls fn with the payload of every notification on the channel until ctx is
+// done. The connection is reestablished when it fails; notifications sent meanwhile are
+// missed.
+func (n *Notifier) Listen(ctx context.Context, fn func(payload string)) {
+	for {
+		err := n.listen(ctx, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn(ctx, "Listening for notifications failed, retrying",
+			logger.String("channel", n.channel),
+			logger.ErrorField(err),
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (n *Notifier) listen(ctx context.Context, fn func(payload string)) error {
+	conn, err := n.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("notifications need the pgx driver")
+		}
+		pgConn := stdConn.Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{n.channel}.Sanitize()); err != nil {
+			return err
+		}
+		for {
+			notification, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			fn(notification.Payload)
+		}
+	})
+}
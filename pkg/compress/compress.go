@@ -0,0 +1,103 @@
+// Package compress implements the compression applied to backup payloads before they
+// are uploaded, as streaming stages so payloads are never held in memory.
+package compress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Algorithms a job can compress its payloads with. None leaves them as they are.
+const (
+	None = ""
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Parse validates the name of an algorithm; "none" is accepted for None
+func Parse(name string) (string, error) {
+	switch name {
+	case None, "none":
+		return None, nil
+	case Gzip, Zstd:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q, expected gzip, zstd or none", name)
+	}
+}
+
+// Extension returns the file name extension of payloads compressed with algorithm
+func Extension(algorithm string) string {
+	switch algorithm {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// NewWriter returns a writer that compresses to w. Closing it flushes the compressed
+// stream but does not close w.
+func NewWriter(w io.Writer, algorithm string) (io.WriteCloser, error) {
+	switch algorithm {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algorithm)
+	}
+}
+
+// NewReader returns a reader that decompresses r
+func NewReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", algorithm)
+	}
+}
+
+// Reader returns the compressed form of what is read from r. The compression runs while
+// the result is read; closing the result stops it.
+func Reader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	if algorithm == None {
+		return io.NopCloser(r), nil
+	}
+
+	pr, pw := io.Pipe()
+	w, err := NewWriter(pw, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, err := io.Copy(w, r)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,43 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderRoundTrips(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"subject":"weekly report","body":"..."}`), 5000)
+
+	for _, algorithm := range []string{None, Gzip, Zstd} {
+		compressed, err := Reader(bytes.NewReader(content), algorithm)
+		require.NoError(t, err)
+		data, err := io.ReadAll(compressed)
+		require.NoError(t, err)
+		require.NoError(t, compressed.Close())
+		if algorithm != None {
+			assert.Less(t, len(data), len(content), algorithm)
+		}
+
+		r, err := NewReader(bytes.NewReader(data), algorithm)
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, content, decompressed, algorithm)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for name, expected := range map[string]string{"": None, "none": None, "gzip": Gzip, "zstd": Zstd} {
+		algorithm, err := Parse(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, algorithm)
+	}
+
+	_, err := Parse("lz4")
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+// Package errs classifies failures from the provider clients (Google, Outlook, StorX) into
+// a small set of categories, so callers decide on retries, deactivation and user messages
+// from the category instead of matching error text.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Category is the kind of failure
+type Category string
+
+const (
+	// Unknown is any error that was not classified
+	Unknown Category = "unknown"
+	// AuthRevoked means the source account credentials are invalid, expired or revoked
+	AuthRevoked Category = "auth_revoked"
+	// StoragePermission means the StorX access grant is invalid or lacks permissions
+	StoragePermission Category = "storage_permission"
+	// Quota means a storage or API quota is exhausted
+	Quota Category = "quota"
+	// RateLimited means the remote side asked us to slow down
+	RateLimited Category = "rate_limited"
+	// Network covers connection failures, timeouts and 5xx responses
+	Network Category = "network"
+	// NotFound means the requested item does not exist
+	NotFound Category = "not_found"
+	// ItemPermission means the source denies access to a single item, e.g. a shared file
+	// whose owner disabled downloads. The item is skipped, the account is fine.
+	ItemPermission Category = "item_permission"
+	// SourceBug is a failure in our own code or unexpected data from a source
+	SourceBug Category = "source_bug"
+)
+
+// Retryable reports whether an operation that failed with this category may succeed if
+// retried later
+func (c Category) Retryable() bool {
+	return c == Network || c == RateLimited
+}
+
+// NeedsUserAction reports whether the failure persists until the user reconnects an
+// account or updates permissions
+func (c Category) NeedsUserAction() bool {
+	return c == AuthRevoked || c == StoragePermission
+}
+
+// Description is a short user-facing explanation of the category
+func (c Category) Description() string {
+	switch c {
+	case AuthRevoked:
+		return "the connected account's credentials are invalid or were revoked"
+	case StoragePermission:
+		return "the StorX access is missing permissions"
+	case Quota:
+		return "a storage or API quota has been exceeded"
+	case RateLimited:
+		return "the service is limiting requests"
+	case Network:
+		return "of a temporary network or service issue"
+	case NotFound:
+		return "the requested item could not be found"
+	case ItemPermission:
+		return "access to an item was denied by its owner"
+	default:
+		return "of a technical issue"
+	}
+}
+
+// Error is an error with a category and the service it came from
+type Error struct {
+	Category Category
+	// Source is the service the error came from, e.g. "google", "outlook" or "storx"
+	Source string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap attaches a category to err. Errors that already carry a category keep it, so the
+// client closest to the failure decides. Wrapping with Unknown or a nil error returns err
+// unchanged.
+func Wrap(category Category, source string, err error) error {
+	if err == nil || category == "" || category == Unknown {
+		return err
+	}
+	var existing *Error
+	if errors.As(err, &existing) {
+		return err
+	}
+	return &Error{Category: category, Source: source, Err: err}
+}
+
+// New creates a categorised error from a message
+func New(category Category, source, format string, args ...interface{}) error {
+	return &Error{Category: category, Source: source, Err: fmt.Errorf(format, args...)}
+}
+
+// CategoryOf returns the category of err. Errors that were never wrapped are still
+// recognised when they are timeouts or connection failures.
+func CategoryOf(err error) Category {
+	if err == nil {
+		return Unknown
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Category
+	}
+
+	if isNetworkError(err) {
+		return Network
+	}
+	return Unknown
+}
+
+// SourceOf returns the service a categorised error came from, or "" if unknown
+func SourceOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Source
+	}
+	return ""
+}
+
+// FromStatus maps an HTTP status code to a category. A 403 alone does not say whether the
+// account or a single item was refused, so it is a SourceBug unless the caller can tell
+// from the error details.
+func FromStatus(code int) Category {
+	switch {
+	case code == http.StatusUnauthorized:
+		return AuthRevoked
+	case code == http.StatusForbidden:
+		return SourceBug
+	case code == http.StatusNotFound:
+		return NotFound
+	case code == http.StatusTooManyRequests:
+		return RateLimited
+	case code == http.StatusInsufficientStorage:
+		return Quota
+	case code == http.StatusRequestTimeout, code >= 500:
+		return Network
+	default:
+		return Unknown
+	}
+}
+
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
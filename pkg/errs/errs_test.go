@@ -0,0 +1,65 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapAndCategoryOf(t *testing.T) {
+	base := errors.New("boom")
+
+	wrapped := Wrap(AuthRevoked, "google", base)
+	assert.Equal(t, AuthRevoked, CategoryOf(wrapped))
+	assert.Equal(t, "google", SourceOf(wrapped))
+	assert.Equal(t, "boom", wrapped.Error())
+	assert.ErrorIs(t, wrapped, base)
+
+	// The category survives further wrapping with %w
+	outer := fmt.Errorf("error while generating auth token: %w", wrapped)
+	assert.Equal(t, AuthRevoked, CategoryOf(outer))
+
+	// The innermost category wins
+	assert.Equal(t, AuthRevoked, CategoryOf(Wrap(Network, "storx", outer)))
+
+	assert.Nil(t, Wrap(Network, "storx", nil))
+	assert.Same(t, base, Wrap(Unknown, "storx", base))
+	assert.Equal(t, Unknown, CategoryOf(base))
+	assert.Equal(t, Unknown, CategoryOf(nil))
+}
+
+func TestCategoryOfUnwrappedNetworkErrors(t *testing.T) {
+	assert.Equal(t, Network, CategoryOf(fmt.Errorf("list: %w", context.DeadlineExceeded)))
+	assert.Equal(t, Network, CategoryOf(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+}
+
+func TestFromStatus(t *testing.T) {
+	for code, want := range map[int]Category{
+		200: Unknown,
+		400: Unknown,
+		401: AuthRevoked,
+		403: SourceBug,
+		404: NotFound,
+		429: RateLimited,
+		500: Network,
+		503: Network,
+		507: Quota,
+	} {
+		assert.Equal(t, want, FromStatus(code), "status %d", code)
+	}
+}
+
+func TestCategoryPolicy(t *testing.T) {
+	assert.True(t, Network.Retryable())
+	assert.True(t, RateLimited.Retryable())
+	assert.False(t, AuthRevoked.Retryable())
+	assert.False(t, SourceBug.Retryable())
+
+	assert.True(t, AuthRevoked.NeedsUserAction())
+	assert.True(t, StoragePermission.NeedsUserAction())
+	assert.False(t, Quota.NeedsUserAction())
+}
@@ -8,6 +8,9 @@ type GormModel struct {
 
 type DB struct{ *gorm.DB }
 
+// ErrRecordNotFound is returned when a query for a single record finds none
+var ErrRecordNotFound = gorm.ErrRecordNotFound
+
 func NewDB(gormDB *gorm.DB) *DB { return &DB{DB: gormDB} }
 
 func (db *DB) GetGormDB() *gorm.DB { return db.DB }
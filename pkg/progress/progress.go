@@ -0,0 +1,307 @@
+// Package progress fans out live task progress to subscribers such as the dashboard's
+// Server-Sent Events stream. The hub is in-memory; with several instances the hubs are
+// connected with Hub.Relay so a subscriber sees the tasks of every instance.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Task statuses reported in updates
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusStopped   = "stopped"
+)
+
+// Update is the progress of one task at a point in time
+type Update struct {
+	UserID   string `json:"-"`
+	TaskType string `json:"task_type"`
+	TaskID   uint   `json:"task_id"`
+	JobID    uint   `json:"job_id,omitempty"`
+	Method   string `json:"method"`
+	Status   string `json:"status"`
+
+	// Done and Failed count processed items. Total is zero when the number of items is
+	// not known up front, as for auto-sync tasks.
+	Done        int    `json:"done"`
+	Failed      int    `json:"failed"`
+	Total       int    `json:"total,omitempty"`
+	CurrentItem string `json:"current_item,omitempty"`
+	Bytes       int64  `json:"bytes_uploaded"`
+	// ETASeconds is only set once Total is known and at least one item was processed
+	ETASeconds *int64    `json:"eta_seconds,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	At         time.Time `json:"at"`
+}
+
+func (u Update) key() taskKey {
+	return taskKey{taskType: u.TaskType, taskID: u.TaskID}
+}
+
+type taskKey struct {
+	taskType string
+	taskID   uint
+}
+
+type subscriber struct {
+	userID string
+	ch     chan Update
+}
+
+// staleAfter is how long the latest update of a running task is handed to new
+// subscribers. Running tasks publish at every heartbeat, so a task not heard of for
+// longer ran on an instance that went away.
+const staleAfter = 5 * time.Minute
+
+// Hub routes updates to the subscribers of the task's user and keeps the latest update
+// of every running task so new subscribers start with a full picture
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	latest map[taskKey]latestUpdate
+	closed bool
+
+	// instance and relay are set by Relay
+	instance string
+	relay    chan Update
+}
+
+type latestUpdate struct {
+	update   Update
+	received time.Time
+}
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{
+		subs:   make(map[*subscriber]struct{}),
+		latest: make(map[taskKey]latestUpdate),
+	}
+}
+
+// Default is the hub the task runners publish to
+var Default = NewHub()
+
+// Subscribe returns a channel receiving the updates of userID's tasks, starting with the
+// latest update of each task that is still running. Updates are dropped when the channel
+// buffer is full. The channel is closed by unsubscribe or when the hub is closed.
+func (h *Hub) Subscribe(userID string, buffer int) (updates <-chan Update, unsubscribe func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &subscriber{userID: userID, ch: make(chan Update, buffer)}
+	if h.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+
+	for key, latest := range h.latest {
+		if time.Since(latest.received) > staleAfter {
+			delete(h.latest, key)
+			continue
+		}
+		if latest.update.UserID == userID {
+			select {
+			case sub.ch <- latest.update:
+			default:
+			}
+		}
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub.ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[sub]; ok {
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Publish sends u to the subscribers of its user and, if relayed, to the other
+// instances. It never blocks.
+func (h *Hub) Publish(u Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.deliver(u)
+
+	if h.relay != nil {
+		select {
+		case h.relay <- u:
+		default:
+		}
+	}
+}
+
+// deliver keeps track of u and sends it to the subscribers of its user. h.mu is held.
+func (h *Hub) deliver(u Update) {
+	if u.Status == StatusRunning {
+		h.latest[u.key()] = latestUpdate{update: u, received: time.Now()}
+	} else {
+		delete(h.latest, u.key())
+	}
+
+	for sub := range h.subs {
+		if sub.userID != u.UserID {
+			continue
+		}
+		select {
+		case sub.ch <- u:
+		default:
+		}
+	}
+}
+
+// Close closes every subscription so open streams end, e.g. on server shutdown
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for sub := range h.subs {
+		close(sub.ch)
+	}
+	h.subs = make(map[*subscriber]struct{})
+	if h.relay != nil {
+		close(h.relay)
+	}
+}
+
+// Tracker accumulates the progress of one task and publishes it. Updates caused by items
+// and heartbeats are throttled to one per interval; Finish is always published. A nil
+// Tracker does nothing.
+type Tracker struct {
+	hub      *Hub
+	interval time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	update      Update
+	lastPublish time.Time
+}
+
+// DefaultInterval is the minimum time between two published updates of a task
+const DefaultInterval = time.Second
+
+// NewTracker starts tracking a task and publishes its first update. base identifies the
+// task; its counters are ignored.
+func NewTracker(hub *Hub, base Update) *Tracker {
+	t := &Tracker{hub: hub, interval: DefaultInterval, now: time.Now}
+	t.start(base)
+	return t
+}
+
+func (t *Tracker) start(base Update) {
+	t.update = Update{
+		UserID:    base.UserID,
+		TaskType:  base.TaskType,
+		TaskID:    base.TaskID,
+		JobID:     base.JobID,
+		Method:    base.Method,
+		Status:    StatusRunning,
+		Total:     base.Total,
+		StartedAt: t.now(),
+	}
+	t.publish(true)
+}
+
+// SetTotal sets the number of items the task will process
+func (t *Tracker) SetTotal(total int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.update.Total = total
+	t.mu.Unlock()
+}
+
+// ItemDone records an item that was backed up
+func (t *Tracker) ItemDone(item string, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.update.Done++
+	t.update.Bytes += bytes
+	t.update.CurrentItem = item
+	t.mu.Unlock()
+	t.publish(false)
+}
+
+// ItemFailed records an item that could not be backed up
+func (t *Tracker) ItemFailed(item string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.update.Failed++
+	t.update.CurrentItem = item
+	t.mu.Unlock()
+	t.publish(false)
+}
+
+// Heartbeat publishes the current progress if nothing was published for a while, so
+// subscribers see that a slow item is still being worked on
+func (t *Tracker) Heartbeat() {
+	if t == nil {
+		return
+	}
+	t.publish(false)
+}
+
+// Finish publishes the final status of the task and removes it from the running tasks
+func (t *Tracker) Finish(status string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.update.Status = status
+	t.update.CurrentItem = ""
+	t.mu.Unlock()
+	t.publish(true)
+}
+
+func (t *Tracker) publish(force bool) {
+	t.mu.Lock()
+	now := t.now()
+	if !force && now.Sub(t.lastPublish) < t.interval {
+		t.mu.Unlock()
+		return
+	}
+	t.lastPublish = now
+
+	u := t.update
+	u.At = now
+	u.ETASeconds = eta(u, now)
+	t.mu.Unlock()
+
+	t.hub.Publish(u)
+}
+
+// eta extrapolates the time left from the average time per processed item
+func eta(u Update, now time.Time) *int64 {
+	processed := u.Done + u.Failed
+	if u.Status != StatusRunning || u.Total <= 0 || processed == 0 {
+		return nil
+	}
+
+	remaining := u.Total - processed
+	if remaining < 0 {
+		remaining = 0
+	}
+	perItem := now.Sub(u.StartedAt) / time.Duration(processed)
+	seconds := int64((perItem * time.Duration(remaining)).Round(time.Second) / time.Second)
+	return &seconds
+}
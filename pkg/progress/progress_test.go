@@ -0,0 +1,134 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishOnlyReachesTaskOwner(t *testing.T) {
+	hub := NewHub()
+	alice, unsubscribeAlice := hub.Subscribe("alice", 4)
+	defer unsubscribeAlice()
+	bob, unsubscribeBob := hub.Subscribe("bob", 4)
+	defer unsubscribeBob()
+
+	hub.Publish(Update{UserID: "alice", TaskID: 1, Status: StatusRunning})
+
+	select {
+	case u := <-alice:
+		assert.Equal(t, uint(1), u.TaskID)
+	default:
+		t.Fatal("expected update for alice")
+	}
+	select {
+	case u := <-bob:
+		t.Fatalf("unexpected update for bob: %+v", u)
+	default:
+	}
+}
+
+func TestSubscribeStartsWithRunningTasks(t *testing.T) {
+	hub := NewHub()
+	hub.Publish(Update{UserID: "alice", TaskType: "scheduled", TaskID: 1, Status: StatusRunning})
+	hub.Publish(Update{UserID: "alice", TaskType: "scheduled", TaskID: 2, Status: StatusRunning})
+	hub.Publish(Update{UserID: "alice", TaskType: "scheduled", TaskID: 2, Status: StatusCompleted})
+
+	updates, unsubscribe := hub.Subscribe("alice", 4)
+	defer unsubscribe()
+
+	require.Len(t, updates, 1)
+	assert.Equal(t, uint(1), (<-updates).TaskID)
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	hub := NewHub()
+	updates, unsubscribe := hub.Subscribe("alice", 1)
+	hub.Close()
+
+	_, ok := <-updates
+	assert.False(t, ok)
+	unsubscribe()
+}
+
+func TestTrackerThrottlesAndEstimates(t *testing.T) {
+	hub := NewHub()
+	updates, unsubscribe := hub.Subscribe("alice", 16)
+	defer unsubscribe()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := &Tracker{hub: hub, interval: time.Second, now: func() time.Time { return now }}
+	tracker.start(Update{UserID: "alice", TaskID: 7, Total: 4})
+	require.Len(t, updates, 1)
+	first := <-updates
+	assert.Nil(t, first.ETASeconds)
+
+	// Within the interval: counted but not published
+	tracker.ItemDone("a", 100)
+	assert.Len(t, updates, 0)
+
+	now = now.Add(10 * time.Second)
+	tracker.ItemDone("b", 50)
+	require.Len(t, updates, 1)
+	u := <-updates
+	assert.Equal(t, 2, u.Done)
+	assert.Equal(t, int64(150), u.Bytes)
+	assert.Equal(t, "b", u.CurrentItem)
+	require.NotNil(t, u.ETASeconds)
+	assert.Equal(t, int64(10), *u.ETASeconds)
+
+	tracker.Finish(StatusCompleted)
+	require.Len(t, updates, 1)
+	u = <-updates
+	assert.Equal(t, StatusCompleted, u.Status)
+	assert.Nil(t, u.ETASeconds)
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.ItemDone("a", 1)
+	tracker.ItemFailed("b")
+	tracker.Heartbeat()
+	tracker.Finish(StatusFailed)
+}
+
+func TestRelayReachesOtherInstances(t *testing.T) {
+	a, b := NewHub(), NewHub()
+	defer a.Close()
+	defer b.Close()
+
+	// Like a notification channel, every message reaches every instance
+	messages := make(chan string, 16)
+	broadcast := func(message string) error {
+		messages <- message
+		return nil
+	}
+	a.Relay("a", broadcast)
+	b.Relay("b", broadcast)
+
+	onA, unsubscribeA := a.Subscribe("alice", 4)
+	defer unsubscribeA()
+	onB, unsubscribeB := b.Subscribe("alice", 4)
+	defer unsubscribeB()
+
+	a.Publish(Update{UserID: "alice", TaskType: "scheduled", TaskID: 7, Status: StatusRunning, Done: 3})
+	message := <-messages
+	a.Receive(message)
+	b.Receive(message)
+
+	require.Len(t, onA, 1, "an instance does not receive its own updates twice")
+	u := <-onB
+	assert.Equal(t, uint(7), u.TaskID)
+	assert.Equal(t, 3, u.Done)
+	assert.Equal(t, "alice", u.UserID)
+
+	// Running tasks of other instances are part of the picture for new subscribers
+	late, unsubscribeLate := b.Subscribe("alice", 4)
+	defer unsubscribeLate()
+	require.Len(t, late, 1)
+
+	b.Receive("not json")
+	assert.Len(t, onB, 0)
+}
@@ -0,0 +1,67 @@
+package progress
+
+import "encoding/json"
+
+// relayBuffer is how many updates wait to be relayed before further ones are dropped
+const relayBuffer = 256
+
+// maxRelayedItem bounds the current item of relayed updates, relays such as Postgres
+// notifications limit the size of a message
+const maxRelayedItem = 512
+
+// relayed is an update on its way between the hubs of two instances
+type relayed struct {
+	Instance string `json:"instance"`
+	UserID   string `json:"user_id"`
+	Update   Update `json:"update"`
+}
+
+// Relay connects the hub to the hubs of the other instances. Every update published
+// from now on is encoded and passed to send in the background; updates are dropped when
+// sending falls behind. The messages of all instances, this one's included, are to be
+// passed to Receive. instance identifies this hub.
+func (h *Hub) Relay(instance string, send func(message string) error) {
+	out := make(chan Update, relayBuffer)
+
+	h.mu.Lock()
+	if h.closed || h.relay != nil {
+		h.mu.Unlock()
+		return
+	}
+	h.instance = instance
+	h.relay = out
+	h.mu.Unlock()
+
+	go func() {
+		for u := range out {
+			if len(u.CurrentItem) > maxRelayedItem {
+				u.CurrentItem = u.CurrentItem[:maxRelayedItem]
+			}
+			message, err := json.Marshal(relayed{Instance: instance, UserID: u.UserID, Update: u})
+			if err != nil {
+				continue
+			}
+			// Failures are the sender's to report; progress is best effort
+			_ = send(string(message))
+		}
+	}()
+}
+
+// Receive delivers an update relayed by another instance to the subscribers of this
+// hub. The hub's own updates and malformed messages are ignored.
+func (h *Hub) Receive(message string) {
+	var r relayed
+	if err := json.Unmarshal([]byte(message), &r); err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || r.Instance == h.instance {
+		return
+	}
+	u := r.Update
+	u.UserID = r.UserID
+	h.deliver(u)
+}
@@ -0,0 +1,328 @@
+// Package ratelimit throttles calls to the provider APIs (Gmail, Drive, Photos, Microsoft
+// Graph, StorX) so the service stays within their quotas instead of running into 429s.
+// Every provider has a token bucket per account and one shared by the whole project, and
+// throttled responses pause the bucket for as long as the provider asks.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/backoff"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Limits is the sustained request rate of a bucket and the burst it allows. A zero Rate
+// means the bucket is only paused by throttled responses and never limits on its own.
+type Limits struct {
+	Rate  float64
+	Burst int
+}
+
+// Config configures the limits per provider
+type Config struct {
+	// Account limits apply to every account of a provider separately
+	Account map[string]Limits
+	// Project limits are shared by all accounts of a provider, e.g. the quota of our
+	// Google Cloud project
+	Project map[string]Limits
+	// MaxRetries is the number of times a throttled request is retried
+	MaxRetries int
+	// MaxRetryWait is the longest Retry-After a request is retried for; longer pauses are
+	// still honoured by the bucket but the throttled response is returned to the caller
+	MaxRetryWait time.Duration
+}
+
+// DefaultConfig returns limits somewhat below the documented default quotas
+func DefaultConfig() Config {
+	return Config{
+		Account: map[string]Limits{
+			"gmail":  {Rate: 40, Burst: 50},
+			"drive":  {Rate: 10, Burst: 20},
+			"photos": {Rate: 5, Burst: 10},
+			"google": {Rate: 10, Burst: 20},
+			"graph":  {Rate: 15, Burst: 30},
+			"uplink": {Rate: 10, Burst: 20},
+		},
+		Project: map[string]Limits{
+			"gmail":  {Rate: 500, Burst: 500},
+			"drive":  {Rate: 150, Burst: 200},
+			"photos": {Rate: 10, Burst: 20},
+			"storx":  {Rate: 100, Burst: 200},
+		},
+		MaxRetries:   3,
+		MaxRetryWait: time.Minute,
+	}
+}
+
+// ConfigFromEnv overrides the default limits from the environment. Entries are
+// provider=rate[:burst] with the rate in requests per second:
+//
+//	RATELIMIT_ACCOUNT_LIMITS=gmail=20:40,graph=10
+//	RATELIMIT_PROJECT_LIMITS=drive=100:150
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	parseLimits(cfg.Account, "RATELIMIT_ACCOUNT_LIMITS")
+	parseLimits(cfg.Project, "RATELIMIT_PROJECT_LIMITS")
+	return cfg
+}
+
+func parseLimits(limits map[string]Limits, key string) {
+	for _, pair := range strings.Split(utils.GetEnvWithKey(key), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		provider, value, ok := strings.Cut(pair, "=")
+		l, err := parseLimit(value)
+		if !ok || err != nil {
+			logger.Warn(context.Background(), "Ignoring invalid "+key+" entry", logger.String("entry", pair))
+			continue
+		}
+		limits[strings.TrimSpace(provider)] = l
+	}
+}
+
+func parseLimit(value string) (Limits, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 {
+		return Limits{}, strconv.ErrSyntax
+	}
+	l := Limits{Rate: r, Burst: int(math.Ceil(r))}
+	if hasBurst {
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return Limits{}, strconv.ErrSyntax
+		}
+		l.Burst = b
+	}
+	return l, nil
+}
+
+// AccountKey identifies an account by a stable identity, such as its email address or
+// login ID, without keeping it in memory. Callers that do not know the account pass
+// only the credential the requests are made with; its key changes whenever the
+// credential is refreshed, so refreshed tokens of one account do not share a bucket.
+func AccountKey(identity, credential string) string {
+	if identity == "" {
+		identity = credential
+	}
+	if identity == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(identity))
+	return hex.EncodeToString(sum[:8])
+}
+
+// idleBucketTTL is how long an unused account bucket is kept. Account keys derived from
+// credentials change whenever one is refreshed, so old buckets are dropped.
+const idleBucketTTL = 15 * time.Minute
+
+type bucketKey struct {
+	provider string
+	account  string
+}
+
+// Manager holds the buckets of all providers and accounts
+type Manager struct {
+	cfg    Config
+	policy *backoff.Policy
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+
+	delayed   *prometheus.CounterVec
+	throttled *prometheus.CounterVec
+}
+
+// NewManager creates a rate limit manager
+func NewManager(cfg Config) *Manager {
+	if cfg.Account == nil {
+		cfg.Account = make(map[string]Limits)
+	}
+	if cfg.Project == nil {
+		cfg.Project = make(map[string]Limits)
+	}
+	return &Manager{
+		cfg:     cfg,
+		policy:  backoff.NewPolicy(time.Second, cfg.MaxRetryWait, cfg.MaxRetries),
+		buckets: make(map[bucketKey]*bucket),
+		delayed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimit_delayed_requests_total",
+			Help: "Requests that waited for a rate limit bucket, by provider",
+		}, []string{"provider"}),
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ratelimit_throttled_responses_total",
+			Help: "Rate limit responses received from a provider, by provider",
+		}, []string{"provider"}),
+	}
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultManager *Manager
+)
+
+// Default returns the manager shared by all clients of the process, configured from the
+// environment
+func Default() *Manager {
+	defaultOnce.Do(func() {
+		defaultManager = NewManager(ConfigFromEnv())
+		for name, collector := range map[string]prometheus.Collector{
+			"ratelimit_delayed_requests_total":    defaultManager.delayed,
+			"ratelimit_throttled_responses_total": defaultManager.throttled,
+		} {
+			if err := monitor.RegisterGlobalCustomMetric(name, collector); err != nil {
+				logger.Warn(context.Background(), "Failed to register rate limit metric",
+					logger.String("metric", name), logger.ErrorField(err))
+			}
+		}
+	})
+	return defaultManager
+}
+
+// Wait blocks until both the project bucket of provider and the bucket of account allow
+// another request. An empty account only waits for the project bucket.
+func (m *Manager) Wait(ctx context.Context, provider, account string) error {
+	now := time.Now()
+	delay := m.bucket(provider, "").reserve(now)
+	if account != "" {
+		if d := m.bucket(provider, account).reserve(now); d > delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	m.delayed.WithLabelValues(provider).Inc()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause stops requests of an account for d, or of the whole provider if account is empty.
+// A shorter pause never cuts an existing one short.
+func (m *Manager) Pause(provider, account string, d time.Duration) {
+	m.throttled.WithLabelValues(provider).Inc()
+	m.bucket(provider, account).pause(time.Now().Add(d))
+}
+
+func (m *Manager) bucket(provider, account string) *bucket {
+	key := bucketKey{provider: provider, account: account}
+
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > idleBucketTTL {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		limits := m.cfg.Project[provider]
+		if account != "" {
+			limits = m.cfg.Account[provider]
+		}
+		b = newBucket(limits, now)
+		m.buckets[key] = b
+	}
+	return b
+}
+
+// sweep drops account buckets that were not used for idleBucketTTL. m.mu must be held.
+func (m *Manager) sweep(now time.Time) {
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if key.account != "" && b.idle(now) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// bucket is a token bucket that can be paused
+type bucket struct {
+	limits Limits
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	used        time.Time
+}
+
+func newBucket(limits Limits, now time.Time) *bucket {
+	if limits.Burst < 1 {
+		limits.Burst = 1
+	}
+	return &bucket{limits: limits, tokens: float64(limits.Burst), last: now, used: now}
+}
+
+// idle reports whether the bucket was unused for idleBucketTTL and is not paused
+func (b *bucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Sub(b.used) > idleBucketTTL && !b.pausedUntil.After(now)
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+// Tokens may go negative, which queues callers behind each other.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used = now
+
+	start := now
+	if b.pausedUntil.After(start) {
+		start = b.pausedUntil
+	}
+	if b.limits.Rate <= 0 {
+		return start.Sub(now)
+	}
+
+	if start.After(b.last) {
+		b.tokens = math.Min(float64(b.limits.Burst), b.tokens+start.Sub(b.last).Seconds()*b.limits.Rate)
+		b.last = start
+	}
+	b.tokens--
+
+	wait := start.Sub(now)
+	if b.tokens < 0 {
+		wait += time.Duration(-b.tokens / b.limits.Rate * float64(time.Second))
+	}
+	return wait
+}
+
+// pause blocks the bucket until the given time. The bucket restarts with a single token so
+// requests resume at the sustained rate rather than with a burst.
+func (b *bucket) pause(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !until.After(b.pausedUntil) {
+		return
+	}
+	b.pausedUntil = until
+	if b.tokens > 1 {
+		b.tokens = 1
+	}
+	if until.After(b.last) {
+		b.last = until
+	}
+}
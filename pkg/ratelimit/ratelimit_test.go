@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketAllowsBurstThenQueues(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Limits{Rate: 10, Burst: 2}, now)
+
+	assert.Zero(t, b.reserve(now))
+	assert.Zero(t, b.reserve(now))
+	assert.Equal(t, 100*time.Millisecond, b.reserve(now))
+	assert.Equal(t, 200*time.Millisecond, b.reserve(now))
+
+	// Tokens refill at the sustained rate
+	later := now.Add(time.Second)
+	assert.Zero(t, b.reserve(later))
+}
+
+func TestBucketPauseDelaysAndDrains(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Limits{Rate: 10, Burst: 5}, now)
+
+	b.pause(now.Add(2 * time.Second))
+	assert.Equal(t, 2*time.Second, b.reserve(now))
+	// The bucket restarts empty instead of allowing a burst after the pause
+	assert.Equal(t, 2100*time.Millisecond, b.reserve(now))
+
+	// A shorter pause does not cut the existing one short
+	b.pause(now.Add(time.Second))
+	assert.True(t, b.reserve(now) >= 2*time.Second)
+}
+
+func TestUnlimitedBucketOnlyHonoursPauses(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Limits{}, now)
+	for i := 0; i < 100; i++ {
+		assert.Zero(t, b.reserve(now))
+	}
+
+	b.pause(now.Add(time.Second))
+	assert.Equal(t, time.Second, b.reserve(now))
+}
+
+func TestManagerPausesAccountsSeparately(t *testing.T) {
+	m := NewManager(Config{})
+	m.Pause("gmail", "alice", time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.Wait(ctx, "gmail", "alice"), context.DeadlineExceeded)
+	assert.NoError(t, m.Wait(ctx, "gmail", "bob"))
+	assert.NoError(t, m.Wait(ctx, "drive", "alice"))
+
+	// A project-wide pause holds up every account
+	m.Pause("drive", "", time.Hour)
+	assert.ErrorIs(t, m.Wait(ctx, "drive", "bob"), context.DeadlineExceeded)
+}
+
+func TestManagerDropsIdleAccountBuckets(t *testing.T) {
+	m := NewManager(Config{})
+	project := m.bucket("gmail", "")
+	idle := m.bucket("gmail", "alice")
+	paused := m.bucket("gmail", "bob")
+	paused.pause(time.Now().Add(time.Hour))
+
+	old := time.Now().Add(-2 * idleBucketTTL)
+	project.used, idle.used, paused.used, m.lastSweep = old, old, old, old
+	m.bucket("gmail", "carol")
+
+	assert.NotContains(t, m.buckets, bucketKey{provider: "gmail", account: "alice"})
+	assert.Contains(t, m.buckets, bucketKey{provider: "gmail", account: "bob"})
+	assert.Contains(t, m.buckets, bucketKey{provider: "gmail"})
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := retryAfter("30", now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+
+	d, ok = retryAfter(now.Add(time.Minute).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	_, ok = retryAfter("", now)
+	assert.False(t, ok)
+	_, ok = retryAfter("soon", now)
+	assert.False(t, ok)
+}
+
+func TestThrottleScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		header      string
+		projectWide bool
+		throttled   bool
+	}{
+		{name: "429", status: http.StatusTooManyRequests, throttled: true},
+		{name: "user rate limit", status: http.StatusForbidden, body: `{"error":{"errors":[{"reason":"userRateLimitExceeded"}]}}`, throttled: true},
+		{name: "project rate limit", status: http.StatusForbidden, body: `{"error":{"errors":[{"reason":"rateLimitExceeded"}]}}`, projectWide: true, throttled: true},
+		{name: "permission denied", status: http.StatusForbidden, body: `{"error":{"errors":[{"reason":"insufficientPermissions"}]}}`},
+		{name: "503 with Retry-After", status: http.StatusServiceUnavailable, header: "5", projectWide: true, throttled: true},
+		{name: "503 without Retry-After", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+
+			projectWide, throttled := throttleScope(resp)
+			assert.Equal(t, tt.throttled, throttled)
+			assert.Equal(t, tt.projectWide, projectWide)
+
+			// The body is still readable by the caller
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestTransportRetriesAfterRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "payload", string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	m := NewManager(Config{MaxRetries: 2, MaxRetryWait: time.Second})
+	client := &http.Client{Transport: &Transport{Manager: m, Provider: Fixed("gmail"), Account: "alice"}}
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestTransportReturnsThrottledResponseWhenOutOfRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	m := NewManager(Config{MaxRetries: 3, MaxRetryWait: time.Minute})
+	client := &http.Client{Transport: &Transport{Manager: m, Provider: Fixed("graph"), Account: "alice"}}
+
+	// Retry-After is longer than MaxRetryWait, so the caller gets the 429 right away
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// and later requests of the account wait for the pause
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.Wait(ctx, "graph", "alice"), context.DeadlineExceeded)
+}
+
+func TestGoogleAPI(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://gmail.googleapis.com/gmail/v1/users/me/messages":   "gmail",
+		"https://www.googleapis.com/drive/v3/files":                 "drive",
+		"https://www.googleapis.com/upload/drive/v3/files":          "drive",
+		"https://photoslibrary.googleapis.com/v1/mediaItems:search": "photos",
+		"https://storage.googleapis.com/storage/v1/b":               "google",
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		assert.Equal(t, want, GoogleAPI(req), url)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	l, err := parseLimit("2.5")
+	require.NoError(t, err)
+	assert.Equal(t, Limits{Rate: 2.5, Burst: 3}, l)
+
+	l, err = parseLimit("10:40")
+	require.NoError(t, err)
+	assert.Equal(t, Limits{Rate: 10, Burst: 40}, l)
+
+	_, err = parseLimit("fast")
+	assert.Error(t, err)
+	_, err = parseLimit("10:0")
+	assert.Error(t, err)
+}
+
+func TestAccountKey(t *testing.T) {
+	// Refreshed tokens of one account share its bucket
+	assert.Equal(t, AccountKey("user@example.com", "token-1"), AccountKey("user@example.com", "token-2"))
+	assert.NotEqual(t, AccountKey("user@example.com", "token-1"), AccountKey("other@example.com", "token-1"))
+
+	assert.Equal(t, AccountKey("", "token-1"), AccountKey("", "token-1"))
+	assert.NotEqual(t, AccountKey("", "token-1"), AccountKey("", "token-2"))
+	assert.Empty(t, AccountKey("", ""))
+}
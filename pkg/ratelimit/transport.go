@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+)
+
+// maxErrorPeek is how much of a 403 body is read to tell rate limits from permission errors
+const maxErrorPeek = 4 << 10
+
+// Fixed returns a provider resolver that counts every request against provider
+func Fixed(provider string) func(*http.Request) string {
+	return func(*http.Request) string {
+		return provider
+	}
+}
+
+// GoogleAPI resolves the Google API a request is made to, since Gmail, Drive and Photos
+// have separate quotas
+func GoogleAPI(req *http.Request) string {
+	host, path := req.URL.Host, req.URL.Path
+	switch {
+	case strings.HasPrefix(host, "gmail."), strings.HasPrefix(path, "/gmail/"):
+		return "gmail"
+	case strings.HasPrefix(host, "photoslibrary."):
+		return "photos"
+	case strings.HasPrefix(host, "drive."), strings.HasPrefix(path, "/drive/"), strings.HasPrefix(path, "/upload/drive/"):
+		return "drive"
+	default:
+		return "google"
+	}
+}
+
+// Transport is an http.RoundTripper that waits for the rate limit buckets before every
+// request. Throttled responses pause the buckets for the Retry-After the provider sent, or
+// an exponential backoff without one, and are retried when the request can be replayed.
+type Transport struct {
+	// Base sends the requests; nil uses http.DefaultTransport
+	Base http.RoundTripper
+	// Manager holds the buckets; nil uses Default()
+	Manager *Manager
+	// Provider names the quota a request counts against
+	Provider func(*http.Request) string
+	// Account is the account key of the credentials the requests are made with; see
+	// AccountKey
+	Account string
+	// NoRetry returns throttled responses to the caller right away, for clients that
+	// retry on their own. The buckets are paused either way.
+	NoRetry bool
+}
+
+// NewTransport wraps base so its requests count against provider and account
+func NewTransport(base http.RoundTripper, provider func(*http.Request) string, account string) *Transport {
+	return &Transport{Base: base, Provider: provider, Account: account}
+}
+
+// WrapClient returns a copy of client whose requests go through a Transport
+func WrapClient(client *http.Client, provider func(*http.Request) string, account string) *http.Client {
+	wrapped := *client
+	wrapped.Transport = NewTransport(client.Transport, provider, account)
+	return &wrapped
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	m := t.Manager
+	if m == nil {
+		m = Default()
+	}
+	provider := t.Provider(req)
+
+	for attempt := 1; ; attempt++ {
+		if err := m.Wait(req.Context(), provider, t.Account); err != nil {
+			return nil, err
+		}
+
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		projectWide, throttled := throttleScope(resp)
+		if !throttled {
+			return resp, nil
+		}
+
+		delay, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			delay = m.policy.Delay(attempt)
+		}
+		account := t.Account
+		if projectWide {
+			account = ""
+		}
+		m.Pause(provider, account, delay)
+
+		logger.Warn(req.Context(), "Provider is rate limiting requests",
+			logger.String("provider", provider),
+			logger.Int("status", resp.StatusCode),
+			logger.String("pause", delay.String()),
+			logger.Int("attempt", attempt),
+		)
+
+		if t.NoRetry || !m.policy.ShouldRetry(attempt) || delay > m.cfg.MaxRetryWait {
+			return resp, nil
+		}
+		next, ok := replay(req)
+		if !ok {
+			return resp, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorPeek))
+		resp.Body.Close()
+		req = next
+	}
+}
+
+// throttleScope reports whether resp asks us to slow down and whether the limit is shared
+// by the whole project rather than the account. Google reports rate limits as 403 as well
+// as 429, so the body of a 403 is checked for the reason.
+func throttleScope(resp *http.Response) (projectWide, throttled bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return false, true
+	case http.StatusServiceUnavailable:
+		// Only an explicit Retry-After marks an overloaded service rather than an outage
+		if resp.Header.Get("Retry-After") != "" {
+			return true, true
+		}
+	case http.StatusForbidden:
+		body := peekBody(resp)
+		switch {
+		case bytes.Contains(body, []byte("userRateLimitExceeded")):
+			return false, true
+		case bytes.Contains(body, []byte("rateLimitExceeded")):
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// peekBody reads the start of the response body and puts it back for the caller
+func peekBody(resp *http.Response) []byte {
+	if resp.Body == nil {
+		return nil
+	}
+	peek, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorPeek))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	return peek
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an HTTP date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// replay returns a copy of req that can be sent again, or false if the body cannot be
+// rewound
+func replay(req *http.Request) (*http.Request, bool) {
+	next := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return next, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	next.Body = body
+	return next, true
+}
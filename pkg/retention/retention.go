@@ -0,0 +1,167 @@
+// Package retention decides which backups of a job to keep. A policy keeps the most
+// recent backups, the backups of the last days, and the newest backup of each of the
+// last days, weeks and months (grandfather-father-son). Everything no rule keeps is
+// removed, except for the newest backup, so a job that stopped backing up never loses
+// its last one.
+package retention
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reasons a backup is kept
+const (
+	ReasonNewest  = "newest"
+	ReasonLast    = "last"
+	ReasonWithin  = "within"
+	ReasonDaily   = "daily"
+	ReasonWeekly  = "weekly"
+	ReasonMonthly = "monthly"
+)
+
+// maxCount bounds every rule so a typo cannot keep backups forever by accident
+const maxCount = 10000
+
+// Policy is the retention policy of a job. Zero values disable a rule; a policy without
+// any rule keeps everything.
+type Policy struct {
+	// KeepLast keeps the most recent backups
+	KeepLast int `json:"keep_last,omitempty"`
+	// MaxAgeDays keeps the backups of the last days; older ones are removed unless
+	// another rule keeps them
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// KeepDaily, KeepWeekly and KeepMonthly keep the newest backup of each of the last
+	// days, ISO weeks and months that have a backup
+	KeepDaily   int `json:"keep_daily,omitempty"`
+	KeepWeekly  int `json:"keep_weekly,omitempty"`
+	KeepMonthly int `json:"keep_monthly,omitempty"`
+}
+
+// Enabled reports whether the policy has any rule, i.e. whether it removes anything
+func (p Policy) Enabled() bool {
+	return p.KeepLast > 0 || p.MaxAgeDays > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// Validate checks that every rule is in range
+func (p Policy) Validate() error {
+	for name, value := range map[string]int{
+		"keep_last":    p.KeepLast,
+		"max_age_days": p.MaxAgeDays,
+		"keep_daily":   p.KeepDaily,
+		"keep_weekly":  p.KeepWeekly,
+		"keep_monthly": p.KeepMonthly,
+	} {
+		if value < 0 || value > maxCount {
+			return fmt.Errorf("%s must be between 0 and %d", name, maxCount)
+		}
+	}
+	return nil
+}
+
+// Item is one backup
+type Item struct {
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// Decision tells whether a backup is kept and which rules keep it
+type Decision struct {
+	Item
+	Keep    bool     `json:"keep"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Apply decides for every item whether the policy keeps it. Days, weeks and months are
+// those of loc. The decisions are ordered newest first.
+func (p Policy) Apply(items []Item, now time.Time, loc *time.Location) []Decision {
+	decisions := make([]Decision, len(items))
+	for i, item := range items {
+		decisions[i] = Decision{Item: item}
+	}
+	sort.SliceStable(decisions, func(i, j int) bool {
+		if !decisions[i].Time.Equal(decisions[j].Time) {
+			return decisions[i].Time.After(decisions[j].Time)
+		}
+		return decisions[i].Key > decisions[j].Key
+	})
+
+	if !p.Enabled() {
+		for i := range decisions {
+			decisions[i].Keep = true
+		}
+		return decisions
+	}
+
+	if loc == nil {
+		loc = time.Local
+	}
+	cutoff := now.AddDate(0, 0, -p.MaxAgeDays)
+
+	tiers := []struct {
+		reason string
+		count  int
+		period func(time.Time) string
+	}{
+		{ReasonDaily, p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{ReasonWeekly, p.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{ReasonMonthly, p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	seen := make([]map[string]bool, len(tiers))
+	for i := range seen {
+		seen[i] = map[string]bool{}
+	}
+
+	for i := range decisions {
+		decision := &decisions[i]
+		if i < p.KeepLast {
+			decision.Reasons = append(decision.Reasons, ReasonLast)
+		}
+		if p.MaxAgeDays > 0 && decision.Time.After(cutoff) {
+			decision.Reasons = append(decision.Reasons, ReasonWithin)
+		}
+		// Decisions are newest first, so the first backup of a period is its newest
+		for t, tier := range tiers {
+			period := tier.period(decision.Time.In(loc))
+			if len(seen[t]) < tier.count && !seen[t][period] {
+				seen[t][period] = true
+				decision.Reasons = append(decision.Reasons, tier.reason)
+			}
+		}
+		if i == 0 && len(decision.Reasons) == 0 {
+			decision.Reasons = append(decision.Reasons, ReasonNewest)
+		}
+		decision.Keep = len(decision.Reasons) > 0
+	}
+	return decisions
+}
+
+// Value implements the driver.Valuer interface
+func (p Policy) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (p *Policy) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	case []uint8:
+		return json.Unmarshal(v, p)
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+}
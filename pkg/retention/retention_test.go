@@ -0,0 +1,88 @@
+package retention
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dailyBackups returns one backup per day at 02:00 UTC for the days before now
+func dailyBackups(now time.Time, days int) []Item {
+	var items []Item
+	for i := 0; i < days; i++ {
+		t := time.Date(now.Year(), now.Month(), now.Day(), 2, 0, 0, 0, time.UTC).AddDate(0, 0, -i)
+		items = append(items, Item{Key: fmt.Sprintf("postgresql/app_%d.sql", t.Unix()), Time: t})
+	}
+	return items
+}
+
+func kept(decisions []Decision) []time.Time {
+	var times []time.Time
+	for _, decision := range decisions {
+		if decision.Keep {
+			times = append(times, decision.Time)
+		}
+	}
+	return times
+}
+
+func TestApplyWithoutRulesKeepsEverything(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	decisions := Policy{}.Apply(dailyBackups(now, 10), now, time.UTC)
+	assert.Len(t, kept(decisions), 10)
+}
+
+func TestApplyKeepLast(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	decisions := Policy{KeepLast: 3}.Apply(dailyBackups(now, 10), now, time.UTC)
+
+	require.Len(t, decisions, 10)
+	assert.Len(t, kept(decisions), 3)
+	assert.Equal(t, []string{ReasonLast}, decisions[0].Reasons)
+	assert.False(t, decisions[3].Keep)
+}
+
+func TestApplyMaxAgeKeepsLastBackups(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	items := dailyBackups(now.AddDate(0, 0, -60), 5)
+
+	// Backups older than the limit are still kept while KeepLast asks for them
+	decisions := Policy{MaxAgeDays: 30, KeepLast: 2}.Apply(items, now, time.UTC)
+	assert.Len(t, kept(decisions), 2)
+
+	decisions = Policy{MaxAgeDays: 30}.Apply(append(items, dailyBackups(now, 3)...), now, time.UTC)
+	assert.Len(t, kept(decisions), 3)
+
+	// The newest backup survives a job that stopped backing up
+	decisions = Policy{MaxAgeDays: 30}.Apply(items, now, time.UTC)
+	require.Len(t, kept(decisions), 1)
+	assert.Equal(t, []string{ReasonNewest}, decisions[0].Reasons)
+}
+
+func TestApplyGrandfatherFatherSon(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	// Two backups a day for 100 days
+	items := dailyBackups(now, 100)
+	for _, item := range dailyBackups(now, 100) {
+		items = append(items, Item{Key: item.Key + ".early", Time: item.Time.Add(-time.Hour)})
+	}
+
+	decisions := Policy{KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 3}.Apply(items, now, time.UTC)
+	times := kept(decisions)
+
+	// 7 days, 4 weeks of which the first overlaps the days, and 3 months of which the
+	// first overlaps as well
+	assert.Len(t, times, 7+3+2)
+	for _, t2 := range times {
+		assert.Equal(t, 2, t2.Hour(), "only the newest backup of a period is kept")
+	}
+	assert.Equal(t, time.Date(2026, 1, 31, 2, 0, 0, 0, time.UTC), times[len(times)-1])
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Policy{KeepLast: 5, KeepMonthly: 12}.Validate())
+	assert.Error(t, Policy{KeepDaily: -1}.Validate())
+}
@@ -0,0 +1,73 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.100.100.200":  false,
+		"0.0.0.0":          false,
+		"255.255.255.255":  false,
+		"fe80::1":          false,
+		"fd00:ec2::254":    false,
+		"::ffff:127.0.0.1": false,
+		"64:ff9b::a00:1":   false,
+	} {
+		assert.Equal(t, want, IsPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, raw := range []string{
+		"https://example.com/hook",
+		"http://93.184.216.34:8080/hook",
+	} {
+		assert.NoError(t, CheckURL(raw), raw)
+	}
+	for _, raw := range []string{
+		"ftp://example.com",
+		"https://",
+		"http://localhost:8005/admin",
+		"http://127.0.0.1/",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://metadata.google.internal/computeMetadata/v1/",
+		"http://10.0.0.5/",
+	} {
+		assert.Error(t, CheckURL(raw), raw)
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The test server listens on loopback, which the client must not reach
+	_, err := NewClient(5 * time.Second).Get(server.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrForbiddenAddress))
+
+	// Names are checked after resolution
+	_, err = NewClient(5 * time.Second).Get("http://localhost:1/")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrForbiddenAddress))
+}
@@ -0,0 +1,250 @@
+// Package schedule evaluates auto-sync job schedules. Every place that needs to know
+// when a job runs next (task creation, the job listing and request validation) goes
+// through Parse so they always agree.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // user time zones must resolve on hosts without zoneinfo
+
+	"github.com/robfig/cron/v3"
+)
+
+// Supported intervals
+const (
+	IntervalOneTime = "one_time"
+	IntervalHourly  = "hourly"
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+	IntervalCron    = "cron"
+)
+
+// MaxMonthDay is the last day of the month a monthly job can be scheduled on, so
+// every month has a matching day
+const MaxMonthDay = 28
+
+// ErrNoSchedule is returned for one-time jobs, which run once and are never rescheduled
+var ErrNoSchedule = errors.New("interval has no recurring schedule")
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Schedule is a parsed job schedule
+type Schedule struct {
+	spec cron.Schedule
+
+	// every is set for "every N hours" schedules, which count from the last run
+	// rather than following the wall clock
+	every time.Duration
+
+	// dateOnly marks the legacy daily/weekly/monthly forms without a time of day.
+	// These run once on the matching day, whatever the hour.
+	dateOnly bool
+}
+
+// Parse builds a schedule from a job's interval and on values:
+//
+//	hourly   "6"                 every 6 hours (1-23)
+//	daily    "12am", "14:30", "9:15pm"
+//	weekly   "Monday", "Monday 14:30"
+//	monthly  "15", "15 06:00"    day 1-28
+//	cron     "30 2 * * 1-5"      standard 5-field expression or descriptor (@daily, @every 90m)
+func Parse(interval, on string) (*Schedule, error) {
+	on = strings.TrimSpace(on)
+
+	switch interval {
+	case IntervalOneTime:
+		return nil, ErrNoSchedule
+
+	case IntervalHourly:
+		n, err := strconv.Atoi(on)
+		if err != nil || n < 1 || n > 23 {
+			return nil, fmt.Errorf("hourly interval must be a number of hours between 1 and 23")
+		}
+		return &Schedule{every: time.Duration(n) * time.Hour}, nil
+
+	case IntervalDaily:
+		if on == "" {
+			return nil, fmt.Errorf("daily interval requires a time of day")
+		}
+		hour, minute, err := parseTimeOfDay(on)
+		if err != nil {
+			return nil, err
+		}
+		return fromSpec(fmt.Sprintf("%d %d * * *", minute, hour), isLegacyMidnight(on))
+
+	case IntervalWeekly:
+		day, clock, _ := strings.Cut(on, " ")
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", day)
+		}
+		hour, minute, err := parseOptionalTimeOfDay(clock)
+		if err != nil {
+			return nil, err
+		}
+		return fromSpec(fmt.Sprintf("%d %d * * %d", minute, hour, weekday), strings.TrimSpace(clock) == "")
+
+	case IntervalMonthly:
+		day, clock, _ := strings.Cut(on, " ")
+		n, err := strconv.Atoi(day)
+		if err != nil || n < 1 || n > MaxMonthDay {
+			return nil, fmt.Errorf("monthly interval must be a day between 1 and %d", MaxMonthDay)
+		}
+		hour, minute, err := parseOptionalTimeOfDay(clock)
+		if err != nil {
+			return nil, err
+		}
+		return fromSpec(fmt.Sprintf("%d %d %d * *", minute, hour, n), strings.TrimSpace(clock) == "")
+
+	case IntervalCron:
+		if on == "" {
+			return nil, fmt.Errorf("cron interval requires an expression")
+		}
+		if strings.HasPrefix(on, "TZ=") || strings.HasPrefix(on, "CRON_TZ=") {
+			return nil, fmt.Errorf("time zone prefixes are not allowed in cron expressions")
+		}
+		spec, err := cron.ParseStandard(on)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		return &Schedule{spec: spec}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+}
+
+func fromSpec(expr string, dateOnly bool) (*Schedule, error) {
+	spec, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Schedule{spec: spec, dateOnly: dateOnly}, nil
+}
+
+// Valid reports whether interval and on form a usable schedule. One-time jobs are valid
+// even though they have no schedule.
+func Valid(interval, on string) bool {
+	_, err := Parse(interval, on)
+	return err == nil || errors.Is(err, ErrNoSchedule)
+}
+
+// LoadLocation resolves an IANA time zone name such as "Asia/Kolkata". An empty name
+// means the server's local time zone, which is what jobs created before per-user time
+// zones existed were evaluated in.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	if strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	return loc, nil
+}
+
+// Next returns the first activation strictly after t, in t's location
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	return s.spec.Next(t)
+}
+
+// Relative reports whether the schedule counts from the last run ("every N hours")
+// rather than following the wall clock
+func (s *Schedule) Relative() bool {
+	return s.every > 0
+}
+
+// NextRun returns when a job should run next given when it last ran. Wall-clock fields
+// (time of day, weekday, day of month) are evaluated in now's location, so pass now in
+// the job's time zone.
+//
+// A job that has never run is due at its first activation from now; for "every N hours"
+// and for the legacy day-only forms that means it runs straight away (or today, if today
+// matches), which is how jobs behaved before explicit times existed. The result may be
+// in the past, meaning the job is overdue.
+func (s *Schedule) NextRun(lastRun *time.Time, now time.Time) time.Time {
+	if lastRun == nil {
+		switch {
+		case s.every > 0:
+			return now
+		case s.dateOnly:
+			startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			return s.Next(startOfDay.Add(-time.Nanosecond))
+		default:
+			return s.Next(now)
+		}
+	}
+	return s.Next(lastRun.In(now.Location()))
+}
+
+// isLegacyMidnight reports whether on is the original "12am" daily value, which means
+// "some time today" rather than exactly midnight
+func isLegacyMidnight(on string) bool {
+	return strings.EqualFold(on, "12am")
+}
+
+func parseOptionalTimeOfDay(s string) (int, int, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, 0, nil
+	}
+	return parseTimeOfDay(s)
+}
+
+// parseTimeOfDay accepts 24-hour "HH:MM" and 12-hour "h[:mm]am"/"h[:mm]pm" times
+func parseTimeOfDay(s string) (int, int, error) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+
+	suffix := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		suffix = s[len(s)-2:]
+		s = s[:len(s)-2]
+	}
+
+	hourPart, minutePart, hasMinutes := strings.Cut(s, ":")
+	hour, err := strconv.Atoi(hourPart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q", s+suffix)
+	}
+	minute := 0
+	if hasMinutes {
+		if minute, err = strconv.Atoi(minutePart); err != nil || minute < 0 || minute > 59 {
+			return 0, 0, fmt.Errorf("invalid time of day %q", s+suffix)
+		}
+	}
+
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid time of day %q", s+suffix)
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if !hasMinutes || hour < 0 || hour > 23 {
+			return 0, 0, fmt.Errorf("invalid time of day %q, use HH:MM or h[:mm]am/pm", s)
+		}
+	}
+
+	return hour, minute, nil
+}
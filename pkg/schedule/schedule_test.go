@@ -0,0 +1,91 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseRejectsInvalidValues(t *testing.T) {
+	for _, tc := range []struct{ interval, on string }{
+		{"daily", ""},
+		{"daily", "25:00"},
+		{"daily", "13pm"},
+		{"daily", "9"},
+		{"weekly", "Funday"},
+		{"monthly", "29"},
+		{"monthly", "0"},
+		{"hourly", "24"},
+		{"cron", "* * *"},
+		{"cron", "CRON_TZ=UTC 0 1 * * *"},
+		{"yearly", "1"},
+	} {
+		_, err := Parse(tc.interval, tc.on)
+		assert.Error(t, err, "%s %q", tc.interval, tc.on)
+		assert.False(t, Valid(tc.interval, tc.on), "%s %q", tc.interval, tc.on)
+	}
+
+	_, err := Parse("one_time", "")
+	assert.ErrorIs(t, err, ErrNoSchedule)
+	assert.True(t, Valid("one_time", ""))
+}
+
+func TestNextRun(t *testing.T) {
+	wednesday := date(2024, time.May, 15, 10, 0)
+	lastRun := date(2024, time.May, 15, 0, 5)
+
+	for _, tc := range []struct {
+		name     string
+		interval string
+		on       string
+		lastRun  *time.Time
+		want     time.Time
+	}{
+		{"legacy daily never run is due today", "daily", "12am", nil, date(2024, time.May, 15, 0, 0)},
+		{"legacy daily ran today waits for tomorrow", "daily", "12am", &lastRun, date(2024, time.May, 16, 0, 0)},
+		{"daily at time later today", "daily", "14:30", nil, date(2024, time.May, 15, 14, 30)},
+		{"daily at time already passed", "daily", "9:15am", nil, date(2024, time.May, 16, 9, 15)},
+		{"daily 12 hour pm", "daily", "9pm", &lastRun, date(2024, time.May, 15, 21, 0)},
+		{"legacy weekly on a later day", "weekly", "Friday", nil, date(2024, time.May, 17, 0, 0)},
+		{"legacy weekly on today", "weekly", "Wednesday", nil, date(2024, time.May, 15, 0, 0)},
+		{"weekly with time", "weekly", "Monday 06:00", &lastRun, date(2024, time.May, 20, 6, 0)},
+		{"monthly next month", "monthly", "1", &lastRun, date(2024, time.June, 1, 0, 0)},
+		{"monthly with time", "monthly", "15 18:00", &lastRun, date(2024, time.May, 15, 18, 0)},
+		{"every n hours never run", "hourly", "6", nil, wednesday},
+		{"every n hours after last run", "hourly", "6", &lastRun, date(2024, time.May, 15, 6, 5)},
+		{"cron expression overdue since last run", "cron", "30 2 * * 1-5", &lastRun, date(2024, time.May, 15, 2, 30)},
+		{"cron expression never run", "cron", "30 2 * * 1-5", nil, date(2024, time.May, 16, 2, 30)},
+		{"cron descriptor", "cron", "@hourly", nil, date(2024, time.May, 15, 11, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := Parse(tc.interval, tc.on)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, s.NextRun(tc.lastRun, wednesday))
+		})
+	}
+}
+
+func TestNextRunInTimeZone(t *testing.T) {
+	kolkata, err := LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	// 20:00 UTC on Sunday is already 01:30 on Monday in Kolkata
+	now := date(2024, time.May, 19, 20, 0).In(kolkata)
+
+	s, err := Parse("weekly", "Monday")
+	require.NoError(t, err)
+	assert.True(t, date(2024, time.May, 19, 18, 30).Equal(s.NextRun(nil, now)))
+
+	s, err = Parse("daily", "06:00")
+	require.NoError(t, err)
+	assert.True(t, date(2024, time.May, 20, 0, 30).Equal(s.NextRun(nil, now)))
+
+	_, err = LoadLocation("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
@@ -0,0 +1,268 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+)
+
+// DefaultJitterWindow is how far due times are spread when SCHEDULE_JITTER_WINDOW is unset
+const DefaultJitterWindow = 15 * time.Minute
+
+// maxBlackoutHops bounds the search for a time outside overlapping blackout windows
+const maxBlackoutHops = 16
+
+// Window is a recurring time of day during which no backup starts, such as business
+// hours. A window whose end is not after its start runs past midnight.
+type Window struct {
+	// days are the weekdays the window starts on; none set means every day
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+	// loc is the time zone the window is evaluated in; nil means the zone of the time
+	// being checked, i.e. the job's time zone
+	loc *time.Location
+}
+
+// Blackouts is a set of blackout windows
+type Blackouts []Window
+
+// ParseBlackouts parses windows separated by semicolons. Every window is a time range
+// in 24-hour HH:MM, optionally preceded by the weekdays it applies to:
+//
+//	09:00-17:00                      every day
+//	mon-fri 09:00-17:00              weekdays only
+//	sat,sun 22:00-06:00              weekend nights, running past midnight
+//
+// The windows are evaluated in loc, or in the job's time zone if loc is nil.
+func ParseBlackouts(spec string, loc *time.Location) (Blackouts, error) {
+	var windows Blackouts
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		w := Window{loc: loc}
+		days, times, hasDays := strings.Cut(part, " ")
+		if !hasDays {
+			days, times = "", part
+		}
+		if err := parseDays(strings.TrimSpace(days), &w.days); err != nil {
+			return nil, err
+		}
+
+		from, to, ok := strings.Cut(strings.TrimSpace(times), "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid blackout window %q, use [days ]HH:MM-HH:MM", part)
+		}
+		var err error
+		if w.start, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.start == w.end || w.start >= 24*time.Hour {
+			return nil, fmt.Errorf("invalid blackout window %q", part)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseDay(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if day, ok := weekdays[s]; ok {
+		return day, nil
+	}
+	if day, ok := dayNames[s]; ok {
+		return day, nil
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+// parseDays parses a comma separated list of weekdays and weekday ranges
+func parseDays(s string, days *[7]bool) error {
+	if s == "" {
+		return nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, err := parseDay(from)
+		if err != nil {
+			return err
+		}
+		last := first
+		if isRange {
+			if last, err = parseDay(to); err != nil {
+				return err
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM, allowing 24:00 as the end of the day
+func parseClock(s string) (time.Duration, error) {
+	hour, minute, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, herr := strconv.Atoi(hour)
+	m, merr := strconv.Atoi(minute)
+	if !ok || herr != nil || merr != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// until returns the end of the occurrence of the window that contains t
+func (w Window) until(t time.Time) (time.Time, bool) {
+	if w.loc != nil {
+		t = t.In(w.loc)
+	}
+	// An occurrence that started yesterday may still be running
+	for _, offset := range []int{0, -1} {
+		day := time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, t.Location())
+		if w.days != [7]bool{} && !w.days[day.Weekday()] {
+			continue
+		}
+		start := at(day, w.start)
+		end := at(day, w.end)
+		if w.end <= w.start {
+			end = at(day.AddDate(0, 0, 1), w.end)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// at returns the wall clock time of day d on day, which stays correct across DST changes
+func at(day time.Time, d time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(d/time.Minute), 0, 0, day.Location())
+}
+
+// Until returns when the blackout containing t ends, or false if t is outside every window
+func (b Blackouts) Until(t time.Time) (time.Time, bool) {
+	end, blocked := t, false
+	for i := 0; i < maxBlackoutHops; i++ {
+		moved := false
+		for _, w := range b {
+			if until, ok := w.until(end); ok {
+				end, moved, blocked = until.In(t.Location()), true, true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	return end, blocked
+}
+
+// Next returns the first time at or after t that is outside every window
+func (b Blackouts) Next(t time.Time) time.Time {
+	next, _ := b.Until(t)
+	return next
+}
+
+// Jitter returns an offset in [0, window) derived from key, so a job gets the same
+// offset on every run while different jobs are spread across the window
+func Jitter(key string, window time.Duration) time.Duration {
+	if window < time.Second {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return time.Duration(h.Sum64()%uint64(window/time.Second)) * time.Second
+}
+
+// Spread moves due times of jobs to their own slot, so jobs sharing a schedule do not all
+// start in the same minute, and out of blackout windows
+type Spread struct {
+	// JitterWindow is the longest a job is delayed past its scheduled time
+	JitterWindow time.Duration
+	// Blackouts apply to every job
+	Blackouts Blackouts
+}
+
+var (
+	defaultSpreadOnce sync.Once
+	defaultSpread     Spread
+)
+
+// DefaultSpread returns the spread configured in the environment:
+//
+//	SCHEDULE_JITTER_WINDOW=15m                     0 disables the jitter
+//	SCHEDULE_BLACKOUT_WINDOWS="mon-fri 09:00-17:00" in the server's time zone
+func DefaultSpread() Spread {
+	defaultSpreadOnce.Do(func() {
+		ctx := context.Background()
+		defaultSpread.JitterWindow = DefaultJitterWindow
+		if v := utils.GetEnvWithKey("SCHEDULE_JITTER_WINDOW"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				defaultSpread.JitterWindow = d
+			} else {
+				logger.Warn(ctx, "Invalid SCHEDULE_JITTER_WINDOW value, using default",
+					logger.String("value", v), logger.String("default", DefaultJitterWindow.String()))
+			}
+		}
+
+		blackouts, err := ParseBlackouts(utils.GetEnvWithKey("SCHEDULE_BLACKOUT_WINDOWS"), time.Local)
+		if err != nil {
+			logger.Warn(ctx, "Ignoring invalid SCHEDULE_BLACKOUT_WINDOWS", logger.ErrorField(err))
+		}
+		defaultSpread.Blackouts = blackouts
+	})
+	return defaultSpread
+}
+
+// Slot returns when a job identified by key should start for an activation due at due.
+// period is the time to the following activation; the jitter is kept below half of it so
+// runs never overtake each other. extra are blackouts of the job's user, applied along
+// with the global ones.
+func (s Spread) Slot(key string, due time.Time, period time.Duration, extra Blackouts) time.Time {
+	window := s.JitterWindow
+	if period > 0 && window > period/2 {
+		window = period / 2
+	}
+
+	slot := due.Add(Jitter(key, window))
+	if release, blocked := s.release(key, slot, window, extra); blocked {
+		return release
+	}
+	return slot
+}
+
+// Blocked reports whether t falls into a blackout window and, if so, when the job
+// identified by key may start instead
+func (s Spread) Blocked(key string, t time.Time, extra Blackouts) (time.Time, bool) {
+	return s.release(key, t, s.JitterWindow, extra)
+}
+
+func (s Spread) release(key string, t time.Time, window time.Duration, extra Blackouts) (time.Time, bool) {
+	blackouts := append(append(Blackouts{}, s.Blackouts...), extra...)
+	end, blocked := blackouts.Until(t)
+	if !blocked {
+		return t, false
+	}
+	// Jobs released by the end of a blackout are spread out again
+	return blackouts.Next(end.Add(Jitter(key, window))), true
+}
@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBlackoutsRejectsInvalidValues(t *testing.T) {
+	for _, spec := range []string{
+		"09:00",
+		"9-17",
+		"09:00-09:00",
+		"funday 09:00-17:00",
+		"24:00-06:00",
+		"09:00-25:00",
+	} {
+		_, err := ParseBlackouts(spec, nil)
+		assert.Error(t, err, spec)
+	}
+
+	blackouts, err := ParseBlackouts(" ; ", nil)
+	require.NoError(t, err)
+	assert.Empty(t, blackouts)
+}
+
+func TestBlackoutsUntil(t *testing.T) {
+	blackouts, err := ParseBlackouts("mon-fri 09:00-17:00; sat,sun 22:00-06:00", nil)
+	require.NoError(t, err)
+
+	// Wednesday 15 May 2024
+	for _, tc := range []struct {
+		name    string
+		at      time.Time
+		want    time.Time
+		blocked bool
+	}{
+		{"weekday business hours", date(2024, time.May, 15, 10, 0), date(2024, time.May, 15, 17, 0), true},
+		{"start of the window is blocked", date(2024, time.May, 15, 9, 0), date(2024, time.May, 15, 17, 0), true},
+		{"end of the window is free", date(2024, time.May, 15, 17, 0), date(2024, time.May, 15, 17, 0), false},
+		{"weekday night is free", date(2024, time.May, 15, 23, 0), date(2024, time.May, 15, 23, 0), false},
+		{"saturday daytime is free", date(2024, time.May, 18, 10, 0), date(2024, time.May, 18, 10, 0), false},
+		{"window past midnight", date(2024, time.May, 18, 23, 0), date(2024, time.May, 19, 6, 0), true},
+		{"window started the day before", date(2024, time.May, 19, 2, 0), date(2024, time.May, 19, 6, 0), true},
+		{"sunday night runs into monday", date(2024, time.May, 20, 5, 0), date(2024, time.May, 20, 6, 0), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			until, blocked := blackouts.Until(tc.at)
+			assert.Equal(t, tc.blocked, blocked)
+			assert.Equal(t, tc.want, until)
+		})
+	}
+}
+
+func TestBlackoutsChainOverlappingWindows(t *testing.T) {
+	blackouts, err := ParseBlackouts("09:00-12:00; 11:00-14:00", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, date(2024, time.May, 15, 14, 0), blackouts.Next(date(2024, time.May, 15, 10, 0)))
+}
+
+func TestBlackoutsInFixedLocation(t *testing.T) {
+	kolkata, err := LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	blackouts, err := ParseBlackouts("09:00-17:00", kolkata)
+	require.NoError(t, err)
+
+	// 04:00 UTC is 09:30 in Kolkata
+	until, blocked := blackouts.Until(date(2024, time.May, 15, 4, 0))
+	assert.True(t, blocked)
+	assert.True(t, date(2024, time.May, 15, 11, 30).Equal(until))
+	assert.Equal(t, time.UTC, until.Location())
+}
+
+func TestJitterIsStableAndBounded(t *testing.T) {
+	window := 15 * time.Minute
+	seen := make(map[time.Duration]bool)
+	for _, key := range []string{"1", "2", "3", "4", "5", "6", "7", "8"} {
+		j := Jitter(key, window)
+		assert.Equal(t, j, Jitter(key, window))
+		assert.True(t, j >= 0 && j < window, "%s: %s", key, j)
+		seen[j] = true
+	}
+	assert.Greater(t, len(seen), 1, "jobs should not share a slot")
+
+	assert.Zero(t, Jitter("1", 0))
+}
+
+func TestSpreadSlot(t *testing.T) {
+	blackouts, err := ParseBlackouts("00:00-01:00", nil)
+	require.NoError(t, err)
+	midnight := date(2024, time.May, 15, 0, 0)
+	jitter := Jitter("42", 30*time.Minute)
+
+	spread := Spread{JitterWindow: 30 * time.Minute}
+	assert.Equal(t, midnight.Add(jitter), spread.Slot("42", midnight, 24*time.Hour, nil))
+
+	// The jitter never reaches the next activation
+	slot := spread.Slot("42", midnight, 10*time.Minute, nil)
+	assert.True(t, slot.Before(midnight.Add(5*time.Minute)))
+
+	// Jobs held back by a blackout are spread after it again
+	assert.Equal(t, midnight.Add(time.Hour+jitter), spread.Slot("42", midnight, 24*time.Hour, blackouts))
+
+	spread.Blackouts = blackouts
+	release, blocked := spread.Blocked("42", midnight.Add(30*time.Minute), nil)
+	assert.True(t, blocked)
+	assert.Equal(t, midnight.Add(time.Hour+jitter), release)
+
+	_, blocked = spread.Blocked("42", midnight.Add(2*time.Hour), nil)
+	assert.False(t, blocked)
+}
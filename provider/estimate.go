@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+)
+
+// Rough throughput used to turn an estimate into a duration. Each item costs a few API
+// calls on top of its transfer time.
+const (
+	estimateItemOverhead = 400 * time.Millisecond
+	estimateBytesPerSec  = 4 << 20
+)
+
+// DefaultEstimateMaxItems bounds the number of items a dry run walks
+const DefaultEstimateMaxItems = 5000
+
+// MaxEstimateMaxItems is the most items a dry run may be asked to walk
+const MaxEstimateMaxItems = 50000
+
+// EstimateInput is the input handed to a provider for a dry run. Nothing is uploaded.
+type EstimateInput struct {
+	Ctx    context.Context
+	UserID string
+	// Account is the login of the source account; its objects are stored under Account + "/"
+	Account   string
+	InputData map[string]interface{}
+	// ItemIDs are the items of a scheduled task. Nil means the whole account, as backed
+	// up by an auto-sync job.
+	ItemIDs  []string
+	Database *db.PostgresDb
+	// MaxItems stops the walk early; the estimate is then marked as truncated
+	MaxItems int
+}
+
+// Estimate is the result of a dry run
+type Estimate struct {
+	Method string `json:"method"`
+	// Items counts the items found at the source, split into new and already synced ones
+	Items       int `json:"items"`
+	NewItems    int `json:"new_items"`
+	SyncedItems int `json:"already_synced_items"`
+	// FailedItems could not be looked up, e.g. because they were deleted
+	FailedItems int `json:"failed_items"`
+	// Bytes only include items whose size the source reports; see SizeKnown
+	Bytes     int64 `json:"bytes"`
+	NewBytes  int64 `json:"new_bytes"`
+	SizeKnown bool  `json:"size_known"`
+	// Truncated is set when the walk stopped at MaxItems
+	Truncated                bool  `json:"truncated"`
+	EstimatedDurationSeconds int64 `json:"estimated_duration_seconds"`
+
+	maxItems int
+}
+
+// NewEstimate creates an empty estimate for method. sizeKnown tells whether the source
+// reports item sizes.
+func NewEstimate(method string, maxItems int, sizeKnown bool) *Estimate {
+	if maxItems <= 0 {
+		maxItems = DefaultEstimateMaxItems
+	}
+	maxItems = min(maxItems, MaxEstimateMaxItems)
+	return &Estimate{Method: method, SizeKnown: sizeKnown, maxItems: maxItems}
+}
+
+// Add counts an item of the given size
+func (e *Estimate) Add(size int64, synced bool) {
+	e.Items++
+	e.Bytes += size
+	if synced {
+		e.SyncedItems++
+		return
+	}
+	e.NewItems++
+	e.NewBytes += size
+}
+
+// Fail counts an item that could not be looked up
+func (e *Estimate) Fail() {
+	e.FailedItems++
+}
+
+// Full reports whether the walk should stop, and marks the estimate as truncated if so
+func (e *Estimate) Full() bool {
+	if e.Items+e.FailedItems >= e.maxItems {
+		e.Truncated = true
+	}
+	return e.Truncated
+}
+
+// Finish computes the estimated duration of the backup of the new items
+func (e *Estimate) Finish() *Estimate {
+	d := time.Duration(e.NewItems) * estimateItemOverhead
+	if e.SizeKnown {
+		d += time.Duration(e.NewBytes/estimateBytesPerSec) * time.Second
+	}
+	e.EstimatedDurationSeconds = int64(d.Round(time.Second) / time.Second)
+	return e
+}
+
+// SyncedItemIDs returns the source IDs of the objects already synced for an account.
+// idOf extracts the ID from an object key and returns "" for keys without one, such as
+// folder placeholders.
+func SyncedItemIDs(store *db.PostgresDb, userID, bucket, account, source, objectType string, idOf func(key string) string) (map[string]bool, error) {
+	objects, err := store.SyncedObjectRepo.GetSyncedObjectsByUserAndBucket(userID, bucket, source, objectType)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := account + "/"
+	ids := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.ObjectKey, prefix) {
+			continue
+		}
+		if id := idOf(obj.ObjectKey); id != "" {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
+
+// MessageIDFromKey extracts the message ID from the key of a backed up email, which ends
+// in " - <id>.<ext>"
+func MessageIDFromKey(ext string) func(string) string {
+	return func(key string) string {
+		name := strings.TrimSuffix(path.Base(key), "."+ext)
+		i := strings.LastIndex(name, " - ")
+		if i < 0 || name == path.Base(key) {
+			return ""
+		}
+		return name[i+len(" - "):]
+	}
+}
+
+// PrefixedIDFromKey extracts the item ID from a key whose last segment is "<id>_<name>",
+// as used for Drive files and Photos items
+func PrefixedIDFromKey(key string) string {
+	name := path.Base(key)
+	i := strings.Index(name, "_")
+	if i <= 0 {
+		return ""
+	}
+	return name[:i]
+}
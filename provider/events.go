@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
+	"github.com/StorX2-0/Backup-Tools/repo"
+)
+
+// eventBatchSize is the number of events buffered before they are written
+const eventBatchSize = 50
+
+// EventLog records the timeline of a task in the task_events table. Events are buffered
+// and written in batches; the task runner flushes the rest when the task ends. Item
+// results are also counted towards the task's live progress. A nil EventLog discards
+// everything, so providers can call it unconditionally.
+type EventLog struct {
+	repo     *repo.TaskEventRepository
+	taskType string
+	taskID   uint
+	progress *progress.Tracker
+
+	mu      sync.Mutex
+	pending []repo.TaskEvent
+}
+
+// NewEventLog creates the event log of a task. taskType is repo.TaskEventTypeAutoSync
+// or repo.TaskEventTypeScheduled.
+func NewEventLog(r *repo.TaskEventRepository, taskType string, taskID uint) *EventLog {
+	return &EventLog{repo: r, taskType: taskType, taskID: taskID}
+}
+
+// Track counts the items recorded in the log towards the live progress of the task
+func (l *EventLog) Track(tracker *progress.Tracker) {
+	if l != nil {
+		l.progress = tracker
+	}
+}
+
+// Info records a message about the task as a whole
+func (l *EventLog) Info(message string) {
+	l.add(repo.TaskEvent{Level: repo.TaskEventLevelInfo, Message: message})
+}
+
+// Synced records an item that was uploaded to objectKey
+func (l *EventLog) Synced(itemID, objectKey string, bytes int64) {
+	if l != nil {
+		l.progress.ItemDone(itemID, bytes)
+	}
+	l.add(repo.TaskEvent{
+		Level:     repo.TaskEventLevelInfo,
+		Message:   "synced",
+		ItemID:    itemID,
+		ObjectKey: objectKey,
+		Bytes:     bytes,
+	})
+}
+
+// Skipped records an item that was not uploaded, e.g. because it already exists
+func (l *EventLog) Skipped(itemID, objectKey, reason string) {
+	if l != nil {
+		l.progress.ItemDone(itemID, 0)
+	}
+	l.add(repo.TaskEvent{
+		Level:     repo.TaskEventLevelInfo,
+		Message:   "skipped: " + reason,
+		ItemID:    itemID,
+		ObjectKey: objectKey,
+	})
+}
+
+// Failed records an item that could not be backed up. itemID is empty for failures of
+// the task as a whole.
+func (l *EventLog) Failed(itemID string, err error) {
+	if err == nil {
+		return
+	}
+	if l != nil && itemID != "" {
+		l.progress.ItemFailed(itemID)
+	}
+	l.add(repo.TaskEvent{
+		Level:         repo.TaskEventLevelError,
+		Message:       err.Error(),
+		ItemID:        itemID,
+		ErrorCategory: string(errs.CategoryOf(err)),
+	})
+}
+
+func (l *EventLog) add(event repo.TaskEvent) {
+	if l == nil {
+		return
+	}
+	event.CreatedAt = time.Now()
+	event.TaskType = l.taskType
+	event.TaskID = l.taskID
+
+	l.mu.Lock()
+	l.pending = append(l.pending, event)
+	full := len(l.pending) >= eventBatchSize
+	l.mu.Unlock()
+
+	if full {
+		l.Flush(context.Background())
+	}
+}
+
+// Flush writes the buffered events. Failures are logged and the events dropped, since the
+// event log must never fail a backup.
+func (l *EventLog) Flush(ctx context.Context) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	events := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	if err := l.repo.AppendTaskEvents(events); err != nil {
+		logger.Warn(ctx, "Failed to write task events",
+			logger.String("task_type", l.taskType),
+			logger.Int("task_id", int(l.taskID)),
+			logger.Int("events", len(events)),
+			logger.ErrorField(err),
+		)
+	}
+}
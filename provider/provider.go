@@ -0,0 +1,305 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/pkg/database"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// Capability describes what a provider is able to do. Capabilities are bit flags
+// so a provider can advertise several of them at once.
+type Capability uint
+
+const (
+	// CapFullSync means the provider can back up a whole account on a schedule (auto-sync jobs)
+	CapFullSync Capability = 1 << iota
+	// CapSelectiveSync means the provider can back up an explicit list of items (scheduled tasks)
+	CapSelectiveSync
+	// CapRestore means backed up data can be pushed back into the provider
+	CapRestore
+	// CapRetention means every run uploads new objects, e.g. timestamped dumps, so the
+	// older ones can be pruned by a retention policy
+	CapRetention
+)
+
+// Has reports whether all capabilities in c are present
+func (c Capability) Has(capability Capability) bool {
+	return c&capability == capability
+}
+
+// ErrNotSupported is returned when a provider is asked to do something it has no capability for
+var ErrNotSupported = errors.New("operation not supported by provider")
+
+// Deps contains all dependencies for task processing
+type Deps struct {
+	Store *db.PostgresDb
+	Repo  *repo.ScheduledTasksRepository
+}
+
+// FullSyncInput is the input handed to a provider for an auto-sync (cron) task
+type FullSyncInput struct {
+	// Ctx is cancelled when the server shuts down. HeartBeatFunc then returns an error,
+	// so providers stop at their next checkpoint with the job's task memory up to date.
+	Ctx           context.Context
+	InputData     *database.DbJson[map[string]interface{}]
+	Task          *repo.TaskListingDB
+	Job           *repo.CronJobListingDB
+	HeartBeatFunc func() error
+	Database      *db.PostgresDb
+	// Events records per-item results in the task's timeline
+	Events *EventLog
+	// Storage is the object store of the job's access grant, held for the whole run
+	Storage satellite.ObjectStore
+	// SaveCheckpoint persists the job's task memory right away, e.g. after every part of
+	// a multipart upload, so a task restarted after a crash resumes from there
+	SaveCheckpoint func() error
+}
+
+// ObjectMetadata returns meta tagged with the job the task runs for. Uploads with it are
+// compressed as the job is configured to.
+func (in FullSyncInput) ObjectMetadata(meta satellite.ObjectMetadata) satellite.ObjectMetadata {
+	meta.JobID = strconv.FormatUint(uint64(in.Job.ID), 10)
+	meta.Compression = in.Job.Compression
+	return meta
+}
+
+// SelectiveSyncInput is the input handed to a provider for a scheduled task
+type SelectiveSyncInput struct {
+	// Ctx is cancelled when the server shuts down, see FullSyncInput
+	Ctx           context.Context
+	InputData     map[string]interface{}
+	Memory        map[string][]string
+	Task          *repo.ScheduledTasks
+	HeartBeatFunc func() error
+	Deps          *Deps
+	// Events records per-item results in the task's timeline
+	Events *EventLog
+	// Storage is the object store of the task's access grant, held for the whole run
+	Storage satellite.ObjectStore
+	// SaveCheckpoint persists Memory right away, see FullSyncInput
+	SaveCheckpoint func() error
+}
+
+// ObjectMetadata returns meta tagged with the scheduled task. Scheduled tasks have no
+// job, their IDs are recorded as "task-<id>".
+func (in SelectiveSyncInput) ObjectMetadata(meta satellite.ObjectMetadata) satellite.ObjectMetadata {
+	meta.JobID = fmt.Sprintf("task-%d", in.Task.ID)
+	return meta
+}
+
+// Provider is the plugin interface implemented by every backup source.
+// Methods for capabilities the provider does not advertise should return ErrNotSupported.
+type Provider interface {
+	Name() string
+	Capabilities() Capability
+	FullSync(FullSyncInput) error
+	SelectiveSync(SelectiveSyncInput) error
+	// Estimate walks the source like a sync would without uploading anything
+	Estimate(EstimateInput) (*Estimate, error)
+	// Account resolves the account an access token of a scheduled task belongs to and
+	// the input data the task runs with
+	Account(accessToken string) (string, map[string]interface{}, error)
+	// Bucket is the bucket the objects of the provider are uploaded to, or "" if it has none
+	Bucket() string
+}
+
+// Plugin is a Provider assembled from plain functions. It is the usual way to register
+// a provider whose full and selective sync live in different packages.
+type Plugin struct {
+	Method string
+	// BucketName is the bucket the objects are uploaded to
+	BucketName        string
+	Caps              Capability
+	FullSyncFunc      func(FullSyncInput) error
+	SelectiveSyncFunc func(SelectiveSyncInput) error
+	// FullEstimateFunc and SelectiveEstimateFunc are optional dry runs of the two syncs
+	FullEstimateFunc      func(EstimateInput) (*Estimate, error)
+	SelectiveEstimateFunc func(EstimateInput) (*Estimate, error)
+	// AccountFunc resolves the account of a scheduled task's access token, see Provider
+	AccountFunc func(accessToken string) (string, map[string]interface{}, error)
+}
+
+func (p *Plugin) Name() string {
+	return p.Method
+}
+
+func (p *Plugin) Bucket() string {
+	return p.BucketName
+}
+
+// Capabilities returns the advertised capabilities. Sync capabilities without a
+// function behind them are dropped so callers can trust the flags.
+func (p *Plugin) Capabilities() Capability {
+	caps := p.Caps
+	if p.FullSyncFunc == nil {
+		caps &^= CapFullSync
+	}
+	if p.SelectiveSyncFunc == nil || p.AccountFunc == nil {
+		caps &^= CapSelectiveSync
+	}
+	return caps
+}
+
+func (p *Plugin) FullSync(input FullSyncInput) error {
+	if p.FullSyncFunc == nil {
+		return fmt.Errorf("%s: full sync: %w", p.Method, ErrNotSupported)
+	}
+	return p.FullSyncFunc(input)
+}
+
+func (p *Plugin) SelectiveSync(input SelectiveSyncInput) error {
+	if p.SelectiveSyncFunc == nil {
+		return fmt.Errorf("%s: selective sync: %w", p.Method, ErrNotSupported)
+	}
+	return p.SelectiveSyncFunc(input)
+}
+
+func (p *Plugin) Account(accessToken string) (string, map[string]interface{}, error) {
+	if p.AccountFunc == nil {
+		return "", nil, fmt.Errorf("%s: account: %w", p.Method, ErrNotSupported)
+	}
+	return p.AccountFunc(accessToken)
+}
+
+// Estimate dispatches to the full or selective estimate depending on whether item IDs
+// were given
+func (p *Plugin) Estimate(input EstimateInput) (*Estimate, error) {
+	estimate := p.FullEstimateFunc
+	if input.ItemIDs != nil {
+		estimate = p.SelectiveEstimateFunc
+	}
+	if estimate == nil {
+		return nil, fmt.Errorf("%s: estimate: %w", p.Method, ErrNotSupported)
+	}
+	return estimate(input)
+}
+
+// Registry holds the providers known to the service, keyed by method name
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+// NewRegistry creates an empty provider registry
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register adds a provider to the registry. Registering the same method twice is an error.
+func (r *Registry) Register(p Provider) error {
+	if p == nil || p.Name() == "" {
+		return fmt.Errorf("provider must have a name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.providers[p.Name()]; exists {
+		return fmt.Errorf("provider %s already registered", p.Name())
+	}
+	r.providers[p.Name()] = p
+	return nil
+}
+
+// Lookup returns the provider registered for a method
+func (r *Registry) Lookup(method string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.providers[method]
+	return p, ok
+}
+
+// Supports reports whether a provider is registered for method and has the given capability
+func (r *Registry) Supports(method string, capability Capability) bool {
+	p, ok := r.Lookup(method)
+	return ok && p.Capabilities().Has(capability)
+}
+
+// Methods returns the sorted method names of all providers with the given capability
+func (r *Registry) Methods(capability Capability) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var methods []string
+	for name, p := range r.providers {
+		if p.Capabilities().Has(capability) {
+			methods = append(methods, name)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Bucket returns the bucket the objects of method are uploaded to
+func (r *Registry) Bucket(method string) (string, bool) {
+	p, ok := r.Lookup(method)
+	if !ok || p.Bucket() == "" {
+		return "", false
+	}
+	return p.Bucket(), true
+}
+
+// BucketMethods returns the sorted methods whose objects are uploaded to bucket
+func (r *Registry) BucketMethods(bucket string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var methods []string
+	for name, p := range r.providers {
+		if p.Bucket() == bucket {
+			methods = append(methods, name)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// defaultRegistry is shared by the cron jobs, scheduled tasks and HTTP handlers
+var defaultRegistry = NewRegistry()
+
+// Register adds a provider to the default registry
+func Register(p Provider) error {
+	return defaultRegistry.Register(p)
+}
+
+// MustRegister adds a provider to the default registry and panics on failure.
+// Intended for use from init functions.
+func MustRegister(p Provider) {
+	if err := defaultRegistry.Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the provider registered for a method in the default registry
+func Lookup(method string) (Provider, bool) {
+	return defaultRegistry.Lookup(method)
+}
+
+// Supports reports whether the default registry has a provider for method with the given capability
+func Supports(method string, capability Capability) bool {
+	return defaultRegistry.Supports(method, capability)
+}
+
+// Methods returns the method names in the default registry with the given capability
+func Methods(capability Capability) []string {
+	return defaultRegistry.Methods(capability)
+}
+
+// Bucket returns the bucket the objects of method are uploaded to in the default registry
+func Bucket(method string) (string, bool) {
+	return defaultRegistry.Bucket(method)
+}
+
+// BucketMethods returns the methods in the default registry whose objects are uploaded to bucket
+func BucketMethods(bucket string) []string {
+	return defaultRegistry.BucketMethods(bucket)
+}
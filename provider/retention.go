@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/retention"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// RetentionPlan applies policy to the backups of job. Only objects whose metadata names
+// the job are considered, so objects of other jobs in the same bucket and objects
+// uploaded before the job ID was recorded are never removed.
+func RetentionPlan(ctx context.Context, store satellite.ObjectStore, job *repo.CronJobListingDB, policy retention.Policy, now time.Time) ([]retention.Decision, error) {
+	if !Supports(job.Method, CapRetention) {
+		return nil, fmt.Errorf("%s: retention: %w", job.Method, ErrNotSupported)
+	}
+	bucket, ok := Bucket(job.Method)
+	if !ok {
+		return nil, fmt.Errorf("%s: retention: %w", job.Method, ErrNotSupported)
+	}
+
+	objects, err := store.List(ctx, bucket, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID := strconv.FormatUint(uint64(job.ID), 10)
+	var items []retention.Item
+	for _, object := range objects {
+		// Content preserved for snapshots carries the job ID of the object it was copied
+		// from, but belongs to the snapshots
+		if object.IsPrefix || object.Metadata.JobID != jobID || strings.HasPrefix(object.Key, satellite.SnapshotPrefix) {
+			continue
+		}
+		items = append(items, retention.Item{Key: object.Key, Time: object.Created, Size: object.Size})
+	}
+	return policy.Apply(items, now, job.Location()), nil
+}
@@ -0,0 +1,199 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/repo"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// SnapshotListing is the part of a bucket that is listed into the manifest of a run's
+// snapshot: the objects the backup consists of, whichever run uploaded them
+type SnapshotListing struct {
+	Bucket string
+	Prefix string
+	// JobID limits the listing to the objects uploaded by one job
+	JobID string
+}
+
+// SnapshotListing returns the objects the backup of the job consists of: the account's
+// folder, or for sources that upload a new dump every run, the dumps of the job
+func (in FullSyncInput) SnapshotListing() (SnapshotListing, bool) {
+	bucket, ok := Bucket(in.Job.Method)
+	if !ok {
+		return SnapshotListing{}, false
+	}
+	if Supports(in.Job.Method, CapRetention) {
+		return SnapshotListing{Bucket: bucket, JobID: strconv.FormatUint(uint64(in.Job.ID), 10)}, true
+	}
+	return SnapshotListing{Bucket: bucket, Prefix: in.Job.Name + "/"}, true
+}
+
+// SnapshotListing returns the account's folder the scheduled task backs up to
+func (in SelectiveSyncInput) SnapshotListing() (SnapshotListing, bool) {
+	bucket, ok := Bucket(in.Task.Method)
+	if !ok || in.Task.LoginId == "" {
+		return SnapshotListing{}, false
+	}
+	return SnapshotListing{Bucket: bucket, Prefix: in.Task.LoginId + "/"}, true
+}
+
+// RecordSnapshot lists the objects of listing into a manifest and registers snapshot for
+// it. snapshot names the user and run; the rest is filled in.
+func RecordSnapshot(ctx context.Context, database *db.PostgresDb, store satellite.ObjectStore, listing SnapshotListing, snapshot *repo.Snapshot) error {
+	now := time.Now()
+	manifest, err := satellite.ListManifest(ctx, store, listing.Bucket, listing.Prefix, listing.JobID, now)
+	if err != nil {
+		return err
+	}
+
+	snapshot.BucketName = listing.Bucket
+	snapshot.Prefix = listing.Prefix
+
+	// The content of the items is kept as of now, so they can be restored after the next
+	// runs replaced their objects
+	manifest.ObjectsPrefix = preservedPrefix(snapshot)
+	if err := satellite.PreserveItems(ctx, store, manifest); err != nil {
+		return err
+	}
+
+	snapshot.ManifestKey = fmt.Sprintf("%s%s-%d-%d.json", satellite.SnapshotPrefix, snapshot.TaskType, snapshot.TaskID, now.Unix())
+	snapshot.ItemCount = len(manifest.Items)
+	snapshot.TotalBytes = manifest.TotalSize()
+
+	if err := satellite.WriteManifest(ctx, store, snapshot.ManifestKey, manifest); err != nil {
+		return err
+	}
+	if err := database.SnapshotRepo.CreateSnapshot(snapshot); err != nil {
+		return err
+	}
+
+	// The snapshot was recorded, so old ones that stay around are only logged
+	if err := PruneSnapshots(ctx, database, store, snapshot, SnapshotsKept()); err != nil {
+		logger.Warn(ctx, "Failed to prune snapshots",
+			logger.String("bucket", snapshot.BucketName),
+			logger.String("prefix", snapshot.Prefix),
+			logger.ErrorField(err),
+		)
+	}
+	return nil
+}
+
+// defaultSnapshotsKept is how many snapshots of a job or account are kept when
+// SNAPSHOTS_KEPT is unset
+const defaultSnapshotsKept = 30
+
+var (
+	snapshotsKeptOnce sync.Once
+	snapshotsKept     int
+)
+
+// SnapshotsKept returns how many snapshots of a job or account are kept. Older ones are
+// removed when a new one is recorded.
+func SnapshotsKept() int {
+	snapshotsKeptOnce.Do(func() {
+		snapshotsKept = defaultSnapshotsKept
+		if v := utils.GetEnvWithKey("SNAPSHOTS_KEPT"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				snapshotsKept = n
+			} else {
+				logger.Warn(context.Background(), "Invalid SNAPSHOTS_KEPT value, using default",
+					logger.String("value", v), logger.Int("default", defaultSnapshotsKept))
+			}
+		}
+	})
+	return snapshotsKept
+}
+
+// preservedPrefix returns where the item content of the snapshots of the same job or
+// account as snapshot is preserved
+func preservedPrefix(snapshot *repo.Snapshot) string {
+	if snapshot.JobID != 0 {
+		return fmt.Sprintf("%sobjects/job-%d/%s", satellite.SnapshotPrefix, snapshot.JobID, snapshot.Prefix)
+	}
+	return satellite.SnapshotPrefix + "objects/" + snapshot.Prefix
+}
+
+// PruneSnapshots removes the snapshots of the same job or account as snapshot beyond the
+// newest keep, manifest first, and then the preserved content no remaining snapshot refers
+// to. A snapshot whose manifest could not be deleted is kept, so no manifest is left
+// without its row.
+func PruneSnapshots(ctx context.Context, database *db.PostgresDb, store satellite.ObjectStore, snapshot *repo.Snapshot, keep int) error {
+	expired, err := database.SnapshotRepo.GetSnapshotsBeyond(snapshot, keep)
+	if err != nil {
+		return err
+	}
+
+	var removed []uint
+	for _, old := range expired {
+		err := store.Delete(ctx, old.BucketName, old.ManifestKey)
+		if err != nil && !errors.Is(err, satellite.ErrObjectNotFound) {
+			logger.Warn(ctx, "Failed to delete snapshot manifest",
+				logger.Int("snapshot_id", int(old.ID)),
+				logger.String("manifest_key", old.ManifestKey),
+				logger.ErrorField(err),
+			)
+			continue
+		}
+		removed = append(removed, old.ID)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := database.SnapshotRepo.DeleteSnapshots(removed); err != nil {
+		return err
+	}
+	return removeUnreferencedContent(ctx, database, store, snapshot)
+}
+
+// removeUnreferencedContent deletes the preserved content of the snapshots of the same job
+// or account as snapshot that none of them refers to any more. Nothing is deleted if a
+// manifest can not be read.
+func removeUnreferencedContent(ctx context.Context, database *db.PostgresDb, store satellite.ObjectStore, snapshot *repo.Snapshot) error {
+	remaining, err := database.SnapshotRepo.GetSnapshotsBeyond(snapshot, 0)
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{}
+	for _, s := range remaining {
+		manifest, err := satellite.ReadManifest(ctx, store, s.BucketName, s.ManifestKey)
+		if errors.Is(err, satellite.ErrObjectNotFound) {
+			// Stored with another access grant; so is its content
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, item := range manifest.Items {
+			if key, ok := manifest.PreservedKey(item); ok {
+				referenced[key] = true
+			}
+		}
+	}
+
+	objects, err := store.List(ctx, snapshot.BucketName, preservedPrefix(snapshot), true)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if object.IsPrefix || referenced[object.Key] {
+			continue
+		}
+		if err := store.Delete(ctx, snapshot.BucketName, object.Key); err != nil {
+			logger.Warn(ctx, "Failed to delete preserved snapshot content",
+				logger.String("bucket", snapshot.BucketName),
+				logger.String("object_key", object.Key),
+				logger.ErrorField(err),
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/database"
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Statuses of a catalog check
+const (
+	CatalogCheckStatusPending = "pending"
+	CatalogCheckStatusRunning = "running"
+	CatalogCheckStatusSuccess = "success"
+	CatalogCheckStatusFailed  = "failed"
+)
+
+// What started a catalog check
+const (
+	CatalogCheckTriggerScheduled = "scheduled"
+	CatalogCheckTriggerManual    = "manual"
+)
+
+// CatalogCheckSampleSize is how many keys of each discrepancy a check keeps for its
+// report
+const CatalogCheckSampleSize = 100
+
+// catalogCheckLease is how long a running check may go without finishing before another
+// instance takes it over
+const catalogCheckLease = 2 * time.Hour
+
+// CatalogCheck is one comparison of the synced_objects catalog of a user and bucket with
+// the objects in the bucket
+type CatalogCheck struct {
+	gorm.GormModel
+
+	UserID     string `json:"user_id" gorm:"not null;index:idx_catalog_checks_user_bucket"`
+	BucketName string `json:"bucket_name" gorm:"not null;index:idx_catalog_checks_user_bucket"`
+	Trigger    string `json:"trigger"`
+	Status     string `json:"status" gorm:"default:pending;index"`
+	// DryRun checks only report; the catalog is left as it is
+	DryRun bool `json:"dry_run"`
+
+	CatalogCount int `json:"catalog_count"`
+	ObjectCount  int `json:"object_count"`
+	// MissingFromCatalog counts objects in the bucket the catalog does not know, which
+	// would be uploaded again
+	MissingFromCatalog int `json:"missing_from_catalog"`
+	// MissingFromBucket counts catalog rows of objects that no longer exist, which would
+	// never be backed up again
+	MissingFromBucket int `json:"missing_from_bucket"`
+	Added             int `json:"added"`
+	Removed           int `json:"removed"`
+
+	// The first CatalogCheckSampleSize keys of each discrepancy
+	MissingFromCatalogKeys database.DbJson[[]string] `json:"missing_from_catalog_keys" gorm:"type:jsonb"`
+	MissingFromBucketKeys  database.DbJson[[]string] `json:"missing_from_bucket_keys" gorm:"type:jsonb"`
+
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// CatalogCheckRepository handles all database operations for catalog checks
+type CatalogCheckRepository struct {
+	db *gorm.DB
+}
+
+// NewCatalogCheckRepository creates a new catalog check repository
+func NewCatalogCheckRepository(db *gorm.DB) *CatalogCheckRepository {
+	return &CatalogCheckRepository{db: db}
+}
+
+// QueueCatalogCheck creates a pending check for the bucket of a user. A check that is
+// already pending or running for it is returned instead of a new one.
+func (r *CatalogCheckRepository) QueueCatalogCheck(userID, bucketName, trigger string, dryRun bool) (*CatalogCheck, error) {
+	var check CatalogCheck
+	err := r.db.Where("user_id = ? AND bucket_name = ? AND status IN ?",
+		userID, bucketName, []string{CatalogCheckStatusPending, CatalogCheckStatusRunning}).
+		First(&check).Error
+	if err == nil {
+		return &check, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error queueing catalog check: %v", err)
+	}
+
+	check = CatalogCheck{
+		UserID:     userID,
+		BucketName: bucketName,
+		Trigger:    trigger,
+		Status:     CatalogCheckStatusPending,
+		DryRun:     dryRun,
+	}
+	if err := r.db.Create(&check).Error; err != nil {
+		return nil, fmt.Errorf("error queueing catalog check: %v", err)
+	}
+	return &check, nil
+}
+
+// ClaimNextCatalogCheck atomically marks the oldest pending check as running. Checks
+// whose runner stopped without finishing them are claimed again once their lease lapsed.
+func (r *CatalogCheckRepository) ClaimNextCatalogCheck() (*CatalogCheck, error) {
+	var check CatalogCheck
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND started_at < ?)",
+				CatalogCheckStatusPending, CatalogCheckStatusRunning, time.Now().Add(-catalogCheckLease)).
+			Order("id").First(&check).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		check.Status = CatalogCheckStatusRunning
+		check.StartedAt = &now
+		return tx.Save(&check).Error
+	})
+	return &check, err
+}
+
+// FinishCatalogCheck stores the outcome of a check
+func (r *CatalogCheckRepository) FinishCatalogCheck(check *CatalogCheck) error {
+	now := time.Now()
+	check.FinishedAt = &now
+	if err := r.db.Save(check).Error; err != nil {
+		return fmt.Errorf("error finishing catalog check: %v", err)
+	}
+	return nil
+}
+
+// ListCatalogChecksForUser returns the latest checks of a user, newest first. bucketName
+// is an optional filter.
+func (r *CatalogCheckRepository) ListCatalogChecksForUser(userID, bucketName string, limit int) ([]CatalogCheck, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if bucketName != "" {
+		query = query.Where("bucket_name = ?", bucketName)
+	}
+
+	var checks []CatalogCheck
+	if err := query.Order("id DESC").Limit(limit).Find(&checks).Error; err != nil {
+		return nil, fmt.Errorf("error listing catalog checks: %v", err)
+	}
+	return checks, nil
+}
+
+// CatalogCheckDue reports whether the bucket of a user has no check that is pending,
+// running or finished within the last interval
+func (r *CatalogCheckRepository) CatalogCheckDue(userID, bucketName string, interval time.Duration) (bool, error) {
+	var count int64
+	err := r.db.Model(&CatalogCheck{}).
+		Where("user_id = ? AND bucket_name = ?", userID, bucketName).
+		Where("status IN ? OR finished_at > ?",
+			[]string{CatalogCheckStatusPending, CatalogCheckStatusRunning}, time.Now().Add(-interval)).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("error checking catalog checks: %v", err)
+	}
+	return count == 0, nil
+}
@@ -1,13 +1,18 @@
 package repo
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/StorX2-0/Backup-Tools/pkg/database"
 	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"github.com/StorX2-0/Backup-Tools/pkg/retention"
+	"github.com/StorX2-0/Backup-Tools/pkg/schedule"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"github.com/StorX2-0/Backup-Tools/satellite"
 )
 
 // Job message status constants
@@ -26,15 +31,20 @@ const (
 	JobStatusInProgress = "in_progress"
 	JobStatusSuccess    = "success"
 	JobStatusFailed     = "failed"
-
-	TaskStatusPushed  = "pushed"
-	TaskStatusRunning = "running"
-	TaskStatusSuccess = "success"
-	TaskStatusFailed  = "failed"
+	JobStatusPaused     = "paused"
+	JobStatusCancelled  = "cancelled"
+
+	TaskStatusPushed    = "pushed"
+	TaskStatusRunning   = "running"
+	TaskStatusSuccess   = "success"
+	TaskStatusFailed    = "failed"
+	TaskStatusPaused    = "paused"
+	TaskStatusCancelled = "cancelled"
 )
 
 // Other constants
 const (
+	// MaxRetryCount is the default number of automatic retries for a failed task
 	MaxRetryCount = 3
 )
 
@@ -52,6 +62,14 @@ type CronJobListingDB struct {
 	On       string     `json:"on"`
 	LastRun  *time.Time `json:"last_run"`
 
+	// NextRunAt is when the job is next due, as computed by pkg/schedule from Interval and On.
+	// Null for one-time jobs and for jobs that have no valid schedule yet.
+	NextRunAt *time.Time `json:"next_run_at" gorm:"index"`
+
+	// Timezone is the IANA time zone the schedule is evaluated in. It defaults to the
+	// user's time zone when the job is created; empty means the server's time zone.
+	Timezone string `json:"timezone"`
+
 	// Change the type from map[string]interface{} to *database.DbJson[map[string]interface{}]
 	InputData *database.DbJson[map[string]interface{}] `json:"input_data" gorm:"type:jsonb"`
 
@@ -76,6 +94,64 @@ type CronJobListingDB struct {
 	// Hidden column for scheduled tasks - when true, hides the cron job from the live view
 	// Default is false, and it gets reset to false when a new task is created
 	Hidden bool `json:"hidden" gorm:"default:false"`
+
+	// DependsOn lists jobs that must have succeeded since this job last ran before it is
+	// picked up again; until then the job stays due
+	DependsOn JobIDs `json:"depends_on" gorm:"type:jsonb"`
+
+	// Hooks run after a task of the job finished, see JobHooks
+	Hooks JobHooks `json:"hooks" gorm:"type:jsonb"`
+
+	// Compression is the algorithm of pkg/compress backup payloads are compressed with
+	// before they are uploaded; empty uploads them as they are
+	Compression string `json:"compression"`
+
+	// Retention prunes the older backups of the job, see pkg/retention. Only methods
+	// with the retention capability honour it.
+	Retention retention.Policy `json:"retention" gorm:"type:jsonb"`
+}
+
+// Location returns the time zone the job's schedule is evaluated in, falling back
+// to the server's time zone if the stored name is unknown
+func (job *CronJobListingDB) Location() *time.Location {
+	loc, err := schedule.LoadLocation(job.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// ComputeNextRunAt evaluates the job's schedule in its time zone and returns when it is
+// next due. It returns nil for one-time jobs and for jobs without a valid schedule.
+//
+// The due time is moved to the job's slot: a fixed offset within the jitter window so
+// jobs sharing a schedule do not all start at once, and out of the global and the user's
+// blackout windows. settings may be nil.
+func (job *CronJobListingDB) ComputeNextRunAt(now time.Time, settings *UserSettings) *time.Time {
+	sched, err := schedule.Parse(job.Interval, job.On)
+	if err != nil {
+		return nil
+	}
+	next := sched.NextRun(job.LastRun, now.In(job.Location()))
+
+	spread := schedule.DefaultSpread()
+	if sched.Relative() {
+		// Runs every N hours already start whenever the previous run finished
+		spread.JitterWindow = 0
+	}
+	slot := spread.Slot(job.slotKey(), next, sched.Next(next).Sub(next), settings.Blackouts())
+	return &slot
+}
+
+// BlackoutRelease reports whether now falls into a blackout window for the job and, if
+// so, when the job may start instead. The scheduler uses it for jobs that became due
+// before a blackout began but were not started in time.
+func (job *CronJobListingDB) BlackoutRelease(now time.Time, settings *UserSettings) (time.Time, bool) {
+	return schedule.DefaultSpread().Blocked(job.slotKey(), now.In(job.Location()), settings.Blackouts())
+}
+
+func (job *CronJobListingDB) slotKey() string {
+	return strconv.FormatUint(uint64(job.ID), 10)
 }
 
 // TaskMemory represents the memory state of a task
@@ -90,6 +166,21 @@ type TaskMemory struct {
 	GmailSyncComplete    bool `json:"gmail_sync_complete"`
 	OutlookSyncComplete  bool `json:"outlook_sync_complete"`
 	DatabaseSyncComplete bool `json:"database_sync_complete"`
+
+	// DatabaseUpload is the progress of a database dump upload that was interrupted
+	DatabaseUpload *satellite.UploadCheckpoint `json:"database_upload,omitempty"`
+	// DatabaseUploadTaskID is the original task of the retry chain that saved
+	// DatabaseUpload. Other tasks dump the database again.
+	DatabaseUploadTaskID uint `json:"database_upload_task_id,omitempty"`
+}
+
+// Value implements the driver.Valuer interface
+func (t TaskMemory) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
 }
 
 // Scan implements the sql.Scanner interface
@@ -274,35 +365,57 @@ func (r *CronJobRepository) GetAllActiveCronJobsForUser(userID string) ([]LiveCr
 	return results, nil
 }
 
+// dependenciesMetSQL holds for a job cj whose active dependencies all succeeded after cj
+// last ran. Its parameter is JobStatusSuccess.
+const dependenciesMetSQL = `NOT EXISTS (
+			SELECT 1 FROM cron_job_listing_dbs dep
+			WHERE cj.depends_on @> to_jsonb(dep.id)
+			AND dep.active = true
+			AND dep.deleted_at is null
+			AND (dep.status != ? OR dep.last_run IS NULL
+				OR (cj.last_run IS NOT NULL AND dep.last_run <= cj.last_run))
+		)`
+
+// DependenciesMet reports whether the active dependencies of a job all succeeded after
+// the job last ran, as GetJobsToProcess requires before it runs the job
+func (r *CronJobRepository) DependenciesMet(jobID uint) (bool, error) {
+	var count int64
+	err := r.db.Raw(`SELECT count(*) FROM cron_job_listing_dbs cj WHERE cj.id = ? AND `+dependenciesMetSQL,
+		jobID, JobStatusSuccess).Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("error checking job dependencies: %v", err)
+	}
+	return count > 0, nil
+}
+
 // GetJobsToProcess retrieves jobs that are ready to be processed
 func (r *CronJobRepository) GetJobsToProcess() ([]CronJobListingDB, error) {
 	var res []CronJobListingDB
 	tx := r.db.Begin()
 
 	// The raw SQL query
+	// Jobs wait for their active dependencies to succeed after their own last run
 	sqlQuery := `
-		SELECT *
-		FROM cron_job_listing_dbs
-		WHERE active = true
-		AND (message is null or message != ?)
-		AND (last_run IS NULL OR DATE(last_run) != ?)
-		AND (interval = 'daily'
-				OR (interval = 'weekly' AND "on" = ?)
-			OR (interval = 'monthly' AND "on" = ?))
-		AND id not in (
+		SELECT cj.*
+		FROM cron_job_listing_dbs cj
+		WHERE cj.active = true
+		AND (cj.message is null or cj.message != ?)
+		AND cj.next_run_at IS NOT NULL
+		AND cj.next_run_at <= ?
+		AND cj.id not in (
 			SELECT DISTINCT cron_job_id FROM task_listing_dbs
-			WHERE status IN (?, ?)
+			WHERE status IN (?, ?, ?)
 		)
-		AND deleted_at is null
+		AND ` + dependenciesMetSQL + `
+		AND cj.deleted_at is null
+		ORDER BY cj.next_run_at
 		LIMIT 10
-		FOR UPDATE
+		FOR UPDATE OF cj SKIP LOCKED
 	`
 
 	// Execute the raw SQL query and store the result in the cronJobs slice
-	rawQuery := tx.Raw(sqlQuery, JobMessagePushToQueue, time.Now().Format("2006-01-02"),
-		time.Now().Weekday().String(),
-		fmt.Sprint(time.Now().Day()),
-		TaskStatusRunning, TaskStatusPushed)
+	rawQuery := tx.Raw(sqlQuery, JobMessagePushToQueue, time.Now(),
+		TaskStatusRunning, TaskStatusPushed, TaskStatusPaused, JobStatusSuccess)
 
 	scanResult := rawQuery.Scan(&res)
 	if scanResult.Error != nil {
@@ -328,6 +441,62 @@ func (r *CronJobRepository) GetJobsToProcess() ([]CronJobListingDB, error) {
 	return res, nil
 }
 
+// BackfillNextRunAt computes next_run_at for active recurring jobs that do not have one,
+// such as jobs created before schedules were stored or whose interval was just set
+func (r *CronJobRepository) BackfillNextRunAt() (int, error) {
+	var jobs []CronJobListingDB
+	if err := r.db.Where("active = ? AND next_run_at IS NULL AND interval != ? AND interval != ''", true, schedule.IntervalOneTime).
+		Find(&jobs).Error; err != nil {
+		return 0, fmt.Errorf("error getting jobs without next run: %v", err)
+	}
+
+	now := time.Now()
+	updated := 0
+	settings := make(map[string]*UserSettings)
+	for i := range jobs {
+		userID := jobs[i].UserID
+		if _, ok := settings[userID]; !ok {
+			settings[userID] = loadUserSettings(r.db, userID)
+		}
+		next := jobs[i].ComputeNextRunAt(now, settings[userID])
+		if next == nil {
+			continue
+		}
+		if err := r.db.Model(&CronJobListingDB{}).Where("id = ?", jobs[i].ID).Update("next_run_at", next).Error; err != nil {
+			return updated, fmt.Errorf("error updating next run for job %d: %v", jobs[i].ID, err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// RecomputeNextRunAtForUser recomputes next_run_at for the active recurring jobs of a
+// user, e.g. after the user's blackout windows changed
+func (r *CronJobRepository) RecomputeNextRunAtForUser(userID string) (int, error) {
+	var jobs []CronJobListingDB
+	if err := r.db.Where("user_id = ? AND active = ? AND interval != ? AND interval != ''", userID, true, schedule.IntervalOneTime).
+		Find(&jobs).Error; err != nil {
+		return 0, fmt.Errorf("error getting jobs for user: %v", err)
+	}
+
+	now := time.Now()
+	settings := loadUserSettings(r.db, userID)
+	updated := 0
+	for i := range jobs {
+		next := jobs[i].ComputeNextRunAt(now, settings)
+		if next == nil {
+			continue
+		}
+		if err := r.db.Model(&CronJobListingDB{}).Where("id = ?", jobs[i].ID).Update("next_run_at", next).Error; err != nil {
+			return updated, fmt.Errorf("error updating next run for job %d: %v", jobs[i].ID, err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
 // GetJobByIDForUser retrieves a specific cron job by ID for a user
 func (r *CronJobRepository) GetJobByIDForUser(userID string, jobID uint) (*CronJobListingDB, error) {
 	var res CronJobListingDB
@@ -367,16 +536,32 @@ func (r *CronJobRepository) GetAccessGrantByProjectID(projectID, method string)
 	return cronJob.StorxToken, nil
 }
 
+// GetJobsWithAccessGrantForUser returns the jobs of a user with one of methods that have
+// an access grant
+func (r *CronJobRepository) GetJobsWithAccessGrantForUser(userID string, methods []string) ([]CronJobListingDB, error) {
+	var cronJobs []CronJobListingDB
+	result := r.db.Where("user_id = ? AND method IN ? AND storx_token != ''", userID, methods).
+		Order("updated_at DESC").Find(&cronJobs)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get jobs of user %s: %w", userID, result.Error)
+	}
+
+	return cronJobs, nil
+}
+
 // CreateCronJobForUser creates a new cron job for a user
-func (r *CronJobRepository) CreateCronJobForUser(userID, name, method string, syncType string, inputData map[string]interface{}) (*CronJobListingDB, error) {
+func (r *CronJobRepository) CreateCronJobForUser(userID, name, method string, syncType string, inputData map[string]interface{}, timezone, compression string, policy retention.Policy) (*CronJobListingDB, error) {
 	data := CronJobListingDB{
-		UserID:    userID,
-		Name:      name,
-		Method:    method,
-		SyncType:  syncType,
-		InputData: database.NewDbJsonFromValue(inputData),
-		Status:    JobStatusCreated,
-		LastRun:   nil,
+		UserID:      userID,
+		Name:        name,
+		Method:      method,
+		SyncType:    syncType,
+		InputData:   database.NewDbJsonFromValue(inputData),
+		Status:      JobStatusCreated,
+		LastRun:     nil,
+		Timezone:    timezone,
+		Compression: compression,
+		Retention:   policy,
 	}
 
 	// Set interval and activation for one-time backups
@@ -404,7 +589,8 @@ func (r *CronJobRepository) DeleteCronJobByID(ID uint) error {
 	return nil
 }
 
-// UpdateCronJobByID updates a cron job by ID
+// UpdateCronJobByID updates a cron job by ID. New dependencies and hooks are validated
+// with the user's other jobs, see ErrInvalidJobChain.
 func (r *CronJobRepository) UpdateCronJobByID(ID uint, m map[string]interface{}) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
@@ -419,6 +605,21 @@ func (r *CronJobRepository) UpdateCronJobByID(ID uint, m map[string]interface{})
 		}
 	}()
 
+	// Changes to dependencies and hooks are checked for cycles with the user's other jobs
+	// while no other change to them can interleave
+	_, dependsOnChanged := m["depends_on"]
+	_, hooksChanged := m["hooks"]
+	chainChanged := dependsOnChanged || hooksChanged
+	if chainChanged {
+		var job CronJobListingDB
+		if err := tx.Select("user_id").First(&job, ID).Error; err != nil {
+			return fmt.Errorf("error getting cron job: %w", err)
+		}
+		if err := lockJobChains(gorm.NewDB(tx), job.UserID); err != nil {
+			return err
+		}
+	}
+
 	// Update the cron job
 	res := tx.Model(&CronJobListingDB{}).Where("id = ?", ID).Updates(m)
 	if res.Error != nil {
@@ -435,6 +636,12 @@ func (r *CronJobRepository) UpdateCronJobByID(ID uint, m map[string]interface{})
 		return fmt.Errorf("error getting updated cron job: %w", err)
 	}
 
+	if chainChanged {
+		if err := validateJobChain(gorm.NewDB(tx), updatedJob.UserID, ID, updatedJob.DependsOn, updatedJob.Hooks); err != nil {
+			return err
+		}
+	}
+
 	// Validate activation if the job is being activated
 	if active, exists := m["active"]; exists && active == true {
 		if err := r.validateJobForActivation(&updatedJob); err != nil {
@@ -442,6 +649,18 @@ func (r *CronJobRepository) UpdateCronJobByID(ID uint, m map[string]interface{})
 		}
 	}
 
+	// Recompute the next run whenever the schedule changes or the job is switched on
+	_, intervalChanged := m["interval"]
+	_, onChanged := m["on"]
+	_, activeChanged := m["active"]
+	_, timezoneChanged := m["timezone"]
+	if intervalChanged || onChanged || activeChanged || timezoneChanged {
+		if err := tx.Model(&CronJobListingDB{}).Where("id = ?", ID).
+			Update("next_run_at", updatedJob.ComputeNextRunAt(time.Now(), loadUserSettings(gorm.NewDB(tx), updatedJob.UserID))).Error; err != nil {
+			return fmt.Errorf("error updating next run: %w", err)
+		}
+	}
+
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("error committing transaction: %w", err)
@@ -452,7 +671,7 @@ func (r *CronJobRepository) UpdateCronJobByID(ID uint, m map[string]interface{})
 }
 
 // UpdateCronJobFieldsForCron updates a cron job by ID for cron processing.
-// For one-time sync jobs, only specific fields are allowed (status, message, message_status, last_run).
+// For one-time sync jobs, only specific fields are allowed (status, message, message_status, last_run, task_memory).
 func (r *CronJobRepository) UpdateCronJobFieldsForCron(ID uint, fields map[string]interface{}) error {
 	tx := r.db.Begin()
 	if tx.Error != nil {
@@ -481,6 +700,7 @@ func (r *CronJobRepository) UpdateCronJobFieldsForCron(ID uint, fields map[strin
 			"message":        true,
 			"message_status": true,
 			"last_run":       true,
+			"task_memory":    true,
 		}
 
 		filteredMap := make(map[string]interface{})
@@ -491,7 +711,7 @@ func (r *CronJobRepository) UpdateCronJobFieldsForCron(ID uint, fields map[strin
 		}
 
 		if len(filteredMap) == 0 {
-			return fmt.Errorf("cannot update one_time sync job: only status, message, message_status, and last_run fields are allowed")
+			return fmt.Errorf("cannot update one_time sync job: only status, message, message_status, last_run and task_memory fields are allowed")
 		}
 
 		updateMap = filteredMap
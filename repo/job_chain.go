@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"github.com/StorX2-0/Backup-Tools/pkg/safehttp"
+)
+
+// Hook types a job can run after a task finishes
+const (
+	// HookTypeWebhook posts the task result as JSON to URL
+	HookTypeWebhook = "webhook"
+	// HookTypeVerify checks that every object uploaded by the task exists in StorX with
+	// the size that was uploaded
+	HookTypeVerify = "verify"
+	// HookTypeRunJob queues a task for the job JobID
+	HookTypeRunJob = "run_job"
+)
+
+// JobHook is one step run after a task of a job finished
+type JobHook struct {
+	Type  string `json:"type"`
+	URL   string `json:"url,omitempty"`
+	JobID uint   `json:"job_id,omitempty"`
+}
+
+// JobHooks are the hooks of a job. OnSuccess hooks run in order after a successful task;
+// when a verify hook fails the remaining ones are skipped and OnFailure runs instead.
+// OnFailure hooks run once a failed task has no retries left.
+type JobHooks struct {
+	OnSuccess []JobHook `json:"on_success"`
+	OnFailure []JobHook `json:"on_failure"`
+}
+
+// Validate checks the type and target of every hook
+func (h JobHooks) Validate() error {
+	for _, hooks := range [][]JobHook{h.OnSuccess, h.OnFailure} {
+		for _, hook := range hooks {
+			switch hook.Type {
+			case HookTypeWebhook:
+				if err := safehttp.CheckURL(hook.URL); err != nil {
+					return fmt.Errorf("webhook hook: %w", err)
+				}
+			case HookTypeVerify:
+			case HookTypeRunJob:
+				if hook.JobID == 0 {
+					return fmt.Errorf("run_job hook needs a job_id")
+				}
+			default:
+				return fmt.Errorf("unknown hook type %q", hook.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// TriggeredJobs returns the jobs queued by run_job hooks
+func (h JobHooks) TriggeredJobs() []uint {
+	var ids []uint
+	for _, hooks := range [][]JobHook{h.OnSuccess, h.OnFailure} {
+		for _, hook := range hooks {
+			if hook.Type == HookTypeRunJob {
+				ids = append(ids, hook.JobID)
+			}
+		}
+	}
+	return ids
+}
+
+// Value implements the driver.Valuer interface
+func (h JobHooks) Value() (driver.Value, error) {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (h *JobHooks) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	case []uint8:
+		return json.Unmarshal(v, h)
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+}
+
+// JobIDs is a list of cron job IDs stored as a JSON array
+type JobIDs []uint
+
+// Value implements the driver.Valuer interface. An empty list is stored as [] rather
+// than null so it can be queried with the jsonb operators.
+func (ids JobIDs) Value() (driver.Value, error) {
+	if ids == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]uint(ids))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface
+func (ids *JobIDs) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), ids)
+	case []uint8:
+		return json.Unmarshal(v, ids)
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+}
+
+// ErrInvalidJobChain is returned when the dependencies or hooks of a job refer to jobs
+// the user does not have or form a cycle
+var ErrInvalidJobChain = errors.New("invalid job dependencies or hooks")
+
+// lockJobChains serializes changes to the chains of userID's jobs until the transaction
+// ends, so two changes cannot each pass the cycle check and form a cycle together
+func lockJobChains(tx *gorm.DB, userID string) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "job_chain:"+userID).Error; err != nil {
+		return fmt.Errorf("error locking job chains: %v", err)
+	}
+	return nil
+}
+
+// validateJobChain checks the dependencies and hooks a job of userID is about to get.
+// Every job they refer to must belong to the same user, and together with the chains of
+// the user's other jobs they must not form a cycle, which would leave the jobs waiting on
+// each other or triggering each other forever. Hold lockJobChains while calling it.
+func validateJobChain(tx *gorm.DB, userID string, jobID uint, dependsOn []uint, hooks JobHooks) error {
+	var jobs []CronJobListingDB
+	if err := tx.Select("id", "name", "depends_on", "hooks").Where("user_id = ?", userID).Find(&jobs).Error; err != nil {
+		return fmt.Errorf("error getting jobs for user: %v", err)
+	}
+
+	names := make(map[uint]string, len(jobs))
+	for _, job := range jobs {
+		names[job.ID] = job.Name
+	}
+	for _, id := range append(append([]uint{}, dependsOn...), hooks.TriggeredJobs()...) {
+		if id == jobID {
+			return fmt.Errorf("%w: job %d cannot depend on or trigger itself", ErrInvalidJobChain, jobID)
+		}
+		if _, ok := names[id]; !ok {
+			return fmt.Errorf("%w: job %d not found", ErrInvalidJobChain, id)
+		}
+	}
+
+	// An edge a -> b means b runs after a: a job runs after its dependencies, and a
+	// run_job hook runs its target after the job
+	edges := make(map[uint][]uint)
+	addChain := func(id uint, deps []uint, hooks JobHooks) {
+		for _, dep := range deps {
+			edges[dep] = append(edges[dep], id)
+		}
+		edges[id] = append(edges[id], hooks.TriggeredJobs()...)
+	}
+	for _, job := range jobs {
+		if job.ID != jobID {
+			addChain(job.ID, job.DependsOn, job.Hooks)
+		}
+	}
+	addChain(jobID, dependsOn, hooks)
+
+	if cycle := findCycle(edges); cycle != nil {
+		path := make([]string, len(cycle))
+		for i, id := range cycle {
+			path[i] = fmt.Sprintf("%d (%s)", id, names[id])
+		}
+		return fmt.Errorf("%w: jobs would depend on each other in a cycle: %s", ErrInvalidJobChain, strings.Join(path, " -> "))
+	}
+	return nil
+}
+
+// findCycle returns the nodes of a cycle in the graph, starting and ending with the same
+// node, or nil if the graph is acyclic
+func findCycle(edges map[uint][]uint) []uint {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[uint]int)
+	var stack []uint
+
+	var visit func(node uint) []uint
+	visit = func(node uint) []uint {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, next := range edges[node] {
+			switch state[next] {
+			case visiting:
+				for i, n := range stack {
+					if n == next {
+						return append(append([]uint{}, stack[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = done
+		return nil
+	}
+
+	// Visit in a fixed order so the reported cycle is stable
+	nodes := make([]uint, 0, len(edges))
+	for node := range edges {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			if cycle := visit(node); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobHooksValidateWebhookURL(t *testing.T) {
+	valid := JobHooks{OnSuccess: []JobHook{{Type: HookTypeWebhook, URL: "https://hooks.example.com/backup"}}}
+	assert.NoError(t, valid.Validate())
+
+	for _, url := range []string{
+		"file:///etc/passwd",
+		"http://localhost:8005/api",
+		"http://127.0.0.1:5432",
+		"http://169.254.169.254/latest/meta-data/iam/",
+		"http://[fd00:ec2::254]/",
+		"http://192.168.0.10/",
+	} {
+		hooks := JobHooks{OnFailure: []JobHook{{Type: HookTypeWebhook, URL: url}}}
+		assert.Error(t, hooks.Validate(), url)
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	assert.Nil(t, findCycle(map[uint][]uint{1: {2}, 2: {3}}))
+	assert.Equal(t, []uint{1, 2, 3, 1}, findCycle(map[uint][]uint{1: {2}, 2: {3}, 3: {1}}))
+}
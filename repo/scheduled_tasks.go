@@ -6,6 +6,7 @@ import (
 
 	"github.com/StorX2-0/Backup-Tools/pkg/database"
 	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ScheduledTasks struct {
@@ -23,6 +24,11 @@ type ScheduledTasks struct {
 	FailedCount  uint                                     `json:"failed_count"`
 	Errors       database.DbJson[[]string]                `json:"errors" gorm:"type:jsonb"`
 	HeartBeat    *time.Time                               `json:"heart_beat"`
+
+	// ClaimedBy is the ID of the instance processing the task
+	ClaimedBy string `json:"claimed_by"`
+	// LeaseExpiresAt is when the claim lapses if the owner stops sending heartbeats
+	LeaseExpiresAt *time.Time `json:"lease_expires_at" gorm:"index"`
 }
 
 type LiveScheduledTasks struct {
@@ -101,13 +107,42 @@ func NewScheduledTasksRepository(db *gorm.DB) *ScheduledTasksRepository {
 	return &ScheduledTasksRepository{db: db}
 }
 
-// GetNextScheduledTask gets the next scheduled task to process
-func (r *ScheduledTasksRepository) GetNextScheduledTask() (*ScheduledTasks, error) {
+// ClaimNextScheduledTask atomically claims the next created scheduled task for owner and
+// marks it as running. Rows locked by another instance are skipped, so concurrent replicas
+// never pick the same task.
+func (r *ScheduledTasksRepository) ClaimNextScheduledTask(owner string) (*ScheduledTasks, error) {
 	var task ScheduledTasks
-	err := r.db.Where("status = ?", "created").First(&task).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", "created").Order("id").First(&task).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		leaseExpiresAt := now.Add(TaskLeaseDuration)
+		task.Status = "running"
+		task.StartTime = &now
+		task.HeartBeat = &now
+		task.ClaimedBy = owner
+		task.LeaseExpiresAt = &leaseExpiresAt
+		return tx.Save(&task).Error
+	})
 	return &task, err
 }
 
+// GetTasksWithAccessGrantForUser returns the scheduled tasks of a user with one of methods
+// that have an access grant
+func (r *ScheduledTasksRepository) GetTasksWithAccessGrantForUser(userID string, methods []string) ([]ScheduledTasks, error) {
+	var tasks []ScheduledTasks
+	result := r.db.Where("user_id = ? AND method IN ? AND storx_token != ''", userID, methods).
+		Order("updated_at DESC").Find(&tasks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get scheduled tasks of user %s: %w", userID, result.Error)
+	}
+
+	return tasks, nil
+}
+
 // GetScheduledTaskByID gets a scheduled task by ID
 func (r *ScheduledTasksRepository) GetScheduledTaskByID(id uint) (*ScheduledTasks, error) {
 	var task ScheduledTasks
@@ -115,11 +150,113 @@ func (r *ScheduledTasksRepository) GetScheduledTaskByID(id uint) (*ScheduledTask
 	return &task, err
 }
 
-// UpdateHeartBeatForScheduledTask updates the heartbeat for a scheduled task
-func (r *ScheduledTasksRepository) UpdateHeartBeatForScheduledTask(id uint) error {
+// UpdateHeartBeatForScheduledTask updates the heartbeat for a scheduled task claimed by
+// owner and renews its lease. It returns ErrTaskClaimLost if owner no longer holds the claim.
+func (r *ScheduledTasksRepository) UpdateHeartBeatForScheduledTask(id uint, owner string) error {
 	now := time.Now()
-	err := r.db.Model(&ScheduledTasks{}).Where("id = ?", id).Update("heart_beat", &now).Error
-	return err
+	db := r.db.Model(&ScheduledTasks{}).Where("id = ? AND claimed_by = ?", id, owner).Updates(map[string]interface{}{
+		"heart_beat":       &now,
+		"lease_expires_at": now.Add(TaskLeaseDuration),
+	})
+	if db.Error != nil {
+		return fmt.Errorf("error updating heartbeat for scheduled task: %v", db.Error)
+	}
+	if db.RowsAffected == 0 {
+		return ErrTaskClaimLost
+	}
+	return nil
+}
+
+// Scheduled task statuses set through the API
+const (
+	ScheduledTaskStatusCreated   = "created"
+	ScheduledTaskStatusRunning   = "running"
+	ScheduledTaskStatusPaused    = "paused"
+	ScheduledTaskStatusCancelled = "cancelled"
+)
+
+// TransitionScheduledTaskStatus moves a scheduled task that is in one of the from statuses
+// to status to. It returns ErrTaskStatusConflict if the task is in any other status. A
+// running task notices the change at its next heartbeat.
+func (r *ScheduledTasksRepository) TransitionScheduledTaskStatus(id uint, from []string, to string) error {
+	updates := map[string]interface{}{
+		"status": to,
+	}
+	if to == ScheduledTaskStatusCreated {
+		// Back in the queue: release the previous claim
+		updates["claimed_by"] = ""
+		updates["lease_expires_at"] = nil
+	}
+
+	db := r.db.Model(&ScheduledTasks{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if db.Error != nil {
+		return fmt.Errorf("error updating scheduled task status: %v", db.Error)
+	}
+	if db.RowsAffected == 0 {
+		return ErrTaskStatusConflict
+	}
+	return nil
+}
+
+// SaveScheduledTaskProgress stores the memory and execution time of a task that was
+// paused or cancelled while running, without touching its status. It returns
+// ErrTaskClaimLost if the task is no longer claimed by task.ClaimedBy.
+func (r *ScheduledTasksRepository) SaveScheduledTaskProgress(task *ScheduledTasks) error {
+	return r.updateClaimedScheduledTask(task.ID, task.ClaimedBy, map[string]interface{}{
+		"memory":           task.Memory,
+		"execution":        task.Execution,
+		"lease_expires_at": nil,
+	})
+}
+
+// SaveScheduledTaskMemory stores the memory of a running task claimed by owner, so the
+// task resumes from it if it is restarted
+func (r *ScheduledTasksRepository) SaveScheduledTaskMemory(id uint, owner string, memory *database.DbJson[map[string][]string]) error {
+	return r.updateClaimedScheduledTask(id, owner, map[string]interface{}{
+		"memory": memory,
+	})
+}
+
+// FinishScheduledTask stores the final status, counts, errors and memory of a task and
+// releases its lease. It returns ErrTaskClaimLost if the task is no longer claimed by
+// task.ClaimedBy.
+func (r *ScheduledTasksRepository) FinishScheduledTask(task *ScheduledTasks) error {
+	return r.updateClaimedScheduledTask(task.ID, task.ClaimedBy, map[string]interface{}{
+		"status":           task.Status,
+		"errors":           task.Errors,
+		"memory":           task.Memory,
+		"execution":        task.Execution,
+		"success_count":    task.SuccessCount,
+		"failed_count":     task.FailedCount,
+		"lease_expires_at": nil,
+	})
+}
+
+func (r *ScheduledTasksRepository) updateClaimedScheduledTask(id uint, owner string, updates map[string]interface{}) error {
+	db := r.db.Model(&ScheduledTasks{}).Where("id = ? AND claimed_by = ?", id, owner).Updates(updates)
+	if db.Error != nil {
+		return fmt.Errorf("error updating scheduled task: %v", db.Error)
+	}
+	if db.RowsAffected == 0 {
+		return ErrTaskClaimLost
+	}
+	return nil
+}
+
+// ReleaseClaimedScheduledTasks puts the running scheduled tasks claimed by owner back in
+// the queue. Their memory keeps the last saved progress.
+func (r *ScheduledTasksRepository) ReleaseClaimedScheduledTasks(owner string) (int64, error) {
+	db := r.db.Model(&ScheduledTasks{}).
+		Where("status = ? AND claimed_by = ?", ScheduledTaskStatusRunning, owner).
+		Updates(map[string]interface{}{
+			"status":           ScheduledTaskStatusCreated,
+			"claimed_by":       "",
+			"lease_expires_at": nil,
+		})
+	if db.Error != nil {
+		return 0, fmt.Errorf("error releasing claimed scheduled tasks: %v", db.Error)
+	}
+	return db.RowsAffected, nil
 }
 
 // GetAllRunningScheduledTasksForUser retrieves all running scheduled tasks for a specific user
@@ -139,20 +276,26 @@ func (r *ScheduledTasksRepository) GetAllRunningScheduledTasksForUser(userID str
 
 // MissedHeartbeatForScheduledTask checks for scheduled tasks with missed heartbeats
 func (r *ScheduledTasksRepository) MissedHeartbeatForScheduledTask() error {
-	// Find scheduled tasks that are running but haven't updated heartbeat in 10 minutes
+	// Find scheduled tasks that are running but whose lease has lapsed. Rows claimed
+	// before leases existed fall back to the heartbeat age.
 	var tasks []ScheduledTasks
-	err := r.db.Where("status = ? AND (heart_beat < ? OR heart_beat IS NULL)",
-		"running", time.Now().Add(-10*time.Minute)).Find(&tasks).Error
+	now := time.Now()
+	err := r.db.Where("status = ? AND (lease_expires_at < ? OR (lease_expires_at IS NULL AND (heart_beat < ? OR heart_beat IS NULL)))",
+		"running", now, now.Add(-TaskLeaseDuration)).Find(&tasks).Error
 	if err != nil {
 		return fmt.Errorf("error getting scheduled tasks with missed heartbeat: %v", err)
 	}
 
 	for _, task := range tasks {
-		// Update task status to failed
-		err := r.db.Model(&ScheduledTasks{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
-			"status": "failed",
-			"errors": `["Process got stuck because of server restart or crash. Marked as failed"]`,
-		}).Error
+		// Update task status to failed, unless the owner renewed the lease in the meantime
+		err := r.db.Model(&ScheduledTasks{}).
+			Where("id = ? AND status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)", task.ID, "running", now).
+			Updates(map[string]interface{}{
+				"status":           "failed",
+				"claimed_by":       "",
+				"lease_expires_at": nil,
+				"errors":           `["Process got stuck because of server restart or crash. Marked as failed"]`,
+			}).Error
 		if err != nil {
 			return fmt.Errorf("error updating scheduled task %d: %v", task.ID, err)
 		}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+)
+
+// Snapshot is the state of a backup as of the end of a task run. The items in the
+// backup's part of the bucket at that time are listed in a manifest object stored in the
+// bucket.
+type Snapshot struct {
+	gorm.GormModel
+
+	UserID string `json:"user_id" gorm:"not null;index:idx_snapshots_user_bucket"`
+	Method string `json:"method"`
+	// TaskType is TaskEventTypeAutoSync or TaskEventTypeScheduled
+	TaskType string `json:"task_type"`
+	TaskID   uint   `json:"task_id"`
+	// JobID is the cron job of auto-sync runs
+	JobID uint `json:"job_id,omitempty" gorm:"index"`
+
+	BucketName string `json:"bucket_name" gorm:"not null;index:idx_snapshots_user_bucket"`
+	// Prefix is the part of the bucket the run backs up, e.g. "<email>/"
+	Prefix      string `json:"prefix"`
+	ManifestKey string `json:"manifest_key"`
+	ItemCount   int    `json:"item_count"`
+	TotalBytes  int64  `json:"total_bytes"`
+}
+
+// SnapshotFilter narrows ListSnapshotsForUser. Zero fields do not filter.
+type SnapshotFilter struct {
+	BucketName string
+	Prefix     string
+	JobID      uint
+	// Before only returns snapshots taken before the time
+	Before *time.Time
+}
+
+// SnapshotRepository handles all database operations for snapshots
+type SnapshotRepository struct {
+	db *gorm.DB
+}
+
+// NewSnapshotRepository creates a new snapshot repository
+func NewSnapshotRepository(db *gorm.DB) *SnapshotRepository {
+	return &SnapshotRepository{db: db}
+}
+
+// CreateSnapshot registers a snapshot whose manifest was written
+func (r *SnapshotRepository) CreateSnapshot(snapshot *Snapshot) error {
+	if err := r.db.Create(snapshot).Error; err != nil {
+		return fmt.Errorf("error creating snapshot: %v", err)
+	}
+	return nil
+}
+
+// ListSnapshotsForUser returns the latest snapshots of a user matching filter, newest
+// first
+func (r *SnapshotRepository) ListSnapshotsForUser(userID string, filter SnapshotFilter, limit int) ([]Snapshot, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if filter.BucketName != "" {
+		query = query.Where("bucket_name = ?", filter.BucketName)
+	}
+	if filter.Prefix != "" {
+		query = query.Where("prefix = ?", filter.Prefix)
+	}
+	if filter.JobID != 0 {
+		query = query.Where("job_id = ?", filter.JobID)
+	}
+	if filter.Before != nil {
+		query = query.Where("created_at < ?", *filter.Before)
+	}
+
+	var snapshots []Snapshot
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("error listing snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshotForUser returns a snapshot of a user
+func (r *SnapshotRepository) GetSnapshotForUser(userID string, snapshotID uint) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := r.db.Where("id = ? AND user_id = ?", snapshotID, userID).First(&snapshot).Error; err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetSnapshotsBeyond returns the snapshots of the same user, bucket, prefix and job as
+// snapshot that are older than the newest keep of them, newest first
+func (r *SnapshotRepository) GetSnapshotsBeyond(snapshot *Snapshot, keep int) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := r.db.Where("user_id = ? AND bucket_name = ? AND prefix = ? AND job_id = ?",
+		snapshot.UserID, snapshot.BucketName, snapshot.Prefix, snapshot.JobID).
+		Order("created_at DESC, id DESC").Offset(keep).Find(&snapshots).Error
+	if err != nil {
+		return nil, fmt.Errorf("error listing snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshots removes snapshots whose manifests were deleted
+func (r *SnapshotRepository) DeleteSnapshots(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Unscoped().Delete(&Snapshot{}, ids).Error; err != nil {
+		return fmt.Errorf("error deleting snapshots: %v", err)
+	}
+	return nil
+}
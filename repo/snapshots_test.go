@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSnapshotsBeyond(t *testing.T) {
+	db := testDB(t)
+	snapshots := NewSnapshotRepository(db)
+
+	userID := "snapshot-user-" + time.Now().Format(time.RFC3339Nano)
+	t.Cleanup(func() { db.Unscoped().Where("user_id = ?", userID).Delete(&Snapshot{}) })
+
+	var taken []Snapshot
+	for i := 0; i < 4; i++ {
+		snapshot := Snapshot{
+			UserID:      userID,
+			BucketName:  "gmail",
+			Prefix:      "user@example.com/",
+			JobID:       7,
+			ManifestKey: fmt.Sprintf(".snapshots/autosync-%d.json", i),
+		}
+		snapshot.CreatedAt = time.Now().Add(time.Duration(i-4) * time.Hour)
+		require.NoError(t, snapshots.CreateSnapshot(&snapshot))
+		taken = append(taken, snapshot)
+	}
+	// Snapshots of another job and another account are not counted
+	for _, other := range []Snapshot{
+		{UserID: userID, BucketName: "gmail", Prefix: "user@example.com/", JobID: 8},
+		{UserID: userID, BucketName: "gmail", Prefix: "other@example.com/", JobID: 7},
+	} {
+		require.NoError(t, snapshots.CreateSnapshot(&other))
+	}
+
+	latest := taken[len(taken)-1]
+	expired, err := snapshots.GetSnapshotsBeyond(&latest, 2)
+	require.NoError(t, err)
+	require.Len(t, expired, 2)
+	assert.Equal(t, taken[1].ID, expired[0].ID)
+	assert.Equal(t, taken[0].ID, expired[1].ID)
+
+	require.NoError(t, snapshots.DeleteSnapshots([]uint{expired[0].ID, expired[1].ID}))
+	left, err := snapshots.ListSnapshotsForUser(userID, SnapshotFilter{JobID: 7, Prefix: "user@example.com/"}, 10)
+	require.NoError(t, err)
+	assert.Len(t, left, 2)
+
+	expired, err = snapshots.GetSnapshotsBeyond(&latest, 2)
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+}
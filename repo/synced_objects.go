@@ -5,18 +5,28 @@ import (
 	"time"
 
 	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SyncedObject represents a synced object in the database
 type SyncedObject struct {
 	gorm.GormModel
 
-	UserID     string    `json:"user_id" gorm:"not null"`
-	BucketName string    `json:"bucket_name" gorm:"not null"`
-	ObjectKey  string    `json:"object_key" gorm:"not null;type:varchar(1000)"`
+	// An object has one live row; deleted rows do not count
+	UserID     string    `json:"user_id" gorm:"not null;uniqueIndex:idx_synced_objects_user_bucket_key,where:deleted_at IS NULL"`
+	BucketName string    `json:"bucket_name" gorm:"not null;uniqueIndex:idx_synced_objects_user_bucket_key"`
+	ObjectKey  string    `json:"object_key" gorm:"not null;type:varchar(1000);uniqueIndex:idx_synced_objects_user_bucket_key"`
 	SyncedAt   time.Time `json:"synced_at" gorm:"default:now()"`
 	Source     string    `json:"source" gorm:"not null;type:varchar(1000)"`
 	Type       string    `json:"type" gorm:"not null;type:varchar(1000)"`
+	// Compression is the algorithm the object is compressed with, empty if it is not
+	Compression string `json:"compression"`
+}
+
+// syncedObjectKey is the conflict target of the unique index on the live rows
+var syncedObjectKey = clause.OnConflict{
+	Columns:     []clause.Column{{Name: "user_id"}, {Name: "bucket_name"}, {Name: "object_key"}},
+	TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
 }
 
 // SyncedObjectRepository handles all database operations for synced objects
@@ -30,19 +40,22 @@ func NewSyncedObjectRepository(db *gorm.DB) *SyncedObjectRepository {
 }
 
 // CreateSyncedObject creates or updates a synced object in the database
-func (r *SyncedObjectRepository) CreateSyncedObject(userID, bucketName, objectKey, source, objectType string) error {
+func (r *SyncedObjectRepository) CreateSyncedObject(userID, bucketName, objectKey, source, objectType, compression string) error {
 	syncedObject := SyncedObject{
-		UserID:     userID,
-		BucketName: bucketName,
-		ObjectKey:  objectKey,
-		Source:     source,
-		Type:       objectType,
-		SyncedAt:   time.Now(),
+		UserID:      userID,
+		BucketName:  bucketName,
+		ObjectKey:   objectKey,
+		Source:      source,
+		Type:        objectType,
+		SyncedAt:    time.Now(),
+		Compression: compression,
 	}
 
-	result := r.db.Where("user_id = ? AND bucket_name = ? AND object_key = ? AND deleted_at IS NULL",
-		userID, bucketName, objectKey).
-		FirstOrCreate(&syncedObject)
+	// An object uploaded again replaces the earlier one, which may have been compressed
+	// differently
+	onConflict := syncedObjectKey
+	onConflict.DoUpdates = clause.AssignmentColumns([]string{"compression", "updated_at"})
+	result := r.db.Clauses(onConflict).Create(&syncedObject)
 
 	if result.Error != nil {
 		return fmt.Errorf("error creating synced object: %v", result.Error)
@@ -98,3 +111,78 @@ func (r *SyncedObjectRepository) GetSyncedObjectsByUserAndBucket(userID, bucketN
 
 	return syncedObjects, nil
 }
+
+// SyncedBucket is a bucket of a user that has objects in the catalog
+type SyncedBucket struct {
+	UserID     string
+	BucketName string
+}
+
+// GetSyncedBuckets returns every bucket of every user that has objects in the catalog.
+// userID is an optional filter.
+func (r *SyncedObjectRepository) GetSyncedBuckets(userID string) ([]SyncedBucket, error) {
+	query := r.db.Model(&SyncedObject{}).Distinct("user_id", "bucket_name")
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var buckets []SyncedBucket
+	if err := query.Order("user_id, bucket_name").Scan(&buckets).Error; err != nil {
+		return nil, fmt.Errorf("error getting synced buckets: %v", err)
+	}
+	return buckets, nil
+}
+
+// CreateSyncedObjects adds objects to the catalog in batches. Objects that already have a
+// row, for example because an upload recorded them meanwhile, are left as they are.
+func (r *SyncedObjectRepository) CreateSyncedObjects(objects []SyncedObject) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	onConflict := syncedObjectKey
+	onConflict.DoNothing = true
+	if err := r.db.Clauses(onConflict).CreateInBatches(objects, 100).Error; err != nil {
+		return fmt.Errorf("error creating synced objects: %v", err)
+	}
+	return nil
+}
+
+// DeleteSyncedObjectsByID removes catalog rows by their IDs
+func (r *SyncedObjectRepository) DeleteSyncedObjectsByID(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Delete(&SyncedObject{}, ids).Error; err != nil {
+		return fmt.Errorf("error deleting synced objects: %v", err)
+	}
+	return nil
+}
+
+// DeleteSyncedObjectsByKeys removes the catalog rows of objects of a user
+func (r *SyncedObjectRepository) DeleteSyncedObjectsByKeys(userID, bucketName string, objectKeys []string) error {
+	if len(objectKeys) == 0 {
+		return nil
+	}
+	result := r.db.Where("user_id = ? AND bucket_name = ? AND object_key IN ?", userID, bucketName, objectKeys).
+		Delete(&SyncedObject{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting synced objects: %v", result.Error)
+	}
+	return nil
+}
+
+// RemoveDuplicateSyncedObjects removes all but the newest live row of every object, so
+// the unique index on the live rows can be created on catalogs recorded without it
+func (r *SyncedObjectRepository) RemoveDuplicateSyncedObjects() error {
+	if !r.db.Migrator().HasTable(&SyncedObject{}) {
+		return nil
+	}
+	err := r.db.Exec(`DELETE FROM synced_objects older USING synced_objects newer
+		WHERE older.deleted_at IS NULL AND newer.deleted_at IS NULL
+		AND older.user_id = newer.user_id AND older.bucket_name = newer.bucket_name
+		AND older.object_key = newer.object_key AND older.id < newer.id`).Error
+	if err != nil {
+		return fmt.Errorf("error removing duplicate synced objects: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncedObjectsHaveOneRowPerObject(t *testing.T) {
+	db := testDB(t)
+	synced := NewSyncedObjectRepository(db)
+
+	userID := "synced-user-" + time.Now().Format(time.RFC3339Nano)
+	t.Cleanup(func() { db.Unscoped().Where("user_id = ?", userID).Delete(&SyncedObject{}) })
+
+	require.NoError(t, synced.CreateSyncedObject(userID, "gmail", "user@example.com/1.eml", "google", "gmail", ""))
+	require.NoError(t, synced.CreateSyncedObject(userID, "gmail", "user@example.com/1.eml", "google", "gmail", "zstd"))
+
+	// A repair racing the upload adds the same object again
+	require.NoError(t, synced.CreateSyncedObjects([]SyncedObject{
+		{UserID: userID, BucketName: "gmail", ObjectKey: "user@example.com/1.eml", Source: "google", Type: "gmail"},
+		{UserID: userID, BucketName: "gmail", ObjectKey: "user@example.com/2.eml", Source: "google", Type: "gmail"},
+	}))
+
+	objects, err := synced.GetSyncedObjectsByUserAndBucket(userID, "gmail", "", "")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	for _, object := range objects {
+		if object.ObjectKey == "user@example.com/1.eml" {
+			assert.Equal(t, "zstd", object.Compression, "an upload replaces the compression of the row")
+		}
+	}
+
+	// A deleted row does not keep the object from being recorded again
+	require.NoError(t, synced.DeleteSyncedObjectsByKeys(userID, "gmail", []string{"user@example.com/2.eml"}))
+	require.NoError(t, synced.CreateSyncedObject(userID, "gmail", "user@example.com/2.eml", "google", "gmail", ""))
+}
@@ -0,0 +1,92 @@
+package repo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+)
+
+// Task types a task event belongs to
+const (
+	TaskEventTypeAutoSync  = "auto_sync"
+	TaskEventTypeScheduled = "scheduled"
+)
+
+// Task event levels
+const (
+	TaskEventLevelInfo  = "info"
+	TaskEventLevelWarn  = "warn"
+	TaskEventLevelError = "error"
+)
+
+// TaskEvent is one entry in the timeline of an auto-sync or scheduled task. Events are
+// only ever appended.
+type TaskEvent struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time `json:"created_at" gorm:"not null;index"`
+	TaskType      string    `json:"task_type" gorm:"not null;type:varchar(20);index:idx_task_events_task"`
+	TaskID        uint      `json:"task_id" gorm:"not null;index:idx_task_events_task"`
+	Level         string    `json:"level" gorm:"not null;type:varchar(10)"`
+	Message       string    `json:"message" gorm:"type:text"`
+	ItemID        string    `json:"item_id,omitempty" gorm:"type:varchar(255)"`
+	ObjectKey     string    `json:"object_key,omitempty" gorm:"type:text"`
+	Bytes         int64     `json:"bytes,omitempty"`
+	ErrorCategory string    `json:"error_category,omitempty" gorm:"type:varchar(50)"`
+}
+
+// TaskEventFilter narrows down the events returned for a task
+type TaskEventFilter struct {
+	Level  string
+	Limit  int
+	Offset int
+}
+
+type TaskEventRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskEventRepository(db *gorm.DB) *TaskEventRepository {
+	return &TaskEventRepository{db: db}
+}
+
+// AppendTaskEvents stores a batch of events
+func (r *TaskEventRepository) AppendTaskEvents(events []TaskEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := r.db.CreateInBatches(events, 100).Error; err != nil {
+		return fmt.Errorf("error appending task events: %v", err)
+	}
+	return nil
+}
+
+// ListTaskEvents returns the events of a task in the order they happened, together with
+// the total number of events matching the filter
+func (r *TaskEventRepository) ListTaskEvents(taskType string, taskID uint, filter TaskEventFilter) ([]TaskEvent, int64, error) {
+	query := r.db.Model(&TaskEvent{}).Where("task_type = ? AND task_id = ?", taskType, taskID)
+	if filter.Level != "" {
+		query = query.Where("level = ?", filter.Level)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting task events: %v", err)
+	}
+
+	var events []TaskEvent
+	if err := query.Order("created_at ASC, id ASC").Limit(filter.Limit).Offset(filter.Offset).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("error listing task events: %v", err)
+	}
+	return events, total, nil
+}
+
+// ListSyncedEvents returns the events of items a task uploaded
+func (r *TaskEventRepository) ListSyncedEvents(taskType string, taskID uint) ([]TaskEvent, error) {
+	var events []TaskEvent
+	if err := r.db.Where("task_type = ? AND task_id = ? AND message = ? AND object_key != ''", taskType, taskID, "synced").
+		Order("id ASC").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("error listing synced task events: %v", err)
+	}
+	return events, nil
+}
@@ -2,12 +2,13 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/StorX2-0/Backup-Tools/pkg/cluster"
 	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
-	gormdb "gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -29,14 +30,48 @@ type TaskListingDB struct {
 	// Execution time in milliseconds
 	Execution uint64 `json:"execution"`
 
-	// RetryCount will be the number of times the task has been retried
-	// Maximum 3 retries are allowed
+	// RetryCount will be the number of retries that preceded this attempt
 	RetryCount uint `json:"retry_count"`
 
+	// RetryOfTaskID links a retry to the task of the first attempt. It is nil for first attempts.
+	RetryOfTaskID *uint `json:"retry_of_task_id" gorm:"index"`
+
+	// Attempt is the attempt number, starting at 1
+	Attempt uint `json:"attempt"`
+
+	// NotBefore holds a retry back until its backoff delay has passed
+	NotBefore *time.Time `json:"not_before" gorm:"index"`
+
 	// LastHeartBeat will be the time when the task was last heartbeat
 	LastHeartBeat *time.Time `json:"last_heart_beat"`
+
+	// ClaimedBy is the ID of the instance processing the task
+	ClaimedBy string `json:"claimed_by"`
+
+	// LeaseExpiresAt is when the claim lapses if the owner stops sending heartbeats
+	LeaseExpiresAt *time.Time `json:"lease_expires_at" gorm:"index"`
+}
+
+// AttemptNumber returns the attempt number of the task. Tasks created before attempts
+// were recorded count as first attempts.
+func (t *TaskListingDB) AttemptNumber() int {
+	if t.Attempt == 0 {
+		return 1
+	}
+	return int(t.Attempt)
+}
+
+// OriginalTaskID returns the ID of the first attempt in the task's retry chain
+func (t *TaskListingDB) OriginalTaskID() uint {
+	if t.RetryOfTaskID != nil {
+		return *t.RetryOfTaskID
+	}
+	return t.ID
 }
 
+// TaskLeaseDuration is how long a claim on a task stays valid without a heartbeat
+const TaskLeaseDuration = 10 * time.Minute
+
 // TaskRepository handles all database operations for tasks
 type TaskRepository struct {
 	db *gorm.DB
@@ -47,18 +82,31 @@ func NewTaskRepository(db *gorm.DB) *TaskRepository {
 	return &TaskRepository{db: db}
 }
 
-// UpdateHeartBeatForTask updates the heartbeat for a specific task
-func (r *TaskRepository) UpdateHeartBeatForTask(ID uint) error {
-	db := r.db.Model(&TaskListingDB{}).Where("id = ?", ID).Update("last_heart_beat", time.Now())
-	if db != nil && db.Error != nil {
+// ErrTaskClaimLost is returned when a task is no longer claimed by the instance that
+// tries to update it, e.g. because its lease lapsed and it was marked as failed
+var ErrTaskClaimLost = errors.New("task is no longer claimed by this instance")
+
+// UpdateHeartBeatForTask updates the heartbeat for a task claimed by owner and renews its
+// lease. It returns ErrTaskClaimLost if owner no longer holds the claim.
+func (r *TaskRepository) UpdateHeartBeatForTask(ID uint, owner string) error {
+	now := time.Now()
+	db := r.db.Model(&TaskListingDB{}).Where("id = ? AND claimed_by = ?", ID, owner).Updates(map[string]interface{}{
+		"last_heart_beat":  now,
+		"lease_expires_at": now.Add(TaskLeaseDuration),
+	})
+	if db.Error != nil {
 		return fmt.Errorf("error updating heartbeat for task: %v", db.Error)
 	}
+	if db.RowsAffected == 0 {
+		return ErrTaskClaimLost
+	}
 
 	return nil
 }
 
-// MissedHeartbeatForTask updates the heartbeat for tasks that have missed their heartbeat
-func (r *TaskRepository) MissedHeartbeatForTask() error {
+// MissedHeartbeatForTask marks tasks that have missed their heartbeat as failed and
+// returns them so the caller can schedule retries
+func (r *TaskRepository) MissedHeartbeatForTask() ([]TaskListingDB, error) {
 	// start a transaction, select all tasks with lock where last_heart_beat is more than 1 minute ago
 	// update status to failed and message to "missed heartbeat"
 	// and for job set message to process got stuck because of some reason
@@ -66,34 +114,43 @@ func (r *TaskRepository) MissedHeartbeatForTask() error {
 	tx := r.db.Begin()
 	logger.Info(context.Background(), "Starting transaction for missed heartbeat check")
 
+	// A task is stale once its lease has lapsed. Rows claimed before leases existed
+	// fall back to the heartbeat age.
 	var tasks []TaskListingDB
-	db := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("status = ? AND (last_heart_beat < ? OR last_heart_beat is null)", "running", time.Now().Add(-10*time.Minute).Format("2006-01-02 15:04:05-0700")).Find(&tasks)
+	now := time.Now()
+	db := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND (lease_expires_at < ? OR (lease_expires_at IS NULL AND (last_heart_beat < ? OR last_heart_beat is null)))",
+			TaskStatusRunning, now, now.Add(-TaskLeaseDuration)).Find(&tasks)
 	if db.Error != nil {
 		tx.Rollback()
-		return fmt.Errorf("error getting tasks with missed heartbeat: %v", db.Error)
+		return nil, fmt.Errorf("error getting tasks with missed heartbeat: %v", db.Error)
 	}
 
-	for _, task := range tasks {
+	for i := range tasks {
+		task := &tasks[i]
 		logger.Info(context.Background(), "Updating task", logger.Int("task_id", int(task.ID)), logger.String("with missed heartbeat", "with missed heartbeat"))
 
 		task.Status = TaskStatusFailed
 		task.Message = "Process got stuck because of some reason. Marked as failed"
+		task.LeaseExpiresAt = nil
+		// Drop the claim so late writes from the previous owner are rejected
+		task.ClaimedBy = ""
 
-		task.Execution = uint64(time.Since(*task.StartTime).Seconds())
-		task.RetryCount++
+		if task.StartTime != nil {
+			task.Execution = uint64(time.Since(*task.StartTime).Seconds())
+		}
 
-		db = tx.Save(&task)
+		db = tx.Save(task)
 		if db != nil && db.Error != nil {
 			tx.Rollback()
-			return fmt.Errorf("error updating task: %v", db.Error)
+			return nil, fmt.Errorf("error updating task: %v", db.Error)
 		}
 
 		var job CronJobListingDB
 		db = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", task.CronJobID).First(&job)
 		if db.Error != nil {
 			tx.Rollback()
-			return fmt.Errorf("error getting job: %v", db.Error)
+			return nil, fmt.Errorf("error getting job: %v", db.Error)
 		}
 
 		job.Message = "Process got stuck because of some reason. Marked as failed"
@@ -103,29 +160,40 @@ func (r *TaskRepository) MissedHeartbeatForTask() error {
 		db = tx.Save(&job)
 		if db != nil && db.Error != nil {
 			tx.Rollback()
-			return fmt.Errorf("error updating job: %v", db.Error)
+			return nil, fmt.Errorf("error updating job: %v", db.Error)
 		}
 	}
 
 	err := tx.Commit()
 	if err != nil && err.Error != nil {
-		return fmt.Errorf("error committing transaction: %v", err.Error)
+		return nil, fmt.Errorf("error committing transaction: %v", err.Error)
 	}
 
-	return nil
+	return tasks, nil
 }
 
 // GetPushedTask retrieves a pushed task and updates its status to running
 func (r *TaskRepository) GetPushedTask() (*TaskListingDB, error) {
+	return r.ClaimPushedTask(cluster.InstanceID(), nil)
+}
+
+// ClaimPushedTask atomically claims a pushed task for owner and updates its status to running.
+// Tasks whose job uses one of excludeMethods are skipped; the worker pool uses this to leave
+// saturated methods in the queue. Rows locked by another instance are skipped rather than
+// waited on, so concurrent replicas never pick the same task.
+func (r *TaskRepository) ClaimPushedTask(owner string, excludeMethods []string) (*TaskListingDB, error) {
 	var res TaskListingDB
 	tx := r.db.Begin()
-	// lock table tasks for update and select and return the first row with status pushed
-	// or status 'failed' and retry count less than 3
-	db := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+	// lock the first unclaimed task row with status pushed whose retry delay (if any) has passed.
+	// Failed tasks are never picked again; a retry is a new pushed task.
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "task_listing_dbs"}, Options: "SKIP LOCKED"}).
 		Joins("JOIN cron_job_listing_dbs ON task_listing_dbs.cron_job_id = cron_job_listing_dbs.id").
-		Where("cron_job_listing_dbs.active = ? AND (task_listing_dbs.status = ? OR (task_listing_dbs.status = ? AND task_listing_dbs.retry_count < ?))",
-			true, TaskStatusPushed, TaskStatusFailed, MaxRetryCount).
-		First(&res)
+		Where("cron_job_listing_dbs.active = ? AND task_listing_dbs.status = ? AND (task_listing_dbs.not_before IS NULL OR task_listing_dbs.not_before <= ?)",
+			true, TaskStatusPushed, time.Now())
+	if len(excludeMethods) > 0 {
+		query = query.Where("cron_job_listing_dbs.method NOT IN ?", excludeMethods)
+	}
+	db := query.Order("task_listing_dbs.id").First(&res)
 	if db.Error != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("error getting pushed task: %v", db.Error)
@@ -137,6 +205,9 @@ func (r *TaskRepository) GetPushedTask() (*TaskListingDB, error) {
 	res.StartTime = &startTime
 	res.LastHeartBeat = &startTime
 	res.Message = "Automatic backup started"
+	res.ClaimedBy = owner
+	leaseExpiresAt := startTime.Add(TaskLeaseDuration)
+	res.LeaseExpiresAt = &leaseExpiresAt
 
 	if err := tx.Save(&res).Error; err != nil {
 		tx.Rollback()
@@ -166,6 +237,32 @@ func (r *TaskRepository) GetPushedTask() (*TaskListingDB, error) {
 	return &res, nil
 }
 
+// CountPushedTasks returns the number of tasks that are due and waiting to be picked up by a worker
+func (r *TaskRepository) CountPushedTasks() (int64, error) {
+	var count int64
+	db := r.db.Model(&TaskListingDB{}).
+		Joins("JOIN cron_job_listing_dbs ON task_listing_dbs.cron_job_id = cron_job_listing_dbs.id").
+		Where("cron_job_listing_dbs.active = ? AND task_listing_dbs.status = ? AND (task_listing_dbs.not_before IS NULL OR task_listing_dbs.not_before <= ?)",
+			true, TaskStatusPushed, time.Now()).
+		Count(&count)
+	if db.Error != nil {
+		return 0, fmt.Errorf("error counting pushed tasks: %v", db.Error)
+	}
+
+	return count, nil
+}
+
+// HasActiveTaskForCronJob reports whether a task of the job is queued, running or paused
+func (r *TaskRepository) HasActiveTaskForCronJob(cronJobID uint) (bool, error) {
+	var count int64
+	if err := r.db.Model(&TaskListingDB{}).
+		Where("cron_job_id = ? AND status IN ?", cronJobID, []string{TaskStatusPushed, TaskStatusRunning, TaskStatusPaused}).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("error counting active tasks for cron job: %v", err)
+	}
+	return count > 0, nil
+}
+
 // GetTaskByID retrieves a task by its ID
 func (r *TaskRepository) GetTaskByID(ID uint) (*TaskListingDB, error) {
 	var res TaskListingDB
@@ -184,6 +281,7 @@ func (r *TaskRepository) CreateTaskForCronJob(cronJobID uint) (*TaskListingDB, e
 	data := TaskListingDB{
 		CronJobID: cronJobID,
 		Status:    TaskStatusPushed,
+		Attempt:   1,
 	}
 
 	// create new entry in database and return newly created task
@@ -210,28 +308,148 @@ func (r *TaskRepository) CreateTaskForCronJob(cronJobID uint) (*TaskListingDB, e
 	return &data, nil
 }
 
-// UpdateTaskByID updates a task by its ID
-func (r *TaskRepository) UpdateTaskByID(ID uint, m map[string]interface{}) error {
-	// update task by ID
-	// create a transaction to update the task and check if m["status"] == "failed"
-	// then update "retry_count" and "status"
+// CreateRetryTask queues the next attempt of a failed task. The new task is linked to the
+// first attempt and is not picked up before notBefore.
+func (r *TaskRepository) CreateRetryTask(failed *TaskListingDB, notBefore time.Time) (*TaskListingDB, error) {
 	tx := r.db.Begin()
-	db := tx.Model(&TaskListingDB{}).Where("id = ?", ID).Updates(m)
-	if db != nil && db.Error != nil {
+
+	originalID := failed.OriginalTaskID()
+	attempt := uint(failed.AttemptNumber() + 1)
+	data := TaskListingDB{
+		CronJobID:     failed.CronJobID,
+		Status:        TaskStatusPushed,
+		Message:       fmt.Sprintf("Retry %d scheduled", attempt-1),
+		RetryCount:    attempt - 1,
+		RetryOfTaskID: &originalID,
+		Attempt:       attempt,
+		NotBefore:     &notBefore,
+	}
+
+	if err := tx.Create(&data).Error; err != nil {
 		tx.Rollback()
-		return fmt.Errorf("error updating task by ID: %v", db.Error)
+		return nil, fmt.Errorf("error creating retry task: %v", err)
 	}
 
-	if m["status"] == TaskStatusFailed {
-		if err := tx.Model(&TaskListingDB{}).Where("id = ?", ID).Update("retry_count", gormdb.Expr("retry_count + 1")).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("error updating task by ID: %v", err)
-		}
+	if err := tx.Model(&CronJobListingDB{}).Where("id = ?", failed.CronJobID).Update("status", JobStatusInQueue).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("error updating cron job status: %v", err)
 	}
 
-	err := tx.Commit()
-	if err != nil && err.Error != nil {
-		return fmt.Errorf("error committing transaction: %v", err.Error)
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return &data, nil
+}
+
+// ErrTaskStatusConflict is returned when a task is not in a status that allows the
+// requested change
+var ErrTaskStatusConflict = errors.New("task status does not allow this action")
+
+// TransitionTaskStatus moves a task that is in one of the from statuses to status to and
+// sets the status and message of its job. It returns ErrTaskStatusConflict if the task is
+// in any other status. A running task notices the change at its next heartbeat.
+//
+// Pausing or cancelling a task also moves the job's next run past the current slot,
+// otherwise the job would be due again straight away and get a new task.
+func (r *TaskRepository) TransitionTaskStatus(ID uint, from []string, to, jobStatus, message string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":  to,
+			"message": message,
+		}
+		if to == TaskStatusPushed {
+			// Back in the queue: release the previous claim and any retry delay
+			updates["claimed_by"] = ""
+			updates["lease_expires_at"] = nil
+			updates["not_before"] = nil
+		}
+
+		db := tx.Model(&TaskListingDB{}).Where("id = ? AND status IN ?", ID, from).Updates(updates)
+		if db.Error != nil {
+			return fmt.Errorf("error updating task status: %v", db.Error)
+		}
+		if db.RowsAffected == 0 {
+			return ErrTaskStatusConflict
+		}
+
+		var task TaskListingDB
+		if err := tx.First(&task, ID).Error; err != nil {
+			return fmt.Errorf("error getting task: %v", err)
+		}
+
+		jobUpdates := map[string]interface{}{
+			"status":         jobStatus,
+			"message":        message,
+			"message_status": JobMessageStatusInfo,
+		}
+		if to == TaskStatusPaused || to == TaskStatusCancelled {
+			var job CronJobListingDB
+			if err := tx.First(&job, task.CronJobID).Error; err != nil {
+				return fmt.Errorf("error getting cron job: %v", err)
+			}
+			// The slot after now; last_run itself keeps the last finished run
+			now := time.Now()
+			job.LastRun = &now
+			jobUpdates["next_run_at"] = job.ComputeNextRunAt(now, loadUserSettings(tx, job.UserID))
+		}
+
+		if err := tx.Model(&CronJobListingDB{}).Where("id = ?", task.CronJobID).Updates(jobUpdates).Error; err != nil {
+			return fmt.Errorf("error updating cron job status: %v", err)
+		}
+
+		return nil
+	})
+}
+
+// ReleaseClaimedTasks puts the running tasks claimed by owner back in the queue. It is
+// used on shutdown for tasks that did not stop before the deadline, so another instance
+// picks them up instead of waiting for their lease to lapse.
+func (r *TaskRepository) ReleaseClaimedTasks(owner string) (int64, error) {
+	var released int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var jobIDs []uint
+		if err := tx.Model(&TaskListingDB{}).
+			Where("status = ? AND claimed_by = ?", TaskStatusRunning, owner).
+			Pluck("cron_job_id", &jobIDs).Error; err != nil {
+			return fmt.Errorf("error getting claimed tasks: %v", err)
+		}
+		if len(jobIDs) == 0 {
+			return nil
+		}
+
+		db := tx.Model(&TaskListingDB{}).
+			Where("status = ? AND claimed_by = ?", TaskStatusRunning, owner).
+			Updates(map[string]interface{}{
+				"status":           TaskStatusPushed,
+				"message":          "Requeued after server shutdown",
+				"claimed_by":       "",
+				"lease_expires_at": nil,
+			})
+		if db.Error != nil {
+			return fmt.Errorf("error releasing claimed tasks: %v", db.Error)
+		}
+		released = db.RowsAffected
+
+		if err := tx.Model(&CronJobListingDB{}).Where("id IN ?", jobIDs).Updates(map[string]interface{}{
+			"status": JobStatusInQueue,
+		}).Error; err != nil {
+			return fmt.Errorf("error updating cron job status: %v", err)
+		}
+		return nil
+	})
+	return released, err
+}
+
+// UpdateClaimedTask updates a task claimed by owner. It returns ErrTaskClaimLost if owner
+// no longer holds the claim.
+func (r *TaskRepository) UpdateClaimedTask(ID uint, owner string, m map[string]interface{}) error {
+	db := r.db.Model(&TaskListingDB{}).Where("id = ? AND claimed_by = ?", ID, owner).Updates(m)
+	if db.Error != nil {
+		return fmt.Errorf("error updating task by ID: %v", db.Error)
+	}
+	if db.RowsAffected == 0 {
+		return ErrTaskClaimLost
 	}
 
 	return nil
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDB connects to the Postgres database in TEST_POSTGRES_DSN. The queries use
+// Postgres features, so the tests are skipped without one.
+func testDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	db, err := gorm.NewDatabase(gorm.PostgresConfig(dsn, false))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(&CronJobListingDB{}, &TaskListingDB{}, &UserSettings{}, &Snapshot{}, &SyncedObject{}))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestTransitionTaskStatusAdvancesNextRun(t *testing.T) {
+	db := testDB(t)
+	jobs, tasks := NewCronJobRepository(db), NewTaskRepository(db)
+
+	for to, jobStatus := range map[string]string{TaskStatusCancelled: JobStatusCancelled, TaskStatusPaused: JobStatusPaused} {
+		t.Run(to, func(t *testing.T) {
+			due := time.Now().Add(-time.Minute)
+			job := &CronJobListingDB{
+				UserID:    "test-user",
+				Name:      "transition-" + to + "-" + time.Now().Format(time.RFC3339Nano),
+				Method:    "gmail",
+				SyncType:  "daily",
+				Interval:  "daily",
+				On:        "09:00",
+				Active:    true,
+				NextRunAt: &due,
+			}
+			require.NoError(t, db.Create(job).Error)
+			t.Cleanup(func() { db.Unscoped().Delete(&CronJobListingDB{}, job.ID) })
+
+			task, err := tasks.CreateTaskForCronJob(job.ID)
+			require.NoError(t, err)
+			require.NoError(t, tasks.TransitionTaskStatus(task.ID, []string{TaskStatusPushed}, to, jobStatus, "stopped by user"))
+
+			var stored CronJobListingDB
+			require.NoError(t, db.First(&stored, job.ID).Error)
+			require.NotNil(t, stored.NextRunAt)
+			assert.True(t, stored.NextRunAt.After(time.Now()))
+
+			toProcess, err := jobs.GetJobsToProcess()
+			require.NoError(t, err)
+			for _, j := range toProcess {
+				assert.NotEqual(t, job.ID, j.ID)
+			}
+		})
+	}
+}
+
+func TestClaimedTaskUpdatesRequireOwner(t *testing.T) {
+	db := testDB(t)
+	tasks := NewTaskRepository(db)
+
+	job := &CronJobListingDB{
+		UserID:   "test-user",
+		Name:     "claim-" + time.Now().Format(time.RFC3339Nano),
+		Method:   "gmail",
+		SyncType: "daily",
+		Interval: "daily",
+		On:       "09:00",
+		Active:   true,
+	}
+	require.NoError(t, db.Create(job).Error)
+	t.Cleanup(func() { db.Unscoped().Delete(&CronJobListingDB{}, job.ID) })
+
+	task, err := tasks.CreateTaskForCronJob(job.ID)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(task).Updates(map[string]interface{}{
+		"status":     TaskStatusRunning,
+		"claimed_by": "owner-a",
+	}).Error)
+
+	require.NoError(t, tasks.UpdateHeartBeatForTask(task.ID, "owner-a"))
+	assert.ErrorIs(t, tasks.UpdateHeartBeatForTask(task.ID, "owner-b"), ErrTaskClaimLost)
+	assert.ErrorIs(t, tasks.UpdateClaimedTask(task.ID, "owner-b", map[string]interface{}{
+		"status": TaskStatusSuccess,
+	}), ErrTaskClaimLost)
+
+	stored, err := tasks.GetTaskByID(task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TaskStatusRunning, stored.Status)
+}
+
+func TestDependenciesMet(t *testing.T) {
+	db := testDB(t)
+	jobs := NewCronJobRepository(db)
+
+	newJob := func(name string, dependsOn ...uint) *CronJobListingDB {
+		job := &CronJobListingDB{
+			UserID:    "test-user",
+			Name:      name + "-" + time.Now().Format(time.RFC3339Nano),
+			Method:    "gmail",
+			SyncType:  "daily",
+			Interval:  "daily",
+			On:        "09:00",
+			Active:    true,
+			DependsOn: dependsOn,
+		}
+		require.NoError(t, db.Create(job).Error)
+		t.Cleanup(func() { db.Unscoped().Delete(&CronJobListingDB{}, job.ID) })
+		return job
+	}
+	dependency := newJob("dependency")
+	dependent := newJob("dependent", dependency.ID)
+
+	met, err := jobs.DependenciesMet(dependent.ID)
+	require.NoError(t, err)
+	assert.False(t, met, "the dependency never ran")
+
+	lastRun := time.Now()
+	require.NoError(t, db.Model(dependency).Updates(map[string]interface{}{"status": JobStatusSuccess, "last_run": lastRun}).Error)
+	met, err = jobs.DependenciesMet(dependent.ID)
+	require.NoError(t, err)
+	assert.True(t, met)
+
+	require.NoError(t, db.Model(dependent).Update("last_run", lastRun.Add(time.Minute)).Error)
+	met, err = jobs.DependenciesMet(dependent.ID)
+	require.NoError(t, err)
+	assert.False(t, met, "the dependency has not run since the job last ran")
+}
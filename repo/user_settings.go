@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/gorm"
+	"github.com/StorX2-0/Backup-Tools/pkg/schedule"
+	"gorm.io/gorm/clause"
+)
+
+// UserSettings holds per-user preferences that apply to all of a user's jobs
+type UserSettings struct {
+	gorm.GormModel
+
+	UserID string `json:"user_id" gorm:"uniqueIndex;not null"`
+
+	// Timezone is the IANA time zone new jobs default to and "today" statistics use.
+	// Empty means the server's time zone.
+	Timezone string `json:"timezone"`
+
+	// BlackoutWindows are times no backup of the user starts, in the format of
+	// schedule.ParseBlackouts, evaluated in each job's time zone
+	BlackoutWindows string `json:"blackout_windows"`
+}
+
+// Blackouts returns the user's blackout windows. The value is validated when it is
+// saved, so a nil settings or an invalid value means no windows.
+func (s *UserSettings) Blackouts() schedule.Blackouts {
+	if s == nil {
+		return nil
+	}
+	blackouts, err := schedule.ParseBlackouts(s.BlackoutWindows, nil)
+	if err != nil {
+		return nil
+	}
+	return blackouts
+}
+
+// loadUserSettings returns the settings of a user, or nil if there are none or they
+// cannot be read; callers fall back to the defaults
+func loadUserSettings(db *gorm.DB, userID string) *UserSettings {
+	var settings UserSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return nil
+	}
+	return &settings
+}
+
+// UserSettingsRepository handles all database operations for user settings
+type UserSettingsRepository struct {
+	db *gorm.DB
+}
+
+// NewUserSettingsRepository creates a new user settings repository
+func NewUserSettingsRepository(db *gorm.DB) *UserSettingsRepository {
+	return &UserSettingsRepository{db: db}
+}
+
+// GetUserSettings returns the settings for a user. Users who never saved settings get
+// the defaults rather than an error.
+func (r *UserSettingsRepository) GetUserSettings(userID string) (*UserSettings, error) {
+	var settings UserSettings
+	err := r.db.Where("user_id = ?", userID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &UserSettings{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user settings: %v", err)
+	}
+
+	return &settings, nil
+}
+
+// UpsertUserSettings creates the settings for a user, or updates the given columns of
+// the existing ones; the other columns keep their saved values
+func (r *UserSettingsRepository) UpsertUserSettings(settings *UserSettings, columns ...string) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns(append(columns, "updated_at")),
+	}).Create(settings).Error
+	if err != nil {
+		return fmt.Errorf("error saving user settings: %v", err)
+	}
+
+	return nil
+}
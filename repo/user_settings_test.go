@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertUserSettingsKeepsOtherColumns(t *testing.T) {
+	db := testDB(t)
+	settings := NewUserSettingsRepository(db)
+
+	userID := "settings-user-" + time.Now().Format(time.RFC3339Nano)
+	t.Cleanup(func() { db.Unscoped().Where("user_id = ?", userID).Delete(&UserSettings{}) })
+
+	require.NoError(t, settings.UpsertUserSettings(&UserSettings{UserID: userID, Timezone: "Asia/Kolkata"}, "timezone"))
+	require.NoError(t, settings.UpsertUserSettings(&UserSettings{UserID: userID, BlackoutWindows: "09:00-17:00"}, "blackout_windows"))
+
+	saved, err := settings.GetUserSettings(userID)
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Kolkata", saved.Timezone)
+	assert.Equal(t, "09:00-17:00", saved.BlackoutWindows)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/StorX2-0/Backup-Tools/handler"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 
@@ -16,7 +17,9 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func StartServer(db *db.PostgresDb, address string) {
+// NewServer creates the Echo server with all middleware and routes registered. The
+// caller starts it and shuts it down.
+func NewServer(db *db.PostgresDb) *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
 
@@ -56,8 +59,13 @@ func StartServer(db *db.PostgresDb, address string) {
 	e.GET("/autobackup/summary", handler.HandleAutomaticBackupSummary)
 	e.GET("/autosync/stats", handler.HandleAutomaticSyncStats)
 
+	// User settings
+	e.GET("/settings", handler.HandleGetUserSettings)
+	e.PUT("/settings", handler.HandleUpdateUserSettings)
+
 	autoSync := e.Group("/auto-sync")
 	autoSync.GET("/live", handler.HandleAutomaticSyncActiveJobsForUser)
+	autoSync.GET("/live/stream", handler.HandleAutomaticSyncLiveStream)
 	autoSync.PUT("/task/hide", handler.HandleHideTask)
 
 	job := autoSync.Group("/job")
@@ -66,12 +74,33 @@ func StartServer(db *db.PostgresDb, address string) {
 	job.POST("/:method", handler.HandleAutomaticSyncCreate)
 	job.PUT("/:job_id", handler.HandleAutomaticBackupUpdate)
 	job.DELETE("/:job_id", handler.HandleAutomaticSyncDelete)
+	job.GET("/:job_id/retention/preview", handler.HandleRetentionPreview)
 
 	job.GET("/interval", handler.HandleIntervalOnConfig)
 
 	task := autoSync.Group("/task")
 	task.POST("/:job_id", handler.HandleAutomaticSyncCreateTask)
 	task.GET("/:job_id", handler.HandleAutomaticSyncTaskList)
+	task.POST("/:task_id/cancel", handler.HandleAutomaticSyncCancelTask)
+	task.POST("/:task_id/pause", handler.HandleAutomaticSyncPauseTask)
+	task.POST("/:task_id/resume", handler.HandleAutomaticSyncResumeTask)
+	task.GET("/:task_id/events", handler.HandleAutomaticSyncTaskEvents)
+
+	// Checks of the synced objects catalog against the buckets
+	catalog := e.Group("/catalog")
+	catalog.POST("/verify", handler.HandleCatalogVerify)
+	catalog.GET("/checks", handler.HandleCatalogChecks)
+
+	// Snapshots of the backups as of the end of each run
+	snapshots := e.Group("/snapshots")
+	snapshots.GET("", handler.HandleSnapshotList)
+	snapshots.GET("/:snapshot_id/items", handler.HandleSnapshotItems)
+	snapshots.POST("/:snapshot_id/restore", handler.HandleSnapshotRestore)
+
+	// Backups as streamed ZIP or TAR archives
+	e.GET("/archive", handler.HandleArchiveDownload)
+	e.HEAD("/archive", handler.HandleArchiveDownload)
+	e.POST("/archive", handler.HandleArchiveDownload)
 
 	// Admin endpoint for deleting jobs by email
 	autoSync.DELETE("/delete-jobs-by-email", handler.HandleDeleteJobsByEmail)
@@ -211,9 +240,15 @@ func StartServer(db *db.PostgresDb, address string) {
 	scheduledTasks.POST("/:method", handler.HandleCreateScheduledTask)
 	scheduledTasks.GET("", handler.HandleGetScheduledTasksByUserID)
 	scheduledTasks.GET("/live", handler.HandleGetRunningScheduledTasks)
+	scheduledTasks.GET("/live/stream", handler.HandleScheduledTasksLiveStream)
+	scheduledTasks.POST("/:id/cancel", handler.HandleCancelScheduledTask)
+	scheduledTasks.POST("/:id/pause", handler.HandlePauseScheduledTask)
+	scheduledTasks.POST("/:id/resume", handler.HandleResumeScheduledTask)
+	scheduledTasks.GET("/:id/events", handler.HandleScheduledTaskEvents)
 
-	err := e.Start(address)
-	if err != nil {
-		logger.Info(context.Background(), "Error starting server", logger.ErrorField(err))
-	}
+	// End open progress streams when the server shuts down, otherwise Shutdown waits
+	// for them until its deadline
+	e.Server.RegisterOnShutdown(progress.Default.Close)
+
+	return e
 }
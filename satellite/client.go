@@ -0,0 +1,606 @@
+package satellite
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/ratelimit"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"storj.io/uplink"
+	privateAccess "storj.io/uplink/private/access"
+)
+
+// defaultProjectIdleTimeout is how long an unused project stays open when
+// SATELLITE_PROJECT_IDLE_TIMEOUT is unset
+const defaultProjectIdleTimeout = 5 * time.Minute
+
+// Pool keeps the uplink projects of recently used access grants open, so consecutive
+// calls for the same grant skip parsing the grant, the satellite handshake and the bucket
+// checks. Projects nobody holds are closed once they were idle for the idle timeout.
+type Pool struct {
+	idleTimeout time.Duration
+
+	// closeProject closes a project the pool let go of
+	closeProject func(*uplink.Project) error
+
+	mu       sync.Mutex
+	projects map[string]*pooledProject
+	closed   bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// errPoolClosed is returned for clients requested after the pool was closed
+var errPoolClosed = errors.New("project pool is closed")
+
+// pooledProject is a project shared by all clients of one access grant
+type pooledProject struct {
+	key     string
+	account string
+	project *uplink.Project
+
+	// refs and lastUsed are guarded by Pool.mu
+	refs     int
+	lastUsed time.Time
+
+	bucketsMu sync.Mutex
+	buckets   map[string]bool
+}
+
+// NewPool creates a pool that closes projects after they were unused for idleTimeout
+func NewPool(idleTimeout time.Duration) *Pool {
+	p := &Pool{
+		idleTimeout:  idleTimeout,
+		closeProject: (*uplink.Project).Close,
+		projects:     make(map[string]*pooledProject),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go p.evictLoop()
+	return p
+}
+
+var (
+	defaultPoolOnce sync.Once
+	defaultPool     *Pool
+)
+
+// DefaultPool returns the pool shared by the process
+func DefaultPool() *Pool {
+	defaultPoolOnce.Do(func() {
+		idleTimeout := defaultProjectIdleTimeout
+		if v := utils.GetEnvWithKey("SATELLITE_PROJECT_IDLE_TIMEOUT"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				idleTimeout = d
+			} else {
+				logger.Warn(context.Background(), "Invalid SATELLITE_PROJECT_IDLE_TIMEOUT value, using default",
+					logger.String("value", v), logger.String("default", defaultProjectIdleTimeout.String()))
+			}
+		}
+		defaultPool = NewPool(idleTimeout)
+	})
+	return defaultPool
+}
+
+// NewClient returns a client for accessGrant from the default pool
+func NewClient(ctx context.Context, accessGrant string) (*Client, error) {
+	return DefaultPool().Client(ctx, accessGrant)
+}
+
+// Client returns a client for accessGrant, opening its project if it is not pooled yet.
+// The client must be closed; its project stays open for as long as any client holds it.
+func (p *Pool) Client(ctx context.Context, accessGrant string) (*Client, error) {
+	key := grantKey(accessGrant)
+
+	if entry := p.acquire(key); entry != nil {
+		return &Client{pool: p, entry: entry}, nil
+	}
+	if p.isClosed() {
+		return nil, WrapError("open project", errPoolClosed)
+	}
+
+	access, err := uplink.ParseAccess(accessGrant)
+	if err != nil {
+		return nil, WrapError("parse access grant", err)
+	}
+	// Grants restricted from one API key share the limits of its project
+	account := ratelimit.AccountKey(string(privateAccess.APIKey(access).Head()), accessGrant)
+	if err := ratelimit.Default().Wait(ctx, "uplink", account); err != nil {
+		return nil, WrapError("wait for rate limit", err)
+	}
+	// The project outlives the call that opened it
+	project, err := uplink.OpenProject(context.WithoutCancel(ctx), access)
+	if err != nil {
+		return nil, WrapError("open project", err)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		_ = p.closeProject(project)
+		return nil, WrapError("open project", errPoolClosed)
+	}
+	entry, ok := p.projects[key]
+	if !ok {
+		entry = &pooledProject{
+			key:     key,
+			account: account,
+			project: project,
+			buckets: make(map[string]bool),
+		}
+		p.projects[key] = entry
+	}
+	entry.refs++
+	entry.lastUsed = time.Now()
+	p.mu.Unlock()
+
+	// Another caller opened the same project in the meantime
+	if ok {
+		_ = p.closeProject(project)
+	}
+	return &Client{pool: p, entry: entry}, nil
+}
+
+func (p *Pool) acquire(key string) *pooledProject {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.projects[key]
+	if !ok {
+		return nil
+	}
+	entry.refs++
+	entry.lastUsed = time.Now()
+	return entry
+}
+
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *Pool) release(entry *pooledProject) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry.refs--
+	entry.lastUsed = time.Now()
+}
+
+func (p *Pool) evictLoop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.evict(now)
+		}
+	}
+}
+
+// evict closes the projects no client held for the idle timeout
+func (p *Pool) evict(now time.Time) {
+	var idle []*pooledProject
+	p.mu.Lock()
+	for key, entry := range p.projects {
+		if entry.refs == 0 && now.Sub(entry.lastUsed) > p.idleTimeout {
+			delete(p.projects, key)
+			idle = append(idle, entry)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, entry := range idle {
+		if err := p.closeProject(entry.project); err != nil {
+			logger.Warn(context.Background(), "Failed to close idle project", logger.ErrorField(err))
+		}
+	}
+}
+
+// Close stops the eviction and closes every pooled project, including the ones clients
+// still hold. Clients requested afterwards fail. Closing the pool again is a no-op.
+func (p *Pool) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+
+		p.mu.Lock()
+		projects := p.projects
+		p.projects = make(map[string]*pooledProject)
+		p.closed = true
+		p.mu.Unlock()
+
+		var errs []error
+		for _, entry := range projects {
+			errs = append(errs, p.closeProject(entry.project))
+		}
+		p.closeErr = errors.Join(errs...)
+	})
+	return p.closeErr
+}
+
+// grantKey identifies a pooled project by the whole hash of its access grant, so no two
+// grants can ever share a project
+func grantKey(accessGrant string) string {
+	sum := sha256.Sum256([]byte(accessGrant))
+	return hex.EncodeToString(sum[:])
+}
+
+// Client performs object operations with the project of one access grant. Processors
+// hold one for a whole run; every operation still counts against the uplink rate limit
+// of the grant.
+type Client struct {
+	pool   *Pool
+	entry  *pooledProject
+	closed atomic.Bool
+}
+
+// Project returns the underlying project. It must not be closed by the caller.
+func (c *Client) Project() *uplink.Project {
+	return c.entry.project
+}
+
+// Close releases the client. The project stays pooled for other clients.
+func (c *Client) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.pool.release(c.entry)
+	}
+	return nil
+}
+
+func (c *Client) wait(ctx context.Context) error {
+	if err := ratelimit.Default().Wait(ctx, "uplink", c.entry.account); err != nil {
+		return WrapError("wait for rate limit", err)
+	}
+	return nil
+}
+
+// EnsureBucket creates the bucket if it does not exist. Buckets seen once are not checked
+// again for the lifetime of the pooled project.
+func (c *Client) EnsureBucket(ctx context.Context, bucketName string) error {
+	entry := c.entry
+	entry.bucketsMu.Lock()
+	known := entry.buckets[bucketName]
+	entry.bucketsMu.Unlock()
+	if known {
+		return nil
+	}
+
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	if _, err := entry.project.EnsureBucket(ctx, bucketName); err != nil {
+		return WrapError("ensure bucket", err)
+	}
+
+	entry.bucketsMu.Lock()
+	entry.buckets[bucketName] = true
+	entry.bucketsMu.Unlock()
+	return nil
+}
+
+// forgetBucket drops a bucket from the cache when an operation found it missing, e.g.
+// because it was deleted from outside
+func (c *Client) forgetBucket(bucketName string, err error) {
+	if errors.Is(err, uplink.ErrBucketNotFound) {
+		c.entry.bucketsMu.Lock()
+		delete(c.entry.buckets, bucketName)
+		c.entry.bucketsMu.Unlock()
+	}
+}
+
+// Upload is an object upload that keeps its project open until it is committed or
+// aborted
+type Upload struct {
+	*uplink.Upload
+	client *Client
+}
+
+// Commit finishes the upload
+func (u *Upload) Commit() error {
+	defer u.client.Close()
+	return u.Upload.Commit()
+}
+
+// Abort cancels the upload
+func (u *Upload) Abort() error {
+	defer u.client.Close()
+	return u.Upload.Abort()
+}
+
+// Uploader starts an upload of objectKey with meta as its custom metadata, creating the
+// bucket if needed. The upload must be committed or aborted.
+func (c *Client) Uploader(ctx context.Context, bucketName, objectKey string, meta ObjectMetadata) (*Upload, error) {
+	if err := c.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := c.entry.project.UploadObject(ctx, bucketName, objectKey, nil)
+	if err != nil {
+		c.forgetBucket(bucketName, err)
+		return nil, WrapError("initiate upload", err)
+	}
+	if err := upload.SetCustomMetadata(ctx, meta.Custom()); err != nil {
+		_ = upload.Abort()
+		return nil, WrapError("set metadata", err)
+	}
+
+	// The upload holds its own reference, so it survives the client being closed
+	held := c.pool.acquire(c.entry.key)
+	if held == nil {
+		_ = upload.Abort()
+		return nil, WrapError("initiate upload", errors.New("project was closed"))
+	}
+	return &Upload{Upload: upload, client: &Client{pool: c.pool, entry: held}}, nil
+}
+
+// UploadStream uploads everything read from r to objectKey with meta as its custom
+// metadata and returns the number of bytes uploaded. The content is compressed on the way
+// if meta names a compression. Nothing is buffered beyond what uplink keeps in flight.
+func (c *Client) UploadStream(ctx context.Context, bucketName, objectKey string, r io.Reader, meta ObjectMetadata) (int64, error) {
+	return c.uploadStream(ctx, bucketName, objectKey, r, meta, meta.Compression)
+}
+
+// uploadStream is UploadStream for content that is compressed with algorithm on the way;
+// content that is compressed already is uploaded as it is
+func (c *Client) uploadStream(ctx context.Context, bucketName, objectKey string, r io.Reader, meta ObjectMetadata, algorithm string) (int64, error) {
+	upload, err := c.Uploader(ctx, bucketName, objectKey, meta)
+	if err != nil {
+		return 0, err
+	}
+
+	content, finish, err := encodeContent(r, meta, algorithm)
+	if err != nil {
+		_ = upload.Abort()
+		return 0, WrapError("upload data", err)
+	}
+	defer content.Close()
+
+	written, err := io.Copy(upload, content)
+	if err != nil {
+		_ = upload.Abort()
+		return written, WrapError("upload data", err)
+	}
+
+	// The hash and the size are only known once everything was read
+	if final := finish(); final != meta {
+		if err := upload.SetCustomMetadata(ctx, final.Custom()); err != nil {
+			_ = upload.Abort()
+			return written, WrapError("set metadata", err)
+		}
+	}
+
+	if err := upload.Commit(); err != nil {
+		return written, WrapError("commit object", err)
+	}
+	return written, nil
+}
+
+// UploadObject uploads data to objectKey
+func (c *Client) UploadObject(ctx context.Context, bucketName, objectKey string, data []byte, meta ObjectMetadata) error {
+	_, err := c.UploadStream(ctx, bucketName, objectKey, bytes.NewReader(data), meta)
+	return err
+}
+
+// Download is an object being read that keeps its project open until it is closed.
+// Compressed objects are decompressed while they are read.
+type Download struct {
+	*uplink.Download
+	client  *Client
+	content io.ReadCloser
+}
+
+// Read reads the content of the object
+func (d *Download) Read(p []byte) (int, error) {
+	return d.content.Read(p)
+}
+
+// Metadata returns the metadata stored with the object
+func (d *Download) Metadata() ObjectMetadata {
+	return ParseObjectMetadata(d.Info().Custom)
+}
+
+// Close finishes the download
+func (d *Download) Close() error {
+	defer d.client.Close()
+	return errors.Join(d.content.Close(), d.Download.Close())
+}
+
+// OpenObject opens objectKey for reading. The download must be closed.
+func (c *Client) OpenObject(ctx context.Context, bucketName, objectKey string) (*Download, error) {
+	if err := c.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	download, err := c.entry.project.DownloadObject(ctx, bucketName, objectKey, nil)
+	if err != nil {
+		c.forgetBucket(bucketName, err)
+		return nil, WrapError("open object", err)
+	}
+
+	content, err := compress.NewReader(download, ParseObjectMetadata(download.Info().Custom).Compression)
+	if err != nil {
+		_ = download.Close()
+		return nil, WrapError("open object", err)
+	}
+
+	// The download holds its own reference, so it survives the client being closed
+	held := c.pool.acquire(c.entry.key)
+	if held == nil {
+		_ = content.Close()
+		_ = download.Close()
+		return nil, WrapError("open object", errors.New("project was closed"))
+	}
+	return &Download{Download: download, client: &Client{pool: c.pool, entry: held}, content: content}, nil
+}
+
+// DownloadStream writes the content of the object to w and returns the number of bytes
+// written
+func (c *Client) DownloadStream(ctx context.Context, bucketName, objectKey string, w io.Writer) (int64, error) {
+	download, err := c.OpenObject(ctx, bucketName, objectKey)
+	if err != nil {
+		return 0, err
+	}
+	defer download.Close()
+
+	written, err := io.Copy(w, download)
+	if err != nil {
+		return written, WrapError("read data", err)
+	}
+	return written, nil
+}
+
+// DownloadObject downloads the whole object into memory. Prefer DownloadStream for
+// objects of unbounded size.
+func (c *Client) DownloadObject(ctx context.Context, bucketName, objectKey string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := c.DownloadStream(ctx, bucketName, objectKey, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StatObject returns the metadata of an object
+func (c *Client) StatObject(ctx context.Context, bucketName, objectKey string) (*uplink.Object, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	object, err := c.entry.project.StatObject(ctx, bucketName, objectKey)
+	if err != nil {
+		c.forgetBucket(bucketName, err)
+		return nil, WrapError("stat object", err)
+	}
+	return object, nil
+}
+
+// ListObjects lists the objects of a bucket
+func (c *Client) ListObjects(ctx context.Context, bucketName string, options *uplink.ListObjectsOptions) ([]uplink.Object, error) {
+	if err := c.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	listIter := c.entry.project.ListObjects(ctx, bucketName, options)
+	var objects []uplink.Object
+	for listIter.Next() {
+		objects = append(objects, *listIter.Item())
+	}
+	if err := listIter.Err(); err != nil {
+		c.forgetBucket(bucketName, err)
+		return nil, WrapError("list objects", err)
+	}
+	return objects, nil
+}
+
+// DeleteObject deletes an object
+func (c *Client) DeleteObject(ctx context.Context, bucketName, objectKey string) error {
+	if err := c.EnsureBucket(ctx, bucketName); err != nil {
+		return err
+	}
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+
+	if _, err := c.entry.project.DeleteObject(ctx, bucketName, objectKey); err != nil {
+		c.forgetBucket(bucketName, err)
+		return WrapError("delete object", err)
+	}
+	return nil
+}
+
+// UpdateMetadata replaces the metadata stored with an object
+func (c *Client) UpdateMetadata(ctx context.Context, bucketName, objectKey string, meta ObjectMetadata) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+
+	if err := c.entry.project.UpdateObjectMetadata(ctx, bucketName, objectKey, meta.Custom(), nil); err != nil {
+		c.forgetBucket(bucketName, err)
+		return WrapError("update metadata", err)
+	}
+	return nil
+}
+
+// Copy copies an object within a bucket on the satellite, without transferring its content
+func (c *Client) Copy(ctx context.Context, bucketName, fromKey, toKey string) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+
+	if _, err := c.entry.project.CopyObject(ctx, bucketName, fromKey, bucketName, toKey, nil); err != nil {
+		c.forgetBucket(bucketName, err)
+		return WrapError("copy object", err)
+	}
+	return nil
+}
+
+// Put implements ObjectStore with UploadStream
+func (c *Client) Put(ctx context.Context, bucketName, objectKey string, r io.Reader, meta ObjectMetadata) (int64, error) {
+	return c.UploadStream(ctx, bucketName, objectKey, r, meta)
+}
+
+// Get implements ObjectStore with OpenObject
+func (c *Client) Get(ctx context.Context, bucketName, objectKey string) (ObjectReader, error) {
+	download, err := c.OpenObject(ctx, bucketName, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	return download, nil
+}
+
+// Stat implements ObjectStore with StatObject
+func (c *Client) Stat(ctx context.Context, bucketName, objectKey string) (*ObjectInfo, error) {
+	object, err := c.StatObject(ctx, bucketName, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	info := objectInfo(object)
+	return &info, nil
+}
+
+// List implements ObjectStore with ListObjects
+func (c *Client) List(ctx context.Context, bucketName, prefix string, recursive bool) ([]ObjectInfo, error) {
+	objects, err := c.ListObjects(ctx, bucketName, &uplink.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: recursive,
+		System:    true,
+		Custom:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ObjectInfo, len(objects))
+	for i := range objects {
+		infos[i] = objectInfo(&objects[i])
+	}
+	return infos, nil
+}
+
+// Delete implements ObjectStore with DeleteObject
+func (c *Client) Delete(ctx context.Context, bucketName, objectKey string) error {
+	return c.DeleteObject(ctx, bucketName, objectKey)
+}
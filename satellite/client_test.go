@@ -0,0 +1,113 @@
+package satellite
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"storj.io/uplink"
+)
+
+// testPool returns a pool that records the projects it closes instead of closing them.
+// Its eviction loop never fires on its own.
+func testPool(t *testing.T) (*Pool, func() []*uplink.Project) {
+	p := NewPool(time.Hour)
+	var mu sync.Mutex
+	var closed []*uplink.Project
+	p.closeProject = func(project *uplink.Project) error {
+		mu.Lock()
+		defer mu.Unlock()
+		closed = append(closed, project)
+		return nil
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p, func() []*uplink.Project {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*uplink.Project(nil), closed...)
+	}
+}
+
+// pool adds an open project for accessGrant to the pool
+func (p *Pool) pool(accessGrant string) *pooledProject {
+	entry := &pooledProject{
+		key:      grantKey(accessGrant),
+		project:  &uplink.Project{},
+		lastUsed: time.Now(),
+		buckets:  make(map[string]bool),
+	}
+	p.mu.Lock()
+	p.projects[entry.key] = entry
+	p.mu.Unlock()
+	return entry
+}
+
+func TestPoolAcquireRelease(t *testing.T) {
+	p, closed := testPool(t)
+	entry := p.pool("grant-a")
+	p.pool("grant-b")
+
+	first, err := p.Client(context.Background(), "grant-a")
+	require.NoError(t, err)
+	second, err := p.Client(context.Background(), "grant-a")
+	require.NoError(t, err)
+	assert.Same(t, entry.project, first.Project())
+	assert.Same(t, entry.project, second.Project())
+	assert.Equal(t, 2, entry.refs)
+
+	require.NoError(t, first.Close())
+	require.NoError(t, first.Close())
+	assert.Equal(t, 1, entry.refs, "closing a client twice releases it once")
+	require.NoError(t, second.Close())
+	assert.Equal(t, 0, entry.refs)
+
+	assert.Empty(t, closed(), "released projects stay pooled")
+}
+
+func TestPoolEvictsIdleProjects(t *testing.T) {
+	p, closed := testPool(t)
+	idle := p.pool("idle")
+	held := p.pool("held")
+	recent := p.pool("recent")
+
+	client, err := p.Client(context.Background(), "held")
+	require.NoError(t, err)
+	defer client.Close()
+
+	now := time.Now()
+	idle.lastUsed = now.Add(-2 * time.Hour)
+	held.lastUsed = now.Add(-2 * time.Hour)
+	recent.lastUsed = now.Add(-time.Minute)
+	p.evict(now)
+
+	assert.Equal(t, []*uplink.Project{idle.project}, closed())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	assert.NotContains(t, p.projects, idle.key)
+	assert.Contains(t, p.projects, held.key)
+	assert.Contains(t, p.projects, recent.key)
+}
+
+func TestPoolClose(t *testing.T) {
+	p, closed := testPool(t)
+	a := p.pool("grant-a")
+	b := p.pool("grant-b")
+
+	client, err := p.Client(context.Background(), "grant-a")
+	require.NoError(t, err)
+
+	require.NoError(t, p.Close())
+	assert.ElementsMatch(t, []*uplink.Project{a.project, b.project}, closed())
+
+	require.NoError(t, p.Close(), "closing twice must not panic")
+	assert.Len(t, closed(), 2)
+
+	// Clients still held can be released after the pool was closed
+	require.NoError(t, client.Close())
+
+	_, err = p.Client(context.Background(), "grant-a")
+	assert.ErrorIs(t, err, errPoolClosed)
+}
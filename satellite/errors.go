@@ -0,0 +1,40 @@
+package satellite
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
+	"storj.io/uplink"
+)
+
+// errSource identifies StorX in categorised errors
+const errSource = "storx"
+
+// WrapError prefixes an uplink error with the operation that failed and attaches an
+// error category to it
+func WrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return errs.Wrap(uplinkCategory(op, err), errSource, fmt.Errorf("%s: %w", op, err))
+}
+
+func uplinkCategory(op string, err error) errs.Category {
+	switch {
+	case op == "parse access grant",
+		errors.Is(err, uplink.ErrPermissionDenied):
+		return errs.StoragePermission
+	case errors.Is(err, uplink.ErrTooManyRequests):
+		return errs.RateLimited
+	case errors.Is(err, uplink.ErrBandwidthLimitExceeded),
+		errors.Is(err, uplink.ErrStorageLimitExceeded),
+		errors.Is(err, uplink.ErrSegmentsLimitExceeded):
+		return errs.Quota
+	case errors.Is(err, uplink.ErrObjectNotFound),
+		errors.Is(err, uplink.ErrBucketNotFound):
+		return errs.NotFound
+	default:
+		return errs.CategoryOf(err)
+	}
+}
@@ -0,0 +1,158 @@
+package satellite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"storj.io/uplink"
+)
+
+// ToolVersion is recorded on every uploaded object. It is set at build time.
+var ToolVersion = "dev"
+
+// Custom metadata keys of uploaded objects
+const (
+	MetadataSource         = "backup-tools:source"
+	MetadataSourceID       = "backup-tools:source-id"
+	MetadataSourceModified = "backup-tools:source-modified"
+	MetadataContentHash    = "backup-tools:content-hash"
+	MetadataCRC32          = "backup-tools:crc32"
+	MetadataMimeType       = "backup-tools:mime-type"
+	MetadataJobID          = "backup-tools:job-id"
+	MetadataToolVersion    = "backup-tools:tool-version"
+	MetadataCompression    = "backup-tools:compression"
+	MetadataOriginalSize   = "backup-tools:original-size"
+)
+
+// ObjectMetadata describes where an uploaded object comes from. It is stored as custom
+// metadata with the object, so a backup can be restored without the synced_objects
+// catalog. Fields that are not known are left empty.
+type ObjectMetadata struct {
+	// Source is the provider the item was backed up from, e.g. "google" or "outlook"
+	Source string
+	// SourceID is the ID of the item at the provider
+	SourceID string
+	// SourceModified is the time the item was last modified at the provider, RFC 3339
+	SourceModified string
+	// ContentHash is "sha256:" followed by the hex digest of the content before
+	// compression. Uploads fill it in when they read the whole content.
+	ContentHash string
+	// CRC32 is the hex IEEE CRC-32 of the content before compression, filled in along
+	// with ContentHash. ZIP archives need it for entries they do not read.
+	CRC32 string
+	// MimeType is the type of the item at the provider
+	MimeType string
+	// JobID is the cron job, or "task-<id>" for the scheduled task, that made the backup
+	JobID       string
+	ToolVersion string
+	// Compression is the algorithm of pkg/compress the object is compressed with. Objects
+	// are decompressed transparently when they are opened.
+	Compression string
+	// OriginalSize is the size of the content before compression
+	OriginalSize int64
+}
+
+// Custom returns the metadata in the form uplink stores it
+func (m ObjectMetadata) Custom() uplink.CustomMetadata {
+	if m.ToolVersion == "" {
+		m.ToolVersion = ToolVersion
+	}
+
+	custom := uplink.CustomMetadata{}
+	for key, value := range map[string]string{
+		MetadataSource:         m.Source,
+		MetadataSourceID:       m.SourceID,
+		MetadataSourceModified: m.SourceModified,
+		MetadataContentHash:    m.ContentHash,
+		MetadataCRC32:          m.CRC32,
+		MetadataMimeType:       m.MimeType,
+		MetadataJobID:          m.JobID,
+		MetadataToolVersion:    m.ToolVersion,
+		MetadataCompression:    m.Compression,
+	} {
+		if value != "" {
+			custom[key] = value
+		}
+	}
+	if m.Compression != "" && m.OriginalSize > 0 {
+		custom[MetadataOriginalSize] = strconv.FormatInt(m.OriginalSize, 10)
+	}
+	return custom
+}
+
+// ParseObjectMetadata reads the metadata stored with an object. Objects uploaded before
+// metadata was recorded return an empty ObjectMetadata.
+func ParseObjectMetadata(custom uplink.CustomMetadata) ObjectMetadata {
+	originalSize, _ := strconv.ParseInt(custom[MetadataOriginalSize], 10, 64)
+	return ObjectMetadata{
+		Source:         custom[MetadataSource],
+		SourceID:       custom[MetadataSourceID],
+		SourceModified: custom[MetadataSourceModified],
+		ContentHash:    custom[MetadataContentHash],
+		CRC32:          custom[MetadataCRC32],
+		MimeType:       custom[MetadataMimeType],
+		JobID:          custom[MetadataJobID],
+		ToolVersion:    custom[MetadataToolVersion],
+		Compression:    custom[MetadataCompression],
+		OriginalSize:   originalSize,
+	}
+}
+
+// ContentCRC32 returns the recorded CRC32 of the content, if any
+func (m ObjectMetadata) ContentCRC32() (uint32, bool) {
+	if m.CRC32 == "" {
+		return 0, false
+	}
+	sum, err := strconv.ParseUint(m.CRC32, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(sum), true
+}
+
+// contentHasher hashes content while it is uploaded
+type contentHasher struct {
+	hash hash.Hash
+	crc  hash.Hash32
+}
+
+func newContentHasher() *contentHasher {
+	return &contentHasher{hash: sha256.New(), crc: crc32.NewIEEE()}
+}
+
+// reader returns r, hashing everything read from it
+func (h *contentHasher) reader(r io.Reader) io.Reader {
+	return io.TeeReader(r, io.MultiWriter(h.hash, h.crc))
+}
+
+// sum returns the hash in the form of ObjectMetadata.ContentHash
+func (h *contentHasher) sum() string {
+	return "sha256:" + hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// complete fills in the hash and the CRC32 of meta that are not known yet
+func (h *contentHasher) complete(meta *ObjectMetadata) {
+	if meta.ContentHash == "" {
+		meta.ContentHash = h.sum()
+	}
+	if meta.CRC32 == "" {
+		meta.CRC32 = fmt.Sprintf("%08x", h.crc.Sum32())
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
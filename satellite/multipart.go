@@ -0,0 +1,228 @@
+package satellite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+	"github.com/StorX2-0/Backup-Tools/pkg/logger"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"storj.io/uplink"
+)
+
+// defaultPartSizeMB is the size of a multipart upload part when
+// SATELLITE_MULTIPART_PART_SIZE_MB is unset. Objects up to one part are uploaded in one go.
+const defaultPartSizeMB = 64
+
+var (
+	partSizeOnce sync.Once
+	partSize     int64
+)
+
+// PartSize returns the size of the parts of multipart uploads
+func PartSize() int64 {
+	partSizeOnce.Do(func() {
+		mb := defaultPartSizeMB
+		if v := utils.GetEnvWithKey("SATELLITE_MULTIPART_PART_SIZE_MB"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				mb = n
+			} else {
+				logger.Warn(context.Background(), "Invalid SATELLITE_MULTIPART_PART_SIZE_MB value, using default",
+					logger.String("value", v), logger.Int("default", defaultPartSizeMB))
+			}
+		}
+		partSize = int64(mb) << 20
+	})
+	return partSize
+}
+
+// UploadCheckpoint is the progress of a multipart upload. It is stored in the task
+// checkpoint after every committed part, so a restarted task resumes the upload instead
+// of starting over.
+type UploadCheckpoint struct {
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key"`
+	UploadID string `json:"upload_id"`
+	// Version identifies the uploaded content, e.g. the source's modification time. An
+	// upload of other content is never resumed.
+	Version  string `json:"version,omitempty"`
+	PartSize int64  `json:"part_size"`
+	// Parts is the number of committed parts
+	Parts uint32 `json:"parts"`
+}
+
+// Offset is the number of bytes the committed parts hold
+func (c *UploadCheckpoint) Offset() int64 {
+	return int64(c.Parts) * c.PartSize
+}
+
+// matches reports whether the checkpoint belongs to the upload
+func (c *UploadCheckpoint) matches(u *ResumableUpload, partSize int64) bool {
+	return c.UploadID != "" && c.Bucket == u.Bucket && c.Key == u.Key &&
+		c.Version == u.Version && c.PartSize == partSize
+}
+
+// ResumableUpload describes an upload that survives restarts of the task running it
+type ResumableUpload struct {
+	Bucket  string
+	Key     string
+	Version string
+	// Size is the length of the content
+	Size int64
+	// Metadata is stored with the object once it is committed. Open returns content that
+	// is compressed already if Metadata names a compression; Metadata.OriginalSize is the
+	// size of that content before compression then.
+	Metadata ObjectMetadata
+	// Open returns the content starting at offset
+	Open func(ctx context.Context, offset int64) (io.ReadCloser, error)
+	// Checkpoint is the progress saved by an earlier attempt, or nil
+	Checkpoint *UploadCheckpoint
+	// Save persists the checkpoint after every committed part, and nil once the object
+	// is committed. An error stops the upload; the committed parts are kept.
+	Save func(*UploadCheckpoint) error
+}
+
+// UploadResumable uploads content larger than one part with uplink's multipart API,
+// resuming after the last committed part of a matching checkpoint. Smaller content is
+// uploaded in one go. It returns the size of the object.
+func (c *Client) UploadResumable(ctx context.Context, u ResumableUpload) (int64, error) {
+	size := PartSize()
+	if u.Size <= size {
+		r, err := u.Open(ctx, 0)
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+		return c.uploadStream(ctx, u.Bucket, u.Key, r, u.Metadata, compress.None)
+	}
+
+	checkpoint := u.Checkpoint
+	if checkpoint != nil && !checkpoint.matches(&u, size) {
+		c.AbortUpload(ctx, checkpoint)
+		checkpoint = nil
+	}
+
+	written, err := c.uploadParts(ctx, &u, checkpoint, size)
+	if errors.Is(err, uplink.ErrUploadIDInvalid) && checkpoint != nil {
+		// The upload expired or was aborted elsewhere
+		logger.Warn(ctx, "Multipart upload can not be resumed, starting over",
+			logger.String("bucket", u.Bucket),
+			logger.String("object_key", u.Key),
+			logger.ErrorField(err))
+		written, err = c.uploadParts(ctx, &u, nil, size)
+	}
+	return written, err
+}
+
+func (c *Client) uploadParts(ctx context.Context, u *ResumableUpload, checkpoint *UploadCheckpoint, partSize int64) (int64, error) {
+	if checkpoint == nil {
+		if err := c.EnsureBucket(ctx, u.Bucket); err != nil {
+			return 0, err
+		}
+		if err := c.wait(ctx); err != nil {
+			return 0, err
+		}
+		info, err := c.entry.project.BeginUpload(ctx, u.Bucket, u.Key, nil)
+		if err != nil {
+			c.forgetBucket(u.Bucket, err)
+			return 0, WrapError("begin upload", err)
+		}
+		checkpoint = &UploadCheckpoint{
+			Bucket:   u.Bucket,
+			Key:      u.Key,
+			UploadID: info.UploadID,
+			Version:  u.Version,
+			PartSize: partSize,
+		}
+		if err := u.Save(checkpoint); err != nil {
+			return 0, err
+		}
+	} else {
+		logger.Info(ctx, "Resuming multipart upload",
+			logger.String("bucket", u.Bucket),
+			logger.String("object_key", u.Key),
+			logger.Int("committed_parts", int(checkpoint.Parts)))
+	}
+
+	content, err := u.Open(ctx, checkpoint.Offset())
+	if err != nil {
+		return checkpoint.Offset(), err
+	}
+	defer content.Close()
+
+	// Only an upload read from the start can hash the whole content, and only uncompressed
+	// content is hashed
+	meta := u.Metadata
+	var r io.Reader = content
+	var hasher *contentHasher
+	if (meta.ContentHash == "" || meta.CRC32 == "") && meta.Compression == compress.None && checkpoint.Parts == 0 {
+		hasher = newContentHasher()
+		r = hasher.reader(content)
+	}
+
+	for checkpoint.Offset() < u.Size {
+		if err := c.uploadPart(ctx, checkpoint, r, min(partSize, u.Size-checkpoint.Offset())); err != nil {
+			return checkpoint.Offset(), err
+		}
+		checkpoint.Parts++
+		if err := u.Save(checkpoint); err != nil {
+			return checkpoint.Offset(), err
+		}
+	}
+
+	if err := c.wait(ctx); err != nil {
+		return u.Size, err
+	}
+	if hasher != nil {
+		hasher.complete(&meta)
+	}
+	object, err := c.entry.project.CommitUpload(ctx, u.Bucket, u.Key, checkpoint.UploadID, &uplink.CommitUploadOptions{
+		CustomMetadata: meta.Custom(),
+	})
+	if err != nil {
+		return u.Size, WrapError("commit upload", err)
+	}
+	return object.System.ContentLength, u.Save(nil)
+}
+
+// uploadPart uploads the next part of the checkpoint, which is the following length bytes
+// of r
+func (c *Client) uploadPart(ctx context.Context, checkpoint *UploadCheckpoint, r io.Reader, length int64) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	// Part numbers start at 1
+	part, err := c.entry.project.UploadPart(ctx, checkpoint.Bucket, checkpoint.Key, checkpoint.UploadID, checkpoint.Parts+1)
+	if err != nil {
+		return WrapError("upload part", err)
+	}
+
+	// A short part would shift every following part, so the source must deliver all of it
+	if _, err := io.CopyN(part, r, length); err != nil {
+		_ = part.Abort()
+		return WrapError("upload data", err)
+	}
+	if err := part.Commit(); err != nil {
+		return WrapError("commit part", err)
+	}
+	return nil
+}
+
+// AbortUpload drops the parts of a checkpoint that can not be resumed
+func (c *Client) AbortUpload(ctx context.Context, checkpoint *UploadCheckpoint) {
+	if checkpoint.UploadID == "" {
+		return
+	}
+	if err := c.wait(ctx); err != nil {
+		return
+	}
+	if err := c.entry.project.AbortUpload(ctx, checkpoint.Bucket, checkpoint.Key, checkpoint.UploadID); err != nil {
+		logger.Warn(ctx, "Failed to abort stale multipart upload",
+			logger.String("bucket", checkpoint.Bucket),
+			logger.String("object_key", checkpoint.Key),
+			logger.ErrorField(err))
+	}
+}
@@ -12,10 +12,10 @@ import (
 
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/ratelimit"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
-	"storj.io/common/grant"
 	"storj.io/uplink"
 )
 
@@ -34,6 +34,14 @@ const (
 
 var StorxSatelliteService string
 
+// httpClient is used for the calls to the StorX satellite service made by backups and
+// notifications. Its requests share the service's rate limit.
+var httpClient = ratelimit.WrapClient(&http.Client{Timeout: 30 * time.Second}, ratelimit.Fixed("storx"), "")
+
+// authClient looks up the user of an API request. Every authenticated request does, so
+// it is not rate limited; a shared limit would throttle the whole API for all users.
+var authClient = &http.Client{Timeout: 30 * time.Second}
+
 // HandleSatelliteAuthentication authenticates app with satellite account
 func HandleSatelliteAuthentication(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -57,103 +65,60 @@ func HandleSatelliteAuthentication(c echo.Context) error {
 	})
 }
 
-// GetUploader creates an uploader for the specified bucket and object
-func GetUploader(ctx context.Context, accessGrant, bucketName, objectKey string) (*uplink.Upload, error) {
-
-	access, err := uplink.ParseAccess(accessGrant)
-	if err != nil {
-		return nil, fmt.Errorf("parse access grant: %w", err)
-	}
-
-	testAccessParse, err := grant.ParseAccess(accessGrant)
-	if err != nil {
-		return nil, fmt.Errorf("parse access grant: %w", err)
-	}
-
-	logger.Info(ctx, "access details",
-		logger.String("satellite", testAccessParse.SatelliteAddress),
-		logger.String("api_key", testAccessParse.APIKey.Serialize()))
-
-	project, err := uplink.OpenProject(ctx, access)
-	if err != nil {
-		return nil, fmt.Errorf("open project: %w", err)
-	}
-	defer project.Close()
-
-	_, err = project.EnsureBucket(ctx, bucketName)
-	if err != nil {
-		_, err = project.CreateBucket(ctx, bucketName)
-		if err != nil {
-			return nil, fmt.Errorf("create bucket: %w", err)
-		}
-	} else {
-	}
-
-	logger.Info(ctx, "Uploading object",
-		logger.String("bucket", bucketName),
-		logger.String("object", objectKey))
-
-	upload, err := project.UploadObject(ctx, bucketName, objectKey, nil)
-	if err != nil {
-		return nil, fmt.Errorf("initiate upload: %w", err)
-	}
-
-	return upload, nil
-}
-
 // UploadObject uploads data to satellite storage
-func UploadObject(ctx context.Context, accessGrant, bucketName, objectKey string, data []byte) error {
-
-	upload, err := GetUploader(ctx, accessGrant, bucketName, objectKey)
-	if err != nil {
-		return err
-	}
-
-	buf := bytes.NewBuffer(data)
-	_, err = io.Copy(upload, buf)
-	if err != nil {
-		_ = upload.Abort()
-		return fmt.Errorf("upload data: %w", err)
-	}
+func UploadObject(ctx context.Context, accessGrant, bucketName, objectKey string, data []byte, meta ObjectMetadata) error {
+	_, err := UploadStream(ctx, accessGrant, bucketName, objectKey, bytes.NewReader(data), meta)
+	return err
+}
 
-	err = upload.Commit()
+// UploadStream uploads everything read from r to the storage backend and returns the
+// number of bytes uploaded
+func UploadStream(ctx context.Context, accessGrant, bucketName, objectKey string, r io.Reader, meta ObjectMetadata) (int64, error) {
+	store, err := OpenStore(ctx, accessGrant)
 	if err != nil {
-		return fmt.Errorf("commit object: %w", err)
+		return 0, err
 	}
+	defer store.Close()
 
-	return nil
+	return store.Put(ctx, bucketName, objectKey, r, meta)
 }
 
-// DownloadObject downloads data from satellite storage
+// DownloadObject downloads data from the storage backend. Prefer DownloadStream or
+// OpenObject for objects of unbounded size.
 func DownloadObject(ctx context.Context, accessGrant, bucketName, objectKey string) ([]byte, error) {
-	access, err := uplink.ParseAccess(accessGrant)
-	if err != nil {
-		return nil, fmt.Errorf("parse access grant: %w", err)
-	}
-
-	project, err := uplink.OpenProject(ctx, access)
-	if err != nil {
-		return nil, fmt.Errorf("open project: %w", err)
+	var buf bytes.Buffer
+	if _, err := DownloadStream(ctx, accessGrant, bucketName, objectKey, &buf); err != nil {
+		return nil, err
 	}
-	defer project.Close()
+	return buf.Bytes(), nil
+}
 
-	_, err = project.EnsureBucket(ctx, bucketName)
+// DownloadStream writes an object from the storage backend to w and returns the number of
+// bytes written
+func DownloadStream(ctx context.Context, accessGrant, bucketName, objectKey string, w io.Writer) (int64, error) {
+	download, err := OpenObject(ctx, accessGrant, bucketName, objectKey)
 	if err != nil {
-		return nil, fmt.Errorf("ensure bucket: %w", err)
+		return 0, err
 	}
+	defer download.Close()
 
-	download, err := project.DownloadObject(ctx, bucketName, objectKey, nil)
+	written, err := io.Copy(w, download)
 	if err != nil {
-		return nil, fmt.Errorf("open object: %w", err)
+		return written, WrapError("read data", err)
 	}
-	defer download.Close()
+	return written, nil
+}
 
-	receivedContents, err := io.ReadAll(download)
+// OpenObject opens an object in the storage backend for reading. The reader must be
+// closed.
+func OpenObject(ctx context.Context, accessGrant, bucketName, objectKey string) (ObjectReader, error) {
+	store, err := OpenStore(ctx, accessGrant)
 	if err != nil {
-		return nil, fmt.Errorf("read data: %w", err)
+		return nil, err
 	}
+	defer store.Close()
 
-	return receivedContents, nil
+	return store.Get(ctx, bucketName, objectKey)
 }
 
 // ListObjects lists all objects in a bucket
@@ -163,113 +128,69 @@ func ListObjects(ctx context.Context, accessGrant, bucketName string) (map[strin
 
 // ListObjectsWithPrefix lists objects with a specific prefix
 func ListObjectsWithPrefix(ctx context.Context, accessGrant, bucketName, prefix string) (map[string]bool, error) {
-	access, err := uplink.ParseAccess(accessGrant)
-	if err != nil {
-		return nil, fmt.Errorf("parse access grant: %w", err)
-	}
-
-	project, err := uplink.OpenProject(ctx, access)
-	if err != nil {
-		return nil, fmt.Errorf("open project: %w", err)
-	}
-	defer project.Close()
-
-	_, err = project.EnsureBucket(ctx, bucketName)
+	list, err := listObjects(ctx, accessGrant, bucketName, prefix, false)
 	if err != nil {
-		return nil, fmt.Errorf("ensure bucket: %w", err)
-	}
-
-	listIter := project.ListObjects(ctx, bucketName, &uplink.ListObjectsOptions{
-		Prefix: prefix,
-	})
-
-	objects := make(map[string]bool)
-	for listIter.Next() {
-		objects[listIter.Item().Key] = true
+		return nil, err
 	}
 
-	if err := listIter.Err(); err != nil {
-		return nil, fmt.Errorf("list objects: %w", err)
+	objects := make(map[string]bool, len(list))
+	for _, object := range list {
+		objects[object.Key] = true
 	}
-
 	return objects, nil
 }
 
 // ListObjectsDetailed returns detailed object information
 func ListObjectsDetailed(ctx context.Context, accessGrant, bucketName string) ([]uplink.Object, error) {
-	return listObjectsWithOptions(ctx, accessGrant, bucketName, &uplink.ListObjectsOptions{})
+	return listObjects(ctx, accessGrant, bucketName, "", false)
 }
 
 // GetFilesInFolder lists objects with a specific prefix
 func GetFilesInFolder(ctx context.Context, accessGrant, bucketName, prefix string) ([]uplink.Object, error) {
-	return listObjectsWithOptions(ctx, accessGrant, bucketName, &uplink.ListObjectsOptions{
-		Prefix: prefix,
-	})
+	return listObjects(ctx, accessGrant, bucketName, prefix, false)
 }
 
 // ListObjectsRecursive lists all objects recursively
 func ListObjectsRecursive(ctx context.Context, accessGrant, bucketName string) ([]uplink.Object, error) {
-	return listObjectsWithOptions(ctx, accessGrant, bucketName, &uplink.ListObjectsOptions{
-		Recursive: true,
-	})
+	return listObjects(ctx, accessGrant, bucketName, "", true)
 }
 
-// listObjectsWithOptions helper function for listing objects with options
-func listObjectsWithOptions(ctx context.Context, accessGrant, bucketName string, options *uplink.ListObjectsOptions) ([]uplink.Object, error) {
-	access, err := uplink.ParseAccess(accessGrant)
+// listObjects lists the objects of a bucket in the form uplink returns them, whatever
+// the storage backend. Snapshot manifests are left out; they are no backed up items.
+func listObjects(ctx context.Context, accessGrant, bucketName, prefix string, recursive bool) ([]uplink.Object, error) {
+	store, err := OpenStore(ctx, accessGrant)
 	if err != nil {
-		return nil, fmt.Errorf("parse access grant: %w", err)
+		return nil, err
 	}
+	defer store.Close()
 
-	project, err := uplink.OpenProject(ctx, access)
-	if err != nil {
-		return nil, fmt.Errorf("open project: %w", err)
+	if err := store.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, err
 	}
-	defer project.Close()
-
-	_, err = project.EnsureBucket(ctx, bucketName)
+	list, err := store.List(ctx, bucketName, prefix, recursive)
 	if err != nil {
-		return nil, fmt.Errorf("ensure bucket: %w", err)
+		return nil, err
 	}
 
-	listIter := project.ListObjects(ctx, bucketName, options)
-	var objects []uplink.Object
-
-	for listIter.Next() {
-		objects = append(objects, *listIter.Item())
-	}
-
-	if err := listIter.Err(); err != nil {
-		return nil, fmt.Errorf("list objects: %w", err)
+	objects := make([]uplink.Object, 0, len(list))
+	for _, info := range list {
+		if strings.HasPrefix(info.Key, SnapshotPrefix) {
+			continue
+		}
+		objects = append(objects, info.uplinkObject())
 	}
-
 	return objects, nil
 }
 
-// DeleteObject deletes an object from satellite storage
+// DeleteObject deletes an object from the storage backend
 func DeleteObject(ctx context.Context, accessGrant, bucketName, objectKey string) error {
-	access, err := uplink.ParseAccess(accessGrant)
-	if err != nil {
-		return fmt.Errorf("parse access grant: %w", err)
-	}
-
-	project, err := uplink.OpenProject(ctx, access)
+	store, err := OpenStore(ctx, accessGrant)
 	if err != nil {
-		return fmt.Errorf("open project: %w", err)
-	}
-	defer project.Close()
-
-	_, err = project.EnsureBucket(ctx, bucketName)
-	if err != nil {
-		return fmt.Errorf("ensure bucket: %w", err)
-	}
-
-	_, err = project.DeleteObject(ctx, bucketName, objectKey)
-	if err != nil {
-		return fmt.Errorf("delete object: %w", err)
+		return err
 	}
+	defer store.Close()
 
-	return nil
+	return store.Delete(ctx, bucketName, objectKey)
 }
 
 // GetUserdetails retrieves user details from satellite service
@@ -285,8 +206,7 @@ func GetUserdetails(c echo.Context) (string, error) {
 	req.Header.Set("accept", "application/json")
 	req.Header.Set("cookie", "_tokenKey="+tokenKey)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	res, err := client.Do(req)
+	res, err := authClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("send request: %w", err)
 	}
@@ -340,8 +260,7 @@ func GetProjectIDFromAccessGrant(ctx context.Context, accessGrant string) (strin
 	req.Header.Set("accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	res, err := client.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		return "", nil
 	}
@@ -427,8 +346,7 @@ func SendEmailForBackupFailure(ctx context.Context, email, errorMsg, method stri
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	res, err := client.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("send request: %w", err)
 	}
@@ -542,8 +460,7 @@ func SendNotification(ctx context.Context, userID, title, body string, priority
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	res, err := client.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("send request: %w", err)
 	}
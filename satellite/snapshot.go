@@ -0,0 +1,199 @@
+package satellite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+)
+
+// SnapshotPrefix is the prefix of the manifest objects in a bucket. Manifests describe
+// backups but are no backed up items, so catalogs and manifests leave them out.
+const SnapshotPrefix = ".snapshots/"
+
+// ManifestVersion is the version of the manifest format written
+const ManifestVersion = 1
+
+// Manifest is a listing of the backed up items in part of a bucket, taken when a backup
+// run finished. It holds every item there at that time, including those uploaded by
+// earlier runs that the run did not touch.
+type Manifest struct {
+	Version int    `json:"version"`
+	Bucket  string `json:"bucket"`
+	Prefix  string `json:"prefix"`
+	// JobID restricts the manifest to the objects uploaded by one job, see ObjectMetadata
+	JobID     string         `json:"job_id,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	Items     []ManifestItem `json:"items"`
+	// ObjectsPrefix is where the content of the items is preserved, under their hashes,
+	// so items can be restored after their objects were replaced. Manifests written
+	// before content was preserved have none.
+	ObjectsPrefix string `json:"objects_prefix,omitempty"`
+}
+
+// PreservedKey returns the key the content of item is preserved under. Items without a
+// hash are not preserved.
+func (m *Manifest) PreservedKey(item ManifestItem) (string, bool) {
+	digest, ok := strings.CutPrefix(item.Hash, "sha256:")
+	if m.ObjectsPrefix == "" || !ok || digest == "" {
+		return "", false
+	}
+	return m.ObjectsPrefix + digest, true
+}
+
+// PreserveItems copies the content of the items of m under m.ObjectsPrefix, unless a
+// copy with the same hash is there already. A restore copies it back once the item's
+// object was replaced or deleted.
+func PreserveItems(ctx context.Context, store ObjectStore, m *Manifest) error {
+	if m.ObjectsPrefix == "" {
+		return nil
+	}
+	existing, err := store.List(ctx, m.Bucket, m.ObjectsPrefix, true)
+	if err != nil {
+		return fmt.Errorf("failed to list preserved objects: %w", err)
+	}
+	preserved := make(map[string]bool, len(existing))
+	for _, object := range existing {
+		preserved[object.Key] = true
+	}
+
+	for _, item := range m.Items {
+		key, ok := m.PreservedKey(item)
+		if !ok || preserved[key] {
+			continue
+		}
+		if err := store.Copy(ctx, m.Bucket, item.Key, key); err != nil {
+			if errors.Is(err, ErrObjectNotFound) {
+				continue
+			}
+			return fmt.Errorf("failed to preserve %s: %w", item.Key, err)
+		}
+		// The object may have been replaced since it was listed; a copy of other
+		// content must not be kept under the item's hash
+		copied, err := store.Stat(ctx, m.Bucket, key)
+		if err != nil {
+			return fmt.Errorf("failed to preserve %s: %w", item.Key, err)
+		}
+		if copied.Metadata.ContentHash != item.Hash {
+			if err := store.Delete(ctx, m.Bucket, key); err != nil {
+				return fmt.Errorf("failed to preserve %s: %w", item.Key, err)
+			}
+			continue
+		}
+		preserved[key] = true
+	}
+	return nil
+}
+
+// ManifestItem is one backed up item of a manifest
+type ManifestItem struct {
+	Key      string `json:"key"`
+	SourceID string `json:"source_id,omitempty"`
+	// Size is the size of the content before compression
+	Size int64 `json:"size"`
+	// Hash is the ContentHash of the object, empty for objects uploaded before it was
+	// recorded
+	Hash     string `json:"hash,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// NewManifestItem describes a listed object
+func NewManifestItem(object ObjectInfo) ManifestItem {
+	size := object.Size
+	if object.Metadata.Compression != compress.None && object.Metadata.OriginalSize > 0 {
+		size = object.Metadata.OriginalSize
+	}
+	return ManifestItem{
+		Key:      object.Key,
+		SourceID: object.Metadata.SourceID,
+		Size:     size,
+		Hash:     object.Metadata.ContentHash,
+		Modified: object.Metadata.SourceModified,
+	}
+}
+
+// Matches reports whether object still holds the content the item recorded. The hashes
+// are compared when both are known, the sizes otherwise.
+func (item ManifestItem) Matches(object ObjectInfo) bool {
+	current := NewManifestItem(object)
+	if item.Hash != "" && current.Hash != "" {
+		return item.Hash == current.Hash
+	}
+	return item.Size == current.Size
+}
+
+// ListManifest lists the objects under prefix of a bucket into a manifest. With a jobID
+// only the objects uploaded by that job are listed.
+func ListManifest(ctx context.Context, store ObjectStore, bucketName, prefix, jobID string, now time.Time) (*Manifest, error) {
+	objects, err := store.List(ctx, bucketName, prefix, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects for manifest: %w", err)
+	}
+
+	manifest := &Manifest{
+		Version:   ManifestVersion,
+		Bucket:    bucketName,
+		Prefix:    prefix,
+		JobID:     jobID,
+		CreatedAt: now.UTC(),
+		Items:     []ManifestItem{},
+	}
+	for _, object := range objects {
+		if object.IsPrefix || strings.HasPrefix(object.Key, SnapshotPrefix) {
+			continue
+		}
+		if jobID != "" && object.Metadata.JobID != jobID {
+			continue
+		}
+		manifest.Items = append(manifest.Items, NewManifestItem(object))
+	}
+	return manifest, nil
+}
+
+// TotalSize returns the size of all items of the manifest
+func (m *Manifest) TotalSize() int64 {
+	var total int64
+	for _, item := range m.Items {
+		total += item.Size
+	}
+	return total
+}
+
+// WriteManifest stores m under objectKey of its bucket. The manifest is compressed and
+// carries no job ID, so retention policies never remove it.
+func WriteManifest(ctx context.Context, store ObjectStore, objectKey string, m *Manifest) error {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(json.NewEncoder(w).Encode(m))
+	}()
+	defer r.Close()
+
+	if _, err := store.Put(ctx, m.Bucket, objectKey, r, ObjectMetadata{
+		Source:      "backup-tools",
+		MimeType:    "application/json",
+		Compression: compress.Gzip,
+	}); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest reads the manifest stored under objectKey of a bucket
+func ReadManifest(ctx context.Context, store ObjectStore, bucketName, objectKey string) (*Manifest, error) {
+	r, err := store.Get(ctx, bucketName, objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer r.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return &manifest, nil
+}
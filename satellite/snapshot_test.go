@@ -0,0 +1,107 @@
+package satellite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.NoError(t, store.EnsureBucket(ctx, "google-drive"))
+
+	put := func(key, content string, meta ObjectMetadata) {
+		_, err := store.Put(ctx, "google-drive", key, strings.NewReader(content), meta)
+		require.NoError(t, err)
+	}
+	put("user@example.com/report.pdf", strings.Repeat("report ", 100), ObjectMetadata{SourceID: "f1", Compression: compress.Gzip, JobID: "task-1"})
+	put("user@example.com/notes.txt", "notes", ObjectMetadata{SourceID: "f2", JobID: "task-2"})
+	put("other@example.com/notes.txt", "other", ObjectMetadata{})
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	manifest, err := ListManifest(ctx, store, "google-drive", "user@example.com/", "", now)
+	require.NoError(t, err)
+	require.Len(t, manifest.Items, 2)
+	assert.Equal(t, "user@example.com/notes.txt", manifest.Items[0].Key)
+	assert.Equal(t, "f2", manifest.Items[0].SourceID)
+	assert.NotEmpty(t, manifest.Items[0].Hash)
+	// Sizes are those of the content, not of the compressed object
+	assert.Equal(t, int64(700), manifest.Items[1].Size)
+	assert.Equal(t, int64(705), manifest.TotalSize())
+
+	const key = SnapshotPrefix + "task-1.json"
+	require.NoError(t, WriteManifest(ctx, store, key, manifest))
+	read, err := ReadManifest(ctx, store, "google-drive", key)
+	require.NoError(t, err)
+	assert.Equal(t, manifest, read)
+
+	// Manifests are not part of later manifests
+	manifest, err = ListManifest(ctx, store, "google-drive", "", "", now)
+	require.NoError(t, err)
+	assert.Len(t, manifest.Items, 3)
+
+	manifest, err = ListManifest(ctx, store, "google-drive", "", "task-2", now)
+	require.NoError(t, err)
+	require.Len(t, manifest.Items, 1)
+	item := manifest.Items[0]
+
+	info, err := store.Stat(ctx, "google-drive", item.Key)
+	require.NoError(t, err)
+	assert.True(t, item.Matches(*info))
+
+	put(item.Key, "changed", ObjectMetadata{})
+	info, err = store.Stat(ctx, "google-drive", item.Key)
+	require.NoError(t, err)
+	assert.False(t, item.Matches(*info))
+}
+
+func TestPreserveItems(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.NoError(t, store.EnsureBucket(ctx, "google-drive"))
+
+	put := func(key, content string) {
+		_, err := store.Put(ctx, "google-drive", key, strings.NewReader(content), ObjectMetadata{})
+		require.NoError(t, err)
+	}
+	put("user@example.com/a.txt", "first")
+	put("user@example.com/b.txt", "first")
+
+	manifest, err := ListManifest(ctx, store, "google-drive", "user@example.com/", "", time.Now())
+	require.NoError(t, err)
+	manifest.ObjectsPrefix = SnapshotPrefix + "objects/user@example.com/"
+	require.NoError(t, PreserveItems(ctx, store, manifest))
+
+	// Items with the same content share one copy
+	preserved, err := store.List(ctx, "google-drive", manifest.ObjectsPrefix, true)
+	require.NoError(t, err)
+	require.Len(t, preserved, 1)
+
+	// The copy outlives the object it was taken from
+	put("user@example.com/a.txt", "second")
+	key, ok := manifest.PreservedKey(manifest.Items[0])
+	require.True(t, ok)
+	r, err := store.Get(ctx, "google-drive", key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "first", string(data))
+
+	// An object replaced after it was listed is not kept under the listed hash
+	manifest.Items = append(manifest.Items, ManifestItem{Key: "user@example.com/a.txt", Hash: "sha256:0000"})
+	require.NoError(t, PreserveItems(ctx, store, manifest))
+	_, err = store.Stat(ctx, "google-drive", manifest.ObjectsPrefix+"0000")
+	assert.True(t, errors.Is(err, ErrObjectNotFound))
+
+	_, ok = (&Manifest{}).PreservedKey(manifest.Items[0])
+	assert.False(t, ok, "manifests without preserved content")
+}
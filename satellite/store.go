@@ -0,0 +1,282 @@
+package satellite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+	"github.com/StorX2-0/Backup-Tools/pkg/utils"
+	"storj.io/uplink"
+)
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	BackendUplink     = "uplink"
+	BackendFilesystem = "filesystem"
+	BackendMemory     = "memory"
+)
+
+// defaultFilesystemRoot is where the filesystem backend keeps objects when
+// STORAGE_FILESYSTEM_ROOT is unset
+const defaultFilesystemRoot = "./storage"
+
+// ErrObjectNotFound is returned by every backend for objects that do not exist
+var ErrObjectNotFound = uplink.ErrObjectNotFound
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key string
+	// IsPrefix marks a common prefix of a listing that is not recursive
+	IsPrefix bool
+	// Size is the stored size, i.e. after compression
+	Size     int64
+	Created  time.Time
+	Metadata ObjectMetadata
+}
+
+// ObjectReader is an object being read. Compressed objects are decompressed while they
+// are read.
+type ObjectReader interface {
+	io.ReadCloser
+	Metadata() ObjectMetadata
+}
+
+// ObjectStore stores the objects of one access grant. The uplink Client is the default
+// implementation; FilesystemStore and MemoryStore run the service without a satellite.
+type ObjectStore interface {
+	// EnsureBucket creates the bucket if it does not exist
+	EnsureBucket(ctx context.Context, bucketName string) error
+	// Put uploads everything read from r, compressed as meta says, and returns the number
+	// of bytes stored
+	Put(ctx context.Context, bucketName, objectKey string, r io.Reader, meta ObjectMetadata) (int64, error)
+	// Get opens an object for reading. The reader must be closed.
+	Get(ctx context.Context, bucketName, objectKey string) (ObjectReader, error)
+	Stat(ctx context.Context, bucketName, objectKey string) (*ObjectInfo, error)
+	// List lists the objects under prefix. Unless recursive, keys below the next "/" are
+	// collapsed into one prefix entry.
+	List(ctx context.Context, bucketName, prefix string, recursive bool) ([]ObjectInfo, error)
+	Delete(ctx context.Context, bucketName, objectKey string) error
+	// UpdateMetadata replaces the metadata stored with an object
+	UpdateMetadata(ctx context.Context, bucketName, objectKey string, meta ObjectMetadata) error
+	// Copy copies an object with its metadata to another key of the bucket, replacing
+	// what is stored there
+	Copy(ctx context.Context, bucketName, fromKey, toKey string) error
+	// Close releases the store; the objects stay
+	Close() error
+}
+
+// ResumableStore is implemented by stores that upload in parts that survive a restart
+type ResumableStore interface {
+	UploadResumable(ctx context.Context, u ResumableUpload) (int64, error)
+	AbortUpload(ctx context.Context, checkpoint *UploadCheckpoint)
+}
+
+// AbortUpload drops the committed parts of an upload that will not be resumed. Stores
+// without a multipart API keep no parts.
+func AbortUpload(ctx context.Context, store ObjectStore, checkpoint *UploadCheckpoint) {
+	if resumable, ok := store.(ResumableStore); ok {
+		resumable.AbortUpload(ctx, checkpoint)
+	}
+}
+
+// UploadResumable uploads u with the multipart API of the store, or in one go if the
+// store has none
+func UploadResumable(ctx context.Context, store ObjectStore, u ResumableUpload) (int64, error) {
+	if resumable, ok := store.(ResumableStore); ok {
+		return resumable.UploadResumable(ctx, u)
+	}
+
+	r, err := u.Open(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	// The content is compressed already
+	meta := u.Metadata
+	compression := meta.Compression
+	meta.Compression = compress.None
+	written, err := store.Put(ctx, u.Bucket, u.Key, r, meta)
+	if err != nil {
+		return written, err
+	}
+	if compression != compress.None {
+		meta.Compression = compression
+		if err := store.UpdateMetadata(ctx, u.Bucket, u.Key, meta); err != nil {
+			return written, err
+		}
+	}
+	return written, u.Save(nil)
+}
+
+var (
+	backendOnce sync.Once
+	backend     string
+	memoryMu    sync.Mutex
+	memory      = map[string]*MemoryStore{}
+)
+
+// Backend returns the storage backend selected with STORAGE_BACKEND
+func Backend() string {
+	backendOnce.Do(func() {
+		backend = strings.ToLower(strings.TrimSpace(utils.GetEnvWithKey("STORAGE_BACKEND")))
+		if backend == "" {
+			backend = BackendUplink
+		}
+	})
+	return backend
+}
+
+// OpenStore returns the object store of accessGrant on the configured backend. The store
+// must be closed. The filesystem and memory backends keep the objects of every access
+// grant apart, like the satellite does.
+func OpenStore(ctx context.Context, accessGrant string) (ObjectStore, error) {
+	switch Backend() {
+	case BackendUplink:
+		return NewClient(ctx, accessGrant)
+	case BackendFilesystem:
+		root := utils.GetEnvWithKey("STORAGE_FILESYSTEM_ROOT")
+		if root == "" {
+			root = defaultFilesystemRoot
+		}
+		return NewFilesystemStore(filepath.Join(root, grantKey(accessGrant)[:16]))
+	case BackendMemory:
+		memoryMu.Lock()
+		defer memoryMu.Unlock()
+		key := grantKey(accessGrant)
+		if memory[key] == nil {
+			memory[key] = NewMemoryStore()
+		}
+		return memory[key], nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", Backend())
+	}
+}
+
+// encodeContent returns r compressed with algorithm, and a function that completes meta
+// with the hash and the size of the content once all of it was read. Content that is
+// compressed already is stored as it is.
+func encodeContent(r io.Reader, meta ObjectMetadata, algorithm string) (io.ReadCloser, func() ObjectMetadata, error) {
+	hasher := newContentHasher()
+	original := &countingReader{r: hasher.reader(r)}
+	content, err := compress.Reader(original, algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return content, func() ObjectMetadata {
+		// Compressed content that was handed in is not hashed
+		if algorithm == meta.Compression {
+			hasher.complete(&meta)
+		}
+		if algorithm != compress.None {
+			meta.OriginalSize = original.n
+		}
+		return meta
+	}, nil
+}
+
+// ErrContentSizeUnknown is returned for compressed objects that were uploaded before their
+// original size was recorded. Only reading them tells their size.
+var ErrContentSizeUnknown = errors.New("content size of object is not recorded")
+
+// ContentSize returns the number of bytes Get reads from object, or ErrContentSizeUnknown
+func ContentSize(object ObjectInfo) (int64, error) {
+	if object.Metadata.Compression == compress.None || object.Metadata.OriginalSize > 0 {
+		return NewManifestItem(object).Size, nil
+	}
+	return 0, fmt.Errorf("%s: %w", object.Key, ErrContentSizeUnknown)
+}
+
+// listKeys applies the listing rules of ObjectStore.List to the sorted keys of a bucket.
+// info returns the details of a key.
+func listKeys(keys []string, prefix string, recursive bool, info func(key string) ObjectInfo) []ObjectInfo {
+	var objects []ObjectInfo
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if !recursive {
+			if i := strings.Index(key[len(prefix):], "/"); i >= 0 {
+				sub := key[:len(prefix)+i+1]
+				if !seen[sub] {
+					seen[sub] = true
+					objects = append(objects, ObjectInfo{Key: sub, IsPrefix: true})
+				}
+				continue
+			}
+		}
+		objects = append(objects, info(key))
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects
+}
+
+// uplinkObject converts info to the form the listing endpoints return
+func (info ObjectInfo) uplinkObject() uplink.Object {
+	object := uplink.Object{
+		Key:      info.Key,
+		IsPrefix: info.IsPrefix,
+		System: uplink.SystemMetadata{
+			Created:       info.Created,
+			ContentLength: info.Size,
+		},
+	}
+	if info.Metadata != (ObjectMetadata{}) {
+		object.Custom = info.Metadata.Custom()
+	}
+	return object
+}
+
+// objectInfo converts an object listed or stated by uplink
+func objectInfo(object *uplink.Object) ObjectInfo {
+	return ObjectInfo{
+		Key:      object.Key,
+		IsPrefix: object.IsPrefix,
+		Size:     object.System.ContentLength,
+		Created:  object.System.Created,
+		Metadata: ParseObjectMetadata(object.Custom),
+	}
+}
+
+// objectReader is the ObjectReader of the filesystem and memory backends
+type objectReader struct {
+	content io.ReadCloser
+	closer  io.Closer
+	meta    ObjectMetadata
+}
+
+// newObjectReader decompresses r as meta says. closer is closed with the reader.
+func newObjectReader(r io.Reader, closer io.Closer, meta ObjectMetadata) (*objectReader, error) {
+	content, err := compress.NewReader(r, meta.Compression)
+	if err != nil {
+		return nil, err
+	}
+	return &objectReader{content: content, closer: closer, meta: meta}, nil
+}
+
+func (o *objectReader) Read(p []byte) (int, error) {
+	return o.content.Read(p)
+}
+
+func (o *objectReader) Metadata() ObjectMetadata {
+	return o.meta
+}
+
+func (o *objectReader) Close() error {
+	err := o.content.Close()
+	if o.closer != nil {
+		err = errors.Join(err, o.closer.Close())
+	}
+	return err
+}
+
+var _ ObjectStore = (*Client)(nil)
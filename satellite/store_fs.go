@@ -0,0 +1,340 @@
+package satellite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"storj.io/uplink"
+)
+
+// File name suffixes of the content and the metadata of an object. The suffixes keep the
+// object "a" apart from the directory holding "a/b".
+const (
+	dataSuffix     = ".data"
+	metadataSuffix = ".meta"
+)
+
+// FilesystemStore keeps objects as files under a root directory, one directory per
+// bucket. The metadata of an object is kept next to it as JSON in the form uplink stores
+// it. It is meant for development and for running without a satellite.
+type FilesystemStore struct {
+	root string
+}
+
+// NewFilesystemStore returns a store rooted at root, creating the directory if needed
+func NewFilesystemStore(root string) (*FilesystemStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, WrapError("open store", err)
+	}
+	return &FilesystemStore{root: root}, nil
+}
+
+// bucketPath returns the directory of a bucket
+func (s *FilesystemStore) bucketPath(bucketName string) (string, error) {
+	if bucketName == "" || bucketName == "." || bucketName == ".." || strings.ContainsAny(bucketName, `/\`) {
+		return "", fmt.Errorf("invalid bucket name %q", bucketName)
+	}
+	return filepath.Join(s.root, bucketName), nil
+}
+
+// objectPath returns the path of an object without its suffix. Keys that do not map to a
+// path inside the bucket are rejected.
+func (s *FilesystemStore) objectPath(bucketName, objectKey string) (string, error) {
+	dir, err := s.bucketPath(bucketName)
+	if err != nil {
+		return "", err
+	}
+	for _, segment := range strings.Split(objectKey, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, `\`) {
+			return "", fmt.Errorf("object key %q is not supported by the filesystem backend", objectKey)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(objectKey)), nil
+}
+
+// EnsureBucket creates the directory of the bucket
+func (s *FilesystemStore) EnsureBucket(ctx context.Context, bucketName string) error {
+	dir, err := s.bucketPath(bucketName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return WrapError("ensure bucket", err)
+	}
+	return nil
+}
+
+// Put writes the object to a temporary file first, so readers never see a partial object
+func (s *FilesystemStore) Put(ctx context.Context, bucketName, objectKey string, r io.Reader, meta ObjectMetadata) (int64, error) {
+	path, err := s.objectPath(bucketName, objectKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, WrapError("upload data", err)
+	}
+
+	content, finish, err := encodeContent(r, meta, meta.Compression)
+	if err != nil {
+		return 0, WrapError("upload data", err)
+	}
+	defer content.Close()
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, WrapError("upload data", err)
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, WrapError("upload data", err)
+	}
+
+	if err := s.writeMetadata(path, finish()); err != nil {
+		return written, err
+	}
+	if err := os.Rename(file.Name(), path+dataSuffix); err != nil {
+		return written, WrapError("commit object", err)
+	}
+	return written, nil
+}
+
+// writeMetadata replaces the metadata file of the object at path
+func (s *FilesystemStore) writeMetadata(path string, meta ObjectMetadata) error {
+	data, err := json.Marshal(meta.Custom())
+	if err != nil {
+		return WrapError("set metadata", err)
+	}
+	temp := path + metadataSuffix + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return WrapError("set metadata", err)
+	}
+	if err := os.Rename(temp, path+metadataSuffix); err != nil {
+		_ = os.Remove(temp)
+		return WrapError("set metadata", err)
+	}
+	return nil
+}
+
+// readMetadata reads the metadata file of the object at path. Objects without one have
+// empty metadata.
+func (s *FilesystemStore) readMetadata(path string) (ObjectMetadata, error) {
+	data, err := os.ReadFile(path + metadataSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectMetadata{}, nil
+	}
+	if err != nil {
+		return ObjectMetadata{}, err
+	}
+
+	var custom uplink.CustomMetadata
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return ObjectMetadata{}, err
+	}
+	return ParseObjectMetadata(custom), nil
+}
+
+// Get opens the file of the object
+func (s *FilesystemStore) Get(ctx context.Context, bucketName, objectKey string) (ObjectReader, error) {
+	path, err := s.objectPath(bucketName, objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path + dataSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, WrapError("open object", ErrObjectNotFound)
+	}
+	if err != nil {
+		return nil, WrapError("open object", err)
+	}
+
+	meta, err := s.readMetadata(path)
+	if err != nil {
+		_ = file.Close()
+		return nil, WrapError("open object", err)
+	}
+	reader, err := newObjectReader(file, file, meta)
+	if err != nil {
+		_ = file.Close()
+		return nil, WrapError("open object", err)
+	}
+	return reader, nil
+}
+
+// Stat returns the details of the object
+func (s *FilesystemStore) Stat(ctx context.Context, bucketName, objectKey string) (*ObjectInfo, error) {
+	path, err := s.objectPath(bucketName, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.stat(objectKey, path)
+	if err != nil {
+		return nil, WrapError("stat object", err)
+	}
+	return info, nil
+}
+
+func (s *FilesystemStore) stat(objectKey, path string) (*ObjectInfo, error) {
+	fi, err := os.Stat(path + dataSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := s.readMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Key:      objectKey,
+		Size:     fi.Size(),
+		Created:  fi.ModTime(),
+		Metadata: meta,
+	}, nil
+}
+
+// List walks the directory of the bucket
+func (s *FilesystemStore) List(ctx context.Context, bucketName, prefix string, recursive bool) ([]ObjectInfo, error) {
+	dir, err := s.bucketPath(bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, dataSuffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, strings.TrimSuffix(path, dataSuffix))
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, WrapError("list objects", err)
+	}
+	sort.Strings(keys)
+
+	var statErr error
+	objects := listKeys(keys, prefix, recursive, func(key string) ObjectInfo {
+		info, err := s.stat(key, filepath.Join(dir, filepath.FromSlash(key)))
+		if err != nil {
+			statErr = errors.Join(statErr, err)
+			return ObjectInfo{Key: key}
+		}
+		return *info
+	})
+	if statErr != nil {
+		return nil, WrapError("list objects", statErr)
+	}
+	return objects, nil
+}
+
+// Delete removes the files of the object. Deleting a missing object is not an error.
+func (s *FilesystemStore) Delete(ctx context.Context, bucketName, objectKey string) error {
+	path, err := s.objectPath(bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{path + dataSuffix, path + metadataSuffix} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return WrapError("delete object", err)
+		}
+	}
+	return nil
+}
+
+// UpdateMetadata replaces the metadata file of the object
+func (s *FilesystemStore) UpdateMetadata(ctx context.Context, bucketName, objectKey string, meta ObjectMetadata) error {
+	path, err := s.objectPath(bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path + dataSuffix); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = ErrObjectNotFound
+		}
+		return WrapError("update metadata", err)
+	}
+	return s.writeMetadata(path, meta)
+}
+
+// Copy copies the files of the object, the content through a temporary file so readers
+// never see a partial object
+func (s *FilesystemStore) Copy(ctx context.Context, bucketName, fromKey, toKey string) error {
+	from, err := s.objectPath(bucketName, fromKey)
+	if err != nil {
+		return err
+	}
+	to, err := s.objectPath(bucketName, toKey)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(from + dataSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return WrapError("copy object", ErrObjectNotFound)
+	}
+	if err != nil {
+		return WrapError("copy object", err)
+	}
+	defer src.Close()
+	meta, err := s.readMetadata(from)
+	if err != nil {
+		return WrapError("copy object", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return WrapError("copy object", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(to), ".upload-*")
+	if err != nil {
+		return WrapError("copy object", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return WrapError("copy object", err)
+	}
+	if err := s.writeMetadata(to, meta); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), to+dataSuffix); err != nil {
+		return WrapError("copy object", err)
+	}
+	return nil
+}
+
+// Close does nothing; the files stay
+func (s *FilesystemStore) Close() error {
+	return nil
+}
+
+var _ ObjectStore = (*FilesystemStore)(nil)
@@ -0,0 +1,172 @@
+package satellite
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps objects in memory. It is meant for tests and local development;
+// everything is lost when the process exits.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	created time.Time
+	meta    ObjectMetadata
+}
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]map[string]*memoryObject{}}
+}
+
+// EnsureBucket creates the bucket if it does not exist
+func (s *MemoryStore) EnsureBucket(ctx context.Context, bucketName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bucket(bucketName)
+	return nil
+}
+
+// bucket returns the objects of a bucket, creating it if needed. s.mu must be held.
+func (s *MemoryStore) bucket(bucketName string) map[string]*memoryObject {
+	if s.buckets[bucketName] == nil {
+		s.buckets[bucketName] = map[string]*memoryObject{}
+	}
+	return s.buckets[bucketName]
+}
+
+// object returns an object, or nil. s.mu must be held.
+func (s *MemoryStore) object(bucketName, objectKey string) *memoryObject {
+	return s.buckets[bucketName][objectKey]
+}
+
+// Put reads everything from r before the object becomes visible
+func (s *MemoryStore) Put(ctx context.Context, bucketName, objectKey string, r io.Reader, meta ObjectMetadata) (int64, error) {
+	content, finish, err := encodeContent(r, meta, meta.Compression)
+	if err != nil {
+		return 0, WrapError("upload data", err)
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return int64(len(data)), WrapError("upload data", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bucket(bucketName)[objectKey] = &memoryObject{data: data, created: time.Now(), meta: stored(finish())}
+	return int64(len(data)), nil
+}
+
+// Get returns a reader of the object as it is now
+func (s *MemoryStore) Get(ctx context.Context, bucketName, objectKey string) (ObjectReader, error) {
+	s.mu.Lock()
+	object := s.object(bucketName, objectKey)
+	s.mu.Unlock()
+	if object == nil {
+		return nil, WrapError("open object", ErrObjectNotFound)
+	}
+
+	reader, err := newObjectReader(bytes.NewReader(object.data), nil, object.meta)
+	if err != nil {
+		return nil, WrapError("open object", err)
+	}
+	return reader, nil
+}
+
+// Stat returns the details of the object
+func (s *MemoryStore) Stat(ctx context.Context, bucketName, objectKey string) (*ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object := s.object(bucketName, objectKey)
+	if object == nil {
+		return nil, WrapError("stat object", ErrObjectNotFound)
+	}
+	info := object.info(objectKey)
+	return &info, nil
+}
+
+func (o *memoryObject) info(objectKey string) ObjectInfo {
+	return ObjectInfo{
+		Key:      objectKey,
+		Size:     int64(len(o.data)),
+		Created:  o.created,
+		Metadata: o.meta,
+	}
+}
+
+// List lists the objects of the bucket
+func (s *MemoryStore) List(ctx context.Context, bucketName, prefix string, recursive bool) ([]ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := s.buckets[bucketName]
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return listKeys(keys, prefix, recursive, func(key string) ObjectInfo {
+		return objects[key].info(key)
+	}), nil
+}
+
+// Delete removes the object. Deleting a missing object is not an error.
+func (s *MemoryStore) Delete(ctx context.Context, bucketName, objectKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets[bucketName], objectKey)
+	return nil
+}
+
+// UpdateMetadata replaces the metadata of the object
+func (s *MemoryStore) UpdateMetadata(ctx context.Context, bucketName, objectKey string, meta ObjectMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object := s.object(bucketName, objectKey)
+	if object == nil {
+		return WrapError("update metadata", ErrObjectNotFound)
+	}
+	// The metadata is replaced, not changed in place, for readers that hold the object
+	updated := *object
+	updated.meta = stored(meta)
+	s.buckets[bucketName][objectKey] = &updated
+	return nil
+}
+
+// Copy adds the object under toKey as well. Objects are never changed in place, so both
+// keys share the content.
+func (s *MemoryStore) Copy(ctx context.Context, bucketName, fromKey, toKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object := s.object(bucketName, fromKey)
+	if object == nil {
+		return WrapError("copy object", ErrObjectNotFound)
+	}
+	copied := *object
+	copied.created = time.Now()
+	s.bucket(bucketName)[toKey] = &copied
+	return nil
+}
+
+// stored returns meta as the other backends return it after a round trip
+func stored(meta ObjectMetadata) ObjectMetadata {
+	return ParseObjectMetadata(meta.Custom())
+}
+
+// Close does nothing; the objects stay for the next user of the access grant
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+var _ ObjectStore = (*MemoryStore)(nil)
@@ -0,0 +1,156 @@
+package satellite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/StorX2-0/Backup-Tools/pkg/archive"
+	"github.com/StorX2-0/Backup-Tools/pkg/compress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectStores(t *testing.T) {
+	fsStore, err := NewFilesystemStore(t.TempDir())
+	require.NoError(t, err)
+
+	for name, store := range map[string]ObjectStore{"filesystem": fsStore, "memory": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			content := bytes.Repeat([]byte("inbox "), 1000)
+			require.NoError(t, store.EnsureBucket(ctx, "gmail"))
+
+			_, err := store.Put(ctx, "gmail", "user@example.com/a.eml", bytes.NewReader(content),
+				ObjectMetadata{Source: "google", Compression: compress.Gzip})
+			require.NoError(t, err)
+			_, err = store.Put(ctx, "gmail", "user@example.com/b/c.eml", bytes.NewReader(nil), ObjectMetadata{})
+			require.NoError(t, err)
+
+			r, err := store.Get(ctx, "gmail", "user@example.com/a.eml")
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, content, data)
+			assert.Equal(t, "google", r.Metadata().Source)
+			assert.Equal(t, int64(len(content)), r.Metadata().OriginalSize)
+			assert.NotEmpty(t, r.Metadata().ContentHash)
+
+			info, err := store.Stat(ctx, "gmail", "user@example.com/a.eml")
+			require.NoError(t, err)
+			assert.Less(t, info.Size, int64(len(content)))
+
+			list, err := store.List(ctx, "gmail", "user@example.com/", false)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "user@example.com/a.eml", list[0].Key)
+			assert.Equal(t, ObjectInfo{Key: "user@example.com/b/", IsPrefix: true}, list[1])
+
+			list, err = store.List(ctx, "gmail", "", true)
+			require.NoError(t, err)
+			assert.Len(t, list, 2)
+
+			require.NoError(t, store.UpdateMetadata(ctx, "gmail", "user@example.com/b/c.eml", ObjectMetadata{JobID: "7"}))
+			info, err = store.Stat(ctx, "gmail", "user@example.com/b/c.eml")
+			require.NoError(t, err)
+			assert.Equal(t, "7", info.Metadata.JobID)
+
+			require.NoError(t, store.Copy(ctx, "gmail", "user@example.com/a.eml", "copies/a.eml"))
+			r, err = store.Get(ctx, "gmail", "copies/a.eml")
+			require.NoError(t, err)
+			data, err = io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, content, data)
+			assert.Equal(t, "google", r.Metadata().Source)
+			assert.True(t, errors.Is(store.Copy(ctx, "gmail", "user@example.com/missing.eml", "copies/b.eml"), ErrObjectNotFound))
+
+			require.NoError(t, store.Delete(ctx, "gmail", "user@example.com/a.eml"))
+			_, err = store.Get(ctx, "gmail", "user@example.com/a.eml")
+			assert.True(t, errors.Is(err, ErrObjectNotFound))
+			require.NoError(t, store.Delete(ctx, "gmail", "user@example.com/a.eml"))
+		})
+	}
+}
+
+func TestUploadResumableCompressedContent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	content := bytes.Repeat([]byte("INSERT INTO t VALUES (1);\n"), 1000)
+
+	var compressed bytes.Buffer
+	w, err := compress.NewWriter(&compressed, compress.Gzip)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = UploadResumable(ctx, store, ResumableUpload{
+		Bucket:   "database",
+		Key:      "postgresql/db.sql",
+		Size:     int64(compressed.Len()),
+		Metadata: ObjectMetadata{Compression: compress.Gzip, OriginalSize: int64(len(content))},
+		Open: func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(compressed.Bytes()[offset:])), nil
+		},
+		Save: func(*UploadCheckpoint) error { return nil },
+	})
+	require.NoError(t, err)
+
+	r, err := store.Get(ctx, "database", "postgresql/db.sql")
+	require.NoError(t, err)
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	assert.Equal(t, int64(len(content)), r.Metadata().OriginalSize)
+}
+
+func TestContentSize(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.NoError(t, store.EnsureBucket(ctx, "gmail"))
+	content := bytes.Repeat([]byte("inbox "), 1000)
+
+	// Objects uploaded before the original size was recorded only name their compression
+	var compressed bytes.Buffer
+	w, err := compress.NewWriter(&compressed, compress.Gzip)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = store.Put(ctx, "gmail", "user@example.com/a.eml", &compressed, ObjectMetadata{})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateMetadata(ctx, "gmail", "user@example.com/a.eml", ObjectMetadata{Compression: compress.Gzip}))
+
+	object, err := store.Stat(ctx, "gmail", "user@example.com/a.eml")
+	require.NoError(t, err)
+	require.Zero(t, object.Metadata.OriginalSize)
+
+	// Their size is not guessed by reading them
+	_, err = ContentSize(*object)
+	assert.ErrorIs(t, err, ErrContentSizeUnknown)
+
+	// Objects with a recorded size are as long as what the store reads
+	_, err = store.Put(ctx, "gmail", "user@example.com/b.eml", bytes.NewReader(content), ObjectMetadata{Compression: compress.Gzip})
+	require.NoError(t, err)
+	object, err = store.Stat(ctx, "gmail", "user@example.com/b.eml")
+	require.NoError(t, err)
+	size, err := ContentSize(*object)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), size)
+
+	download := &archive.Archive{
+		Format:  archive.FormatTar,
+		Entries: []archive.Entry{{Name: "b.eml", ID: object.Key, Size: size}},
+		Open: func(ctx context.Context, entry archive.Entry) (io.ReadCloser, error) {
+			return store.Get(ctx, "gmail", entry.ID)
+		},
+	}
+	var out bytes.Buffer
+	require.NoError(t, download.Write(ctx, &out))
+	assert.Equal(t, download.Size(), int64(out.Len()))
+}
@@ -0,0 +1,249 @@
+package crons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/StorX2-0/Backup-Tools/apps/google"
+	"github.com/StorX2-0/Backup-Tools/apps/outlook"
+	"github.com/StorX2-0/Backup-Tools/provider"
+	"github.com/StorX2-0/Backup-Tools/satellite"
+	photoslibrary "github.com/gphotosuploader/googlemirror/api/photoslibrary/v1"
+	"google.golang.org/api/drive/v3"
+)
+
+// Dry runs of scheduled tasks. They look up the requested items with the same clients the
+// processors use and diff them against synced_objects by item ID; nothing is uploaded.
+
+// Estimate looks up the sizes of the requested emails
+func (g *GmailProcessor) Estimate(input provider.EstimateInput) (*provider.Estimate, error) {
+	accessToken, _ := input.InputData["access_token"].(string)
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token not found")
+	}
+
+	client, err := google.NewGmailClientUsingToken(accessToken, input.Account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gmail client: %w", err)
+	}
+
+	synced, err := provider.SyncedItemIDs(input.Database, input.UserID, satellite.ReserveBucket_Gmail, input.Account, "google", "gmail", provider.MessageIDFromKey("gmail"))
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := provider.NewEstimate("gmail", input.MaxItems, true)
+	for _, emailID := range uniqueItemIDs(input.ItemIDs) {
+		if estimate.Full() {
+			break
+		}
+		if err := input.Ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		message, err := client.Service.Users.Messages.Get("me", emailID).Format("minimal").Do()
+		if err != nil {
+			estimate.Fail()
+			continue
+		}
+		estimate.Add(message.SizeEstimate, synced[message.Id])
+	}
+
+	return estimate.Finish(), nil
+}
+
+// Estimate checks that the requested emails exist. Outlook does not report message
+// sizes, so only counts are returned.
+func (o *OutlookProcessor) Estimate(input provider.EstimateInput) (*provider.Estimate, error) {
+	accessToken, _ := input.InputData["access_token"].(string)
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token not found")
+	}
+
+	client, err := outlook.NewOutlookClientUsingToken(accessToken, input.Account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Outlook client: %w", err)
+	}
+
+	synced, err := provider.SyncedItemIDs(input.Database, input.UserID, satellite.ReserveBucket_Outlook, input.Account, "outlook", "outlook", provider.MessageIDFromKey("outlook"))
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := provider.NewEstimate("outlook", input.MaxItems, false)
+	for _, emailID := range uniqueItemIDs(input.ItemIDs) {
+		if estimate.Full() {
+			break
+		}
+		if err := input.Ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		message, err := client.GetMessage(emailID)
+		if err != nil {
+			estimate.Fail()
+			continue
+		}
+		estimate.Add(0, synced[message.ID])
+	}
+
+	return estimate.Finish(), nil
+}
+
+// Estimate looks up the requested files and walks requested folders with Files.List
+func (g *GoogleDriveProcessor) Estimate(input provider.EstimateInput) (*provider.Estimate, error) {
+	accessToken, _ := input.InputData["access_token"].(string)
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token not found")
+	}
+
+	service, err := g.createDriveService(input.Ctx, accessToken, input.Account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	synced, err := provider.SyncedItemIDs(input.Database, input.UserID, satellite.ReserveBucket_Drive, input.Account, "google", "drive", provider.PrefixedIDFromKey)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := provider.NewEstimate("google_drive", input.MaxItems, true)
+	var folders []string
+	for _, fileID := range uniqueItemIDs(input.ItemIDs) {
+		if estimate.Full() {
+			break
+		}
+		file, err := service.Files.Get(fileID).Fields("id", "mimeType", "size").Context(input.Ctx).Do()
+		if err != nil {
+			if input.Ctx.Err() != nil {
+				return nil, input.Ctx.Err()
+			}
+			estimate.Fail()
+			continue
+		}
+		if file.MimeType == driveFolderMimeType {
+			folders = append(folders, file.Id)
+			continue
+		}
+		estimate.Add(file.Size, synced[file.Id])
+	}
+
+	visited := make(map[string]bool)
+	for len(folders) > 0 && !estimate.Full() {
+		folderID := folders[0]
+		folders = folders[1:]
+		if visited[folderID] {
+			continue
+		}
+		visited[folderID] = true
+
+		err := service.Files.List().
+			Q(fmt.Sprintf("'%s' in parents", folderID)).
+			Fields("nextPageToken, files(id, mimeType, size)").
+			Pages(input.Ctx, func(page *drive.FileList) error {
+				for _, f := range page.Files {
+					if f.MimeType == driveFolderMimeType {
+						folders = append(folders, f.Id)
+						continue
+					}
+					if estimate.Full() {
+						return errEstimateFull
+					}
+					estimate.Add(f.Size, synced[f.Id])
+				}
+				return nil
+			})
+		if err != nil && !errors.Is(err, errEstimateFull) {
+			return nil, google.WrapError(fmt.Errorf("failed to list files in folder %s: %w", folderID, err))
+		}
+	}
+
+	return estimate.Finish(), nil
+}
+
+// Estimate looks up the requested photos and lists the photos of requested albums. The
+// Photos API does not report file sizes, so only counts are returned.
+func (g *GooglePhotosProcessor) Estimate(input provider.EstimateInput) (*provider.Estimate, error) {
+	accessToken, _ := input.InputData["access_token"].(string)
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token not found")
+	}
+
+	client, err := g.createPhotosClient(accessToken, input.Account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Google Photos client: %w", err)
+	}
+
+	synced, err := provider.SyncedItemIDs(input.Database, input.UserID, satellite.ReserveBucket_Photos, input.Account, "google", "photos", provider.PrefixedIDFromKey)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := provider.NewEstimate("google_photos", input.MaxItems, false)
+	for _, itemID := range uniqueItemIDs(input.ItemIDs) {
+		if estimate.Full() {
+			break
+		}
+		if err := input.Ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Encoded album photos: ALBUM|AlbumID|AlbumTitle|PhotoID
+		if parts := strings.Split(itemID, "|"); parts[0] == "ALBUM" && len(parts) >= 4 {
+			itemID = parts[3]
+		} else if album, err := client.Albums.GetById(input.Ctx, itemID); err == nil && album != nil {
+			if err := g.estimateAlbum(input.Ctx, client, album.ID, estimate, synced); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		mediaItem, err := client.GetPhoto(input.Ctx, itemID)
+		if err != nil {
+			estimate.Fail()
+			continue
+		}
+		estimate.Add(0, synced[mediaItem.ID])
+	}
+
+	return estimate.Finish(), nil
+}
+
+func (g *GooglePhotosProcessor) estimateAlbum(ctx context.Context, client *google.GPotosClient, albumID string, estimate *provider.Estimate, synced map[string]bool) error {
+	err := client.Service.MediaItems.Search(&photoslibrary.SearchMediaItemsRequest{
+		AlbumId:  albumID,
+		PageSize: 100,
+	}).Pages(ctx, func(page *photoslibrary.SearchMediaItemsResponse) error {
+		for _, item := range page.MediaItems {
+			if estimate.Full() {
+				return errEstimateFull
+			}
+			estimate.Add(0, synced[item.Id])
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEstimateFull) {
+		return fmt.Errorf("failed to list photos in album: %w", err)
+	}
+	return nil
+}
+
+const driveFolderMimeType = "application/vnd.google-apps.folder"
+
+// errEstimateFull stops a paged listing once the estimate reached its item limit
+var errEstimateFull = errors.New("estimate item limit reached")
+
+func uniqueItemIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
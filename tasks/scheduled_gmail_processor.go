@@ -11,7 +11,6 @@ import (
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
-	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	"google.golang.org/api/gmail/v1"
 )
@@ -21,8 +20,8 @@ type GmailProcessor struct {
 	BaseProcessor
 }
 
-func NewScheduledGmailProcessor(deps *TaskProcessorDeps) *GmailProcessor {
-	return &GmailProcessor{BaseProcessor{Deps: deps}}
+func NewScheduledGmailProcessor() *GmailProcessor {
+	return &GmailProcessor{BaseProcessor{}}
 }
 
 func (g *GmailProcessor) Run(input ScheduledTaskProcessorInput) error {
@@ -39,13 +38,13 @@ func (g *GmailProcessor) Run(input ScheduledTaskProcessorInput) error {
 		return g.handleError(input.Task, "Access token not found in task data", nil)
 	}
 
-	gmailClient, err := google.NewGmailClientUsingToken(accessToken)
+	gmailClient, err := google.NewGmailClientUsingToken(accessToken, input.Task.LoginId)
 	if err != nil {
 		return g.handleError(input.Task, fmt.Sprintf("Failed to create Gmail client: %s", err), nil)
 	}
 
 	// Create placeholder and get existing emails
-	if err := g.setupStorage(input.Task, satellite.ReserveBucket_Gmail); err != nil {
+	if err := g.setupStorage(input, satellite.ReserveBucket_Gmail); err != nil {
 		return err
 	}
 
@@ -72,8 +71,8 @@ func (g *GmailProcessor) Run(input ScheduledTaskProcessorInput) error {
 	return nil
 }
 
-func (g *GmailProcessor) setupStorage(task *repo.ScheduledTasks, bucket string) error {
-	return handler.UploadObjectAndSync(context.Background(), g.Deps.Store, task.StorxToken, bucket, task.LoginId+"/.file_placeholder", nil, task.UserID)
+func (g *GmailProcessor) setupStorage(input ScheduledTaskProcessorInput, bucket string) error {
+	return handler.UploadObjectAndSync(context.Background(), input.Deps.Store, input.Storage, bucket, input.Task.LoginId+"/.file_placeholder", nil, input.Task.UserID, input.ObjectMetadata(satellite.ObjectMetadata{}))
 }
 
 func (g *GmailProcessor) processEmails(input ScheduledTaskProcessorInput, client *google.GmailClient, existingEmails map[string]bool) error {
@@ -120,6 +119,7 @@ func (g *GmailProcessor) processEmails(input ScheduledTaskProcessorInput, client
 		// Use the same filename format as direct uploads for consistency
 		messagePath := input.Task.LoginId + "/" + utils.GenerateTitleFromGmailMessage(message)
 		if _, exists := existingEmails[messagePath]; exists {
+			input.Events.Skipped(emailID, "", "already exists in storage")
 			moveEmailToStatus(&input.Memory, emailID, "pending", "skipped: already exists in storage")
 			successCount++
 			continue
@@ -181,6 +181,7 @@ func moveEmailToStatus(memory *map[string][]string, emailID, fromStatus, toStatu
 func (g *GmailProcessor) trackFailure(emailID string, err error, failedEmails []string, failedCount int, input ScheduledTaskProcessorInput) ([]string, int) {
 	failedEmails = append(failedEmails, fmt.Sprintf("Email ID %s: %v", emailID, err))
 	failedCount++
+	input.Events.Failed(emailID, err)
 	moveEmailToStatus(&input.Memory, emailID, "pending", fmt.Sprintf("error: %v", err))
 	return failedEmails, failedCount
 }
@@ -188,7 +189,11 @@ func (g *GmailProcessor) trackFailure(emailID string, err error, failedEmails []
 func (g *GmailProcessor) uploadEmail(input ScheduledTaskProcessorInput, message *gmail.Message, messagePath, bucket string) error {
 	b, err := json.Marshal(message)
 	if err != nil {
-		return fmt.Errorf("failed to marshal: %v", err)
+		return fmt.Errorf("failed to marshal: %w", err)
 	}
-	return handler.UploadObjectAndSync(context.TODO(), input.Deps.Store, input.Task.StorxToken, bucket, messagePath, b, input.Task.UserID)
+	if err := handler.UploadObjectAndSync(context.TODO(), input.Deps.Store, input.Storage, bucket, messagePath, b, input.Task.UserID, input.ObjectMetadata(google.GmailObjectMetadata(message))); err != nil {
+		return err
+	}
+	input.Events.Synced(message.Id, messagePath, int64(len(b)))
+	return nil
 }
@@ -2,7 +2,6 @@ package crons
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,7 +13,6 @@ import (
 	"github.com/StorX2-0/Backup-Tools/handler"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
-	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	"golang.org/x/oauth2"
 	oauth2google "golang.org/x/oauth2/google"
@@ -30,9 +28,9 @@ type GoogleDriveProcessor struct {
 	driveConfigErr  error
 }
 
-func NewScheduledGoogleDriveProcessor(deps *TaskProcessorDeps) *GoogleDriveProcessor {
+func NewScheduledGoogleDriveProcessor() *GoogleDriveProcessor {
 	return &GoogleDriveProcessor{
-		BaseProcessor: BaseProcessor{Deps: deps},
+		BaseProcessor: BaseProcessor{},
 	}
 }
 
@@ -60,13 +58,13 @@ func (g *GoogleDriveProcessor) Run(input ScheduledTaskProcessorInput) error {
 		return g.handleError(input.Task, "Access token not found in task data", nil)
 	}
 
-	driveService, err := g.createDriveService(ctx, accessToken)
+	driveService, err := g.createDriveService(ctx, accessToken, input.Task.LoginId)
 	if err != nil {
 		return g.handleError(input.Task, fmt.Sprintf("Failed to create Google Drive service: %v", err), nil)
 	}
 
 	// Create placeholder and get existing files
-	if err := g.setupStorage(ctx, input.Task, satellite.ReserveBucket_Drive); err != nil {
+	if err := g.setupStorage(ctx, input, satellite.ReserveBucket_Drive); err != nil {
 		return err
 	}
 
@@ -107,14 +105,13 @@ func (g *GoogleDriveProcessor) loadDriveConfig() (*oauth2.Config, error) {
 	return g.driveConfig, nil
 }
 
-func (g *GoogleDriveProcessor) createDriveService(ctx context.Context, accessToken string) (*drive.Service, error) {
+func (g *GoogleDriveProcessor) createDriveService(ctx context.Context, accessToken, account string) (*drive.Service, error) {
 	config, err := g.loadDriveConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	token := &oauth2.Token{AccessToken: accessToken}
-	client := config.Client(ctx, token)
+	client := google.NewRateLimitedClient(ctx, config, accessToken, account)
 
 	service, err := drive.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
@@ -124,8 +121,8 @@ func (g *GoogleDriveProcessor) createDriveService(ctx context.Context, accessTok
 	return service, nil
 }
 
-func (g *GoogleDriveProcessor) setupStorage(ctx context.Context, task *repo.ScheduledTasks, bucket string) error {
-	return handler.UploadObjectAndSync(ctx, g.Deps.Store, task.StorxToken, bucket, task.LoginId+"/.file_placeholder", nil, task.UserID)
+func (g *GoogleDriveProcessor) setupStorage(ctx context.Context, input ScheduledTaskProcessorInput, bucket string) error {
+	return handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Storage, bucket, input.Task.LoginId+"/.file_placeholder", nil, input.Task.UserID, input.ObjectMetadata(satellite.ObjectMetadata{}))
 }
 
 func (g *GoogleDriveProcessor) processFiles(ctx context.Context, input ScheduledTaskProcessorInput, service *drive.Service, existingFiles map[string]bool) error {
@@ -203,6 +200,7 @@ func (g *GoogleDriveProcessor) processFiles(ctx context.Context, input Scheduled
 		// Handle folders - create placeholder and discover nested files
 		if file.MimeType == "application/vnd.google-apps.folder" {
 			if file.Name == "My Drive" {
+				input.Events.Skipped(fileID, "", "My Drive container")
 				moveEmailToStatus(&input.Memory, fileID, "pending", "skipped: My Drive container")
 				successCount++
 				continue
@@ -216,13 +214,14 @@ func (g *GoogleDriveProcessor) processFiles(ctx context.Context, input Scheduled
 				folderPath = fmt.Sprintf("%s/%s_%s/.file_placeholder", basePath, file.Id, file.Name)
 			}
 			if _, exists := existingFiles[folderPath]; exists {
+				input.Events.Skipped(fileID, "", "already exists in storage")
 				moveEmailToStatus(&input.Memory, fileID, "pending", "skipped: already exists in storage")
 				successCount++
 				continue
 			}
 
 			// Upload folder placeholder and sync to database
-			if err := handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Task.StorxToken, satellite.ReserveBucket_Drive, folderPath, nil, input.Task.UserID); err != nil {
+			if err := handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Storage, satellite.ReserveBucket_Drive, folderPath, nil, input.Task.UserID, driveObjectMetadata(input, file)); err != nil {
 				failedFiles, failedCount = g.trackFailure(fileID, err, failedFiles, failedCount, input)
 				continue
 			}
@@ -283,6 +282,7 @@ func (g *GoogleDriveProcessor) processFiles(ctx context.Context, input Scheduled
 		}
 
 		if _, exists := existingFiles[filePath]; exists {
+			input.Events.Skipped(fileID, "", "already exists in storage")
 			moveEmailToStatus(&input.Memory, fileID, "pending", "skipped: already exists in storage")
 			successCount++
 			continue
@@ -314,42 +314,12 @@ func (g *GoogleDriveProcessor) processFiles(ctx context.Context, input Scheduled
 func (g *GoogleDriveProcessor) trackFailure(fileID string, err error, failedFiles []string, failedCount int, input ScheduledTaskProcessorInput) ([]string, int) {
 	failedFiles = append(failedFiles, fmt.Sprintf("File ID %s: %v", fileID, err))
 	failedCount++
+	input.Events.Failed(fileID, err)
 	moveEmailToStatus(&input.Memory, fileID, "pending", "error")
 	return failedFiles, failedCount
 }
 
 func (g *GoogleDriveProcessor) uploadFile(ctx context.Context, input ScheduledTaskProcessorInput, service *drive.Service, file *drive.File, filePath string, locationType string) error {
-	var resp *http.Response
-	var err error
-
-	// Handle Google Docs/Sheets/Slides export
-	// Note: Shortcuts should already be resolved before reaching this function
-	if strings.HasPrefix(file.MimeType, "application/vnd.google-apps") {
-		// Skip shortcuts - they should have been resolved earlier
-		if file.MimeType == "application/vnd.google-apps.shortcut" {
-			return fmt.Errorf("shortcut file cannot be downloaded directly, must be resolved to target file first")
-		}
-		exportMimeType := g.getExportMimeType(file.MimeType)
-		if exportMimeType == "" {
-			return fmt.Errorf("unsupported Google Apps file type: %s", file.MimeType)
-		}
-		resp, err = service.Files.Export(file.Id, exportMimeType).Download()
-		if err != nil {
-			return fmt.Errorf("failed to export file: %v", err)
-		}
-	} else {
-		resp, err = service.Files.Get(file.Id).Download()
-		if err != nil {
-			return fmt.Errorf("failed to download file: %v", err)
-		}
-	}
-	defer resp.Body.Close()
-
-	fileData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read file data: %v", err)
-	}
-
 	// Map permissions
 	var permissions []google.DrivePermission
 	for _, p := range file.Permissions {
@@ -380,20 +350,133 @@ func (g *GoogleDriveProcessor) uploadFile(ctx context.Context, input ScheduledTa
 		Starred:      file.Starred,
 	}
 
-	// Create backup item
-	backupItem := google.DriveBackupItem{
-		Metadata: metadata,
-		Content:  fileData,
+	// Handle Google Docs/Sheets/Slides export
+	// Note: Shortcuts should already be resolved before reaching this function
+	if strings.HasPrefix(file.MimeType, "application/vnd.google-apps") {
+		// Skip shortcuts - they should have been resolved earlier
+		if file.MimeType == "application/vnd.google-apps.shortcut" {
+			return fmt.Errorf("shortcut file cannot be downloaded directly, must be resolved to target file first")
+		}
+		exportMimeType := g.getExportMimeType(file.MimeType)
+		if exportMimeType == "" {
+			return fmt.Errorf("unsupported Google Apps file type: %s", file.MimeType)
+		}
+		resp, err := service.Files.Export(file.Id, exportMimeType).Download()
+		if err != nil {
+			return google.WrapError(fmt.Errorf("failed to export file: %w", err))
+		}
+		defer resp.Body.Close()
+		return g.uploadStream(ctx, input, file, filePath, metadata, resp.Body)
+	}
+
+	// Exports have no known size, stored files do and are uploaded in parts that survive
+	// a restart of the task
+	if file.Size > 0 {
+		return g.uploadResumable(ctx, input, service, file, filePath, metadata)
 	}
 
-	jsonData, err := json.Marshal(backupItem)
+	resp, err := service.Files.Get(file.Id).Download()
 	if err != nil {
-		return fmt.Errorf("failed to marshal drive file: %v", err)
+		return google.WrapError(fmt.Errorf("failed to download file: %w", err))
 	}
+	defer resp.Body.Close()
+	return g.uploadStream(ctx, input, file, filePath, metadata, resp.Body)
+}
+
+// uploadStream streams the backup item to satellite while the file is downloaded;
+// metadata is included in the JSON object
+func (g *GoogleDriveProcessor) uploadStream(ctx context.Context, input ScheduledTaskProcessorInput, file *drive.File, filePath string, metadata google.DriveFileMetadata, content io.Reader) error {
+	pr, pw := io.Pipe()
+	contentSize := make(chan int64, 1)
+	go func() {
+		n, err := google.WriteDriveBackupItem(pw, metadata, content)
+		if err != nil {
+			err = fmt.Errorf("failed to read file data: %w", err)
+		}
+		contentSize <- n
+		pw.CloseWithError(err)
+	}()
 
 	// Upload JSON content to satellite and sync to database
-	// Metadata is now included in the JSON object
-	return handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Task.StorxToken, satellite.ReserveBucket_Drive, filePath, jsonData, input.Task.UserID)
+	_, err := handler.UploadStreamAndSync(ctx, input.Deps.Store, input.Storage, satellite.ReserveBucket_Drive, filePath, pr, input.Task.UserID, driveObjectMetadata(input, file))
+	pr.Close()
+	size := <-contentSize
+	if err != nil {
+		return err
+	}
+	input.Events.Synced(file.Id, filePath, size)
+	return nil
+}
+
+// uploadResumable uploads the backup item of a stored file with a known size. The
+// progress is kept in the task memory, so a restarted task downloads and uploads only
+// what is missing.
+func (g *GoogleDriveProcessor) uploadResumable(ctx context.Context, input ScheduledTaskProcessorInput, service *drive.Service, file *drive.File, filePath string, metadata google.DriveFileMetadata) error {
+	bucket := satellite.ReserveBucket_Drive
+	size, err := google.DriveBackupItemSize(metadata, file.Size)
+	if err != nil {
+		return err
+	}
+	version, err := google.DriveBackupItemVersion(metadata, file.Size)
+	if err != nil {
+		return err
+	}
+
+	upload := satellite.ResumableUpload{
+		Bucket:   bucket,
+		Key:      filePath,
+		Version:  version,
+		Size:     size,
+		Metadata: driveObjectMetadata(input, file),
+		Open: func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+			return google.OpenDriveBackupItem(metadata, file.Size, offset, func(offset int64) (io.ReadCloser, error) {
+				return downloadDriveFileFrom(service, file.Id, offset)
+			})
+		},
+		Checkpoint: uploadCheckpoint(input.Memory, bucket, filePath),
+		Save: func(checkpoint *satellite.UploadCheckpoint) error {
+			if err := input.HeartBeatFunc(); err != nil {
+				return err
+			}
+			setUploadCheckpoint(input.Memory, bucket, filePath, checkpoint)
+			return input.SaveCheckpoint()
+		},
+	}
+
+	if _, err := handler.UploadResumableAndSync(ctx, input.Deps.Store, input.Storage, upload, input.Task.UserID); err != nil {
+		return err
+	}
+	input.Events.Synced(file.Id, filePath, file.Size)
+	return nil
+}
+
+// driveObjectMetadata returns the provenance of a file for its backup
+func driveObjectMetadata(input ScheduledTaskProcessorInput, file *drive.File) satellite.ObjectMetadata {
+	return input.ObjectMetadata(satellite.ObjectMetadata{
+		SourceID:       file.Id,
+		SourceModified: file.ModifiedTime,
+		MimeType:       file.MimeType,
+	})
+}
+
+// downloadDriveFileFrom downloads the content of a stored file starting at offset
+func downloadDriveFileFrom(service *drive.Service, fileID string, offset int64) (io.ReadCloser, error) {
+	call := service.Files.Get(fileID)
+	if offset > 0 {
+		call.Header().Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := call.Download()
+	if err != nil {
+		return nil, google.WrapError(fmt.Errorf("failed to download file: %w", err))
+	}
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		// The range was ignored, skip to the offset
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to read file data: %w", err)
+		}
+	}
+	return resp.Body, nil
 }
 
 func (g *GoogleDriveProcessor) getExportMimeType(mimeType string) string {
@@ -461,7 +544,7 @@ func (g *GoogleDriveProcessor) discoverNestedFiles(ctx context.Context, service
 
 			r, err := listCall.Do()
 			if err != nil {
-				return nil, fmt.Errorf("failed to list files in folder %s: %w", folderID, err)
+				return nil, google.WrapError(fmt.Errorf("failed to list files in folder %s: %w", folderID, err))
 			}
 
 			for _, f := range r.Files {
@@ -3,7 +3,6 @@ package crons
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -12,12 +11,10 @@ import (
 	"github.com/StorX2-0/Backup-Tools/handler"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
-	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	gphotos "github.com/gphotosuploader/google-photos-api-client-go/v2"
 	"github.com/gphotosuploader/google-photos-api-client-go/v2/media_items"
 	photoslibrary "github.com/gphotosuploader/googlemirror/api/photoslibrary/v1"
-	"golang.org/x/oauth2"
 	oauth2google "golang.org/x/oauth2/google"
 )
 
@@ -26,8 +23,8 @@ type GooglePhotosProcessor struct {
 	BaseProcessor
 }
 
-func NewScheduledGooglePhotosProcessor(deps *TaskProcessorDeps) *GooglePhotosProcessor {
-	return &GooglePhotosProcessor{BaseProcessor{Deps: deps}}
+func NewScheduledGooglePhotosProcessor() *GooglePhotosProcessor {
+	return &GooglePhotosProcessor{BaseProcessor{}}
 }
 
 func (g *GooglePhotosProcessor) Run(input ScheduledTaskProcessorInput) error {
@@ -54,13 +51,13 @@ func (g *GooglePhotosProcessor) Run(input ScheduledTaskProcessorInput) error {
 		return g.handleError(input.Task, "Access token not found in task data", nil)
 	}
 
-	photosClient, err := g.createPhotosClient(accessToken)
+	photosClient, err := g.createPhotosClient(accessToken, input.Task.LoginId)
 	if err != nil {
 		return g.handleError(input.Task, fmt.Sprintf("Failed to create Google Photos client: %s", err), nil)
 	}
 
 	// Create placeholder and get existing photos
-	if err := g.setupStorage(input.Task, satellite.ReserveBucket_Photos); err != nil {
+	if err := g.setupStorage(input, satellite.ReserveBucket_Photos); err != nil {
 		return err
 	}
 
@@ -74,30 +71,29 @@ func (g *GooglePhotosProcessor) Run(input ScheduledTaskProcessorInput) error {
 	return g.processPhotos(ctx, input, photosClient, photoListFromBucket)
 }
 
-func (g *GooglePhotosProcessor) createPhotosClient(accessToken string) (*google.GPotosClient, error) {
+func (g *GooglePhotosProcessor) createPhotosClient(accessToken, account string) (*google.GPotosClient, error) {
 	b, err := os.ReadFile("credentials.json")
 	if err != nil {
-		return nil, fmt.Errorf("unable to read credentials file: %v", err)
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
 	}
 
 	config, err := oauth2google.ConfigFromJSON(b, photoslibrary.PhotoslibraryReadonlyScope)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse credentials: %v", err)
+		return nil, fmt.Errorf("unable to parse credentials: %w", err)
 	}
 
-	token := &oauth2.Token{AccessToken: accessToken}
-	httpClient := config.Client(context.Background(), token)
+	httpClient := google.NewRateLimitedClient(context.Background(), config, accessToken, account)
 
 	// Create gphotos client
 	gphotosClient, err := gphotos.NewClient(httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create GPhotos client: %v", err)
+		return nil, fmt.Errorf("unable to create GPhotos client: %w", err)
 	}
 
 	// Create photoslibrary service
 	service, err := photoslibrary.New(httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create Photos service: %v", err)
+		return nil, fmt.Errorf("unable to create Photos service: %w", err)
 	}
 
 	return &google.GPotosClient{
@@ -107,8 +103,8 @@ func (g *GooglePhotosProcessor) createPhotosClient(accessToken string) (*google.
 	}, nil
 }
 
-func (g *GooglePhotosProcessor) setupStorage(task *repo.ScheduledTasks, bucket string) error {
-	return handler.UploadObjectAndSync(context.Background(), g.Deps.Store, task.StorxToken, bucket, task.LoginId+"/.file_placeholder", nil, task.UserID)
+func (g *GooglePhotosProcessor) setupStorage(input ScheduledTaskProcessorInput, bucket string) error {
+	return handler.UploadObjectAndSync(context.Background(), input.Deps.Store, input.Storage, bucket, input.Task.LoginId+"/.file_placeholder", nil, input.Task.UserID, input.ObjectMetadata(satellite.ObjectMetadata{}))
 }
 
 func (g *GooglePhotosProcessor) processPhotos(ctx context.Context, input ScheduledTaskProcessorInput, client *google.GPotosClient, existingPhotos map[string]bool) error {
@@ -160,7 +156,7 @@ func (g *GooglePhotosProcessor) processPhotos(ctx context.Context, input Schedul
 				// Create Album Folder Placeholder if not exists
 				albumPath := fmt.Sprintf("%s/%s_%s/.file_placeholder", input.Task.LoginId, albumID, albumTitle)
 				if _, exists := existingPhotos[albumPath]; !exists {
-					if err := handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Task.StorxToken, satellite.ReserveBucket_Photos, albumPath, nil, input.Task.UserID); err == nil {
+					if err := handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Storage, satellite.ReserveBucket_Photos, albumPath, nil, input.Task.UserID, input.ObjectMetadata(satellite.ObjectMetadata{SourceID: albumID})); err == nil {
 						existingPhotos[albumPath] = true
 					}
 				}
@@ -176,6 +172,7 @@ func (g *GooglePhotosProcessor) processPhotos(ctx context.Context, input Schedul
 				photoPath := fmt.Sprintf("%s/%s_%s/%s_%s", input.Task.LoginId, albumID, albumTitle, mediaItem.ID, mediaItem.Filename)
 
 				if _, exists := existingPhotos[photoPath]; exists {
+					input.Events.Skipped(itemID, "", "already exists in storage")
 					moveEmailToStatus(&input.Memory, itemID, "pending", "skipped: already exists in storage")
 					successCount++
 					continue
@@ -239,6 +236,7 @@ func (g *GooglePhotosProcessor) processPhotos(ctx context.Context, input Schedul
 		// Use collision-safe filename format: photoID_filename to avoid duplicates
 		photoPath := fmt.Sprintf("%s/%s_%s", input.Task.LoginId, mediaItem.ID, mediaItem.Filename)
 		if _, exists := existingPhotos[photoPath]; exists {
+			input.Events.Skipped(itemID, "", "already exists in storage")
 			moveEmailToStatus(&input.Memory, itemID, "pending", "skipped: already exists in storage")
 			successCount++
 			continue
@@ -262,6 +260,7 @@ func (g *GooglePhotosProcessor) processPhotos(ctx context.Context, input Schedul
 func (g *GooglePhotosProcessor) trackFailure(photoID string, err error, failedPhotos []string, failedCount int, input ScheduledTaskProcessorInput) ([]string, int) {
 	failedPhotos = append(failedPhotos, fmt.Sprintf("Photo ID %s: %v", photoID, err))
 	failedCount++
+	input.Events.Failed(photoID, err)
 	moveEmailToStatus(&input.Memory, photoID, "pending", "error")
 	return failedPhotos, failedCount
 }
@@ -272,12 +271,12 @@ func (g *GooglePhotosProcessor) uploadPhoto(ctx context.Context, input Scheduled
 
 	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download photo: %v", err)
+		return fmt.Errorf("failed to download photo: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -286,13 +285,13 @@ func (g *GooglePhotosProcessor) uploadPhoto(ctx context.Context, input Scheduled
 		return fmt.Errorf("failed to download photo, status: %s", resp.Status)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	// Upload to satellite and sync to database
+	written, err := handler.UploadStreamAndSync(ctx, input.Deps.Store, input.Storage, satellite.ReserveBucket_Photos, photoPath, resp.Body, input.Task.UserID, input.ObjectMetadata(google.PhotoObjectMetadata(mediaItem)))
 	if err != nil {
-		return fmt.Errorf("failed to read photo data: %v", err)
+		return err
 	}
-
-	// Upload to satellite and sync to database
-	return handler.UploadObjectAndSync(ctx, input.Deps.Store, input.Task.StorxToken, satellite.ReserveBucket_Photos, photoPath, body, input.Task.UserID)
+	input.Events.Synced(mediaItem.ID, photoPath, written)
+	return nil
 }
 
 // discoverPhotosInAlbum recursively discovers all photos inside an album
@@ -10,7 +10,6 @@ import (
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
 	"github.com/StorX2-0/Backup-Tools/pkg/utils"
-	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 )
 
@@ -19,8 +18,8 @@ type OutlookProcessor struct {
 	BaseProcessor
 }
 
-func NewScheduledOutlookProcessor(deps *TaskProcessorDeps) *OutlookProcessor {
-	return &OutlookProcessor{BaseProcessor{Deps: deps}}
+func NewScheduledOutlookProcessor() *OutlookProcessor {
+	return &OutlookProcessor{BaseProcessor{}}
 }
 
 func (o *OutlookProcessor) Run(input ScheduledTaskProcessorInput) error {
@@ -47,13 +46,13 @@ func (o *OutlookProcessor) Run(input ScheduledTaskProcessorInput) error {
 		return o.handleError(input.Task, "Access token not found for Outlook method", nil)
 	}
 
-	outlookClient, err := outlook.NewOutlookClientUsingToken(accessToken)
+	outlookClient, err := outlook.NewOutlookClientUsingToken(accessToken, input.Task.LoginId)
 	if err != nil {
 		return o.handleError(input.Task, fmt.Sprintf("Failed to create Outlook client: %s", err), nil)
 	}
 
 	// Create placeholder and get existing emails
-	if err := o.setupStorage(input.Task, satellite.ReserveBucket_Outlook); err != nil {
+	if err := o.setupStorage(input, satellite.ReserveBucket_Outlook); err != nil {
 		return o.handleError(input.Task, fmt.Sprintf("Failed to create placeholder: %s", err), nil)
 	}
 
@@ -67,8 +66,8 @@ func (o *OutlookProcessor) Run(input ScheduledTaskProcessorInput) error {
 	return o.processEmails(input, outlookClient, emailListFromBucket)
 }
 
-func (o *OutlookProcessor) setupStorage(task *repo.ScheduledTasks, bucket string) error {
-	return handler.UploadObjectAndSync(context.Background(), o.Deps.Store, task.StorxToken, bucket, task.LoginId+"/.file_placeholder", nil, task.UserID)
+func (o *OutlookProcessor) setupStorage(input ScheduledTaskProcessorInput, bucket string) error {
+	return handler.UploadObjectAndSync(context.Background(), input.Deps.Store, input.Storage, bucket, input.Task.LoginId+"/.file_placeholder", nil, input.Task.UserID, input.ObjectMetadata(satellite.ObjectMetadata{}))
 }
 
 func (o *OutlookProcessor) processEmails(input ScheduledTaskProcessorInput, client *outlook.OutlookClient, existingEmails map[string]bool) error {
@@ -105,12 +104,13 @@ func (o *OutlookProcessor) processEmails(input ScheduledTaskProcessorInput, clie
 		})
 
 		if _, exists := existingEmails[messagePath]; exists {
+			input.Events.Skipped(emailID, "", "already exists in storage")
 			moveEmailToStatus(&input.Memory, emailID, "pending", "skipped: already exists in storage")
 			successCount++
 			continue
 		}
 
-		if err := o.uploadEmail(input, message, messagePath, "outlook"); err != nil {
+		if err := o.uploadEmail(input, emailID, message, messagePath, "outlook"); err != nil {
 			failedEmails, failedCount = o.trackFailure(emailID, err, failedEmails, failedCount, input)
 		} else {
 			moveEmailToStatus(&input.Memory, emailID, "pending", "synced")
@@ -127,14 +127,23 @@ func (o *OutlookProcessor) processEmails(input ScheduledTaskProcessorInput, clie
 func (o *OutlookProcessor) trackFailure(emailID string, err error, failedEmails []string, failedCount int, input ScheduledTaskProcessorInput) ([]string, int) {
 	failedEmails = append(failedEmails, fmt.Sprintf("Email ID %s: %v", emailID, err))
 	failedCount++
+	input.Events.Failed(emailID, err)
 	moveEmailToStatus(&input.Memory, emailID, "pending", fmt.Sprintf("error: %v", err))
 	return failedEmails, failedCount
 }
 
-func (o *OutlookProcessor) uploadEmail(input ScheduledTaskProcessorInput, message interface{}, messagePath, bucket string) error {
+func (o *OutlookProcessor) uploadEmail(input ScheduledTaskProcessorInput, emailID string, message interface{}, messagePath, bucket string) error {
 	b, err := json.Marshal(message)
 	if err != nil {
-		return fmt.Errorf("failed to marshal: %v", err)
+		return fmt.Errorf("failed to marshal: %w", err)
 	}
-	return handler.UploadObjectAndSync(context.TODO(), input.Deps.Store, input.Task.StorxToken, bucket, messagePath, b, input.Task.UserID)
+	if err := handler.UploadObjectAndSync(context.TODO(), input.Deps.Store, input.Storage, bucket, messagePath, b, input.Task.UserID, input.ObjectMetadata(satellite.ObjectMetadata{
+		Source:   "outlook",
+		SourceID: emailID,
+		MimeType: "application/json",
+	})); err != nil {
+		return err
+	}
+	input.Events.Synced(emailID, messagePath, int64(len(b)))
+	return nil
 }
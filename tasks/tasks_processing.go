@@ -7,9 +7,13 @@ import (
 	"time"
 
 	"github.com/StorX2-0/Backup-Tools/db"
+	"github.com/StorX2-0/Backup-Tools/pkg/cluster"
 	"github.com/StorX2-0/Backup-Tools/pkg/database"
+	"github.com/StorX2-0/Backup-Tools/pkg/errs"
 	"github.com/StorX2-0/Backup-Tools/pkg/logger"
 	"github.com/StorX2-0/Backup-Tools/pkg/monitor"
+	"github.com/StorX2-0/Backup-Tools/pkg/progress"
+	"github.com/StorX2-0/Backup-Tools/provider"
 	"github.com/StorX2-0/Backup-Tools/repo"
 	"github.com/StorX2-0/Backup-Tools/satellite"
 	"github.com/google/uuid"
@@ -17,28 +21,17 @@ import (
 )
 
 // TaskProcessorDeps contains all dependencies for task processing
-type TaskProcessorDeps struct {
-	Store *db.PostgresDb
-	Repo  *repo.ScheduledTasksRepository
-}
+type TaskProcessorDeps = provider.Deps
 
 // ScheduledTaskProcessorInput defines the input for processor execution
-type ScheduledTaskProcessorInput struct {
-	InputData     map[string]interface{}
-	Memory        map[string][]string
-	Task          *repo.ScheduledTasks
-	HeartBeatFunc func() error
-	Deps          *TaskProcessorDeps
-}
+type ScheduledTaskProcessorInput = provider.SelectiveSyncInput
 
 type ScheduledTaskProcessor interface {
 	Run(ScheduledTaskProcessorInput) error
 }
 
 // BaseProcessor provides common functionality for all processors
-type BaseProcessor struct {
-	Deps *TaskProcessorDeps
-}
+type BaseProcessor struct{}
 
 func (b *BaseProcessor) handleError(task *repo.ScheduledTasks, errMsg string, existingErrors []string) error {
 	if task.Errors.Json() != nil {
@@ -77,22 +70,14 @@ func (b *BaseProcessor) updateTaskStats(input *ScheduledTaskProcessorInput, succ
 
 // ScheduledTaskManager manages scheduled task processing
 type ScheduledTaskManager struct {
-	Deps      *TaskProcessorDeps
-	processor map[string]ScheduledTaskProcessor
+	Deps *TaskProcessorDeps
 }
 
 func NewScheduledTaskManager(store *db.PostgresDb) *ScheduledTaskManager {
-	deps := &TaskProcessorDeps{
-		Store: store,
-		Repo:  repo.NewScheduledTasksRepository(store.DB),
-	}
 	return &ScheduledTaskManager{
-		Deps: deps,
-		processor: map[string]ScheduledTaskProcessor{
-			"gmail":         NewScheduledGmailProcessor(deps),
-			"outlook":       NewScheduledOutlookProcessor(deps),
-			"google_photos": NewScheduledGooglePhotosProcessor(deps),
-			"google_drive":  NewScheduledGoogleDriveProcessor(deps),
+		Deps: &TaskProcessorDeps{
+			Store: store,
+			Repo:  repo.NewScheduledTasksRepository(store.DB),
 		},
 	}
 }
@@ -126,32 +111,28 @@ func (s *ScheduledTaskManager) ProcessScheduledTasks(ctx context.Context) error
 	processedCount, errorCount := 0, 0
 
 	for {
-		task, err := s.Deps.Repo.GetNextScheduledTask()
+		// Stop claiming once the server is shutting down
+		if ctx.Err() != nil {
+			logger.Info(ctx, "Shutting down, not claiming more scheduled tasks")
+			break
+		}
+
+		// Claiming marks the task as running under this instance
+		task, err := s.Deps.Repo.ClaimNextScheduledTask(cluster.InstanceID())
 		if err != nil {
 			if strings.Contains(err.Error(), "record not found") {
 				logger.Info(ctx, "No scheduled tasks to process")
 				break
 			}
-			return fmt.Errorf("failed to get next scheduled task: %w", err)
+			return fmt.Errorf("failed to claim next scheduled task: %w", err)
 		}
 
 		logger.Info(ctx, "Processing scheduled task",
 			logger.Int("task_id", int(task.ID)),
 			logger.String("method", task.Method),
+			logger.String("claimed_by", task.ClaimedBy),
 		)
 
-		// Update task status to running
-		task.Status = "running"
-		now := time.Now()
-		task.StartTime = &now
-		if err := s.Deps.Store.DB.Save(task).Error; err != nil {
-			logger.Error(ctx, "Failed to update task status to running",
-				logger.Int("task_id", int(task.ID)),
-				logger.ErrorField(err),
-			)
-			continue
-		}
-
 		// Send notification for scheduled task started running
 		priority := "normal"
 		data := map[string]interface{}{
@@ -163,7 +144,48 @@ func (s *ScheduledTaskManager) ProcessScheduledTasks(ctx context.Context) error
 		}
 		satellite.SendNotificationAsync(ctx, task.UserID, "Scheduled Task Started", fmt.Sprintf("Scheduled task for %s has started running", task.LoginId), &priority, data, nil)
 
-		processErr := s.processScheduledTask(ctx, task)
+		tracker := progress.NewTracker(progress.Default, progress.Update{
+			UserID:   task.UserID,
+			TaskType: repo.TaskEventTypeScheduled,
+			TaskID:   task.ID,
+			Method:   task.Method,
+		})
+
+		processErr := s.processScheduledTask(ctx, task, tracker)
+
+		// A task paused or cancelled through the API stops at its next heartbeat; keep
+		// the status that was set and only save its pending/synced lists
+		if processErr != nil && s.stoppedExternally(ctx, task) {
+			tracker.Finish(progress.StatusStopped)
+			processedCount++
+			continue
+		}
+
+		// The lease lapsed and the task was marked as failed; its outcome is no longer
+		// this instance's to record
+		if processErr != nil && s.claimLost(task) {
+			tracker.Finish(progress.StatusFailed)
+			logger.Warn(ctx, "Scheduled task claim lost, not recording result",
+				logger.Int("task_id", int(task.ID)),
+				logger.ErrorField(processErr),
+			)
+			errorCount++
+			continue
+		}
+
+		// A task interrupted by shutdown goes back in the queue with its progress
+		if processErr != nil && ctx.Err() != nil {
+			tracker.Finish(progress.StatusStopped)
+			s.requeueInterrupted(ctx, task)
+			break
+		}
+
+		if processErr != nil {
+			tracker.Finish(progress.StatusFailed)
+		} else {
+			tracker.Finish(progress.StatusCompleted)
+		}
+
 		if updateErr := s.UpdateScheduledTaskStatus(task, processErr); updateErr != nil {
 			logger.Error(ctx, "Failed to update scheduled task status",
 				logger.Int("task_id", int(task.ID)),
@@ -185,13 +207,69 @@ func (s *ScheduledTaskManager) ProcessScheduledTasks(ctx context.Context) error
 	return nil
 }
 
-func (s *ScheduledTaskManager) processScheduledTask(ctx context.Context, task *repo.ScheduledTasks) error {
+// stoppedExternally reports whether a task was paused or cancelled while it ran, and if so
+// saves its progress
+func (s *ScheduledTaskManager) stoppedExternally(ctx context.Context, task *repo.ScheduledTasks) bool {
+	current, err := s.Deps.Repo.GetScheduledTaskByID(task.ID)
+	if err != nil || (current.Status != repo.ScheduledTaskStatusPaused && current.Status != repo.ScheduledTaskStatusCancelled) {
+		return false
+	}
+
+	if task.StartTime != nil {
+		task.Execution = uint64(time.Since(*task.StartTime).Seconds())
+	}
+	if err := s.Deps.Repo.SaveScheduledTaskProgress(task); err != nil {
+		logger.Error(ctx, "Failed to save stopped scheduled task",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	logger.Info(ctx, "Scheduled task stopped",
+		logger.Int("task_id", int(task.ID)),
+		logger.String("status", current.Status),
+	)
+	return true
+}
+
+// claimLost reports whether task is no longer claimed by the instance that ran it
+func (s *ScheduledTaskManager) claimLost(task *repo.ScheduledTasks) bool {
+	current, err := s.Deps.Repo.GetScheduledTaskByID(task.ID)
+	return err == nil && current.ClaimedBy != task.ClaimedBy
+}
+
+// requeueInterrupted saves the progress of a task that stopped because the server is
+// shutting down and puts it back in the queue, so the next run only processes what is left
+func (s *ScheduledTaskManager) requeueInterrupted(ctx context.Context, task *repo.ScheduledTasks) {
+	if task.StartTime != nil {
+		task.Execution = uint64(time.Since(*task.StartTime).Seconds())
+	}
+	if err := s.Deps.Repo.SaveScheduledTaskProgress(task); err != nil {
+		logger.Error(ctx, "Failed to save interrupted scheduled task",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(err),
+		)
+	}
+
+	err := s.Deps.Repo.TransitionScheduledTaskStatus(task.ID, []string{repo.ScheduledTaskStatusRunning}, repo.ScheduledTaskStatusCreated)
+	if err != nil {
+		logger.Error(ctx, "Failed to requeue interrupted scheduled task",
+			logger.Int("task_id", int(task.ID)),
+			logger.ErrorField(err),
+		)
+		return
+	}
+
+	logger.Info(ctx, "Scheduled task requeued after shutdown", logger.Int("task_id", int(task.ID)))
+}
+
+func (s *ScheduledTaskManager) processScheduledTask(ctx context.Context, task *repo.ScheduledTasks, tracker *progress.Tracker) error {
 	var err error
 	defer monitor.Mon.Task()(&ctx)(&err)
 
-	processor, ok := s.processor[task.Method]
-	if !ok {
-		return fmt.Errorf("processor for method '%s' not found", task.Method)
+	p, ok := provider.Lookup(task.Method)
+	if !ok || !p.Capabilities().Has(provider.CapSelectiveSync) {
+		return errs.New(errs.SourceBug, task.Method, "processor for method '%s' not found", task.Method)
 	}
 
 	logger.Info(ctx, "Executing processor for scheduled task",
@@ -207,12 +285,35 @@ func (s *ScheduledTaskManager) processScheduledTask(ctx context.Context, task *r
 		memory = *task.Memory.Json()
 	}
 
-	err = processor.Run(ScheduledTaskProcessorInput{
+	events := provider.NewEventLog(s.Deps.Store.TaskEventRepo, repo.TaskEventTypeScheduled, task.ID)
+	defer events.Flush(ctx)
+	events.Track(tracker)
+	tracker.SetTotal(len(memory["pending"]))
+	events.Info(fmt.Sprintf("started %s backup of %d items", task.Method, len(memory["pending"])))
+
+	// One store serves every upload of the run, so the satellite handshake happens once
+	storage, err := satellite.OpenStore(ctx, task.StorxToken)
+	if err != nil {
+		events.Failed("", err)
+		return err
+	}
+	defer storage.Close()
+
+	input := ScheduledTaskProcessorInput{
+		Ctx:       ctx,
 		InputData: inputData,
 		Memory:    memory,
 		Task:      task,
 		Deps:      s.Deps,
+		Events:    events,
+		Storage:   storage,
+		SaveCheckpoint: func() error {
+			return s.Deps.Repo.SaveScheduledTaskMemory(task.ID, task.ClaimedBy, database.NewDbJsonFromValue(memory))
+		},
 		HeartBeatFunc: func() error {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("server shutting down, stopping execution: %w", err)
+			}
 			currentTask, err := s.Deps.Repo.GetScheduledTaskByID(task.ID)
 			if err != nil {
 				return fmt.Errorf("failed to get task status: %w", err)
@@ -220,12 +321,35 @@ func (s *ScheduledTaskManager) processScheduledTask(ctx context.Context, task *r
 			if currentTask.Status != "running" {
 				return fmt.Errorf("task status changed to '%s', stopping execution", currentTask.Status)
 			}
-			if err := s.Deps.Repo.UpdateHeartBeatForScheduledTask(task.ID); err != nil {
+			if err := s.Deps.Repo.UpdateHeartBeatForScheduledTask(task.ID, task.ClaimedBy); err != nil {
 				return fmt.Errorf("failed to update heartbeat: %w", err)
 			}
+			tracker.Heartbeat()
 			return nil
 		},
-	})
+	}
+	err = p.SelectiveSync(input)
+
+	if err != nil {
+		events.Failed("", err)
+	} else {
+		events.Info("completed")
+		if listing, ok := input.SnapshotListing(); ok {
+			// The backup itself succeeded, so a missing snapshot is only logged
+			if snapshotErr := provider.RecordSnapshot(ctx, s.Deps.Store, storage, listing, &repo.Snapshot{
+				UserID:   task.UserID,
+				Method:   task.Method,
+				TaskType: repo.TaskEventTypeScheduled,
+				TaskID:   task.ID,
+			}); snapshotErr != nil {
+				logger.Warn(ctx, "Failed to record snapshot",
+					logger.Int("task_id", int(task.ID)),
+					logger.String("bucket", listing.Bucket),
+					logger.ErrorField(snapshotErr),
+				)
+			}
+		}
+	}
 
 	if memory != nil {
 		task.Memory = database.NewDbJsonFromValue(memory)
@@ -258,6 +382,9 @@ func (s *ScheduledTaskManager) UpdateScheduledTaskStatus(task *repo.ScheduledTas
 		}
 	}
 
+	// The task is finished, so release the claim
+	task.LeaseExpiresAt = nil
+
 	// Determine task status
 	switch {
 	case processErr != nil:
@@ -289,7 +416,7 @@ func (s *ScheduledTaskManager) UpdateScheduledTaskStatus(task *repo.ScheduledTas
 		task.Errors = *database.NewDbJsonFromValue([]string{})
 	}
 
-	if err := s.Deps.Store.DB.Save(task).Error; err != nil {
+	if err := s.Deps.Repo.FinishScheduledTask(task); err != nil {
 		logger.Error(ctx, "Failed to save scheduled task status",
 			logger.Int("task_id", int(task.ID)),
 			logger.ErrorField(err),
@@ -327,6 +454,13 @@ func (s *ScheduledTaskManager) UpdateScheduledTaskStatus(task *repo.ScheduledTas
 			errorMsg = errors[len(errors)-1]
 		}
 		body = fmt.Sprintf("Scheduled task for %s failed: %s", task.LoginId, errorMsg)
+		if processErr != nil {
+			category := errs.CategoryOf(processErr)
+			body = fmt.Sprintf("Scheduled task for %s failed because %s", task.LoginId, category.Description())
+			if category.NeedsUserAction() {
+				body += ". Please reconnect your account and try again"
+			}
+		}
 	default:
 		// No notification for other statuses
 		logger.Info(ctx, "Scheduled task status updated",
@@ -351,6 +485,9 @@ func (s *ScheduledTaskManager) UpdateScheduledTaskStatus(task *repo.ScheduledTas
 	if task.Errors.Json() != nil {
 		data["errors"] = *task.Errors.Json()
 	}
+	if processErr != nil {
+		data["error_category"] = errs.CategoryOf(processErr)
+	}
 	satellite.SendNotificationAsync(ctx, task.UserID, title, body, &priority, data, nil)
 
 	logger.Info(ctx, "Scheduled task status updated",
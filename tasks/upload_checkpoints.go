@@ -0,0 +1,48 @@
+package crons
+
+import (
+	"encoding/json"
+
+	"github.com/StorX2-0/Backup-Tools/satellite"
+)
+
+// uploadsMemoryKey holds the checkpoints of interrupted multipart uploads in a task's
+// memory, one JSON encoded checkpoint per entry
+const uploadsMemoryKey = "uploads"
+
+// uploadCheckpoint returns the saved progress of the upload of key, or nil
+func uploadCheckpoint(memory map[string][]string, bucket, key string) *satellite.UploadCheckpoint {
+	for _, entry := range memory[uploadsMemoryKey] {
+		var checkpoint satellite.UploadCheckpoint
+		if err := json.Unmarshal([]byte(entry), &checkpoint); err != nil {
+			continue
+		}
+		if checkpoint.Bucket == bucket && checkpoint.Key == key {
+			return &checkpoint
+		}
+	}
+	return nil
+}
+
+// setUploadCheckpoint replaces the saved progress of the upload of key; nil removes it
+func setUploadCheckpoint(memory map[string][]string, bucket, key string, checkpoint *satellite.UploadCheckpoint) {
+	var entries []string
+	for _, entry := range memory[uploadsMemoryKey] {
+		var saved satellite.UploadCheckpoint
+		if err := json.Unmarshal([]byte(entry), &saved); err == nil && saved.Bucket == bucket && saved.Key == key {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if checkpoint != nil {
+		if b, err := json.Marshal(checkpoint); err == nil {
+			entries = append(entries, string(b))
+		}
+	}
+
+	if len(entries) == 0 {
+		delete(memory, uploadsMemoryKey)
+		return
+	}
+	memory[uploadsMemoryKey] = entries
+}